@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// AccountsHandler stores account attributes (plan, MRR, tenure, renewal date)
+// at POST /signals/accounts. Omitted fields keep their stored value. It requires
+// the signals token.
+func AccountsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodPost {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only POST method is allowed.")
+		return
+	}
+	if !authorizeSignals(w, r) {
+		return
+	}
+
+	req, apiErr := appcore.DecodeAccountRequest(r.Body)
+	defer r.Body.Close()
+	if apiErr != nil {
+		log.Printf("Rejected /signals/accounts request: %v %+v", apiErr, apiErr.Errors)
+		appcore.RespondWithProblem(w, r, apiErr)
+		return
+	}
+	attributes, err := appcore.StoreAccountAttributes(req)
+	if err != nil {
+		log.Printf("Error storing account attributes: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to store the account attributes.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, attributes)
+}
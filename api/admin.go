@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// authorizeAdmin checks the request's bearer token against ADMIN_API_TOKEN and
+// writes an error response if it does not match. Admin endpoints are disabled
+// entirely when ADMIN_API_TOKEN is not set.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	adminToken := os.Getenv("ADMIN_API_TOKEN")
+	if adminToken == "" {
+		log.Println("Admin endpoint called but ADMIN_API_TOKEN is not set.")
+		appcore.RespondWithError(w, r, appcore.ErrForbidden, "Admin endpoints are disabled.")
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		appcore.RespondWithError(w, r, appcore.ErrUnauthorized, "Invalid or missing admin token.")
+		return false
+	}
+	return true
+}
+
+// authorizeSignals checks the request's bearer token against SIGNALS_API_TOKEN,
+// which help desk, billing and analytics integrations use, or ADMIN_API_TOKEN.
+// The /signals endpoints are disabled when neither is set.
+func authorizeSignals(w http.ResponseWriter, r *http.Request) bool {
+	signalsToken, adminToken := os.Getenv("SIGNALS_API_TOKEN"), os.Getenv("ADMIN_API_TOKEN")
+	if signalsToken == "" && adminToken == "" {
+		log.Println("Signals endpoint called but neither SIGNALS_API_TOKEN nor ADMIN_API_TOKEN is set.")
+		appcore.RespondWithError(w, r, appcore.ErrForbidden, "Signals endpoints are disabled.")
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	for _, expected := range []string{signalsToken, adminToken} {
+		if expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			return true
+		}
+	}
+	appcore.RespondWithError(w, r, appcore.ErrUnauthorized, "Invalid or missing signals token.")
+	return false
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// customerEraseRequest is the request body for /admin/customers/erase.
+type customerEraseRequest struct {
+	AccountID   string              `json:"account_id"`
+	Mode        appcore.ErasureMode `json:"mode"`
+	RequestedBy string              `json:"requested_by"`
+	Reason      string              `json:"reason"`
+}
+
+var customerEraseRequestSpec = appcore.ObjectSpec{
+	Name: "CustomerEraseRequest",
+	Fields: []appcore.FieldSpec{
+		{Name: "account_id", Type: "string", Required: true, MaxLength: 256},
+		{Name: "mode", Type: "string", Enum: []string{string(appcore.ErasureModeDelete), string(appcore.ErasureModeAnonymize)}},
+		{Name: "requested_by", Type: "string", MaxLength: 256},
+		{Name: "reason", Type: "string", MaxLength: 1000},
+	},
+}
+
+// CustomerEraseHandler erases everything stored for an account and returns the
+// audit record written for the erasure.
+func CustomerEraseHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodPost {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only POST method is allowed.")
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	var req customerEraseRequest
+	if apiErr := appcore.DecodeJSONBody(r.Body, customerEraseRequestSpec, &req); apiErr != nil {
+		appcore.RespondWithProblem(w, r, apiErr)
+		return
+	}
+	defer r.Body.Close()
+
+	req.AccountID = strings.TrimSpace(req.AccountID)
+	if req.AccountID == "" {
+		appcore.RespondWithProblem(w, r, appcore.NewValidationError("account_id", appcore.FieldErrRequired, "account_id is required"))
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = appcore.ErasureModeDelete
+	}
+
+	record, err := appcore.EraseCustomerData(req.AccountID, req.Mode, req.RequestedBy, req.Reason)
+	if err != nil {
+		log.Printf("Error erasing customer data: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to erase customer data.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, record)
+}
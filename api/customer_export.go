@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// CustomerExportHandler returns everything stored for an account (feedback,
+// enrichment and predictions) in response to a data subject access request.
+func CustomerExportHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodGet {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET method is allowed.")
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	accountID := strings.TrimSpace(r.URL.Query().Get("account_id"))
+	if accountID == "" {
+		appcore.RespondWithProblem(w, r, appcore.NewValidationError("account_id", appcore.FieldErrRequired, "account_id query parameter is required"))
+		return
+	}
+
+	export, err := appcore.ExportCustomerData(accountID)
+	if err != nil {
+		log.Printf("Error exporting customer data: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to export customer data.")
+		return
+	}
+	log.Printf("Exported %d feedback rows for a data subject request.", len(export.Feedback))
+	appcore.RespondWithJSON(w, http.StatusOK, export)
+}
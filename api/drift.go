@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// DriftHandler reports input and score drift of recent feedback against a reference window:
+//
+//	GET /admin/drift?current_days=7&reference_days=28
+//
+// It only reports; alerts are sent by the scheduled cmd/drift job.
+func DriftHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodGet {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET method is allowed.")
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	days := map[string]int{"current_days": 7, "reference_days": 28}
+	for _, name := range []string{"current_days", "reference_days"} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 365 {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError(name, appcore.FieldErrOutOfRange, name+" must be an integer between 1 and 365"))
+			return
+		}
+		days[name] = n
+	}
+
+	report, err := appcore.CheckDrift(time.Now(), days["current_days"], days["reference_days"], appcore.DriftSettings)
+	if err != nil {
+		log.Printf("Error checking drift: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to read feedback for the drift check.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// IngestHandler accepts the native webhook payload of a configured survey tool
+// (see INGEST_SOURCES_PATH), maps it to a /predict request and runs the
+// prediction pipeline:
+//
+//	POST /ingest/{source}
+//
+// It answers like /predict: 200 with the prediction, or 202 with a job for
+// sources configured as async. A response delivered again gets the stored
+// prediction or the same job. HEAD answers 200 for any source name so tools can
+// check the URL without revealing which sources exist, and an unknown source
+// is rejected like a bad signature.
+func IngestHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+
+	// Vercel rewrites /ingest/{source} to ?source=; the standalone server routes /ingest/ here.
+	name := r.URL.Query().Get("source")
+	if name == "" {
+		name = strings.TrimPrefix(r.URL.Path, "/ingest/")
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only POST method is allowed.")
+		return
+	}
+	source, ok := appcore.IngestSources[name]
+	if !ok {
+		log.Printf("Rejected /ingest/%q request: unknown ingest source", name)
+		appcore.RespondWithError(w, r, appcore.ErrUnauthorized, "Invalid or missing signature.")
+		return
+	}
+
+	body, apiErr := appcore.ReadRequestBody(r.Body)
+	defer r.Body.Close()
+	if apiErr != nil {
+		appcore.RespondWithProblem(w, r, apiErr)
+		return
+	}
+	if err := source.Authenticate(r, body); err != nil {
+		log.Printf("Rejected /ingest/%s request: %v", name, err)
+		appcore.RespondWithError(w, r, appcore.ErrUnauthorized, "Invalid or missing signature.")
+		return
+	}
+	fields, apiErr := source.Fields(body)
+	if apiErr != nil {
+		appcore.RespondWithProblem(w, r, apiErr)
+		return
+	}
+	req, apiErr := source.PredictRequest(fields)
+	if apiErr != nil {
+		log.Printf("Rejected /ingest/%s payload: %v %+v", name, apiErr, apiErr.Errors)
+		appcore.RespondWithProblem(w, r, apiErr)
+		return
+	}
+
+	if source.Async {
+		job, err := appcore.CreatePredictionJob(req)
+		if err != nil {
+			log.Printf("Error storing prediction job: %v", err)
+			appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to store the prediction job.")
+			return
+		}
+		log.Printf("Queued prediction job %s from ingest source %s.", job.ID, name)
+		appcore.WakeJobRunner()
+		w.Header().Set("Location", appcore.JobStatusURL(job.ID))
+		appcore.RespondWithJSON(w, http.StatusAccepted, appcore.JobAccepted{JobID: job.ID, Status: job.Status, StatusURL: appcore.JobStatusURL(job.ID)})
+		return
+	}
+
+	response, err := appcore.ProcessFeedback(req)
+	if errors.Is(err, appcore.ErrFeedbackExists) {
+		log.Printf("Response from ingest source %s was already stored: feedback %s.", name, req.FeedbackID)
+		response, err = appcore.StoredPredictionResponse(req)
+	}
+	if err != nil {
+		log.Printf("Error processing feedback from ingest source %s: %v", name, err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to store customer data and churn prediction.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, response)
+}
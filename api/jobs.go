@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// JobHandler returns the status of an async prediction job, and its result once
+// it has succeeded:
+//
+//	GET /jobs/{id}
+//
+// Job IDs are random UUIDs returned only to the client that created the job.
+func JobHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodGet {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET method is allowed.")
+		return
+	}
+
+	// Vercel rewrites /jobs/{id} to ?id=; the standalone server routes /jobs/ here.
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		id = strings.TrimPrefix(r.URL.Path, "/jobs/")
+	}
+	if !validUUID(id) {
+		appcore.RespondWithError(w, r, appcore.ErrNotFound, "Job not found.")
+		return
+	}
+
+	job, found, err := appcore.FetchPredictionJob(id)
+	if err != nil {
+		log.Printf("Error fetching prediction job %s: %v", id, err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to read the prediction job.")
+		return
+	}
+	if !found {
+		appcore.RespondWithError(w, r, appcore.ErrNotFound, "Job not found.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, job.JobStatus())
+}
+
+// validUUID reports whether id looks like a UUID, so malformed IDs are a 404
+// rather than a database error.
+func validUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, c := range id {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case !strings.ContainsRune("0123456789abcdefABCDEF", c):
+			return false
+		}
+	}
+	return true
+}
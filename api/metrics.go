@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// MetricsHandler exposes process counters (e.g. enrichment cache hits and misses)
+// in Prometheus text format. It is only registered by the standalone server, since
+// per-invocation counters are meaningless for serverless functions.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET method is allowed.")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := appcore.WriteMetrics(w); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// ModelVersionsHandler compares stored churn predictions per model version:
+//
+//	GET /admin/predictions/versions?model_name=churn-rules&versions=5,6&since=2026-01-01T00:00:00Z
+//
+// versions is a comma-separated list; since and until are RFC 3339 timestamps.
+// All parameters are optional.
+func ModelVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodGet {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET method is allowed.")
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := appcore.ModelVersionFilter{ModelName: strings.TrimSpace(query.Get("model_name"))}
+	for _, version := range strings.Split(query.Get("versions"), ",") {
+		if version = strings.TrimSpace(version); version != "" {
+			filter.Versions = append(filter.Versions, version)
+		}
+	}
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		raw := strings.TrimSpace(query.Get(param.name))
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError(param.name, appcore.FieldErrInvalidValue, param.name+" must be an RFC 3339 timestamp"))
+			return
+		}
+		*param.dest = t
+	}
+
+	stats, err := appcore.CompareModelVersions(filter)
+	if err != nil {
+		log.Printf("Error comparing model versions: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to read churn predictions.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"versions": stats})
+}
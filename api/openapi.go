@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// OpenAPIHandler serves the OpenAPI 3 document describing the public API.
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET method is allowed.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, appcore.OpenAPIDocument())
+}
@@ -1,10 +1,9 @@
 package handler
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
-	"strings"
+	"strconv"
 	"sync" // For once.Do
 
 	"go-churn-agent/pkg/appcore" // Import the shared package
@@ -33,95 +32,74 @@ func PredictHandler(w http.ResponseWriter, r *http.Request) {
 	if err := initialize(); err != nil {
 		// If init failed (e.g. missing env vars for Supabase), subsequent calls will also fail here.
 		log.Printf("Initialization check failed: %v", err) // Log the specific init error
-		appcore.RespondWithError(w, http.StatusInternalServerError, "Server initialization failed: "+err.Error())
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
 		return
 	}
-    // Check if SupabaseClient is usable after initialization attempt
+	// Check if SupabaseClient is usable after initialization attempt
 	if appcore.SupabaseClient == nil && initErr != nil {
 		// This condition might be redundant if InitClients already fatally logs or returns clear error
 		// but serves as an additional safeguard.
 		log.Println("SupabaseClient is nil after initialization attempt, likely due to missing env vars for Supabase.")
-		appcore.RespondWithError(w, http.StatusInternalServerError, "Supabase client not available due to initialization error.")
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Supabase client not available due to initialization error.")
 		return
 	}
 
-
 	log.Printf("Received request for /predict from %s", r.RemoteAddr)
 	if r.Method != http.MethodPost {
-		appcore.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST method is allowed.")
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only POST method is allowed.")
 		return
 	}
 
-	var req appcore.ApiPredictRequest // Use struct from appcore
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding request body: %v", err)
-		appcore.RespondWithError(w, http.StatusBadRequest, "Invalid JSON request body.")
-		return
-	}
+	// Validate against the same spec that is published at /openapi.json.
+	req, apiErr := appcore.DecodePredictRequest(r.Body)
 	defer r.Body.Close()
-
-	if req.NLSScore == nil {
-		appcore.RespondWithError(w, http.StatusBadRequest, "NLS score is required.")
-		return
-	}
-	if *req.NLSScore < 0 || *req.NLSScore > 10 {
-		appcore.RespondWithError(w, http.StatusBadRequest, "NLS score must be between 0 and 10.")
+	if apiErr != nil {
+		log.Printf("Rejected /predict request: %v %+v", apiErr, apiErr.Errors)
+		appcore.RespondWithProblem(w, r, apiErr)
 		return
 	}
 	// Feedback text can be empty for LLM processing.
 
-	log.Println("Fetching sentiment from Hugging Face...")
-	sentiment, errSentiment := appcore.GetSentimentFromHF(req.FeedbackText)
-	if errSentiment != nil {
-		log.Printf("Warning: Could not get sentiment from Hugging Face: %v", errSentiment)
-		sentiment = "UNKNOWN"
-	}
-	log.Printf("Sentiment received: %s", sentiment)
-
-	candidateTopics := []string{"service", "product quality", "pricing", "customer support", "speed", "ease of use"}
-	log.Println("Fetching topics from Hugging Face...")
-	topics, errTopics := appcore.GetTopicsFromHF(req.FeedbackText, candidateTopics)
-	if errTopics != nil {
-		log.Printf("Warning: Could not get topics from Hugging Face: %v", errTopics)
+	async := false
+	if raw := r.URL.Query().Get("async"); raw != "" {
+		var err error
+		if async, err = strconv.ParseBool(raw); err != nil {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("async", appcore.FieldErrInvalidType, "async must be true or false"))
+			return
+		}
 	}
-	log.Printf("Topics received: %v", topics)
-
-	customerData := appcore.CustomerData{
-		NLSScore:         *req.NLSScore,
-		Feedback:         req.FeedbackText,
-		CommentSentiment: sentiment,
-		CommentTopics:    topics,
+	if req.CallbackURL != "" {
+		if !async {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("callback_url", appcore.FieldErrInvalidValue, "callback_url requires async=true"))
+			return
+		}
+		if err := appcore.ValidateCallbackURL(req.CallbackURL); err != nil {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("callback_url", appcore.FieldErrInvalidValue, err.Error()))
+			return
+		}
 	}
 
-	log.Println("Storing customer data (with insights) in Supabase...")
-	customerID, err := appcore.StoreCustomerData(customerData) // Pass appcore.SupabaseClient implicitly
-	if err != nil {
-		log.Printf("Error storing customer data: %v", err)
-		appcore.RespondWithError(w, http.StatusInternalServerError, "Failed to store customer data.")
+	// Async requests are stored as a job and processed by the job runner, so a
+	// slow Hugging Face cold start doesn't time out the client.
+	if async {
+		job, err := appcore.CreatePredictionJob(req)
+		if err != nil {
+			log.Printf("Error storing prediction job: %v", err)
+			appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to store the prediction job.")
+			return
+		}
+		log.Printf("Queued prediction job %s.", job.ID)
+		appcore.WakeJobRunner()
+		w.Header().Set("Location", appcore.JobStatusURL(job.ID))
+		appcore.RespondWithJSON(w, http.StatusAccepted, appcore.JobAccepted{JobID: job.ID, Status: job.Status, StatusURL: appcore.JobStatusURL(job.ID)})
 		return
 	}
-	log.Printf("Customer data stored successfully. ID: %s\n", customerID)
 
-	customerData.ID = customerID
-
-	churnPrediction := appcore.PredictChurn(customerData)
-	churnPrediction.CustomerID = customerID
-
-	log.Println("Storing churn prediction in Supabase...")
-	err = appcore.StoreChurnPrediction(churnPrediction) // Pass appcore.SupabaseClient implicitly
+	response, err := appcore.ProcessFeedback(req)
 	if err != nil {
-		log.Printf("Error storing churn prediction: %v", err)
-		appcore.RespondWithError(w, http.StatusInternalServerError, "Failed to store churn prediction.")
+		log.Printf("Error processing feedback: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to store customer data and churn prediction.")
 		return
 	}
-	log.Println("Churn prediction stored successfully.")
-
-	response := appcore.ApiResponse{ // Use struct from appcore
-		CustomerID:       customerID,
-		ChurnProbability: churnPrediction.ChurnProbability,
-		Reason:           churnPrediction.Reason,
-		CommentSentiment: customerData.CommentSentiment,
-		CommentTopics:    customerData.CommentTopics,
-	}
 	appcore.RespondWithJSON(w, http.StatusOK, response)
 }
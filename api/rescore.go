@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+type rescoreRequest struct {
+	Since            string   `json:"since"`
+	Until            string   `json:"until"`
+	ModelName        string   `json:"model_name"`
+	ModelVersion     string   `json:"model_version"`
+	UnknownSentiment bool     `json:"unknown_sentiment"`
+	Mode             string   `json:"mode"`
+	Rate             *float64 `json:"rate"`
+	Limit            int      `json:"limit"`
+	Resume           string   `json:"resume"`
+}
+
+var rescoreRequestSpec = appcore.ObjectSpec{
+	Name: "RescoreRequest",
+	Fields: []appcore.FieldSpec{
+		{Name: "since", Type: "string", MaxLength: 64},
+		{Name: "until", Type: "string", MaxLength: 64},
+		{Name: "model_name", Type: "string", MaxLength: 128},
+		{Name: "model_version", Type: "string", MaxLength: 128},
+		{Name: "unknown_sentiment", Type: "boolean"},
+		{Name: "mode", Type: "string", Enum: []string{appcore.RescoreEnrich, appcore.RescoreScore, appcore.RescoreBoth}},
+		{Name: "rate", Type: "number", Minimum: floatPtr(0), Maximum: floatPtr(20)},
+		{Name: "limit", Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(1000)},
+		{Name: "resume", Type: "string", MaxLength: 36},
+	},
+}
+
+// Defaults for POST /admin/rescore. A request handles at most limit rows so it
+// fits in a serverless function's time budget; larger backfills use cmd/rescore
+// or call again with "resume".
+const (
+	rescoreDefaultLimit = 50
+	rescoreDefaultRate  = 2
+)
+
+// RescoreHandler re-enriches and/or re-scores stored feedback in checkpointed runs:
+//
+//	POST /admin/rescore              {"unknown_sentiment": true, "mode": "both", "limit": 50}
+//	POST /admin/rescore              {"resume": "<run id>", "limit": 50}
+//	GET  /admin/rescore?id=<run id>  returns a run and its checkpoint
+//
+// POST processes up to limit rows synchronously and returns the run; a paused
+// run is continued by posting its ID as resume.
+func RescoreHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("id", appcore.FieldErrRequired, "id query parameter is required"))
+			return
+		}
+		run, found, err := fetchRescoreRun(w, r, id)
+		if err != nil || !found {
+			return
+		}
+		appcore.RespondWithJSON(w, http.StatusOK, run)
+
+	case http.MethodPost:
+		var req rescoreRequest
+		if apiErr := appcore.DecodeJSONBody(r.Body, rescoreRequestSpec, &req); apiErr != nil {
+			appcore.RespondWithProblem(w, r, apiErr)
+			return
+		}
+		defer r.Body.Close()
+		limit := req.Limit
+		if limit == 0 {
+			limit = rescoreDefaultLimit
+		}
+
+		var run appcore.RescoreRun
+		if req.Resume != "" {
+			existing, found, err := fetchRescoreRun(w, r, req.Resume)
+			if err != nil || !found {
+				return
+			}
+			run = existing
+		} else {
+			opts := appcore.RescoreOptions{ModelName: req.ModelName, ModelVersion: req.ModelVersion, UnknownSentiment: req.UnknownSentiment, Mode: req.Mode, Rate: rescoreDefaultRate}
+			if opts.Mode == "" {
+				opts.Mode = appcore.RescoreBoth
+			}
+			if req.Rate != nil {
+				opts.Rate = *req.Rate
+			}
+			for _, bound := range []struct {
+				field, value string
+				dst          **time.Time
+			}{{"since", req.Since, &opts.Since}, {"until", req.Until, &opts.Until}} {
+				if bound.value == "" {
+					continue
+				}
+				t, err := appcore.ParseRescoreTime(bound.value)
+				if err != nil {
+					appcore.RespondWithProblem(w, r, appcore.NewValidationError(bound.field, appcore.FieldErrInvalidValue, bound.field+" must be a date (YYYY-MM-DD) or an RFC 3339 timestamp"))
+					return
+				}
+				*bound.dst = &t
+			}
+			if err := opts.Validate(); err != nil {
+				appcore.RespondWithError(w, r, appcore.ErrValidationFailed, err.Error())
+				return
+			}
+			started, err := appcore.StartRescoreRun(opts)
+			if err != nil {
+				log.Printf("Error starting rescore run: %v", err)
+				appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to start the rescore run.")
+				return
+			}
+			run = started
+		}
+
+		// Failures are recorded on the run, which is returned either way.
+		if err := appcore.ContinueRescoreRun(&run, limit, r.Context().Done()); err != nil {
+			log.Printf("Rescore run %s failed: %v", run.ID, err)
+		}
+		appcore.RespondWithJSON(w, http.StatusOK, run)
+
+	default:
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET and POST methods are allowed.")
+	}
+}
+
+// fetchRescoreRun loads a run and writes the error response if that fails or
+// the run does not exist.
+func fetchRescoreRun(w http.ResponseWriter, r *http.Request, id string) (run appcore.RescoreRun, found bool, err error) {
+	if !validUUID(id) {
+		appcore.RespondWithError(w, r, appcore.ErrNotFound, "Rescore run not found.")
+		return run, false, nil
+	}
+	run, found, err = appcore.FetchRescoreRun(id)
+	if err != nil {
+		log.Printf("Error fetching rescore run %s: %v", id, err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to read the rescore run.")
+		return run, false, err
+	}
+	if !found {
+		appcore.RespondWithError(w, r, appcore.ErrNotFound, "Rescore run not found.")
+	}
+	return run, found, nil
+}
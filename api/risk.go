@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// RiskRankedHandler lists the accounts to act on first, ranked by revenue at
+// risk (churn probability × MRR) boosted near the renewal date:
+//
+//	GET /risk/ranked?limit=50&days=90&min_probability=0.5
+//
+// Only accounts with feedback or tickets in the last days days are ranked.
+// It exposes revenue, so it requires the admin token.
+func RiskRankedHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodGet {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET method is allowed.")
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	limit, days := 50, 90
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 500 {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("limit", appcore.FieldErrOutOfRange, "limit must be an integer between 1 and 500"))
+			return
+		}
+		limit = n
+	}
+	if raw := query.Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 365 {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("days", appcore.FieldErrOutOfRange, "days must be an integer between 1 and 365"))
+			return
+		}
+		days = n
+	}
+	minProbability := 0.0
+	if raw := query.Get("min_probability"); raw != "" {
+		p, err := strconv.ParseFloat(raw, 64)
+		if err != nil || p < 0 || p > 1 {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("min_probability", appcore.FieldErrOutOfRange, "min_probability must be a number between 0 and 1"))
+			return
+		}
+		minProbability = p
+	}
+
+	now := time.Now().UTC()
+	accounts, err := appcore.RankAccountsAtRisk(appcore.RiskRankOptions{
+		Since:          now.AddDate(0, 0, -days),
+		Limit:          limit,
+		MinProbability: minProbability,
+	}, now)
+	if err != nil {
+		log.Printf("Error ranking accounts at risk: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to rank accounts at risk.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"generated_at": now, "accounts": accounts})
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// TicketsHandler accepts support ticket events at POST /signals/tickets. The
+// ticket text is enriched like survey feedback and the event is scored with the
+// account's recent ticket history; the response has the same shape as /predict.
+// It requires the signals token.
+func TicketsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+
+	log.Printf("Received request for /signals/tickets from %s", r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only POST method is allowed.")
+		return
+	}
+	if !authorizeSignals(w, r) {
+		return
+	}
+
+	req, apiErr := appcore.DecodeTicketRequest(r.Body)
+	defer r.Body.Close()
+	if apiErr != nil {
+		log.Printf("Rejected /signals/tickets request: %v %+v", apiErr, apiErr.Errors)
+		appcore.RespondWithProblem(w, r, apiErr)
+		return
+	}
+
+	response, err := appcore.ProcessTicket(req)
+	if errors.Is(err, appcore.ErrTicketEventExists) {
+		appcore.RespondWithError(w, r, appcore.ErrConflict, "This ticket event is already stored.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error processing ticket: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to store the ticket and churn prediction.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// UsageHandler stores an account's usage for one period (logins, active seats,
+// feature adoption) at POST /signals/usage, replacing any usage stored for it.
+// It requires the signals token.
+func UsageHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodPost {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only POST method is allowed.")
+		return
+	}
+	if !authorizeSignals(w, r) {
+		return
+	}
+
+	req, apiErr := appcore.DecodeUsageRequest(r.Body)
+	defer r.Body.Close()
+	if apiErr != nil {
+		log.Printf("Rejected /signals/usage request: %v %+v", apiErr, apiErr.Errors)
+		appcore.RespondWithProblem(w, r, apiErr)
+		return
+	}
+	usage, err := appcore.StoreAccountUsage(req)
+	if err != nil {
+		log.Printf("Error storing account usage: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to store the account usage.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// WebhookDeliveriesHandler returns the delivery log:
+//
+//	GET /admin/webhooks/deliveries?subscription_id=<id>&status=dead&limit=100
+func WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if r.Method != http.MethodGet {
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET method is allowed.")
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	status := query.Get("status")
+	if status != "" && status != appcore.WebhookPending && status != appcore.WebhookDelivered && status != appcore.WebhookDead {
+		appcore.RespondWithProblem(w, r, appcore.NewValidationError("status", appcore.FieldErrInvalidValue, "status must be pending, delivered or dead"))
+		return
+	}
+	limit := 100
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 1000 {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("limit", appcore.FieldErrOutOfRange, "limit must be an integer between 1 and 1000"))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := appcore.FetchWebhookDeliveries(strings.TrimSpace(query.Get("subscription_id")), status, limit)
+	if err != nil {
+		log.Printf("Error listing webhook deliveries: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to list webhook deliveries.")
+		return
+	}
+	appcore.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
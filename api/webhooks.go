@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-churn-agent/pkg/appcore"
+)
+
+// webhookSubscriptionRequest is the request body for POST /admin/webhooks.
+type webhookSubscriptionRequest struct {
+	URL                 string                `json:"url"`
+	Description         string                `json:"description"`
+	Secret              string                `json:"secret"`
+	MinChurnProbability *float64              `json:"min_churn_probability"`
+	Rules               []appcore.WebhookRule `json:"rules"`
+}
+
+var webhookSubscriptionRequestSpec = appcore.ObjectSpec{
+	Name: "WebhookSubscriptionRequest",
+	Fields: []appcore.FieldSpec{
+		{Name: "url", Type: "string", Required: true, MaxLength: 2048},
+		{Name: "description", Type: "string", MaxLength: 256},
+		{Name: "secret", Type: "string", MaxLength: 256},
+		{Name: "min_churn_probability", Type: "number", Minimum: floatPtr(0), Maximum: floatPtr(1)},
+		{Name: "rules", Type: "array", Items: &appcore.FieldSpec{Type: "object", Properties: []appcore.FieldSpec{
+			{Name: "sentiment", Type: "string", Enum: []string{"POSITIVE", "NEGATIVE", "NEUTRAL"}},
+			{Name: "topics", Type: "array", Items: &appcore.FieldSpec{Type: "string"}},
+		}}},
+	},
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+// WebhooksHandler manages webhook subscriptions:
+//
+//	GET    /admin/webhooks            lists subscriptions (without secrets)
+//	POST   /admin/webhooks            registers one and returns it with its secret
+//	DELETE /admin/webhooks?id=<id>    deactivates one
+func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if err := initialize(); err != nil {
+		log.Printf("Initialization check failed: %v", err)
+		appcore.RespondWithError(w, r, appcore.ErrInitializationFailed, "Server initialization failed: "+err.Error())
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		subs, err := appcore.FetchWebhookSubscriptions(false)
+		if err != nil {
+			log.Printf("Error listing webhook subscriptions: %v", err)
+			appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to list webhook subscriptions.")
+			return
+		}
+		for i := range subs {
+			subs[i].Secret = ""
+		}
+		appcore.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"subscriptions": subs})
+
+	case http.MethodPost:
+		var req webhookSubscriptionRequest
+		if apiErr := appcore.DecodeJSONBody(r.Body, webhookSubscriptionRequestSpec, &req); apiErr != nil {
+			appcore.RespondWithProblem(w, r, apiErr)
+			return
+		}
+		defer r.Body.Close()
+		if u, err := url.Parse(strings.TrimSpace(req.URL)); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("url", appcore.FieldErrInvalidValue, "url must be an absolute http(s) URL"))
+			return
+		}
+		if req.MinChurnProbability == nil && len(req.Rules) == 0 {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("min_churn_probability", appcore.FieldErrRequired, "min_churn_probability or rules is required"))
+			return
+		}
+		sub, err := appcore.CreateWebhookSubscription(appcore.WebhookSubscription{
+			URL:                 strings.TrimSpace(req.URL),
+			Description:         req.Description,
+			Secret:              req.Secret,
+			MinChurnProbability: req.MinChurnProbability,
+			Rules:               req.Rules,
+		})
+		if err != nil {
+			log.Printf("Error creating webhook subscription: %v", err)
+			appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to create webhook subscription.")
+			return
+		}
+		appcore.RespondWithJSON(w, http.StatusCreated, sub)
+
+	case http.MethodDelete:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			appcore.RespondWithProblem(w, r, appcore.NewValidationError("id", appcore.FieldErrRequired, "id query parameter is required"))
+			return
+		}
+		if err := appcore.DeactivateWebhookSubscription(id); err != nil {
+			log.Printf("Error deactivating webhook subscription: %v", err)
+			appcore.RespondWithError(w, r, appcore.ErrStorageFailed, "Failed to deactivate webhook subscription.")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		appcore.RespondWithError(w, r, appcore.ErrMethodNotAllowed, "Only GET, POST and DELETE methods are allowed.")
+	}
+}
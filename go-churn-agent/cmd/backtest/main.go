@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"go-churn-agent/pkg/appcore" // Import the shared appcore package
+)
+
+// backtest labels one model version's historical churn predictions for one
+// signal source with the outcomes recorded in churn_outcomes and reports Brier
+// scores and calibration curves. With -fit it also fits a calibration on the
+// older predictions, evaluates it on the newest -holdout share and writes the
+// artifact to load via CALIBRATION_ARTIFACT_PATH:
+//
+//	go run ./cmd/backtest                                    # evaluate the primary model's configured calibration
+//	go run ./cmd/backtest -fit isotonic -out calibration.json
+//	go run ./cmd/backtest -model churn-linear                # evaluate a shadow model on live traffic
+//	go run ./cmd/backtest -source ticket -fit platt -out calibration-tickets.json
+func main() {
+	horizonDays := flag.Int("horizon-days", 90, "A prediction counts as churned if the account churned within this many days after it.")
+	bins := flag.Int("bins", 10, "Number of equal-width bins in the calibration curves.")
+	fit := flag.String("fit", "", "Fit a calibration: 'platt' or 'isotonic'. Empty only evaluates.")
+	holdout := flag.Float64("holdout", 0.2, "Share of the newest predictions held out to evaluate a fitted calibration.")
+	version := flag.String("version", "", "Version of the fitted calibration (default: the fit date).")
+	model := flag.String("model", "", "Backtest this model's predictions, including shadow scores (default: the configured primary model).")
+	modelVersion := flag.String("model-version", "", "Version of -model to backtest (default: its configured version).")
+	source := flag.String("source", appcore.SignalSourceSurvey, "Signal source to backtest: 'survey' or 'ticket'.")
+	out := flag.String("out", "calibration.json", "Where to write the fitted calibration artifact.")
+	flag.Parse()
+
+	if err := appcore.InitClients(); err != nil {
+		log.Fatalf("Initialization failed: %v", err)
+	}
+
+	if *source != appcore.SignalSourceSurvey && *source != appcore.SignalSourceTicket {
+		log.Fatalf("-source must be %q or %q, got %q", appcore.SignalSourceSurvey, appcore.SignalSourceTicket, *source)
+	}
+	if *model == "" {
+		*model = appcore.ChurnModels.Primary
+	}
+	if *modelVersion == "" {
+		configured, ok := appcore.ChurnModels.Models[*model]
+		if !ok {
+			log.Fatalf("Model %q is not configured; pass -model-version.", *model)
+		}
+		*modelVersion = configured.Version()
+	}
+	target := appcore.CalibrationTarget{ModelName: *model, ModelVersion: *modelVersion, Source: *source}
+
+	horizon := time.Duration(*horizonDays) * 24 * time.Hour
+	samples, err := appcore.FetchCalibrationSamples(horizon, time.Now(), target)
+	if err != nil {
+		log.Fatalf("Fetching calibration samples failed: %v", err)
+	}
+	if len(samples) == 0 {
+		log.Fatalf("No %s predictions of %s %s older than %d days with an account; nothing to backtest.", *source, *model, *modelVersion, *horizonDays)
+	}
+
+	if *version == "" {
+		*version = time.Now().UTC().Format("2006-01-02")
+	}
+	report, err := appcore.Backtest(samples, *fit, *version, *holdout, *bins, appcore.CalibrationFor(*model, *modelVersion, *source))
+	if err != nil {
+		log.Fatalf("Backtest failed: %v", err)
+	}
+	if *fit != "" {
+		report.Calibration.CalibrationTarget = target
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Error encoding backtest report: %v", err)
+	}
+
+	if *fit != "" {
+		artifact, err := json.MarshalIndent(report.Calibration, "", "  ")
+		if err != nil {
+			log.Fatalf("Error encoding calibration artifact: %v", err)
+		}
+		if err := os.WriteFile(*out, append(artifact, '\n'), 0o644); err != nil {
+			log.Fatalf("Error writing calibration artifact: %v", err)
+		}
+		log.Printf("Wrote %s calibration %s for %s %s (%s) to %s (Brier %.4f -> %.4f on %d held-out predictions).",
+			*fit, *version, *model, *modelVersion, *source, *out, report.RawBrier, report.CalibratedBrier, report.HoldoutSamples)
+	}
+	log.Printf("Backtested %d predictions; base rate %.3f, raw ECE %.4f.", report.Samples, report.BaseRate, report.RawECE)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"go-churn-agent/pkg/appcore" // Import the shared appcore package
+)
+
+// drift compares the NLS scores, sentiment mix, topic frequencies, feedback
+// lengths and churn probabilities of recent feedback with a reference window,
+// prints the report and alerts DRIFT_ALERT_WEBHOOK_URL when a threshold is
+// exceeded. Run it on a schedule (e.g. daily):
+//
+//	go run ./cmd/drift                                  # last 7 days against the 28 days before
+//	go run ./cmd/drift -current-days 1 -reference-days 14 -no-alert
+func main() {
+	currentDays := flag.Int("current-days", 7, "Length of the current window, ending now, in days.")
+	referenceDays := flag.Int("reference-days", 28, "Length of the reference window, ending where the current window starts, in days.")
+	noAlert := flag.Bool("no-alert", false, "Only print the report; send no alert.")
+	flag.Parse()
+
+	if err := appcore.InitClients(); err != nil {
+		log.Fatalf("Initialization failed: %v", err)
+	}
+
+	report, err := appcore.CheckDrift(time.Now(), *currentDays, *referenceDays, appcore.DriftSettings)
+	if err != nil {
+		log.Fatalf("Drift check failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Printf("Error encoding drift report: %v", err)
+	}
+
+	if !report.Drifted {
+		log.Println("No drift above the thresholds.")
+		return
+	}
+	log.Printf("Drift detected in %v.", report.DriftedFeatures())
+	if *noAlert {
+		return
+	}
+	if appcore.DriftSettings.AlertWebhookURL == "" {
+		log.Println("DRIFT_ALERT_WEBHOOK_URL is not set; no alert sent.")
+		return
+	}
+	if err := appcore.SendDriftAlert(report, appcore.DriftSettings); err != nil {
+		log.Fatalf("Sending drift alert failed: %v", err)
+	}
+}
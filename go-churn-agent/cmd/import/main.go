@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"go-churn-agent/pkg/appcore" // Import the shared appcore package
+)
+
+// import bulk-loads signals from a JSON Lines or CSV file. Each record is
+// validated and processed exactly like a request to the corresponding endpoint
+// (tickets: POST /signals/tickets, accounts: POST /signals/accounts, usage:
+// POST /signals/usage). Ticket events that are already stored are skipped and
+// the others are upserted, so an interrupted import can be run again:
+//
+//	go run ./cmd/import -type tickets -file tickets.jsonl
+//	go run ./cmd/import -type tickets -file tickets.csv -rate 1
+//	go run ./cmd/import -type accounts -file accounts.csv -rate 0
+//	go run ./cmd/import -type usage -file usage.csv -rate 0
+func main() {
+	kind := flag.String("type", "", "Record type: "+strings.Join(appcore.ImportTypes(), ", ")+".")
+	file := flag.String("file", "", "File to import; .csv files are read as CSV with a header row, anything else as JSON Lines.")
+	format := flag.String("format", "", "Override the file format: 'jsonl' or 'csv'.")
+	rate := flag.Float64("rate", 2, "Maximum records per second (each ticket makes several Hugging Face calls; accounts and usage make none, so -rate 0 suits them); 0 is unlimited.")
+	flag.Parse()
+
+	if *kind == "" || *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = appcore.ImportFormatForPath(*file)
+	}
+
+	if err := appcore.InitClients(); err != nil {
+		log.Fatalf("Initialization failed: %v", err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Opening %s failed: %v", *file, err)
+	}
+	defer f.Close()
+
+	// Stop between records on SIGINT/SIGTERM and report what was imported so far.
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		log.Printf("Received %v, stopping the import...", <-signals)
+		close(stop)
+	}()
+	log.Printf("Importing %s from %s (%s, %.1f records/s)...", *kind, *file, *format, *rate)
+	report, err := appcore.ImportRecords(*kind, *format, f, *rate, stop)
+
+	// Always print the report, including partial progress if reading failed.
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encErr := encoder.Encode(report); encErr != nil {
+		log.Printf("Error encoding import report: %v", encErr)
+	}
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	log.Printf("Import of %s finished: read %d, imported %d, skipped %d, failed %d.", *kind, report.Read, report.Imported, report.Skipped, report.Failed)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
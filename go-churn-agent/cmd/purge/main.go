@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"go-churn-agent/pkg/appcore" // Import the shared appcore package
+)
+
+// purge enforces the data retention policy (see appcore.RetentionPolicyFromEnv).
+// It is meant to run on a schedule, e.g. a daily cron job or Vercel cron:
+//
+//	go run ./cmd/purge -dry-run   # report what would be purged
+//	go run ./cmd/purge            # apply the policy
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Only report how many rows would be purged; change nothing.")
+	flag.Parse()
+
+	policy, err := appcore.RetentionPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid retention policy: %v", err)
+	}
+	if err := appcore.InitClients(); err != nil {
+		log.Fatalf("Initialization failed: %v", err)
+	}
+
+	log.Printf("Applying retention policy: feedback text %d months, predictions %d months (0 = keep forever), action %s, dry run %t",
+		policy.FeedbackTextMonths, policy.PredictionMonths, policy.Action, *dryRun)
+	report, err := appcore.ApplyRetentionPolicy(policy, time.Now(), *dryRun)
+
+	// Always print the report, including partial progress if a batch failed.
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encErr := encoder.Encode(report); encErr != nil {
+		log.Printf("Error encoding retention report: %v", encErr)
+	}
+	if err != nil {
+		log.Fatalf("Retention purge failed: %v", err)
+	}
+	if *dryRun {
+		log.Printf("Dry run: %d feedback rows, %d predictions and %d other rows would be purged.", report.FeedbackRows, report.PredictionRows, otherRows(report))
+	} else {
+		log.Printf("Purged %d feedback rows, %d predictions and %d other rows.", report.FeedbackRows, report.PredictionRows, otherRows(report))
+	}
+}
+
+// otherRows totals the rows purged from tables other than customer_feedback and churn_predictions.
+func otherRows(report appcore.RetentionReport) int64 {
+	var total int64
+	for _, n := range report.TableRows {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go-churn-agent/pkg/appcore" // Import the shared appcore package
+)
+
+// rescore re-runs Hugging Face enrichment and/or the current churn models over
+// stored feedback. New scores are inserted as new churn_predictions rows, so the
+// history is kept. Progress is checkpointed in rescore_runs; an interrupted or
+// failed run continues where it stopped with -resume:
+//
+//	go run ./cmd/rescore -unknown-sentiment                       # fix rows left UNKNOWN by an HF outage
+//	go run ./cmd/rescore -mode score -model-version 5              # re-score with the current model
+//	go run ./cmd/rescore -since 2024-06-01 -until 2024-07-01 -rate 1
+//	go run ./cmd/rescore -resume <run id>
+func main() {
+	since := flag.String("since", "", "Only feedback created at or after this date (YYYY-MM-DD) or RFC 3339 time.")
+	until := flag.String("until", "", "Only feedback created before this date (YYYY-MM-DD) or RFC 3339 time.")
+	modelName := flag.String("model-name", "", "Only feedback whose primary prediction came from this model.")
+	modelVersion := flag.String("model-version", "", "Only feedback whose primary prediction came from this model version.")
+	unknownSentiment := flag.Bool("unknown-sentiment", false, "Only feedback with comment_sentiment UNKNOWN.")
+	mode := flag.String("mode", appcore.RescoreBoth, "What to redo: 'enrich', 'score' or 'both'.")
+	rate := flag.Float64("rate", 2, "Maximum feedback rows per second (each row makes several Hugging Face calls when enriching); 0 is unlimited.")
+	limit := flag.Int("limit", 0, "Stop (paused) after this many rows; 0 processes the whole selection.")
+	resume := flag.String("resume", "", "Continue the rescore run with this ID; the selection flags are ignored.")
+	flag.Parse()
+
+	if err := appcore.InitClients(); err != nil {
+		log.Fatalf("Initialization failed: %v", err)
+	}
+
+	var run appcore.RescoreRun
+	if *resume != "" {
+		existing, found, err := appcore.FetchRescoreRun(*resume)
+		if err != nil {
+			log.Fatalf("Loading rescore run failed: %v", err)
+		}
+		if !found {
+			log.Fatalf("Rescore run %s not found.", *resume)
+		}
+		run = existing
+		log.Printf("Resuming rescore run %s (%s) after feedback %s.", run.ID, run.Status, run.LastFeedbackID)
+	} else {
+		opts := appcore.RescoreOptions{ModelName: *modelName, ModelVersion: *modelVersion, UnknownSentiment: *unknownSentiment, Mode: *mode, Rate: *rate}
+		for _, bound := range []struct {
+			value string
+			dst   **time.Time
+		}{{*since, &opts.Since}, {*until, &opts.Until}} {
+			if bound.value == "" {
+				continue
+			}
+			t, err := appcore.ParseRescoreTime(bound.value)
+			if err != nil {
+				log.Fatalf("Invalid date: %v", err)
+			}
+			*bound.dst = &t
+		}
+		started, err := appcore.StartRescoreRun(opts)
+		if err != nil {
+			log.Fatalf("Starting rescore run failed: %v", err)
+		}
+		run = started
+		log.Printf("Started rescore run %s (mode %s, %.1f rows/s).", run.ID, opts.Mode, opts.Rate)
+	}
+
+	// Stop between rows on SIGINT/SIGTERM; the run is left paused and resumable.
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		log.Printf("Received %v, pausing the rescore run...", <-signals)
+		close(stop)
+	}()
+	err := appcore.ContinueRescoreRun(&run, *limit, stop)
+
+	// Always print the run, including partial progress if it failed.
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encErr := encoder.Encode(run); encErr != nil {
+		log.Printf("Error encoding rescore run: %v", encErr)
+	}
+	if err != nil {
+		log.Fatalf("Rescore run %s failed; continue it with -resume %s: %v", run.ID, run.ID, err)
+	}
+	log.Printf("Rescore run %s %s: scanned %d, enriched %d, scored %d, failed %d.", run.ID, run.Status, run.Scanned, run.Enriched, run.Scored, run.Failed)
+}
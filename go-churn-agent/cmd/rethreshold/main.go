@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"go-churn-agent/pkg/appcore" // Import the shared appcore package
+)
+
+// rethreshold recomputes comment_topics and topic_categories of stored feedback
+// from the persisted enrichment_scores, using the thresholds of the currently
+// configured taxonomy (TOPIC_TAXONOMY_PATH). It never calls Hugging Face:
+//
+//	go run ./cmd/rethreshold -dry-run   # report how many rows would change
+//	go run ./cmd/rethreshold            # rewrite the topics
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Only report how many rows would change; change nothing.")
+	flag.Parse()
+
+	if err := appcore.InitClients(); err != nil {
+		log.Fatalf("Initialization failed: %v", err)
+	}
+
+	log.Printf("Re-thresholding stored topics with taxonomy %s, dry run %t", appcore.TopicTaxonomy.Version, *dryRun)
+	report, err := appcore.RethresholdTopics(appcore.TopicTaxonomy, *dryRun)
+
+	// Always print the report, including partial progress if a batch failed.
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encErr := encoder.Encode(report); encErr != nil {
+		log.Printf("Error encoding re-threshold report: %v", encErr)
+	}
+	if err != nil {
+		log.Fatalf("Re-threshold failed: %v", err)
+	}
+	log.Printf("Scanned %d rows; %d changed, %d skipped (unknown product line), %d without topic scores.", report.Scanned, report.Changed, report.Skipped, report.NoTopicScores)
+}
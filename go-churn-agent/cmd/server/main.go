@@ -1,11 +1,15 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 
-	"go-churn-agent/api"         // Import the Vercel handler package
+	api "go-churn-agent/api"     // Import the Vercel handler package (package handler)
 	"go-churn-agent/pkg/appcore" // Import the shared appcore package
 )
 
@@ -27,8 +31,14 @@ func initialize() error {
 }
 
 func main() {
+	worker := flag.Bool("worker", false, "consume feedback from FEEDBACK_QUEUE_URL instead of serving HTTP")
+	flag.Parse()
 	log.Println("Initializing standalone server...")
 
+	// A long-running server can keep enrichment results in memory; serverless
+	// functions default to no cache (or ENRICHMENT_CACHE=supabase).
+	appcore.DefaultEnrichmentCacheBackend = "memory"
+
 	// Initialize appcore (Supabase client, etc.) safely.
 	// This also performs environment variable checks for Supabase and HF tokens.
 	if err := initialize(); err != nil {
@@ -53,7 +63,27 @@ func main() {
 	// 	log.Fatal("Error: HF_TOKEN environment variable must be set for the server to operate.")
 	// }
 	// This check is now inside appcore.InitClients effectively for HF (it's in callHuggingFaceAPI, but InitClients warns)
-    // and critically for Supabase.
+	// and critically for Supabase.
+
+	// Publish prediction.created events from the outbox. Serverless functions
+	// can't run a background loop, so only the standalone server relays.
+	if relay := appcore.OutboxRelaySettings; len(relay.Sinks) > 0 {
+		go relay.Run(make(chan struct{}))
+	} else {
+		log.Println("OUTBOX_SINKS not set; outbox events are stored but not published.")
+	}
+
+	// Send queued webhook deliveries. Serverless deployments rely on cmd/webhooks instead.
+	go appcore.WebhookSettings.RunWebhookDeliveries(make(chan struct{}))
+
+	// Run async /predict jobs. Serverless deployments only queue them, so at
+	// least one standalone server or worker must be running.
+	go appcore.JobRunnerSettings.Run(make(chan struct{}))
+
+	if *worker {
+		runWorker()
+		return
+	}
 
 	// Use the PredictHandler from the api package.
 	// Note: Vercel's `api.PredictHandler` expects to be the entry point and might do its own
@@ -68,11 +98,53 @@ func main() {
 	// So, our server main's `initialize()` call ensures critical env vars are checked at server startup.
 	// The handler will also ensure initialization on its first request if it hasn't happened.
 	http.HandleFunc("/predict", api.PredictHandler)
+	http.HandleFunc("/jobs/", api.JobHandler)
+	http.HandleFunc("/ingest/", api.IngestHandler)
+	http.HandleFunc("/signals/tickets", api.TicketsHandler)
+	http.HandleFunc("/signals/accounts", api.AccountsHandler)
+	http.HandleFunc("/signals/usage", api.UsageHandler)
+	http.HandleFunc("/risk/ranked", api.RiskRankedHandler)
+	http.HandleFunc("/openapi.json", api.OpenAPIHandler)
+	http.HandleFunc("/metrics", api.MetricsHandler)
+	http.HandleFunc("/admin/customers/export", api.CustomerExportHandler)
+	http.HandleFunc("/admin/customers/erase", api.CustomerEraseHandler)
+	http.HandleFunc("/admin/predictions/versions", api.ModelVersionsHandler)
+	http.HandleFunc("/admin/drift", api.DriftHandler)
+	http.HandleFunc("/admin/webhooks", api.WebhooksHandler)
+	http.HandleFunc("/admin/webhooks/deliveries", api.WebhookDeliveriesHandler)
+	http.HandleFunc("/admin/rescore", api.RescoreHandler)
 
 	port := ":8080" // This server will run on 8080 as per Dockerfile EXPOSE
 	log.Printf("Starting standalone API server on port %s...\n", port)
-	log.Println("API endpoint available at /predict (POST)")
+	log.Println("API endpoints available at /predict (POST), /jobs/{id} (GET), /ingest/{source} (POST) and /signals/{tickets,accounts,usage} (POST)")
+	log.Println("OpenAPI document available at /openapi.json (GET)")
+	log.Println("Metrics available at /metrics (GET)")
+	log.Println("Accounts ranked by revenue at risk available at /risk/ranked (GET, admin token)")
+	log.Println("Admin endpoints available at /admin/customers/export (GET) and /admin/customers/erase (POST)")
 	if err := http.ListenAndServe(port, nil); err != nil {
 		log.Fatalf("Failed to start standalone server: %v", err)
 	}
 }
+
+// runWorker runs the feedback queue consumer until SIGINT or SIGTERM, then lets
+// the messages in flight finish. Unfinished messages are redelivered by the queue.
+func runWorker() {
+	queue, err := appcore.NewFeedbackQueueFromEnv()
+	if err != nil {
+		log.Fatalf("Worker initialization failed: %v", err)
+	}
+	defer queue.Close()
+	worker, err := appcore.ConfigureFeedbackWorker(queue)
+	if err != nil {
+		log.Fatalf("Worker initialization failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		log.Printf("Received %v, stopping the worker...", <-signals)
+		close(stop)
+	}()
+	worker.Run(stop)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"go-churn-agent/pkg/appcore" // Import the shared appcore package
+)
+
+// webhooks sends due webhook deliveries, both new ones queued by /predict and
+// retries whose backoff has elapsed, and dead-letters the ones that run out of
+// attempts. The standalone server does this continuously; serverless
+// deployments should run this every minute or so:
+//
+//	go run ./cmd/webhooks
+//	go run ./cmd/webhooks -limit 500
+func main() {
+	limit := flag.Int("limit", 100, "Maximum number of due deliveries to send in this run.")
+	flag.Parse()
+
+	if err := appcore.InitClients(); err != nil {
+		log.Fatalf("Initialization failed: %v", err)
+	}
+
+	report, err := appcore.DeliverDueWebhooks(time.Now(), *limit)
+
+	// Always print the report, including partial progress if a retry failed.
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encErr := encoder.Encode(report); encErr != nil {
+		log.Printf("Error encoding webhook delivery report: %v", encErr)
+	}
+	if err != nil {
+		log.Fatalf("Webhook delivery failed: %v", err)
+	}
+	log.Printf("Sent %d of %d due deliveries (the rest were claimed elsewhere): %d delivered, %d failed.", report.Claimed, report.Due, report.Delivered, report.Failed)
+}
@@ -5,23 +5,61 @@
 -- 1. Create the customer_feedback table
 CREATE TABLE public.customer_feedback (
     id UUID DEFAULT uuid_generate_v4() NOT NULL PRIMARY KEY,
+    account_id TEXT NULL, -- Optional stable customer identifier supplied by the caller; used for data subject requests.
     nls_score INT,
     feedback_text TEXT,
     created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
     comment_sentiment TEXT NULL,
-    comment_topics TEXT[] NULL
+    comment_topics TEXT[] NULL,
+    topic_categories TEXT[] NULL, -- Parent categories of comment_topics, from the topic taxonomy
+    topic_sentiments JSONB NULL, -- Sentiment per extracted topic, e.g. [{"topic": "pricing", "sentiment": "NEGATIVE", "score": 0.97}]
+    product_line TEXT NULL, -- Product line whose taxonomy was applied
+    taxonomy_version TEXT NULL, -- Version of the topic taxonomy used to extract comment_topics
+    language TEXT NULL, -- Detected ISO 639-1 language of feedback_text ('und' if undetermined)
+    translated_by TEXT NULL, -- Translation backend used before enrichment, NULL if the text was enriched as-is
+    emotions TEXT[] NULL, -- Emotions detected by zero-shot classification (anger, frustration, disappointment, satisfaction)
+    cancel_intent BOOLEAN NOT NULL DEFAULT false, -- Explicit cancellation or refund language was found
+    urgency TEXT NULL, -- none, medium or high
+    intent_signals TEXT[] NULL, -- Intent signals found, e.g. {cancel,refund,competitor}
+    enrichment_scores JSONB NULL, -- Every sentiment label and candidate topic with its confidence, e.g. {"sentiment": [{"label": "NEGATIVE", "score": 0.98}], "topics": [{"topic": "pricing", "label": "pricing", "score": 0.91}]}
+    redactions JSONB NULL, -- PII redactions applied to feedback_text before storage, e.g. [{"type": "email", "mode": "mask", "count": 1}]
+    source TEXT NOT NULL DEFAULT 'survey', -- 'survey' or 'ticket'; tickets have no nls_score and feedback_text holds the ticket text
+    ticket JSONB NULL, -- Support ticket fields, e.g. {"ticket_id": "ZD-48213", "priority": "high", "time_to_resolution_hours": 96.5, "reopen_count": 1, "event_at": "2026-10-01T09:30:00Z"}
+    account_signals JSONB NULL -- Account features used when scoring, e.g. {"tickets": {"tickets": 4, "negative_tickets": 3, "urgent_tickets": 1, "reopens": 2, "slow_resolutions": 1}}
 );
 
 -- Optional: Add a comment to describe the table
-COMMENT ON TABLE public.customer_feedback IS 'Stores customer Net Promoter Score (NLS) and their feedback.';
+COMMENT ON TABLE public.customer_feedback IS 'Stores customer Net Promoter Score (NLS) and their feedback, and support ticket events.';
+
+-- Optional: Add an index for data subject export/erasure lookups
+CREATE INDEX idx_customer_feedback_account_id ON public.customer_feedback(account_id);
+
+-- Optional: Add indexes for the retention purge job (cmd/purge)
+CREATE INDEX idx_customer_feedback_created_at ON public.customer_feedback(created_at);
+
+-- Index for the ticket signal lookup (optional) and the unique index that stores
+-- each ticket event once (required: concurrent deliveries of the same event are
+-- rejected here, and answered 409 by /signals/tickets)
+CREATE INDEX idx_customer_feedback_tickets ON public.customer_feedback(account_id, created_at) WHERE source = 'ticket';
+CREATE UNIQUE INDEX idx_customer_feedback_ticket_events ON public.customer_feedback((ticket->>'ticket_id'), (ticket->>'event_at')) WHERE source = 'ticket';
 
 -- 2. Create the churn_predictions table
 CREATE TABLE public.churn_predictions (
     id UUID DEFAULT uuid_generate_v4() NOT NULL PRIMARY KEY,
     customer_feedback_id UUID REFERENCES public.customer_feedback(id) ON DELETE CASCADE, -- Ensures that if a feedback entry is deleted, its predictions are also deleted.
-    churn_probability FLOAT,
+    churn_probability FLOAT, -- Calibrated probability (equals raw_churn_probability when no calibration is loaded)
+    raw_churn_probability FLOAT NULL, -- Model output before calibration
+    calibration_version TEXT NULL, -- Version of the calibration artifact applied
     reason TEXT,
-    predicted_at TIMESTAMPTZ DEFAULT now() NOT NULL
+    predicted_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    model_name TEXT NULL, -- Model that produced the score, e.g. 'churn-rules'; NULL for rows written before versioning
+    model_version TEXT NULL, -- Version of that model (RulesModelVersion for the rules)
+    rules_version TEXT NULL, -- Version of the negative keyword set
+    taxonomy_version TEXT NULL, -- Topic taxonomy version of the enrichment used
+    sentiment_model TEXT NULL, -- Hugging Face sentiment model ID
+    zero_shot_model TEXT NULL, -- Hugging Face zero-shot model ID (topics and emotions)
+    shadow BOOLEAN NOT NULL DEFAULT false, -- Scored by a shadow model for evaluation; never returned to the client
+    rescore_run_id UUID NULL -- rescore_runs row that wrote this prediction; NULL when scored at prediction time
 );
 
 -- Optional: Add a comment to describe the table
@@ -29,3 +67,409 @@ COMMENT ON TABLE public.churn_predictions IS 'Stores churn predictions based on
 
 -- Optional: Add an index for faster lookups on the foreign key
 CREATE INDEX idx_churn_predictions_customer_feedback_id ON public.churn_predictions(customer_feedback_id);
+CREATE INDEX idx_churn_predictions_predicted_at ON public.churn_predictions(predicted_at);
+CREATE INDEX idx_churn_predictions_model_version ON public.churn_predictions(model_name, model_version);
+
+-- 3. Create the data_erasure_audit table
+-- One row per data subject erasure request. The account ID is stored only as a SHA-256 hash.
+CREATE TABLE public.data_erasure_audit (
+    id UUID DEFAULT uuid_generate_v4() NOT NULL PRIMARY KEY,
+    subject_hash TEXT NOT NULL,
+    erasure_mode TEXT NOT NULL, -- 'delete' or 'anonymize'
+    feedback_rows INT NOT NULL,
+    prediction_rows INT NOT NULL,
+    outcome_rows INT NOT NULL DEFAULT 0, -- churn_outcomes rows deleted
+    webhook_rows INT NOT NULL DEFAULT 0, -- webhook_deliveries rows deleted
+    outbox_rows INT NOT NULL DEFAULT 0, -- outbox_events rows deleted
+    job_rows INT NOT NULL DEFAULT 0, -- prediction_jobs rows deleted
+    account_rows INT NOT NULL DEFAULT 0, -- account_attributes and account_usage rows deleted
+    cache_rows INT NOT NULL DEFAULT 0, -- enrichment_cache rows deleted
+    requested_by TEXT NULL,
+    reason TEXT NULL,
+    erased_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+COMMENT ON TABLE public.data_erasure_audit IS 'Audit trail of GDPR erasure requests.';
+
+-- 4. Create the enrichment_cache table
+-- Optional shared cache of Hugging Face responses, used when ENRICHMENT_CACHE=supabase.
+CREATE TABLE public.enrichment_cache (
+    cache_key TEXT NOT NULL PRIMARY KEY, -- text_hash, then SHA-256 of model ID, normalized feedback text and candidate labels
+    text_hash TEXT NULL, -- SHA-256 of the normalized text, so erasure requests can delete every response for it
+    response JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+COMMENT ON TABLE public.enrichment_cache IS 'Cached Hugging Face sentiment/topic responses shared across serverless instances.';
+
+CREATE INDEX idx_enrichment_cache_text_hash ON public.enrichment_cache(text_hash);
+
+-- cmd/purge deletes entries older than the feedback text retention period.
+-- Expired entries are ignored on read; optionally clean them up sooner with:
+-- DELETE FROM public.enrichment_cache WHERE expires_at < now();
+
+-- 5. Create the churn_outcomes table
+-- Observed churn per account, loaded from billing/CRM. Used by cmd/backtest to
+-- label historical predictions and fit calibration. Accounts without a row are
+-- treated as retained.
+CREATE TABLE public.churn_outcomes (
+    account_id TEXT NOT NULL PRIMARY KEY,
+    churned BOOLEAN NOT NULL,
+    churned_at TIMESTAMPTZ NULL, -- When the account churned; required when churned is true
+    recorded_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+COMMENT ON TABLE public.churn_outcomes IS 'Observed churn outcomes per account, for backtesting and calibration.';
+
+-- 6. Create the webhook tables
+-- Subscriptions receive signed POSTs for predictions over min_churn_probability
+-- or matching one of their rules. Each matching event becomes a delivery row that
+-- is retried with backoff by cmd/webhooks; every attempt is logged, and deliveries
+-- that run out of attempts are copied to webhook_dead_letters.
+CREATE TABLE public.webhook_subscriptions (
+    id UUID DEFAULT uuid_generate_v4() NOT NULL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL, -- HMAC-SHA256 signing key
+    description TEXT NULL,
+    min_churn_probability FLOAT NULL,
+    rules JSONB NULL, -- [{"sentiment": "NEGATIVE", "topics": ["pricing"]}]
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+CREATE TABLE public.webhook_deliveries (
+    id UUID DEFAULT uuid_generate_v4() NOT NULL PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES public.webhook_subscriptions(id),
+    event_id TEXT NOT NULL, -- Sent as X-Churn-Event-ID, stable across retries
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL, -- 'pending', 'delivered' or 'dead'
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NULL,
+    last_status_code INT NULL,
+    last_error TEXT NULL,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    delivered_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON public.webhook_deliveries(status, next_attempt_at);
+
+CREATE TABLE public.webhook_delivery_attempts (
+    id UUID DEFAULT uuid_generate_v4() NOT NULL PRIMARY KEY,
+    delivery_id UUID NOT NULL REFERENCES public.webhook_deliveries(id) ON DELETE CASCADE,
+    subscription_id UUID NOT NULL,
+    event_id TEXT NOT NULL,
+    attempt INT NOT NULL,
+    status_code INT NULL,
+    error TEXT NULL,
+    duration_ms BIGINT NOT NULL,
+    attempted_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE public.webhook_dead_letters (
+    id UUID DEFAULT uuid_generate_v4() NOT NULL PRIMARY KEY,
+    delivery_id UUID NOT NULL REFERENCES public.webhook_deliveries(id) ON DELETE CASCADE,
+    subscription_id UUID NOT NULL,
+    event_id TEXT NOT NULL,
+    url TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT NULL,
+    dead_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+COMMENT ON TABLE public.webhook_subscriptions IS 'Registered webhook URLs and the predictions they receive.';
+COMMENT ON TABLE public.webhook_deliveries IS 'One row per webhook event and subscription, with its retry state.';
+COMMENT ON TABLE public.webhook_delivery_attempts IS 'Log of every webhook delivery attempt.';
+COMMENT ON TABLE public.webhook_dead_letters IS 'Webhook deliveries that failed after the maximum number of attempts.';
+
+-- 7. Create the outbox_events table and the store_prediction function
+-- Transactional outbox: POST /predict writes the feedback row, its predictions,
+-- a prediction.created event and its webhook_deliveries in one call to
+-- store_prediction, so events exist exactly when the rows they describe do. The relay in cmd/server publishes
+-- pending events to the OUTBOX_SINKS in created_at order and sets published_at;
+-- delivery is at least once, so consumers should de-duplicate on the event id.
+CREATE TABLE public.outbox_events (
+    id UUID NOT NULL PRIMARY KEY, -- Also the envelope id seen by consumers
+    event_type TEXT NOT NULL, -- e.g. 'prediction.created'
+    aggregate_id UUID NOT NULL, -- customer_feedback id
+    payload JSONB NOT NULL, -- {"id", "type", "created_at", "data": {"feedback": {...}, "prediction": {...}}}
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    published_at TIMESTAMPTZ NULL, -- NULL until every sink accepted the event
+    published_sinks TEXT[] NULL, -- Sinks that accepted the event; retries skip them
+    attempts INT NOT NULL DEFAULT 0, -- Failed publish attempts
+    next_attempt_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    last_error TEXT NULL,
+    failed_at TIMESTAMPTZ NULL -- Set after OUTBOX_MAX_ATTEMPTS failed attempts; the relay gives up
+);
+
+COMMENT ON TABLE public.outbox_events IS 'Events written with the rows they describe, published to downstream sinks by the outbox relay.';
+
+CREATE INDEX idx_outbox_events_pending ON public.outbox_events(next_attempt_at) WHERE published_at IS NULL AND failed_at IS NULL;
+
+-- Failed events stay in the table for inspection. Once the sink is fixed,
+-- requeue them with:
+-- UPDATE public.outbox_events SET failed_at = NULL, attempts = 0, next_attempt_at = now() WHERE failed_at IS NOT NULL;
+
+-- cmd/purge deletes events older than the feedback text retention period.
+-- Published events are kept for replay; optionally clean them up sooner with:
+-- DELETE FROM public.outbox_events WHERE published_at < now() - interval '7 days';
+
+-- The feedback id doubles as an idempotency key: queue workers derive it from
+-- the message ID, so when a redelivered message's feedback is already stored
+-- nothing is written and the function returns NULL. Deliveries carry their own
+-- ids, since jsonb_populate_recordset doesn't apply column defaults.
+-- Upgrading from the three-argument version: drop it first so PostgREST isn't
+-- left with two overloads.
+-- DROP FUNCTION IF EXISTS public.store_prediction(JSONB, JSONB, JSONB);
+CREATE OR REPLACE FUNCTION public.store_prediction(feedback JSONB, predictions JSONB, events JSONB, deliveries JSONB DEFAULT '[]')
+RETURNS UUID
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    INSERT INTO public.customer_feedback
+    SELECT * FROM jsonb_populate_record(NULL::public.customer_feedback, feedback)
+    ON CONFLICT (id) DO NOTHING;
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO public.churn_predictions
+    SELECT * FROM jsonb_populate_recordset(NULL::public.churn_predictions, predictions);
+
+    INSERT INTO public.outbox_events
+    SELECT * FROM jsonb_populate_recordset(NULL::public.outbox_events, events);
+
+    INSERT INTO public.webhook_deliveries
+    SELECT * FROM jsonb_populate_recordset(NULL::public.webhook_deliveries, deliveries);
+
+    RETURN (feedback->>'id')::UUID;
+END;
+$$;
+
+-- 8. Create the prediction_jobs table
+-- POST /predict?async=true stores the (redacted) request here and returns 202.
+-- The job runner in cmd/server claims queued jobs, runs the prediction pipeline
+-- and stores the response in result; clients poll GET /jobs/{id} or get the
+-- job status POSTed to callback_url.
+CREATE TABLE public.prediction_jobs (
+    id UUID NOT NULL PRIMARY KEY,
+    status TEXT NOT NULL, -- 'queued', 'running', 'succeeded' or 'failed'
+    request JSONB NOT NULL, -- The /predict request body, feedback_text already redacted
+    redactions JSONB NULL, -- Redactions applied to request.feedback_text
+    callback_url TEXT NULL,
+    result JSONB NULL, -- The /predict response, once succeeded
+    error TEXT NULL, -- Last processing error
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    started_at TIMESTAMPTZ NULL, -- Start of the latest attempt
+    completed_at TIMESTAMPTZ NULL,
+    callback_status TEXT NULL, -- 'delivered' or 'failed'
+    callback_error TEXT NULL
+);
+
+COMMENT ON TABLE public.prediction_jobs IS 'Async /predict requests and their results.';
+
+CREATE INDEX idx_prediction_jobs_queued ON public.prediction_jobs(next_attempt_at) WHERE status = 'queued';
+
+-- cmd/purge deletes jobs older than the feedback text retention period.
+-- Finished jobs are only needed until the client has the result; optionally clean them up sooner with:
+-- DELETE FROM public.prediction_jobs WHERE completed_at < now() - interval '7 days';
+
+-- 9. Create the rescore_runs table
+-- cmd/rescore and POST /admin/rescore re-enrich and/or re-score stored feedback
+-- selected by date, model version or comment_sentiment = 'UNKNOWN'. Each run
+-- checkpoints its progress here so it can be resumed.
+CREATE TABLE public.rescore_runs (
+    id UUID NOT NULL PRIMARY KEY,
+    status TEXT NOT NULL, -- 'running', 'paused', 'completed' or 'failed'
+    options JSONB NOT NULL, -- Selection filters, mode ('enrich', 'score' or 'both') and rate
+    last_feedback_id UUID NULL, -- Checkpoint: feedback is processed in descending id order
+    scanned INT NOT NULL DEFAULT 0,
+    enriched INT NOT NULL DEFAULT 0,
+    scored INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    error TEXT NULL, -- Why the run stopped, if it failed
+    started_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    completed_at TIMESTAMPTZ NULL
+);
+
+COMMENT ON TABLE public.rescore_runs IS 'Re-enrichment and re-scoring runs over historical feedback, with their checkpoints.';
+
+-- Optional: Add an index to find the feedback left UNKNOWN by Hugging Face outages
+CREATE INDEX idx_customer_feedback_unknown_sentiment ON public.customer_feedback(id) WHERE comment_sentiment = 'UNKNOWN';
+
+-- 10. Create the account_attributes and account_usage tables
+-- Account attributes from billing or the CRM (POST /signals/accounts) and product
+-- usage per period (POST /signals/usage), both also loaded by cmd/import. They
+-- are looked up when the account's feedback and tickets are scored, and the
+-- features used are stored with each row in customer_feedback.account_signals.
+CREATE TABLE public.account_attributes (
+    account_id TEXT NOT NULL PRIMARY KEY,
+    plan TEXT NULL,
+    mrr NUMERIC NULL, -- Monthly recurring revenue, in the billing currency
+    customer_since DATE NULL, -- Start of the subscription, for tenure
+    renewal_date DATE NULL, -- Next renewal
+    updated_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+COMMENT ON TABLE public.account_attributes IS 'Plan, MRR, tenure and renewal date per account.';
+
+CREATE TABLE public.account_usage (
+    account_id TEXT NOT NULL,
+    period_start DATE NOT NULL, -- First day of the period; use the same period length for every period of an account
+    logins INT NOT NULL,
+    active_seats INT NOT NULL,
+    licensed_seats INT NULL,
+    feature_adoption JSONB NULL, -- Uses per feature in the period, e.g. {"exports": 12, "api": 0}
+    recorded_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+    PRIMARY KEY (account_id, period_start)
+);
+
+COMMENT ON TABLE public.account_usage IS 'Product usage (logins, active seats, feature adoption) per account and period.';
+
+-- GET /admin/predictions/versions: stored predictions summarized per model name
+-- and version, ordered by when each version was first used. NULL arguments
+-- don't filter; rows written before versioning have an empty name and version.
+CREATE OR REPLACE FUNCTION public.model_version_stats(
+    high_risk_probability FLOAT,
+    model_name TEXT DEFAULT NULL,
+    versions TEXT[] DEFAULT NULL,
+    since TIMESTAMPTZ DEFAULT NULL,
+    until TIMESTAMPTZ DEFAULT NULL
+)
+RETURNS TABLE (
+    model_name TEXT,
+    model_version TEXT,
+    predictions BIGINT,
+    shadow_predictions BIGINT,
+    first_predicted_at TIMESTAMPTZ,
+    last_predicted_at TIMESTAMPTZ,
+    mean_probability FLOAT,
+    mean_raw_probability FLOAT,
+    high_risk_share FLOAT,
+    reasons JSONB,
+    rules_versions TEXT[],
+    taxonomy_versions TEXT[],
+    calibration_versions TEXT[],
+    sentiment_models TEXT[],
+    zero_shot_models TEXT[]
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH selected AS (
+        SELECT COALESCE(p.model_name, '') AS name, COALESCE(p.model_version, '') AS version, p.*
+        FROM public.churn_predictions p
+        WHERE (model_version_stats.model_name IS NULL OR p.model_name = model_version_stats.model_name)
+          AND (model_version_stats.versions IS NULL OR p.model_version = ANY (model_version_stats.versions))
+          AND (model_version_stats.since IS NULL OR p.predicted_at >= model_version_stats.since)
+          AND (model_version_stats.until IS NULL OR p.predicted_at < model_version_stats.until)
+    ),
+    reason_counts AS (
+        SELECT r.name, r.version, jsonb_object_agg(r.reason, r.n) AS reasons
+        FROM (
+            SELECT s.name, s.version, COALESCE(s.reason, '') AS reason, count(*) AS n
+            FROM selected s
+            GROUP BY 1, 2, 3
+        ) r
+        GROUP BY r.name, r.version
+    )
+    SELECT
+        s.name,
+        s.version,
+        count(*),
+        count(*) FILTER (WHERE s.shadow),
+        min(s.predicted_at),
+        max(s.predicted_at),
+        avg(s.churn_probability),
+        avg(COALESCE(s.raw_churn_probability, s.churn_probability)),
+        avg(CASE WHEN s.churn_probability >= model_version_stats.high_risk_probability THEN 1.0 ELSE 0.0 END)::FLOAT,
+        rc.reasons,
+        COALESCE(array_agg(DISTINCT s.rules_version) FILTER (WHERE s.rules_version <> ''), '{}'),
+        COALESCE(array_agg(DISTINCT s.taxonomy_version) FILTER (WHERE s.taxonomy_version <> ''), '{}'),
+        COALESCE(array_agg(DISTINCT s.calibration_version) FILTER (WHERE s.calibration_version <> ''), '{}'),
+        COALESCE(array_agg(DISTINCT s.sentiment_model) FILTER (WHERE s.sentiment_model <> ''), '{}'),
+        COALESCE(array_agg(DISTINCT s.zero_shot_model) FILTER (WHERE s.zero_shot_model <> ''), '{}')
+    FROM selected s
+    JOIN reason_counts rc ON rc.name = s.name AND rc.version = s.version
+    GROUP BY s.name, s.version, rc.reasons
+    ORDER BY min(s.predicted_at);
+$$;
+
+-- GET /risk/ranked: per account with feedback or tickets created since the given
+-- time, the highest churn probability among them and the signal it came from.
+-- Each signal counts with its latest primary prediction, so a rescore's newer
+-- prediction replaces the older one; ties go to the most recent signal.
+-- Accounts are ranked here rather than by the caller so that PostgREST's row
+-- limit can't drop them before they are ranked: revenue_at_risk is the
+-- probability times the MRR in account_attributes, and priority_score boosts it
+-- linearly from 1 at renewal_soon_days before renewal_date to
+-- 1 + renewal_max_boost on it (as RenewalMaxBoost describes). Accounts without
+-- an MRR have no priority and follow, by probability. max_accounts NULL returns
+-- every account.
+-- Upgrading from the two-argument version: drop it first so PostgREST isn't
+-- left with two overloads.
+-- DROP FUNCTION IF EXISTS public.account_risk(TIMESTAMPTZ, FLOAT);
+CREATE OR REPLACE FUNCTION public.account_risk(
+    since TIMESTAMPTZ,
+    min_probability FLOAT DEFAULT 0,
+    as_of DATE DEFAULT current_date,
+    renewal_soon_days INT DEFAULT 90,
+    renewal_max_boost FLOAT DEFAULT 1,
+    max_accounts INT DEFAULT NULL
+)
+RETURNS TABLE (
+    account_id TEXT,
+    churn_probability FLOAT,
+    reason TEXT,
+    model_name TEXT,
+    model_version TEXT,
+    customer_feedback_id UUID,
+    source TEXT,
+    predicted_at TIMESTAMPTZ,
+    plan TEXT,
+    mrr FLOAT,
+    renewal_date DATE,
+    days_to_renewal INT,
+    revenue_at_risk FLOAT,
+    priority_score FLOAT
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH best AS (
+        SELECT DISTINCT ON (s.account_id)
+            s.account_id, s.churn_probability, s.reason, s.model_name, s.model_version,
+            s.customer_feedback_id, s.source, s.predicted_at
+        FROM (
+            SELECT DISTINCT ON (f.id)
+                f.account_id, p.churn_probability, p.reason, p.model_name, p.model_version,
+                f.id AS customer_feedback_id, f.source, p.predicted_at, f.created_at
+            FROM public.customer_feedback f
+            JOIN public.churn_predictions p ON p.customer_feedback_id = f.id AND NOT p.shadow
+            WHERE f.account_id IS NOT NULL AND f.created_at >= account_risk.since
+            ORDER BY f.id, p.predicted_at DESC
+        ) s
+        WHERE s.churn_probability >= account_risk.min_probability
+        ORDER BY s.account_id, s.churn_probability DESC, s.created_at DESC
+    ), weighted AS (
+        SELECT b.*, a.plan, a.mrr::FLOAT AS mrr, a.renewal_date,
+            a.renewal_date - account_risk.as_of AS days_to_renewal,
+            b.churn_probability * a.mrr::FLOAT AS revenue_at_risk
+        FROM best b
+        LEFT JOIN public.account_attributes a ON a.account_id = b.account_id
+    )
+    SELECT w.account_id, w.churn_probability, w.reason, w.model_name, w.model_version,
+        w.customer_feedback_id, w.source, w.predicted_at,
+        w.plan, w.mrr, w.renewal_date, w.days_to_renewal, w.revenue_at_risk,
+        w.revenue_at_risk * CASE
+            WHEN w.days_to_renewal BETWEEN 0 AND account_risk.renewal_soon_days
+            THEN 1 + account_risk.renewal_max_boost * (1 - w.days_to_renewal::FLOAT / account_risk.renewal_soon_days)
+            ELSE 1
+        END AS priority_score
+    FROM weighted w
+    ORDER BY priority_score DESC NULLS LAST, w.churn_probability DESC, w.account_id
+    LIMIT account_risk.max_accounts;
+$$;
@@ -19,35 +19,49 @@ Feature: Churn Prediction API
     And request { "nls_score": 11, "feedback_text": "Good." }
     When method post
     Then status 400
-    And match response.error == "NLS score must be between 0 and 10"
+    And match header Content-Type contains 'application/problem+json'
+    And match response.code == "validation_failed"
+    And match response.field == "nls_score"
+    And match response.errors contains { field: "nls_score", code: "out_of_range", message: "#string" }
+    And match response.request_id == "#string"
 
   Scenario: Invalid NLS score (too low)
     Given path '/predict'
     And request { "nls_score": -1, "feedback_text": "Not good." }
     When method post
     Then status 400
-    And match response.error == "NLS score must be between 0 and 10"
+    And match response.code == "validation_failed"
+    And match response.errors contains { field: "nls_score", code: "out_of_range", message: "#string" }
 
   Scenario: Missing NLS score (null)
     Given path '/predict'
     And request { "feedback_text": "Feedback only." } # nls_score field is completely missing
     When method post
     Then status 400
-    And match response.error == "NLS score is required." # Matches Go code: if req.NLSScore == nil
+    And match response.code == "validation_failed"
+    And match response.errors contains { field: "nls_score", code: "required", message: "#string" }
 
-  Scenario: Empty feedback text
+  Scenario: Unknown field
     Given path '/predict'
-    And request { "nls_score": 7, "feedback_text": "  " } # Test with whitespace only
+    And request { "nls_score": 7, "feedback_text": "Fine.", "score": 7 }
     When method post
     Then status 400
-    And match response.error == "Feedback text cannot be empty"
+    And match response.code == "validation_failed"
+    And match response.errors contains { field: "score", code: "unknown_field", message: "#string" }
+
+  Scenario: Empty feedback text is accepted
+    Given path '/predict'
+    And request { "nls_score": 7, "feedback_text": "  " } # Test with whitespace only
+    When method post
+    Then status 200
+    And match response.comment_sentiment == "NEUTRAL"
 
   Scenario: Malformed JSON request
     Given path '/predict'
     And request '{ "nls_score": 5, "feedback_text": "Test", }' # Extra comma makes it malformed
     When method post
     Then status 400
-    And match response.error == "Invalid JSON request body."
+    And match response.code == "invalid_json"
 
   Scenario: Low NLS score and negative feedback
     Given path '/predict'
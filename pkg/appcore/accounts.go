@@ -0,0 +1,418 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// UsageSignalWindow is how far back usage periods are read for the usage
+	// signals: enough for the latest ended month and the three before it.
+	UsageSignalWindow = 150 * 24 * time.Hour
+	// usageBaselinePeriods is how many periods before the latest ended one form the login baseline.
+	usageBaselinePeriods = 3
+	// UsageCollapseThreshold is the drop in logins against the baseline, as a
+	// fraction, from which usage counts as collapsing.
+	UsageCollapseThreshold = 0.5
+	// RenewalSoonDays is how close a renewal date counts as near.
+	RenewalSoonDays = 90
+)
+
+// dateLayout is the format of account dates such as renewal_date and period_start.
+const dateLayout = "2006-01-02"
+
+// AccountAttributes are an account's commercial attributes, loaded from billing
+// or the CRM into account_attributes, one row per account.
+type AccountAttributes struct {
+	AccountID     string    `json:"account_id"`
+	Plan          string    `json:"plan,omitempty"`
+	MRR           *float64  `json:"mrr,omitempty"`            // monthly recurring revenue
+	CustomerSince string    `json:"customer_since,omitempty"` // YYYY-MM-DD, for tenure
+	RenewalDate   string    `json:"renewal_date,omitempty"`   // YYYY-MM-DD, the next renewal
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// AccountUsage is an account's product usage over one period (a day, week or
+// month, as long as it is the same for every period of the account), one
+// account_usage row per account and period_start.
+type AccountUsage struct {
+	AccountID       string         `json:"account_id"`
+	PeriodStart     string         `json:"period_start"` // YYYY-MM-DD
+	Logins          int            `json:"logins"`
+	ActiveSeats     int            `json:"active_seats"`
+	LicensedSeats   *int           `json:"licensed_seats"`
+	FeatureAdoption map[string]int `json:"feature_adoption"` // uses per feature in the period
+	RecordedAt      time.Time      `json:"recorded_at"`
+}
+
+// AttributeSignals are the account attributes as of the time a signal was scored.
+type AttributeSignals struct {
+	Plan          string   `json:"plan,omitempty"`
+	MRR           *float64 `json:"mrr,omitempty"`
+	TenureMonths  *int     `json:"tenure_months,omitempty"`
+	DaysToRenewal *int     `json:"days_to_renewal,omitempty"` // negative once the renewal date has passed
+}
+
+// UsageSignals summarise an account's latest ended usage period against the
+// periods before it.
+type UsageSignals struct {
+	PeriodStart     string   `json:"period_start"` // of the latest ended period
+	Logins          int      `json:"logins"`
+	ActiveSeats     int      `json:"active_seats"`
+	SeatUtilization *float64 `json:"seat_utilization,omitempty"` // active / licensed seats
+	FeaturesAdopted int      `json:"features_adopted"`           // features used at least once in the latest period
+	LoginChange     *float64 `json:"login_change,omitempty"`     // vs the mean of the 3 periods before, e.g. -0.6 for a 60% drop
+	MissingPeriods  int      `json:"missing_periods,omitempty"`  // of those 3, periods without usage data
+}
+
+// Collapsing reports whether logins dropped by at least UsageCollapseThreshold.
+func (u *UsageSignals) Collapsing() bool {
+	return u != nil && u.LoginChange != nil && *u.LoginChange <= -UsageCollapseThreshold
+}
+
+// Signals returns the attributes as of asOf, with tenure in whole months and
+// the days left until renewal. Dates that don't parse are left out.
+func (a AccountAttributes) Signals(asOf time.Time) *AttributeSignals {
+	signals := &AttributeSignals{Plan: a.Plan, MRR: a.MRR}
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	if since, err := time.Parse(dateLayout, a.CustomerSince); err == nil && !since.After(day) {
+		months := (day.Year()-since.Year())*12 + int(day.Month()-since.Month())
+		if day.Day() < since.Day() {
+			months--
+		}
+		signals.TenureMonths = &months
+	}
+	if renewal, err := time.Parse(dateLayout, a.RenewalDate); err == nil {
+		days := int(math.Round(renewal.Sub(day).Hours() / 24))
+		signals.DaysToRenewal = &days
+	}
+	return signals
+}
+
+// SummarizeUsage computes the usage signals as of asOf from an account's usage
+// periods, in any order. The period length is inferred from the period starts:
+// whole months if every period starts on the 1st, else the shortest gap. A
+// period counts once it has ended, so a partial period is never compared with
+// full ones; with a single period, whose length is unknown, that period is
+// used. The login baseline is the usageBaselinePeriods periods right before
+// the latest ended one; periods missing there are counted in MissingPeriods
+// rather than replaced by older ones. It returns nil if there are no periods.
+func SummarizeUsage(periods []AccountUsage, asOf time.Time) *UsageSignals {
+	byStart := map[time.Time]AccountUsage{}
+	var starts []time.Time
+	for _, period := range periods {
+		start, err := time.Parse(dateLayout, period.PeriodStart)
+		if err != nil {
+			continue
+		}
+		if _, ok := byStart[start]; !ok {
+			starts = append(starts, start)
+		}
+		byStart[start] = period
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].After(starts[j]) })
+	next := usagePeriodStep(starts)
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	for next != nil && len(starts) > 0 && next(starts[0], 1).After(day) {
+		starts = starts[1:] // still in progress at asOf
+	}
+	if len(starts) == 0 {
+		return nil
+	}
+	latest := byStart[starts[0]]
+
+	signals := &UsageSignals{PeriodStart: latest.PeriodStart, Logins: latest.Logins, ActiveSeats: latest.ActiveSeats}
+	if latest.LicensedSeats != nil && *latest.LicensedSeats > 0 {
+		utilization := float64(latest.ActiveSeats) / float64(*latest.LicensedSeats)
+		signals.SeatUtilization = &utilization
+	}
+	for _, uses := range latest.FeatureAdoption {
+		if uses > 0 {
+			signals.FeaturesAdopted++
+		}
+	}
+
+	if next == nil {
+		return signals
+	}
+	var baseline []AccountUsage
+	for k := 1; k <= usageBaselinePeriods; k++ {
+		if period, ok := byStart[next(starts[0], -k)]; ok {
+			baseline = append(baseline, period)
+		} else {
+			signals.MissingPeriods++
+		}
+	}
+	total := 0
+	for _, period := range baseline {
+		total += period.Logins
+	}
+	if total > 0 {
+		mean := float64(total) / float64(len(baseline))
+		change := float64(latest.Logins)/mean - 1
+		signals.LoginChange = &change
+	}
+	return signals
+}
+
+// usagePeriodStep infers the period length from period starts, latest first,
+// and returns a function moving a period start by n periods. It returns nil if
+// the length can't be inferred from fewer than two periods.
+func usagePeriodStep(starts []time.Time) func(start time.Time, n int) time.Time {
+	if len(starts) < 2 {
+		return nil
+	}
+	monthly, months, days := true, 0, 0
+	for i := 1; i < len(starts); i++ {
+		newer, older := starts[i-1], starts[i]
+		if newer.Day() != 1 || older.Day() != 1 {
+			monthly = false
+		}
+		gapMonths := (newer.Year()-older.Year())*12 + int(newer.Month()-older.Month())
+		if months == 0 || gapMonths < months {
+			months = gapMonths
+		}
+		gapDays := int(newer.Sub(older).Hours() / 24)
+		if days == 0 || gapDays < days {
+			days = gapDays
+		}
+	}
+	if monthly {
+		return func(start time.Time, n int) time.Time { return start.AddDate(0, n*months, 0) }
+	}
+	return func(start time.Time, n int) time.Time { return start.AddDate(0, 0, n*days) }
+}
+
+// FetchAccountAttributes returns an account's attributes, if any are stored.
+func FetchAccountAttributes(accountID string) (*AccountAttributes, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, _, err := SupabaseClient.From("account_attributes").Select("*", "", false).Eq("account_id", accountID).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching account attributes: %w", err)
+	}
+	var rows []AccountAttributes
+	if err := json.Unmarshal(rawData, &rows); err != nil {
+		return nil, fmt.Errorf("error unmarshalling account attributes: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// FetchAccountUsage returns an account's usage periods starting between since
+// and until, latest first. A zero bound is left open.
+func FetchAccountUsage(accountID string, since, until time.Time) ([]AccountUsage, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	var periods []AccountUsage
+	for {
+		query := SupabaseClient.From("account_usage").Select("*", "", false).Eq("account_id", accountID)
+		if !since.IsZero() {
+			query = query.Gte("period_start", since.UTC().Format(dateLayout))
+		}
+		// One filter per column: after the first page the cursor replaces until.
+		if len(periods) > 0 {
+			query = query.Lt("period_start", periods[len(periods)-1].PeriodStart)
+		} else if !until.IsZero() {
+			query = query.Lte("period_start", until.UTC().Format(dateLayout))
+		}
+		rawData, _, err := query.Order("period_start", nil).Limit(pageSize, "").Execute()
+		if err != nil {
+			return nil, fmt.Errorf("error fetching account usage: %w", err)
+		}
+		var page []AccountUsage
+		if err := json.Unmarshal(rawData, &page); err != nil {
+			return nil, fmt.Errorf("error unmarshalling account usage: %w", err)
+		}
+		if len(page) == 0 {
+			return periods, nil
+		}
+		periods = append(periods, page...)
+	}
+}
+
+// ApiAccountRequest updates an account's attributes, as accepted by POST
+// /signals/accounts and cmd/import. Omitted fields keep their stored value.
+type ApiAccountRequest struct {
+	AccountID     string   `json:"account_id"`
+	Plan          *string  `json:"plan,omitempty"`
+	MRR           *float64 `json:"mrr,omitempty"`
+	CustomerSince *string  `json:"customer_since,omitempty"`
+	RenewalDate   *string  `json:"renewal_date,omitempty"`
+}
+
+// AccountRequestSpec is the single definition of the /signals/accounts request body.
+var AccountRequestSpec = ObjectSpec{
+	Name:        "AccountRequest",
+	Description: "Account attributes from billing or the CRM. Omitted fields keep their stored value.",
+	Fields: []FieldSpec{
+		{Name: "account_id", Type: "string", Required: true, MaxLength: 256,
+			Description: "Your stable identifier for the customer, as sent to /predict.", Example: "acct_1234"},
+		{Name: "plan", Type: "string", MaxLength: 64, Description: "Subscription plan.", Example: "business"},
+		{Name: "mrr", Type: "number", Minimum: floatPtr(0), Description: "Monthly recurring revenue, in your billing currency.", Example: 1200},
+		{Name: "customer_since", Type: "string", MaxLength: 10, Description: "Start of the subscription (YYYY-MM-DD), for tenure.", Example: "2023-04-01"},
+		{Name: "renewal_date", Type: "string", MaxLength: 10, Description: "Next renewal date (YYYY-MM-DD).", Example: "2026-12-31"},
+	},
+}
+
+// ApiUsageRequest is an account's usage over one period, as accepted by POST
+// /signals/usage and cmd/import. It replaces any usage stored for the period.
+type ApiUsageRequest struct {
+	AccountID       string         `json:"account_id"`
+	PeriodStart     string         `json:"period_start"`
+	Logins          int            `json:"logins"`
+	ActiveSeats     int            `json:"active_seats"`
+	LicensedSeats   *int           `json:"licensed_seats,omitempty"`
+	FeatureAdoption []FeatureUsage `json:"feature_adoption,omitempty"`
+}
+
+// FeatureUsage is how often one product feature was used in a period.
+type FeatureUsage struct {
+	Feature string `json:"feature"`
+	Count   int    `json:"count"`
+}
+
+// UsageRequestSpec is the single definition of the /signals/usage request body.
+var UsageRequestSpec = ObjectSpec{
+	Name:        "UsageRequest",
+	Description: "Product usage of an account over one period. Use the same period length (day, week or month) for every period of an account.",
+	Fields: []FieldSpec{
+		{Name: "account_id", Type: "string", Required: true, MaxLength: 256,
+			Description: "Your stable identifier for the customer, as sent to /predict.", Example: "acct_1234"},
+		{Name: "period_start", Type: "string", Required: true, MaxLength: 10,
+			Description: "First day of the period (YYYY-MM-DD). Sending a period again replaces it.", Example: "2026-10-01"},
+		{Name: "logins", Type: "integer", Required: true, Minimum: floatPtr(0), Description: "Logins in the period.", Example: 42},
+		{Name: "active_seats", Type: "integer", Required: true, Minimum: floatPtr(0), Description: "Seats (users) active in the period.", Example: 8},
+		{Name: "licensed_seats", Type: "integer", Minimum: floatPtr(0), Description: "Seats paid for, for seat utilization.", Example: 20},
+		{Name: "feature_adoption", Type: "array", Description: "Uses per product feature in the period.", Items: &FieldSpec{Type: "object", Properties: []FieldSpec{
+			{Name: "feature", Type: "string", Required: true, MaxLength: 64, Example: "exports"},
+			{Name: "count", Type: "integer", Required: true, Minimum: floatPtr(0), Example: 12},
+		}}},
+	},
+}
+
+// DecodeAccountRequest validates a /signals/accounts body against AccountRequestSpec and decodes it.
+func DecodeAccountRequest(body io.Reader) (ApiAccountRequest, *APIError) {
+	var req ApiAccountRequest
+	if apiErr := DecodeJSONBody(body, AccountRequestSpec, &req); apiErr != nil {
+		return req, apiErr
+	}
+	if strings.TrimSpace(req.AccountID) == "" {
+		return req, NewValidationError("account_id", FieldErrInvalidValue, "account_id must not be empty")
+	}
+	for _, date := range []struct {
+		field string
+		value *string
+	}{{"customer_since", req.CustomerSince}, {"renewal_date", req.RenewalDate}} {
+		if date.value == nil {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, *date.value); err != nil {
+			return req, NewValidationError(date.field, FieldErrInvalidValue, date.field+" must be a date (YYYY-MM-DD)")
+		}
+	}
+	return req, nil
+}
+
+// DecodeUsageRequest validates a /signals/usage body against UsageRequestSpec and decodes it.
+func DecodeUsageRequest(body io.Reader) (ApiUsageRequest, *APIError) {
+	var req ApiUsageRequest
+	if apiErr := DecodeJSONBody(body, UsageRequestSpec, &req); apiErr != nil {
+		return req, apiErr
+	}
+	if strings.TrimSpace(req.AccountID) == "" {
+		return req, NewValidationError("account_id", FieldErrInvalidValue, "account_id must not be empty")
+	}
+	if _, err := time.Parse(dateLayout, req.PeriodStart); err != nil {
+		return req, NewValidationError("period_start", FieldErrInvalidValue, "period_start must be a date (YYYY-MM-DD)")
+	}
+	seen := map[string]bool{}
+	for i, feature := range req.FeatureAdoption {
+		if seen[feature.Feature] {
+			return req, NewValidationError(fmt.Sprintf("feature_adoption[%d].feature", i), FieldErrInvalidValue, "feature "+feature.Feature+" is listed twice")
+		}
+		seen[feature.Feature] = true
+	}
+	return req, nil
+}
+
+// Usage returns the request as an account_usage row.
+func (req ApiUsageRequest) Usage() AccountUsage {
+	usage := AccountUsage{
+		AccountID:     req.AccountID,
+		PeriodStart:   req.PeriodStart,
+		Logins:        req.Logins,
+		ActiveSeats:   req.ActiveSeats,
+		LicensedSeats: req.LicensedSeats,
+	}
+	if len(req.FeatureAdoption) > 0 {
+		usage.FeatureAdoption = map[string]int{}
+		for _, feature := range req.FeatureAdoption {
+			usage.FeatureAdoption[feature.Feature] = feature.Count
+		}
+	}
+	return usage
+}
+
+// StoreAccountAttributes upserts the attributes in req and returns the stored row.
+func StoreAccountAttributes(req ApiAccountRequest) (AccountAttributes, error) {
+	if SupabaseClient == nil {
+		return AccountAttributes{}, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	// Only the fields sent are written, so the upsert keeps the others.
+	row := map[string]interface{}{"account_id": req.AccountID, "updated_at": time.Now().UTC()}
+	if req.Plan != nil {
+		row["plan"] = *req.Plan
+	}
+	if req.MRR != nil {
+		row["mrr"] = *req.MRR
+	}
+	if req.CustomerSince != nil {
+		row["customer_since"] = *req.CustomerSince
+	}
+	if req.RenewalDate != nil {
+		row["renewal_date"] = *req.RenewalDate
+	}
+	rawData, _, err := SupabaseClient.From("account_attributes").Insert(row, true, "account_id", "representation", "").Execute()
+	if err != nil {
+		return AccountAttributes{}, fmt.Errorf("error storing account attributes: %w", err)
+	}
+	var rows []AccountAttributes
+	if err := json.Unmarshal(rawData, &rows); err != nil {
+		return AccountAttributes{}, fmt.Errorf("error unmarshalling account attributes: %w", err)
+	}
+	if len(rows) == 0 {
+		return AccountAttributes{}, fmt.Errorf("no data returned after upsert")
+	}
+	return rows[0], nil
+}
+
+// StoreAccountUsage upserts a usage period and returns the stored row.
+func StoreAccountUsage(req ApiUsageRequest) (AccountUsage, error) {
+	if SupabaseClient == nil {
+		return AccountUsage{}, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	usage := req.Usage()
+	usage.RecordedAt = time.Now().UTC()
+	rawData, _, err := SupabaseClient.From("account_usage").Insert(usage, true, "account_id,period_start", "representation", "").Execute()
+	if err != nil {
+		return AccountUsage{}, fmt.Errorf("error storing account usage: %w", err)
+	}
+	var rows []AccountUsage
+	if err := json.Unmarshal(rawData, &rows); err != nil {
+		return AccountUsage{}, fmt.Errorf("error unmarshalling account usage: %w", err)
+	}
+	if len(rows) == 0 {
+		return AccountUsage{}, fmt.Errorf("no data returned after upsert")
+	}
+	return rows[0], nil
+}
@@ -0,0 +1,143 @@
+package appcore
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSummarizeUsage tests the login trend, seat utilization and feature adoption.
+func TestSummarizeUsage(t *testing.T) {
+	asOf := time.Date(2026, 11, 3, 12, 0, 0, 0, time.UTC)
+	if SummarizeUsage(nil, asOf) != nil {
+		t.Errorf("Expected no usage signals without usage")
+	}
+	licensed := 20
+	periods := []AccountUsage{
+		{PeriodStart: "2026-08-01", Logins: 100},
+		{PeriodStart: "2026-10-01", Logins: 30, ActiveSeats: 5, LicensedSeats: &licensed, FeatureAdoption: map[string]int{"exports": 3, "api": 0}},
+		{PeriodStart: "2026-09-01", Logins: 80},
+		{PeriodStart: "2026-07-01", Logins: 90},
+		{PeriodStart: "2026-06-01", Logins: 1000}, // beyond the three baseline periods
+	}
+	usage := SummarizeUsage(periods, asOf)
+	if usage.PeriodStart != "2026-10-01" || usage.Logins != 30 || usage.FeaturesAdopted != 1 || usage.MissingPeriods != 0 {
+		t.Errorf("Unexpected usage signals %+v", usage)
+	}
+	if usage.SeatUtilization == nil || *usage.SeatUtilization != 0.25 {
+		t.Errorf("Expected seat utilization 0.25, got %v", usage.SeatUtilization)
+	}
+	if usage.LoginChange == nil || math.Abs(*usage.LoginChange-(-0.66666)) > 0.001 || !usage.Collapsing() {
+		t.Errorf("Expected logins down two thirds, got %v", usage.LoginChange)
+	}
+
+	single := SummarizeUsage(periods[1:2], asOf)
+	if single.LoginChange != nil || single.Collapsing() {
+		t.Errorf("Expected no login trend from a single period, got %+v", single)
+	}
+}
+
+// TestSummarizeUsage_PartialAndMissingPeriods tests that a period still in
+// progress is not compared with full ones and that gaps are not filled with
+// older periods.
+func TestSummarizeUsage_PartialAndMissingPeriods(t *testing.T) {
+	periods := []AccountUsage{
+		{PeriodStart: "2026-07-01", Logins: 100},
+		{PeriodStart: "2026-08-01", Logins: 100},
+		{PeriodStart: "2026-09-01", Logins: 90},
+		{PeriodStart: "2026-10-01", Logins: 12}, // three days into October
+	}
+	usage := SummarizeUsage(periods, time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC))
+	if usage.PeriodStart != "2026-09-01" || usage.LoginChange == nil || math.Abs(*usage.LoginChange-(-0.1)) > 1e-9 || usage.Collapsing() {
+		t.Errorf("Expected September against July and August, got %+v", usage)
+	}
+
+	weekly := []AccountUsage{
+		{PeriodStart: "2026-09-07", Logins: 40},
+		{PeriodStart: "2026-09-14", Logins: 50},
+		{PeriodStart: "2026-09-28", Logins: 10}, // the week of 2026-09-21 is missing
+		{PeriodStart: "2026-08-03", Logins: 500},
+	}
+	usage = SummarizeUsage(weekly, time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC))
+	if usage.PeriodStart != "2026-09-28" || usage.MissingPeriods != 1 || usage.LoginChange == nil || math.Abs(*usage.LoginChange-(-7.0/9)) > 1e-9 {
+		t.Errorf("Expected the week of 2026-09-28 against the two weeks before it with one missing, got %+v", usage)
+	}
+	if usage := SummarizeUsage(weekly, time.Date(2026, 10, 4, 0, 0, 0, 0, time.UTC)); usage.PeriodStart != "2026-09-14" {
+		t.Errorf("Expected the week of 2026-09-28 to be in progress on 2026-10-04, got %+v", usage)
+	}
+}
+
+// TestAccountAttributes_Signals tests tenure and days to renewal.
+func TestAccountAttributes_Signals(t *testing.T) {
+	mrr := 1200.0
+	attributes := AccountAttributes{Plan: "business", MRR: &mrr, CustomerSince: "2024-10-19", RenewalDate: "2026-11-17"}
+	signals := attributes.Signals(time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC))
+	if signals.TenureMonths == nil || *signals.TenureMonths != 23 {
+		t.Errorf("Expected 23 months of tenure, got %v", signals.TenureMonths)
+	}
+	if signals.DaysToRenewal == nil || *signals.DaysToRenewal != 30 {
+		t.Errorf("Expected 30 days to renewal, got %v", signals.DaysToRenewal)
+	}
+	if signals := (AccountAttributes{RenewalDate: "soon"}).Signals(time.Now()); signals.DaysToRenewal != nil || signals.TenureMonths != nil {
+		t.Errorf("Expected unparseable dates to be left out, got %+v", signals)
+	}
+}
+
+// TestPredictChurn_UsageCollapse tests that collapsing usage raises low scores only.
+func TestPredictChurn_UsageCollapse(t *testing.T) {
+	drop := -0.7
+	collapsing := &AccountSignals{Usage: &UsageSignals{LoginChange: &drop}}
+	prediction := PredictChurn(CustomerData{NLSScore: 9, CommentSentiment: "POSITIVE", AccountSignals: collapsing})
+	if prediction.ChurnProbability != 0.6 || prediction.Reason != "Logins down 70% from previous periods." {
+		t.Errorf("Expected a promoter with collapsing usage to score 0.6, got %v (%s)", prediction.ChurnProbability, prediction.Reason)
+	}
+	prediction = PredictChurn(CustomerData{NLSScore: 2, Feedback: "terrible", CommentSentiment: "NEGATIVE", AccountSignals: collapsing})
+	if prediction.ChurnProbability != 0.8 {
+		t.Errorf("Expected a higher score to be kept, got %v", prediction.ChurnProbability)
+	}
+
+	days, tenure := 45, 30
+	features := ChurnFeatures(CustomerData{NLSScore: 9, AccountSignals: &AccountSignals{
+		Usage:      &UsageSignals{LoginChange: &drop, FeaturesAdopted: 4},
+		Attributes: &AttributeSignals{DaysToRenewal: &days, TenureMonths: &tenure},
+	}})
+	if features["usage_collapse"] != 1 || features["login_change"] != drop || features["features_adopted"] != 4 ||
+		features["renewal_soon"] != 1 || features["tenure_years"] != 2.5 {
+		t.Errorf("Unexpected account features %v", features)
+	}
+}
+
+// TestDecodeUsageRequest tests usage and account attribute validation.
+func TestDecodeUsageRequest(t *testing.T) {
+	req, apiErr := DecodeUsageRequest(strings.NewReader(`{"account_id": "acct_1", "period_start": "2026-10-01", "logins": 3, "active_seats": 2,
+		"feature_adoption": [{"feature": "exports", "count": 5}]}`))
+	if apiErr != nil {
+		t.Fatalf("Expected a valid request, got %v %+v", apiErr, apiErr.Errors)
+	}
+	if usage := req.Usage(); usage.FeatureAdoption["exports"] != 5 || usage.Logins != 3 {
+		t.Errorf("Unexpected usage row %+v", usage)
+	}
+	tests := []struct {
+		body  string
+		field string
+	}{
+		{`{"account_id": "acct_1", "period_start": "2026-10-01", "active_seats": 2}`, "logins"},
+		{`{"account_id": "acct_1", "period_start": "October", "logins": 3, "active_seats": 2}`, "period_start"},
+		{`{"account_id": "acct_1", "period_start": "2026-10-01", "logins": 3, "active_seats": 2,
+			"feature_adoption": [{"feature": "api", "count": 1}, {"feature": "api", "count": 2}]}`, "feature_adoption[1].feature"},
+	}
+	for _, tt := range tests {
+		if _, apiErr := DecodeUsageRequest(strings.NewReader(tt.body)); apiErr == nil || apiErr.Field != tt.field {
+			t.Errorf("Expected %s to fail on %s, got %v", tt.body, tt.field, apiErr)
+		}
+	}
+
+	account, apiErr := DecodeAccountRequest(strings.NewReader(`{"account_id": "acct_1", "mrr": 99.5}`))
+	if apiErr != nil || account.MRR == nil || *account.MRR != 99.5 || account.Plan != nil {
+		t.Errorf("Expected only mrr to be set, got %+v, %v", account, apiErr)
+	}
+	if _, apiErr := DecodeAccountRequest(strings.NewReader(`{"account_id": "acct_1", "renewal_date": "2026-13-01"}`)); apiErr == nil || apiErr.Field != "renewal_date" {
+		t.Errorf("Expected an invalid renewal_date to be rejected, got %v", apiErr)
+	}
+}
@@ -22,33 +22,105 @@ import (
 type ApiPredictRequest struct {
 	NLSScore     *int   `json:"nls_score"`
 	FeedbackText string `json:"feedback_text"`
+	AccountID    string `json:"account_id,omitempty"`   // Optional stable customer identifier, used for data subject requests.
+	ProductLine  string `json:"product_line,omitempty"` // Selects the topic set in TopicTaxonomy; defaults to DefaultProductLine.
+	CallbackURL  string `json:"callback_url,omitempty"` // Async requests only: where the finished job is POSTed.
+
+	// FeedbackID, if set, is the ID the feedback is stored under, so that
+	// processing the same request twice stores it once (see ErrFeedbackExists).
+	FeedbackID string `json:"-"`
 }
 
 // ApiResponse defines the structure for successful /predict endpoint responses.
 // Similar to ApiPredictRequest, might be better in `api` if only used there.
 type ApiResponse struct {
-	CustomerID       string   `json:"customer_id"`
-	ChurnProbability float64  `json:"churn_probability"`
-	Reason           string   `json:"reason"`
-	CommentSentiment string   `json:"comment_sentiment,omitempty"`
-	CommentTopics    []string `json:"comment_topics,omitempty"`
+	CustomerID          string           `json:"customer_id"`
+	AccountID           string           `json:"account_id,omitempty"`
+	ChurnProbability    float64          `json:"churn_probability"`
+	RawChurnProbability *float64         `json:"raw_churn_probability,omitempty"`
+	CalibrationVersion  string           `json:"calibration_version,omitempty"`
+	Reason              string           `json:"reason"`
+	CommentSentiment    string           `json:"comment_sentiment,omitempty"`
+	CommentTopics       []string         `json:"comment_topics,omitempty"`
+	TopicCategories     []string         `json:"topic_categories,omitempty"`
+	TopicSentiments     []TopicSentiment `json:"topic_sentiments,omitempty"`
+	ProductLine         string           `json:"product_line,omitempty"`
+	TaxonomyVersion     string           `json:"taxonomy_version,omitempty"`
+	Language            string           `json:"language,omitempty"`
+	Emotions            []string         `json:"emotions,omitempty"`
+	CancelIntent        bool             `json:"cancel_intent"`
+	Urgency             string           `json:"urgency,omitempty"`
+	ModelName           string           `json:"model_name"`
+	ModelVersion        string           `json:"model_version"`
+	RulesVersion        string           `json:"rules_version,omitempty"`
+	SentimentModel      string           `json:"sentiment_model,omitempty"`
+	ZeroShotModel       string           `json:"zero_shot_model,omitempty"`
 }
 
 type CustomerData struct {
-	ID               string    `json:"id,omitempty"`
-	NLSScore         int       `json:"nls_score"`
-	Feedback         string    `json:"feedback_text"`
-	CreatedAt        time.Time `json:"created_at,omitempty"`
-	CommentSentiment string    `json:"comment_sentiment,omitempty"`
-	CommentTopics    []string  `json:"comment_topics,omitempty"`
+	ID               string           `json:"id,omitempty"`
+	AccountID        string           `json:"account_id,omitempty"`
+	NLSScore         int              `json:"nls_score"`
+	Feedback         string           `json:"feedback_text"`
+	CreatedAt        time.Time        `json:"created_at,omitempty"`
+	CommentSentiment string           `json:"comment_sentiment,omitempty"`
+	CommentTopics    []string         `json:"comment_topics,omitempty"`
+	TopicCategories  []string         `json:"topic_categories,omitempty"`
+	TopicSentiments  []TopicSentiment `json:"topic_sentiments,omitempty"`
+	ProductLine      string           `json:"product_line,omitempty"`
+	TaxonomyVersion  string           `json:"taxonomy_version,omitempty"`
+	Language         string           `json:"language,omitempty"`
+	TranslatedBy     string           `json:"translated_by,omitempty"`
+	Emotions         []string         `json:"emotions,omitempty"`
+	CancelIntent     bool             `json:"cancel_intent"`
+	Urgency          string           `json:"urgency,omitempty"`
+	IntentSignals    []string         `json:"intent_signals,omitempty"`
+	Redactions       []Redaction      `json:"redactions,omitempty"`
+
+	EnrichmentScores *EnrichmentScores `json:"enrichment_scores,omitempty"`
+
+	// Source is SignalSourceSurvey (the default) or SignalSourceTicket; tickets
+	// have no NLS score and carry their ticket fields in Ticket.
+	Source         string          `json:"source,omitempty"`
+	Ticket         *TicketDetails  `json:"ticket,omitempty"`
+	AccountSignals *AccountSignals `json:"account_signals,omitempty"` // account features looked up when the signal was scored
+}
+
+// MarshalJSON writes Source as SignalSourceSurvey when it is unset, and
+// nls_score as null for tickets, which have none.
+func (d CustomerData) MarshalJSON() ([]byte, error) {
+	type customerData CustomerData
+	row := struct {
+		customerData
+		NLSScore *int `json:"nls_score"`
+	}{customerData: customerData(d)}
+	if row.Source == "" {
+		row.Source = SignalSourceSurvey
+	}
+	if row.Source != SignalSourceTicket {
+		row.NLSScore = &d.NLSScore
+	}
+	return json.Marshal(row)
 }
 
 type ChurnPrediction struct {
-	ID               string    `json:"id,omitempty"`
-	CustomerID       string    `json:"customer_feedback_id"`
-	ChurnProbability float64   `json:"churn_probability"`
-	Reason           string    `json:"reason"`
-	PredictedAt      time.Time `json:"predicted_at,omitempty"`
+	ID                  string    `json:"id,omitempty"`
+	CustomerID          string    `json:"customer_feedback_id"`
+	ChurnProbability    float64   `json:"churn_probability"`               // calibrated, see CalibratePrediction
+	RawChurnProbability *float64  `json:"raw_churn_probability,omitempty"` // model output before calibration
+	CalibrationVersion  string    `json:"calibration_version,omitempty"`
+	Reason              string    `json:"reason"`
+	PredictedAt         time.Time `json:"predicted_at,omitempty"`
+
+	// What produced the score, so predictions from different logic can be told apart.
+	ModelName       string `json:"model_name,omitempty"`
+	ModelVersion    string `json:"model_version,omitempty"`
+	RulesVersion    string `json:"rules_version,omitempty"` // version of the negative keyword set
+	TaxonomyVersion string `json:"taxonomy_version,omitempty"`
+	SentimentModel  string `json:"sentiment_model,omitempty"`
+	ZeroShotModel   string `json:"zero_shot_model,omitempty"` // topics and emotions
+	Shadow          bool   `json:"shadow"`                    // scored for evaluation only, never returned to the client
+	RescoreRunID    string `json:"rescore_run_id,omitempty"`  // set when written by a rescore run rather than at prediction time
 }
 
 type HFSentimentRequest struct {
@@ -66,8 +138,9 @@ type HFZeroShotRequest struct {
 }
 
 type HFZeroShotParams struct {
-	CandidateLabels []string `json:"candidate_labels"`
-	MultiLabel      bool     `json:"multi_label"`
+	CandidateLabels    []string `json:"candidate_labels"`
+	MultiLabel         bool     `json:"multi_label"`
+	HypothesisTemplate string   `json:"hypothesis_template,omitempty"`
 }
 
 type HFZeroShotResponse struct {
@@ -76,6 +149,12 @@ type HFZeroShotResponse struct {
 	Scores   []float64 `json:"scores"`
 }
 
+// LabelScore is a model label with its confidence score.
+type LabelScore struct {
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+}
+
 // --- Global Variables and Constants ---
 
 // SupabaseClient needs to be initialized and set, e.g., by a main package or an Init function.
@@ -91,16 +170,13 @@ const (
 
 // --- Helper Functions for HTTP responses (Exported) ---
 
-func RespondWithError(w http.ResponseWriter, code int, message string) {
-	RespondWithJSON(w, code, map[string]string{"error": message})
-}
-
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("Error marshalling JSON response: %v", err)
+		w.Header().Set("Content-Type", ProblemContentType)
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "Internal server error during JSON marshalling"}`))
+		w.Write([]byte(`{"type":"/problems/internal_error","title":"Internal server error","status":500,"code":"internal_error","detail":"Error marshalling JSON response."}`))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -164,47 +240,102 @@ func GetSentimentFromHF(feedbackText string) (string, error) {
 	if strings.TrimSpace(feedbackText) == "" {
 		return "NEUTRAL", nil
 	}
+	scores, err := GetSentimentScoresFromHF(feedbackText)
+	if err != nil {
+		return "UNKNOWN", err
+	}
+	best, ok := BestLabel(scores)
+	if !ok {
+		return "NEUTRAL", nil
+	}
+	return best.Label, nil
+}
+
+// GetSentimentScoresFromHF returns every sentiment label with its confidence.
+// Empty text is not sent to the model and yields no scores.
+func GetSentimentScoresFromHF(feedbackText string) ([]LabelScore, error) {
+	return GetSentimentScoresWithModel(SentimentModelID, feedbackText)
+}
+
+// GetSentimentScoresWithModel is GetSentimentScoresFromHF for a specific model.
+// Labels are normalized to POSITIVE, NEGATIVE or NEUTRAL where recognizable.
+func GetSentimentScoresWithModel(modelID, feedbackText string) ([]LabelScore, error) {
+	if strings.TrimSpace(feedbackText) == "" {
+		return []LabelScore{}, nil
+	}
 	requestPayload := HFSentimentRequest{Inputs: feedbackText}
-	responseBody, err := CallHuggingFaceAPI(SentimentModelID, requestPayload)
+	responseBody, err := callHuggingFaceCached(modelID, feedbackText, nil, requestPayload)
 	if err != nil {
-		return "UNKNOWN", fmt.Errorf("sentiment API call failed: %w", err)
+		return nil, fmt.Errorf("sentiment API call failed: %w", err)
 	}
 
 	var sentimentResponse HFSentimentResponse
 	if err := json.Unmarshal(responseBody, &sentimentResponse); err != nil {
 		log.Printf("Error unmarshalling sentiment response: %s. Body: %s", err, string(responseBody))
-		return "UNKNOWN", fmt.Errorf("error unmarshalling sentiment response: %w", err)
+		return nil, fmt.Errorf("error unmarshalling sentiment response: %w", err)
 	}
 
 	if len(sentimentResponse) == 0 || len(sentimentResponse[0]) == 0 {
 		log.Printf("Sentiment response format unexpected or empty. Body: %s", string(responseBody))
-		return "UNKNOWN", fmt.Errorf("sentiment response format unexpected or empty")
+		return nil, fmt.Errorf("sentiment response format unexpected or empty")
 	}
 
-	highestScore := 0.0
-	bestLabel := "NEUTRAL"
+	scores := make([]LabelScore, 0, len(sentimentResponse[0]))
 	for _, labelScorePair := range sentimentResponse[0] {
-		if labelScorePair.Score > highestScore {
-			highestScore = labelScorePair.Score
-			bestLabel = labelScorePair.Label
+		scores = append(scores, LabelScore{Label: NormalizeSentimentLabel(labelScorePair.Label), Score: labelScorePair.Score})
+	}
+	return scores, nil
+}
+
+// BestLabel returns the highest-scoring label, or false if there are no scores.
+func BestLabel(scores []LabelScore) (LabelScore, bool) {
+	if len(scores) == 0 {
+		return LabelScore{}, false
+	}
+	best := scores[0]
+	for _, ls := range scores[1:] {
+		if ls.Score > best.Score {
+			best = ls
 		}
 	}
-	return bestLabel, nil
+	return best, true
 }
 
 func GetTopicsFromHF(feedbackText string, candidateTopics []string) ([]string, error) {
-	if strings.TrimSpace(feedbackText) == "" || len(candidateTopics) == 0 {
-		return []string{}, nil
+	scores, err := GetZeroShotScoresFromHF(feedbackText, candidateTopics, "")
+	if err != nil {
+		return nil, err
+	}
+	var extractedTopics []string
+	for _, ls := range scores {
+		if ls.Score > TopicScoreThreshold {
+			extractedTopics = append(extractedTopics, ls.Label)
+		}
+	}
+	return extractedTopics, nil
+}
+
+// GetZeroShotScoresFromHF scores every candidate label against the text (multi-label),
+// optionally with a custom hypothesis template such as "This feedback is about {}.".
+func GetZeroShotScoresFromHF(feedbackText string, candidateLabels []string, hypothesisTemplate string) ([]LabelScore, error) {
+	return GetZeroShotScoresWithModel(ZeroShotModelID, feedbackText, candidateLabels, hypothesisTemplate)
+}
+
+// GetZeroShotScoresWithModel is GetZeroShotScoresFromHF for a specific model.
+func GetZeroShotScoresWithModel(modelID, feedbackText string, candidateLabels []string, hypothesisTemplate string) ([]LabelScore, error) {
+	if strings.TrimSpace(feedbackText) == "" || len(candidateLabels) == 0 {
+		return []LabelScore{}, nil
 	}
 
 	requestPayload := HFZeroShotRequest{
 		Inputs: feedbackText,
 		Parameters: HFZeroShotParams{
-			CandidateLabels: candidateTopics,
-			MultiLabel:      true,
+			CandidateLabels:    candidateLabels,
+			MultiLabel:         true,
+			HypothesisTemplate: hypothesisTemplate,
 		},
 	}
-	responseBody, err := CallHuggingFaceAPI(ZeroShotModelID, requestPayload)
+	responseBody, err := callHuggingFaceCached(modelID, feedbackText, candidateLabels, requestPayload, hypothesisTemplate)
 	if err != nil {
 		return nil, fmt.Errorf("topic extraction API call failed: %w", err)
 	}
@@ -215,37 +346,72 @@ func GetTopicsFromHF(feedbackText string, candidateTopics []string) ([]string, e
 		return nil, fmt.Errorf("error unmarshalling zero-shot response: %w", err)
 	}
 
-	var extractedTopics []string
+	var scores []LabelScore
 	if len(zeroShotResponse.Labels) > 0 && len(zeroShotResponse.Scores) == len(zeroShotResponse.Labels) {
 		for i, label := range zeroShotResponse.Labels {
-			if zeroShotResponse.Scores[i] > TopicScoreThreshold {
-				extractedTopics = append(extractedTopics, label)
-			}
+			scores = append(scores, LabelScore{Label: label, Score: zeroShotResponse.Scores[i]})
 		}
 	} else {
 		log.Printf("Zero-shot response format unexpected or empty. Body: %s", string(responseBody))
 	}
-	return extractedTopics, nil
+	return scores, nil
 }
 
 // --- Business Logic Functions (Exported) ---
 
 func PredictChurn(data CustomerData) ChurnPrediction {
-	prediction := ChurnPrediction{}
-	negativeKeywords := []string{"bad", "poor", "terrible", "unhappy"}
-	hasNegativeFeedback := false
-	if data.Feedback != "" {
-		for _, keyword := range negativeKeywords {
-			if strings.Contains(strings.ToLower(data.Feedback), keyword) {
-				hasNegativeFeedback = true
-				break
-			}
-		}
+	prediction := ChurnPrediction{
+		ModelName:       RulesModelName,
+		ModelVersion:    RulesModelVersion,
+		RulesVersion:    NegativeFeedbackMatcher.Version(),
+		TaxonomyVersion: data.TaxonomyVersion,
+	}
+	if data.EnrichmentScores != nil {
+		prediction.SentimentModel = data.EnrichmentScores.SentimentModel
+		prediction.ZeroShotModel = data.EnrichmentScores.TopicModel
 	}
+	// Whole-word, stemmed matching: "not bad at all" and "badge" don't count, and
+	// downtoned matches ("a bit poor") only count in combination.
+	hasNegativeFeedback := data.Feedback != "" && AffirmedWeight(NegativeFeedbackMatcher.Match(data.Feedback)) >= 1
 	isNegativeSentiment := strings.ToUpper(data.CommentSentiment) == "NEGATIVE"
-	if (data.NLSScore < 5 && hasNegativeFeedback) || (data.NLSScore < 3 && isNegativeSentiment) {
+	negativeAspects := NegativeChurnAspects(data.TopicSentiments)
+	negativeEmotions := intersectStrings(data.Emotions, NegativeEmotions)
+	tickets := data.AccountSignals.TicketSummary()
+	if data.CancelIntent {
+		// Explicit cancellation or refund language overrides the NLS buckets.
+		prediction.ChurnProbability = 0.9
+		prediction.Reason = "Explicit cancellation intent (" + strings.Join(data.IntentSignals, ", ") + ")."
+	} else if tickets.NegativeTickets >= NegativeTicketThreshold {
+		// Repeated frustration with support outweighs a single survey score.
+		prediction.ChurnProbability = 0.8
+		prediction.Reason = fmt.Sprintf("%d negative support tickets in the last %d days.", tickets.NegativeTickets, int(TicketSignalWindow.Hours()/24))
+	} else if data.Source == SignalSourceTicket {
+		// Tickets have no NLS score; they are scored on the ticket text and fields.
+		negativeTicket := isNegativeSentiment || hasNegativeFeedback
+		switch {
+		case negativeTicket && data.Ticket.Escalated():
+			prediction.ChurnProbability = 0.8
+			prediction.Reason = "Negative support ticket with high priority, reopens or slow resolution."
+		case negativeTicket || len(negativeAspects) > 0 || len(negativeEmotions) > 0:
+			prediction.ChurnProbability = 0.6
+			prediction.Reason = "Negative support ticket."
+		default:
+			prediction.ChurnProbability = 0.4
+			prediction.Reason = "Support ticket without negative signals."
+		}
+	} else if (data.NLSScore < 5 && hasNegativeFeedback) || (data.NLSScore < 3 && isNegativeSentiment) {
 		prediction.ChurnProbability = 0.8
 		prediction.Reason = "Low NLS score and/or negative feedback/sentiment."
+	} else if data.NLSScore < 8 && len(negativeAspects) > 0 {
+		// A comment can be positive overall yet negative about pricing or support.
+		prediction.ChurnProbability = 0.6
+		prediction.Reason = "Negative sentiment about " + strings.Join(negativeAspects, " and ") + "."
+	} else if data.NLSScore < 8 && len(negativeEmotions) > 0 {
+		prediction.ChurnProbability = 0.6
+		prediction.Reason = "Negative emotion (" + strings.Join(negativeEmotions, ", ") + ")."
+	} else if data.NLSScore < 8 && containsString(data.IntentSignals, "competitor") {
+		prediction.ChurnProbability = 0.6
+		prediction.Reason = "Mentions switching to a competitor."
 	} else if data.NLSScore >= 8 {
 		prediction.ChurnProbability = 0.1
 		prediction.Reason = "High NLS score."
@@ -253,6 +419,11 @@ func PredictChurn(data CustomerData) ChurnPrediction {
 		prediction.ChurnProbability = 0.4
 		prediction.Reason = "Moderate NLS score or neutral feedback/sentiment."
 	}
+	// Collapsing usage catches silent churners whose survey answers or tickets look fine.
+	if usage := data.AccountSignals.UsageSummary(); usage.Collapsing() && prediction.ChurnProbability < 0.6 {
+		prediction.ChurnProbability = 0.6
+		prediction.Reason = fmt.Sprintf("Logins down %.0f%% from previous periods.", -*usage.LoginChange*100)
+	}
 	prediction.PredictedAt = time.Now()
 	return prediction
 }
@@ -285,13 +456,20 @@ func StoreCustomerData(data CustomerData) (string, error) {
 }
 
 func StoreChurnPrediction(prediction ChurnPrediction) error {
+	return StoreChurnPredictions([]ChurnPrediction{prediction})
+}
+
+// StoreChurnPredictions stores a primary prediction and its shadow predictions in one insert.
+func StoreChurnPredictions(predictions []ChurnPrediction) error {
 	if SupabaseClient == nil {
 		return fmt.Errorf("SupabaseClient not initialized in appcore")
 	}
-	if prediction.PredictedAt.IsZero() {
-		prediction.PredictedAt = time.Now()
+	for i := range predictions {
+		if predictions[i].PredictedAt.IsZero() {
+			predictions[i].PredictedAt = time.Now()
+		}
 	}
-	rawData, count, err := SupabaseClient.From("churn_predictions").Insert(prediction, false, "", "", "").Execute()
+	rawData, count, err := SupabaseClient.From("churn_predictions").Insert(predictions, false, "", "", "").Execute()
 	if err != nil {
 		log.Printf("Raw error from Supabase (prediction): %#v\n", err)
 		log.Printf("Type of error (prediction): %T\n", err)
@@ -321,10 +499,59 @@ func InitClients() error {
 	}
 
 	var err error
+	FeedbackRedactor, err = NewRedactorFromEnv()
+	if err != nil {
+		return fmt.Errorf("error configuring PII redaction: %w", err)
+	}
+
 	SupabaseClient, err = supabase.NewClient(envSupabaseURL, envSupabaseKey, nil)
 	if err != nil {
 		return fmt.Errorf("error initializing Supabase client: %w", err)
 	}
+
+	if err := ConfigureCalibration(); err != nil {
+		return fmt.Errorf("error loading churn calibration: %w", err)
+	}
+
+	if err := ConfigureKeywordMatcher(); err != nil {
+		return fmt.Errorf("error configuring keyword matcher: %w", err)
+	}
+
+	if err := ConfigureLanguageSupport(); err != nil {
+		return fmt.Errorf("error configuring language support: %w", err)
+	}
+
+	if err := ConfigureTopicTaxonomy(); err != nil {
+		return fmt.Errorf("error loading topic taxonomy: %w", err)
+	}
+
+	if err := ConfigureChurnModels(); err != nil {
+		return fmt.Errorf("error configuring churn models: %w", err)
+	}
+
+	if err := ConfigureDriftMonitor(); err != nil {
+		return fmt.Errorf("error configuring drift monitor: %w", err)
+	}
+
+	if err := ConfigureWebhooks(); err != nil {
+		return fmt.Errorf("error configuring webhooks: %w", err)
+	}
+
+	if err := ConfigureOutbox(); err != nil {
+		return fmt.Errorf("error configuring event outbox: %w", err)
+	}
+
+	if err := ConfigureJobRunner(); err != nil {
+		return fmt.Errorf("error configuring prediction job runner: %w", err)
+	}
+
+	if err := ConfigureEnrichmentCache(); err != nil {
+		return fmt.Errorf("error configuring enrichment cache: %w", err)
+	}
+
+	if err := ConfigureIngestSources(); err != nil {
+		return fmt.Errorf("error configuring survey ingest sources: %w", err)
+	}
 	log.Println("Supabase client initialized successfully in appcore.")
 	return nil
 }
@@ -0,0 +1,128 @@
+package appcore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TopicSentiment is the sentiment of the clauses of a comment that are about one topic.
+type TopicSentiment struct {
+	Topic     string  `json:"topic"`
+	Sentiment string  `json:"sentiment"`
+	Score     float64 `json:"score"`
+}
+
+// AspectRelevanceThreshold is the minimum zero-shot score for a clause to count
+// as being about a topic (when it does not mention the topic or a synonym).
+const AspectRelevanceThreshold = 0.5
+
+// MaxAspectClauses caps the clauses analysed per comment; each costs up to two
+// Hugging Face calls. Later clauses are ignored.
+const MaxAspectClauses = 8
+
+// ChurnAspectTopics are the topics whose negative sentiment is a churn feature.
+var ChurnAspectTopics = []string{"pricing", "customer support", "billing errors"}
+
+var (
+	sentenceBoundary = regexp.MustCompile(`[.!?;\n]+`)
+	// Contrastive conjunctions usually switch aspect: "love the product, but hate the pricing".
+	clauseBoundary = regexp.MustCompile(`(?i),|\s+(?:but|however|although|though|whereas|yet)\s+`)
+)
+
+// SplitClauses splits a comment into sentences and then into clauses at commas
+// and contrastive conjunctions, dropping empty pieces.
+func SplitClauses(text string) []string {
+	var clauses []string
+	for _, sentence := range sentenceBoundary.Split(text, -1) {
+		for _, clause := range clauseBoundary.Split(sentence, -1) {
+			if clause = strings.TrimSpace(clause); clause != "" {
+				clauses = append(clauses, clause)
+			}
+		}
+	}
+	return clauses
+}
+
+// ClauseTopics returns which of the given topics a clause is about: those whose
+// name or a synonym appears in it, or whose zero-shot score for the clause is at
+// least AspectRelevanceThreshold.
+func (t *Taxonomy) ClauseTopics(clause, productLine string, topics []string, scores []LabelScore) []string {
+	scoreByLabel := make(map[string]float64, len(scores))
+	for _, ls := range scores {
+		scoreByLabel[ls.Label] = ls.Score
+	}
+	var relevant []string
+	for _, topic := range t.topicsFor(productLine) {
+		if !containsString(topics, topic.Name) {
+			continue
+		}
+		mentioned := mentionsAny(clause, append([]string{topic.Name}, topic.Synonyms...))
+		if mentioned || scoreByLabel[topic.label()] >= AspectRelevanceThreshold {
+			relevant = append(relevant, topic.Name)
+		}
+	}
+	return relevant
+}
+
+// ExtractTopicSentiments runs the sentiment model on the clauses relevant to each
+// extracted topic. A single-clause comment gives every topic its overall
+// sentiment. Topics without a relevant clause are omitted.
+func (t *Taxonomy) ExtractTopicSentiments(feedbackText, productLine string, topics []string, models LanguageModels) ([]TopicSentiment, error) {
+	if len(topics) == 0 {
+		return []TopicSentiment{}, nil
+	}
+	clauses := SplitClauses(feedbackText)
+	if len(clauses) > MaxAspectClauses {
+		clauses = clauses[:MaxAspectClauses]
+	}
+
+	clausesByTopic := map[string][]string{}
+	if len(clauses) <= 1 {
+		for _, topic := range topics {
+			clausesByTopic[topic] = clauses
+		}
+	} else {
+		var labels []string
+		for _, topic := range t.topicsFor(productLine) {
+			if containsString(topics, topic.Name) {
+				labels = append(labels, topic.label())
+			}
+		}
+		for _, clause := range clauses {
+			scores, err := GetZeroShotScoresWithModel(models.ZeroShot, clause, labels, t.HypothesisTemplate)
+			if err != nil {
+				return nil, err
+			}
+			for _, topic := range t.ClauseTopics(clause, productLine, topics, scores) {
+				clausesByTopic[topic] = append(clausesByTopic[topic], clause)
+			}
+		}
+	}
+
+	result := []TopicSentiment{}
+	for _, topic := range topics {
+		relevant := clausesByTopic[topic]
+		if len(relevant) == 0 {
+			continue
+		}
+		scores, err := GetSentimentScoresWithModel(models.Sentiment, strings.Join(relevant, ". "))
+		if err != nil {
+			return nil, err
+		}
+		if best, ok := BestLabel(scores); ok {
+			result = append(result, TopicSentiment{Topic: topic, Sentiment: best.Label, Score: best.Score})
+		}
+	}
+	return result, nil
+}
+
+// NegativeChurnAspects returns the ChurnAspectTopics with negative sentiment.
+func NegativeChurnAspects(topicSentiments []TopicSentiment) []string {
+	var negative []string
+	for _, ts := range topicSentiments {
+		if strings.ToUpper(ts.Sentiment) == "NEGATIVE" && containsString(ChurnAspectTopics, ts.Topic) {
+			negative = append(negative, ts.Topic)
+		}
+	}
+	return negative
+}
@@ -0,0 +1,59 @@
+package appcore
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSplitClauses tests splitting on sentences, commas and contrastive conjunctions.
+func TestSplitClauses(t *testing.T) {
+	got := SplitClauses("Love the product, hate the pricing. Support was fine but slow!")
+	expected := []string{"Love the product", "hate the pricing", "Support was fine", "slow"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected clauses %v, got %v", expected, got)
+	}
+	if got := SplitClauses("  ...  "); len(got) != 0 {
+		t.Errorf("Expected no clauses for punctuation only, got %v", got)
+	}
+}
+
+// TestClauseTopics tests mention-based and score-based clause relevance.
+func TestClauseTopics(t *testing.T) {
+	taxonomy := &Taxonomy{Version: "v1", DefaultThreshold: 0.8, ProductLines: map[string][]TopicDefinition{
+		DefaultProductLine: {
+			{Name: "pricing", Description: "price and cost", Synonyms: []string{"expensive"}},
+			{Name: "product quality"},
+		},
+	}}
+	topics := []string{"pricing", "product quality"}
+	if got := taxonomy.ClauseTopics("way too expensive", DefaultProductLine, topics, nil); strings.Join(got, ",") != "pricing" {
+		t.Errorf("Expected synonym mention to select pricing, got %v", got)
+	}
+	scores := []LabelScore{{Label: "price and cost", Score: 0.2}, {Label: "product quality", Score: 0.6}}
+	if got := taxonomy.ClauseTopics("love the thing", DefaultProductLine, topics, scores); strings.Join(got, ",") != "product quality" {
+		t.Errorf("Expected zero-shot score to select product quality, got %v", got)
+	}
+}
+
+// TestPredictChurn_NegativePricingAspect tests negative pricing sentiment as a churn feature.
+func TestPredictChurn_NegativePricingAspect(t *testing.T) {
+	customerData := CustomerData{
+		NLSScore:         6,
+		Feedback:         "love the product, hate the pricing",
+		CommentSentiment: "POSITIVE",
+		TopicSentiments: []TopicSentiment{
+			{Topic: "product quality", Sentiment: "POSITIVE", Score: 0.99},
+			{Topic: "pricing", Sentiment: "NEGATIVE", Score: 0.98},
+		},
+	}
+	prediction := PredictChurn(customerData)
+	if prediction.ChurnProbability != 0.6 || !strings.Contains(prediction.Reason, "pricing") {
+		t.Errorf("Expected 0.6 with a pricing reason, got %v (%s)", prediction.ChurnProbability, prediction.Reason)
+	}
+
+	// High NLS still wins over aspect sentiment.
+	customerData.NLSScore = 9
+	if prediction := PredictChurn(customerData); prediction.ChurnProbability != 0.1 {
+		t.Errorf("Expected 0.1 for high NLS, got %v", prediction.ChurnProbability)
+	}
+}
@@ -0,0 +1,267 @@
+package appcore
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// EnrichmentCache stores raw Hugging Face responses so identical (or trivially
+// different) feedback texts are not sent to HF again.
+type EnrichmentCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// HFCache is the cache used by the enrichment calls; nil disables caching.
+// It is configured from the environment by InitClients.
+var HFCache EnrichmentCache
+
+// HFCacheTTL is how long cached enrichment results stay valid.
+var HFCacheTTL = 24 * time.Hour
+
+// DefaultEnrichmentCacheBackend is used when ENRICHMENT_CACHE is unset. It is "off"
+// for serverless functions; cmd/server sets it to "memory" before InitClients.
+var DefaultEnrichmentCacheBackend = "off"
+
+const defaultEnrichmentCacheSize = 10000
+
+var (
+	cacheHits   = NewCounter("churn_enrichment_cache_hits_total", "Hugging Face enrichment results served from cache.")
+	cacheMisses = NewCounter("churn_enrichment_cache_misses_total", "Hugging Face enrichment lookups not found in cache.")
+	cacheErrors = NewCounter("churn_enrichment_cache_errors_total", "Enrichment cache reads or writes that failed.")
+)
+
+// NormalizeFeedbackText lowercases, collapses whitespace and trims surrounding
+// punctuation so that "Great service!" and "great  service" share a cache entry.
+func NormalizeFeedbackText(text string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	return strings.TrimFunc(normalized, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+}
+
+// EnrichmentCacheKey derives a cache key from the normalized text, the model
+// and (for zero-shot) the candidate labels, independent of label order.
+// Extra parts (e.g. a hypothesis template) are included verbatim. The key
+// starts with the text's EnrichmentTextHash and a colon.
+func EnrichmentCacheKey(modelID, text string, labels []string, extra ...string) string {
+	sortedLabels := append([]string(nil), labels...)
+	sort.Strings(sortedLabels)
+	h := sha256.New()
+	h.Write([]byte(modelID))
+	h.Write([]byte{0})
+	h.Write([]byte(NormalizeFeedbackText(text)))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(sortedLabels, "\x1f")))
+	for _, part := range extra {
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return EnrichmentTextHash(text) + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// EnrichmentTextHash identifies the cached responses for a text, whatever the
+// model, so they can be erased with the feedback (see EraseCustomerData).
+func EnrichmentTextHash(text string) string {
+	sum := sha256.Sum256([]byte(NormalizeFeedbackText(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheKeyTextHash returns the EnrichmentTextHash a cache key starts with.
+func cacheKeyTextHash(key string) string {
+	textHash, _, _ := strings.Cut(key, ":")
+	return textHash
+}
+
+// callHuggingFaceCached wraps CallHuggingFaceAPI with HFCache. Only successful
+// responses are cached.
+func callHuggingFaceCached(modelID, text string, labels []string, requestBody interface{}, extraKey ...string) ([]byte, error) {
+	if HFCache == nil {
+		return CallHuggingFaceAPI(modelID, requestBody)
+	}
+	key := EnrichmentCacheKey(modelID, text, labels, extraKey...)
+	if cached, ok := HFCache.Get(key); ok {
+		cacheHits.Inc()
+		return cached, nil
+	}
+	cacheMisses.Inc()
+
+	responseBody, err := CallHuggingFaceAPI(modelID, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	HFCache.Set(key, responseBody, HFCacheTTL)
+	return responseBody, nil
+}
+
+// --- In-memory LRU (cmd/server) ---
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRUCache is a size-bounded, TTL-aware in-memory EnrichmentCache.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front = most recently used
+	entries  map[string]*list.Element
+}
+
+// NewLRUCache returns an LRUCache holding at most capacity entries.
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity <= 0 {
+		capacity = defaultEnrichmentCacheSize
+	}
+	return &LRUCache{capacity: capacity, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value, entry.expiresAt = value, time.Now().Add(ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// DeleteTexts removes the entries for the given EnrichmentTextHash values and
+// returns how many were removed.
+func (c *LRUCache) DeleteTexts(textHashes map[string]bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, elem := range c.entries {
+		if textHashes[cacheKeyTextHash(key)] {
+			c.order.Remove(elem)
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len returns the number of cached entries (including expired ones not yet evicted).
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// --- Supabase-backed cache (shared across serverless instances) ---
+
+// SupabaseCache stores entries in the enrichment_cache table so every serverless
+// instance shares them. Failures are logged and treated as misses.
+type SupabaseCache struct{}
+
+type enrichmentCacheRow struct {
+	CacheKey  string          `json:"cache_key"`
+	TextHash  string          `json:"text_hash"`
+	Response  json.RawMessage `json:"response"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+func (SupabaseCache) Get(key string) ([]byte, bool) {
+	if SupabaseClient == nil {
+		return nil, false
+	}
+	rawData, _, err := SupabaseClient.From("enrichment_cache").Select("response", "", false).
+		Eq("cache_key", key).Gt("expires_at", time.Now().UTC().Format(time.RFC3339)).Limit(1, "").Execute()
+	if err != nil {
+		cacheErrors.Inc()
+		log.Printf("Warning: enrichment cache read failed: %v", err)
+		return nil, false
+	}
+	var rows []enrichmentCacheRow
+	if err := json.Unmarshal(rawData, &rows); err != nil || len(rows) == 0 {
+		return nil, false
+	}
+	return rows[0].Response, true
+}
+
+func (SupabaseCache) Set(key string, value []byte, ttl time.Duration) {
+	if SupabaseClient == nil {
+		return
+	}
+	row := enrichmentCacheRow{CacheKey: key, TextHash: cacheKeyTextHash(key), Response: value, ExpiresAt: time.Now().Add(ttl)}
+	if _, _, err := SupabaseClient.From("enrichment_cache").Insert(row, true, "cache_key", "minimal", "").Execute(); err != nil {
+		cacheErrors.Inc()
+		log.Printf("Warning: enrichment cache write failed: %v", err)
+	}
+}
+
+// ConfigureEnrichmentCache sets HFCache from ENRICHMENT_CACHE ("memory", "supabase"
+// or "off"; default DefaultEnrichmentCacheBackend), ENRICHMENT_CACHE_SIZE (memory
+// only) and ENRICHMENT_CACHE_TTL (a Go duration such as "24h").
+func ConfigureEnrichmentCache() error {
+	if raw := strings.TrimSpace(os.Getenv("ENRICHMENT_CACHE_TTL")); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("ENRICHMENT_CACHE_TTL must be a positive duration such as 24h, got %q", raw)
+		}
+		HFCacheTTL = ttl
+	}
+
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("ENRICHMENT_CACHE")))
+	if backend == "" {
+		backend = DefaultEnrichmentCacheBackend
+	}
+	switch backend {
+	case "off", "none":
+		HFCache = nil
+	case "memory":
+		size := defaultEnrichmentCacheSize
+		if raw := strings.TrimSpace(os.Getenv("ENRICHMENT_CACHE_SIZE")); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("ENRICHMENT_CACHE_SIZE must be a positive integer, got %q", raw)
+			}
+			size = n
+		}
+		HFCache = NewLRUCache(size)
+	case "supabase":
+		HFCache = SupabaseCache{}
+	default:
+		return fmt.Errorf("ENRICHMENT_CACHE must be 'memory', 'supabase' or 'off', got %q", backend)
+	}
+	log.Printf("Enrichment cache: %s (TTL %s).", backend, HFCacheTTL)
+	return nil
+}
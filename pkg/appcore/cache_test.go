@@ -0,0 +1,50 @@
+package appcore
+
+import (
+	"testing"
+	"time"
+)
+
+// TestEnrichmentCacheKey_Normalization tests that near-identical texts and reordered labels share a key.
+func TestEnrichmentCacheKey_Normalization(t *testing.T) {
+	labels := []string{"pricing", "service"}
+	base := EnrichmentCacheKey(ZeroShotModelID, "Great service!", labels)
+
+	if key := EnrichmentCacheKey(ZeroShotModelID, "  great   SERVICE ", []string{"service", "pricing"}); key != base {
+		t.Errorf("Expected normalized text and reordered labels to share a cache key")
+	}
+	if key := EnrichmentCacheKey(SentimentModelID, "Great service!", labels); key == base {
+		t.Errorf("Expected a different model to produce a different cache key")
+	}
+	if key := EnrichmentCacheKey(ZeroShotModelID, "Great service!", []string{"pricing"}); key == base {
+		t.Errorf("Expected different candidate labels to produce a different cache key")
+	}
+	if key := EnrichmentCacheKey(ZeroShotModelID, "Not great service!", labels); key == base {
+		t.Errorf("Expected different text to produce a different cache key")
+	}
+}
+
+// TestLRUCache_EvictionAndTTL tests least-recently-used eviction and expiry.
+func TestLRUCache_EvictionAndTTL(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", []byte("1"), time.Hour)
+	cache.Set("b", []byte("2"), time.Hour)
+	cache.Get("a") // "b" is now least recently used
+	cache.Set("c", []byte("3"), time.Hour)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected 'b' to be evicted")
+	}
+	if v, ok := cache.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("Expected 'a' to be cached, got %q %v", v, ok)
+	}
+
+	cache.Set("expired", []byte("x"), -time.Second)
+	if _, ok := cache.Get("expired"); ok {
+		t.Errorf("Expected expired entry to be a miss")
+	}
+	// Adding "expired" evicted "c"; reading it removed it again, leaving only "a".
+	if cache.Len() != 1 {
+		t.Errorf("Expected 1 entry after eviction and expiry, got %d", cache.Len())
+	}
+}
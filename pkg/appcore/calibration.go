@@ -0,0 +1,568 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Calibration methods.
+const (
+	CalibrationPlatt    = "platt"
+	CalibrationIsotonic = "isotonic"
+)
+
+// CalibrationSample is one historical prediction with its observed outcome.
+type CalibrationSample struct {
+	Probability float64   `json:"probability"` // raw (uncalibrated) model output
+	Churned     bool      `json:"churned"`
+	PredictedAt time.Time `json:"predicted_at"`
+}
+
+// PlattParams are the parameters of P(churn) = 1 / (1 + exp(A*logit(p) + B)).
+type PlattParams struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+}
+
+// IsotonicParams is a non-decreasing piecewise-linear map from raw to calibrated
+// probability, through the points (X[i], Y[i]).
+type IsotonicParams struct {
+	X []float64 `json:"x"`
+	Y []float64 `json:"y"`
+}
+
+// CalibrationTarget identifies the scores a calibration was fitted on: one
+// model version's outputs for one signal source. A calibration is only applied
+// to predictions with the same target.
+type CalibrationTarget struct {
+	ModelName    string `json:"model_name"`
+	ModelVersion string `json:"model_version"`
+	Source       string `json:"source"` // SignalSourceSurvey or SignalSourceTicket
+}
+
+// CalibrationArtifact is a fitted calibration, written by cmd/backtest and loaded
+// from CALIBRATION_ARTIFACT_PATH.
+type CalibrationArtifact struct {
+	CalibrationTarget
+	Version     string          `json:"version"`
+	Method      string          `json:"method"`
+	FittedAt    time.Time       `json:"fitted_at"`
+	Samples     int             `json:"samples"`
+	BaseRate    float64         `json:"base_rate"`
+	Platt       *PlattParams    `json:"platt,omitempty"`
+	Isotonic    *IsotonicParams `json:"isotonic,omitempty"`
+	BrierBefore float64         `json:"brier_before"` // on the holdout set, if any
+	BrierAfter  float64         `json:"brier_after"`
+}
+
+// ChurnCalibrations holds the calibration of each model version and signal
+// source; predictions without one are left uncalibrated. InitClients loads it
+// from CALIBRATION_ARTIFACT_PATH.
+var ChurnCalibrations = map[CalibrationTarget]*CalibrationArtifact{}
+
+// CalibrationFor returns the configured calibration for a model version's
+// scores of source, or nil if there is none.
+func CalibrationFor(modelName, modelVersion, source string) *CalibrationArtifact {
+	if source == "" {
+		source = SignalSourceSurvey
+	}
+	return ChurnCalibrations[CalibrationTarget{ModelName: modelName, ModelVersion: modelVersion, Source: source}]
+}
+
+// Apply maps a raw probability to a calibrated one.
+func (a *CalibrationArtifact) Apply(p float64) float64 {
+	if a == nil {
+		return p
+	}
+	switch a.Method {
+	case CalibrationPlatt:
+		if a.Platt != nil {
+			return 1 / (1 + math.Exp(a.Platt.A*logit(p)+a.Platt.B))
+		}
+	case CalibrationIsotonic:
+		if a.Isotonic != nil {
+			return interpolate(a.Isotonic.X, a.Isotonic.Y, p)
+		}
+	}
+	return p
+}
+
+// CalibratePrediction keeps the model's output as RawChurnProbability and, if
+// the prediction's model version has a calibration for source, replaces
+// ChurnProbability with the calibrated value. Call it after any churn model.
+func CalibratePrediction(prediction ChurnPrediction, source string) ChurnPrediction {
+	raw := prediction.ChurnProbability
+	prediction.RawChurnProbability = &raw
+	if calibration := CalibrationFor(prediction.ModelName, prediction.ModelVersion, source); calibration != nil {
+		prediction.ChurnProbability = calibration.Apply(raw)
+		prediction.CalibrationVersion = calibration.Version
+	}
+	return prediction
+}
+
+func logit(p float64) float64 {
+	const eps = 1e-6
+	p = math.Min(math.Max(p, eps), 1-eps)
+	return math.Log(p / (1 - p))
+}
+
+func interpolate(xs, ys []float64, x float64) float64 {
+	if len(xs) == 0 {
+		return x
+	}
+	if x <= xs[0] {
+		return ys[0]
+	}
+	if x >= xs[len(xs)-1] {
+		return ys[len(ys)-1]
+	}
+	i := sort.SearchFloat64s(xs, x)
+	if xs[i] == x {
+		return ys[i]
+	}
+	t := (x - xs[i-1]) / (xs[i] - xs[i-1])
+	return ys[i-1] + t*(ys[i]-ys[i-1])
+}
+
+// FitPlatt fits Platt scaling on logit(p) by Newton's method, using Platt's
+// smoothed targets so that perfectly separated data doesn't diverge.
+func FitPlatt(samples []CalibrationSample) (PlattParams, error) {
+	var positives, negatives float64
+	for _, s := range samples {
+		if s.Churned {
+			positives++
+		} else {
+			negatives++
+		}
+	}
+	if positives == 0 || negatives == 0 {
+		return PlattParams{}, fmt.Errorf("platt scaling needs both churned and retained samples (got %v and %v)", positives, negatives)
+	}
+	hiTarget := (positives + 1) / (positives + 2)
+	loTarget := 1 / (negatives + 2)
+
+	a, b := 0.0, math.Log((negatives+1)/(positives+1))
+	for iter := 0; iter < 100; iter++ {
+		// Gradient and Hessian of the negative log-likelihood in (a, b).
+		var gA, gB, hAA, hAB, hBB float64
+		for _, s := range samples {
+			f := logit(s.Probability)
+			target := loTarget
+			if s.Churned {
+				target = hiTarget
+			}
+			p := 1 / (1 + math.Exp(a*f+b))
+			d := target - p // derivative of the NLL w.r.t. (a*f+b)
+			w := p * (1 - p)
+			gA += f * d
+			gB += d
+			hAA += f * f * w
+			hAB += f * w
+			hBB += w
+		}
+		hAA += 1e-12
+		hBB += 1e-12
+		det := hAA*hBB - hAB*hAB
+		if det == 0 {
+			break
+		}
+		dA := (hBB*gA - hAB*gB) / det
+		dB := (hAA*gB - hAB*gA) / det
+		a, b = a-dA, b-dB
+		if math.Abs(dA) < 1e-10 && math.Abs(dB) < 1e-10 {
+			break
+		}
+	}
+	return PlattParams{A: a, B: b}, nil
+}
+
+// FitIsotonic fits isotonic regression with the pool-adjacent-violators algorithm.
+func FitIsotonic(samples []CalibrationSample) (IsotonicParams, error) {
+	if len(samples) == 0 {
+		return IsotonicParams{}, fmt.Errorf("isotonic regression needs at least one sample")
+	}
+	sorted := append([]CalibrationSample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Probability < sorted[j].Probability })
+
+	type block struct{ sumX, sumY, n float64 }
+	var blocks []block
+	for _, s := range sorted {
+		y := 0.0
+		if s.Churned {
+			y = 1
+		}
+		// Ties in the raw score always share a block.
+		if len(blocks) > 0 && blocks[len(blocks)-1].sumX/blocks[len(blocks)-1].n == s.Probability {
+			last := &blocks[len(blocks)-1]
+			last.sumX, last.sumY, last.n = last.sumX+s.Probability, last.sumY+y, last.n+1
+		} else {
+			blocks = append(blocks, block{s.Probability, y, 1})
+		}
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			if prev.sumY/prev.n <= last.sumY/last.n {
+				break
+			}
+			blocks = blocks[:len(blocks)-2]
+			blocks = append(blocks, block{prev.sumX + last.sumX, prev.sumY + last.sumY, prev.n + last.n})
+		}
+	}
+
+	params := IsotonicParams{}
+	for _, b := range blocks {
+		params.X = append(params.X, b.sumX/b.n)
+		params.Y = append(params.Y, b.sumY/b.n)
+	}
+	return params, nil
+}
+
+// FitCalibration fits method on train and reports Brier scores on holdout (or
+// on train when holdout is empty).
+func FitCalibration(method, version string, train, holdout []CalibrationSample) (*CalibrationArtifact, error) {
+	artifact := &CalibrationArtifact{Version: version, Method: method, FittedAt: time.Now().UTC(), Samples: len(train), BaseRate: observedRate(train)}
+	switch method {
+	case CalibrationPlatt:
+		params, err := FitPlatt(train)
+		if err != nil {
+			return nil, err
+		}
+		artifact.Platt = &params
+	case CalibrationIsotonic:
+		params, err := FitIsotonic(train)
+		if err != nil {
+			return nil, err
+		}
+		artifact.Isotonic = &params
+	default:
+		return nil, fmt.Errorf("calibration method must be %q or %q, got %q", CalibrationPlatt, CalibrationIsotonic, method)
+	}
+	evaluation := holdout
+	if len(evaluation) == 0 {
+		evaluation = train
+	}
+	artifact.BrierBefore = BrierScore(evaluation, nil)
+	artifact.BrierAfter = BrierScore(evaluation, artifact)
+	return artifact, nil
+}
+
+// BrierScore is the mean squared error of the (calibrated) probabilities.
+func BrierScore(samples []CalibrationSample, calibration *CalibrationArtifact) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, s := range samples {
+		y := 0.0
+		if s.Churned {
+			y = 1
+		}
+		d := calibration.Apply(s.Probability) - y
+		total += d * d
+	}
+	return total / float64(len(samples))
+}
+
+func observedRate(samples []CalibrationSample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	churned := 0
+	for _, s := range samples {
+		if s.Churned {
+			churned++
+		}
+	}
+	return float64(churned) / float64(len(samples))
+}
+
+// CalibrationBin is one bucket of a reliability (calibration) curve.
+type CalibrationBin struct {
+	Lower         float64 `json:"lower"`
+	Upper         float64 `json:"upper"`
+	Count         int     `json:"count"`
+	MeanPredicted float64 `json:"mean_predicted"`
+	ObservedRate  float64 `json:"observed_rate"`
+}
+
+// CalibrationCurve buckets (calibrated) predictions into equal-width bins and
+// compares the mean prediction with the observed churn rate in each. Empty bins are omitted.
+func CalibrationCurve(samples []CalibrationSample, calibration *CalibrationArtifact, bins int) []CalibrationBin {
+	if bins <= 0 {
+		bins = 10
+	}
+	curve := make([]CalibrationBin, bins)
+	for i := range curve {
+		curve[i].Lower, curve[i].Upper = float64(i)/float64(bins), float64(i+1)/float64(bins)
+	}
+	for _, s := range samples {
+		p := calibration.Apply(s.Probability)
+		i := int(p * float64(bins))
+		if i >= bins {
+			i = bins - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		curve[i].Count++
+		curve[i].MeanPredicted += p
+		if s.Churned {
+			curve[i].ObservedRate++
+		}
+	}
+	result := []CalibrationBin{}
+	for _, bin := range curve {
+		if bin.Count == 0 {
+			continue
+		}
+		bin.MeanPredicted /= float64(bin.Count)
+		bin.ObservedRate /= float64(bin.Count)
+		result = append(result, bin)
+	}
+	return result
+}
+
+// ExpectedCalibrationError is the count-weighted mean gap between predicted and observed rates.
+func ExpectedCalibrationError(curve []CalibrationBin) float64 {
+	total, weighted := 0, 0.0
+	for _, bin := range curve {
+		total += bin.Count
+		weighted += float64(bin.Count) * math.Abs(bin.MeanPredicted-bin.ObservedRate)
+	}
+	if total == 0 {
+		return 0
+	}
+	return weighted / float64(total)
+}
+
+// --- Historical samples ---
+
+// ChurnOutcome is a row of churn_outcomes: whether (and when) an account churned.
+type ChurnOutcome struct {
+	AccountID  string     `json:"account_id"`
+	Churned    bool       `json:"churned"`
+	ChurnedAt  *time.Time `json:"churned_at,omitempty"`
+	RecordedAt time.Time  `json:"recorded_at,omitempty"`
+}
+
+const calibrationBatchSize = 1000
+
+type predictionWithAccount struct {
+	ID                  string    `json:"id"`
+	ChurnProbability    float64   `json:"churn_probability"`
+	RawChurnProbability *float64  `json:"raw_churn_probability"`
+	PredictedAt         time.Time `json:"predicted_at"`
+	CustomerFeedback    *struct {
+		AccountID string `json:"account_id"`
+		Source    string `json:"source"`
+	} `json:"customer_feedback"`
+}
+
+// FetchCalibrationSamples labels every prediction of target's model version and
+// source made before now-horizon for a known account: churned if churn_outcomes
+// records a churn within horizon after the prediction, retained otherwise
+// (including accounts without an outcome row). Primary and shadow scores are
+// both used. Predictions added by rescore runs are skipped: they are dated when
+// the run wrote them, not when the feedback arrived.
+func FetchCalibrationSamples(horizon time.Duration, now time.Time, target CalibrationTarget) ([]CalibrationSample, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	cutoff := now.Add(-horizon).UTC().Format(time.RFC3339)
+
+	var predictions []predictionWithAccount
+	lastID := ""
+	for {
+		query := SupabaseClient.From("churn_predictions").
+			Select("id,churn_probability,raw_churn_probability,predicted_at,customer_feedback(account_id,source)", "", false).
+			Lt("predicted_at", cutoff).
+			Is("rescore_run_id", "null").
+			Eq("model_name", target.ModelName).
+			Eq("model_version", target.ModelVersion)
+		if lastID != "" {
+			query = query.Lt("id", lastID)
+		}
+		rawData, _, err := query.Order("id", nil).Limit(calibrationBatchSize, "").Execute()
+		if err != nil {
+			return nil, fmt.Errorf("error selecting churn predictions: %w", err)
+		}
+		var batch []predictionWithAccount
+		if err := json.Unmarshal(rawData, &batch); err != nil {
+			return nil, fmt.Errorf("error unmarshalling churn predictions: %w", err)
+		}
+		predictions = append(predictions, batch...)
+		if len(batch) < calibrationBatchSize {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	outcomes, err := fetchChurnOutcomes()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]CalibrationSample, 0, len(predictions))
+	for _, p := range predictions {
+		if p.CustomerFeedback == nil || p.CustomerFeedback.AccountID == "" {
+			continue // no account, no outcome
+		}
+		if source := p.CustomerFeedback.Source; source != target.Source && !(source == "" && target.Source == SignalSourceSurvey) {
+			continue
+		}
+		raw := p.ChurnProbability // rows from before calibration existed
+		if p.RawChurnProbability != nil {
+			raw = *p.RawChurnProbability
+		}
+		churned := false
+		if outcome, ok := outcomes[p.CustomerFeedback.AccountID]; ok && outcome.Churned && outcome.ChurnedAt != nil {
+			churned = outcome.ChurnedAt.After(p.PredictedAt) && !outcome.ChurnedAt.After(p.PredictedAt.Add(horizon))
+		}
+		samples = append(samples, CalibrationSample{Probability: raw, Churned: churned, PredictedAt: p.PredictedAt})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].PredictedAt.Before(samples[j].PredictedAt) })
+	return samples, nil
+}
+
+// fetchChurnOutcomes reads every churn_outcomes row, paging by account_id so
+// accounts beyond the API's max-rows limit aren't mislabelled as retained.
+func fetchChurnOutcomes() (map[string]ChurnOutcome, error) {
+	outcomes := map[string]ChurnOutcome{}
+	lastAccountID := ""
+	for {
+		query := SupabaseClient.From("churn_outcomes").Select("*", "", false)
+		if lastAccountID != "" {
+			query = query.Lt("account_id", lastAccountID)
+		}
+		rawData, _, err := query.Order("account_id", nil).Limit(calibrationBatchSize, "").Execute()
+		if err != nil {
+			return nil, fmt.Errorf("error selecting churn outcomes: %w", err)
+		}
+		var rows []ChurnOutcome
+		if err := json.Unmarshal(rawData, &rows); err != nil {
+			return nil, fmt.Errorf("error unmarshalling churn outcomes: %w", err)
+		}
+		for _, row := range rows {
+			outcomes[row.AccountID] = row
+		}
+		if len(rows) < calibrationBatchSize {
+			return outcomes, nil
+		}
+		lastAccountID = rows[len(rows)-1].AccountID
+	}
+}
+
+// LoadCalibrationArtifact reads a calibration artifact written by cmd/backtest.
+func LoadCalibrationArtifact(path string) (*CalibrationArtifact, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading calibration artifact: %w", err)
+	}
+	var artifact CalibrationArtifact
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return nil, fmt.Errorf("error parsing calibration artifact %s: %w", path, err)
+	}
+	switch {
+	case artifact.Method == CalibrationPlatt && artifact.Platt != nil:
+	case artifact.Method == CalibrationIsotonic && artifact.Isotonic != nil &&
+		len(artifact.Isotonic.X) > 0 && len(artifact.Isotonic.X) == len(artifact.Isotonic.Y):
+	default:
+		return nil, fmt.Errorf("calibration artifact %s has no valid %q parameters", path, artifact.Method)
+	}
+	if (artifact.ModelName == "") != (artifact.ModelVersion == "") {
+		return nil, fmt.Errorf("calibration artifact %s needs both model_name and model_version", path)
+	}
+	if artifact.Source == "" {
+		artifact.Source = SignalSourceSurvey
+	}
+	return &artifact, nil
+}
+
+// ConfigureCalibration sets ChurnCalibrations from CALIBRATION_ARTIFACT_PATH, a
+// comma-separated list of artifacts with at most one per model version and
+// source, or disables calibration when it is unset.
+func ConfigureCalibration() error {
+	calibrations := map[CalibrationTarget]*CalibrationArtifact{}
+	for _, path := range strings.Split(os.Getenv("CALIBRATION_ARTIFACT_PATH"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		artifact, err := LoadCalibrationArtifact(path)
+		if err != nil {
+			return err
+		}
+		if artifact.ModelName == "" {
+			// Fitted before calibrations were keyed by model; we can't tell which
+			// model version's scores it fits, so it is not applied to any.
+			log.Printf("Warning: calibration artifact %s names no model; ignoring it. Refit it with cmd/backtest.", path)
+			continue
+		}
+		if _, ok := calibrations[artifact.CalibrationTarget]; ok {
+			return fmt.Errorf("more than one calibration artifact for %s %s (%s)", artifact.ModelName, artifact.ModelVersion, artifact.Source)
+		}
+		calibrations[artifact.CalibrationTarget] = artifact
+		log.Printf("Churn calibration %s for %s %s (%s; %s, %d samples) loaded from %s.",
+			artifact.Version, artifact.ModelName, artifact.ModelVersion, artifact.Source, artifact.Method, artifact.Samples, path)
+	}
+	ChurnCalibrations = calibrations
+	return nil
+}
+
+// --- Backtesting ---
+
+// BacktestReport compares raw and calibrated predictions with observed outcomes.
+type BacktestReport struct {
+	Samples         int                  `json:"samples"`
+	HoldoutSamples  int                  `json:"holdout_samples"`
+	BaseRate        float64              `json:"base_rate"`
+	RawBrier        float64              `json:"raw_brier"`
+	RawECE          float64              `json:"raw_ece"`
+	RawCurve        []CalibrationBin     `json:"raw_curve"`
+	Calibration     *CalibrationArtifact `json:"calibration,omitempty"` // fitted, or the one currently configured
+	CalibratedBrier float64              `json:"calibrated_brier,omitempty"`
+	CalibratedECE   float64              `json:"calibrated_ece,omitempty"`
+	CalibratedCurve []CalibrationBin     `json:"calibrated_curve,omitempty"`
+}
+
+// Backtest evaluates samples (sorted by PredictedAt). With method set it fits a
+// calibration on the oldest (1-holdout) share and evaluates on the newest
+// holdout share, so the curves show how the fit generalises forward in time;
+// otherwise it evaluates calibration (which may be nil) on all samples.
+func Backtest(samples []CalibrationSample, method, version string, holdout float64, bins int, calibration *CalibrationArtifact) (BacktestReport, error) {
+	evaluation := samples
+	if method != "" {
+		if holdout < 0 || holdout >= 1 {
+			return BacktestReport{}, fmt.Errorf("holdout must be in [0, 1), got %g", holdout)
+		}
+		split := len(samples) - int(float64(len(samples))*holdout)
+		artifact, err := FitCalibration(method, version, samples[:split], samples[split:])
+		if err != nil {
+			return BacktestReport{}, err
+		}
+		calibration = artifact
+		if split < len(samples) {
+			evaluation = samples[split:]
+		}
+	}
+
+	report := BacktestReport{
+		Samples:        len(samples),
+		HoldoutSamples: len(evaluation),
+		BaseRate:       observedRate(samples),
+		RawBrier:       BrierScore(evaluation, nil),
+		RawCurve:       CalibrationCurve(evaluation, nil, bins),
+		Calibration:    calibration,
+	}
+	report.RawECE = ExpectedCalibrationError(report.RawCurve)
+	if calibration != nil {
+		report.CalibratedBrier = BrierScore(evaluation, calibration)
+		report.CalibratedCurve = CalibrationCurve(evaluation, calibration, bins)
+		report.CalibratedECE = ExpectedCalibrationError(report.CalibratedCurve)
+	}
+	return report, nil
+}
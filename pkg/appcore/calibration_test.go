@@ -0,0 +1,86 @@
+package appcore
+
+import (
+	"testing"
+)
+
+// calibrationSamples builds samples where predictions of p churn at rate observed.
+func calibrationSamples(p float64, n, churned int) []CalibrationSample {
+	samples := make([]CalibrationSample, n)
+	for i := range samples {
+		samples[i] = CalibrationSample{Probability: p, Churned: i < churned}
+	}
+	return samples
+}
+
+// TestFitIsotonic tests that isotonic calibration maps each raw score to its observed rate and stays monotonic.
+func TestFitIsotonic(t *testing.T) {
+	var samples []CalibrationSample
+	samples = append(samples, calibrationSamples(0.1, 10, 1)...)
+	samples = append(samples, calibrationSamples(0.4, 10, 3)...)
+	samples = append(samples, calibrationSamples(0.8, 10, 2)...) // over-predicted: pooled with 0.4
+	params, err := FitIsotonic(samples)
+	if err != nil {
+		t.Fatalf("FitIsotonic failed: %v", err)
+	}
+	calibration := &CalibrationArtifact{Method: CalibrationIsotonic, Isotonic: &params}
+	if got := calibration.Apply(0.1); got < 0.099 || got > 0.101 {
+		t.Errorf("Expected 0.1 to map to 0.1, got %v", got)
+	}
+	if low, high := calibration.Apply(0.4), calibration.Apply(0.8); low > high || high < 0.249 || high > 0.251 {
+		t.Errorf("Expected a monotonic map pooling 0.4 and 0.8 at 0.25, got %v and %v", low, high)
+	}
+	if BrierScore(samples, calibration) >= BrierScore(samples, nil) {
+		t.Errorf("Expected calibration to improve the Brier score")
+	}
+}
+
+// TestFitPlatt tests that Platt scaling moves an over-confident score toward the observed rate.
+func TestFitPlatt(t *testing.T) {
+	var samples []CalibrationSample
+	samples = append(samples, calibrationSamples(0.1, 50, 5)...)
+	samples = append(samples, calibrationSamples(0.8, 50, 20)...)
+	report, err := Backtest(samples, CalibrationPlatt, "test", 0, 10, nil)
+	if err != nil {
+		t.Fatalf("Backtest failed: %v", err)
+	}
+	if got := report.Calibration.Apply(0.8); got > 0.6 || got < 0.25 {
+		t.Errorf("Expected 0.8 to be calibrated toward 0.4, got %v", got)
+	}
+	if report.CalibratedECE >= report.RawECE || len(report.RawCurve) != 2 {
+		t.Errorf("Expected calibration to reduce ECE over a two-bin raw curve, got %+v", report)
+	}
+}
+
+// TestCalibratePrediction tests that predictions always keep their raw probability
+// and are only calibrated by their own model version's artifact for their source.
+func TestCalibratePrediction(t *testing.T) {
+	original := ChurnCalibrations
+	defer func() { ChurnCalibrations = original }()
+
+	ChurnCalibrations = map[CalibrationTarget]*CalibrationArtifact{}
+	rules := ChurnPrediction{ModelName: RulesModelName, ModelVersion: RulesModelVersion, ChurnProbability: 0.8}
+	if p := CalibratePrediction(rules, ""); p.ChurnProbability != 0.8 || p.RawChurnProbability == nil || p.CalibrationVersion != "" {
+		t.Errorf("Expected no change without a calibration, got %+v", p)
+	}
+
+	target := CalibrationTarget{ModelName: RulesModelName, ModelVersion: RulesModelVersion, Source: SignalSourceSurvey}
+	ChurnCalibrations[target] = &CalibrationArtifact{CalibrationTarget: target, Version: "v1", Method: CalibrationIsotonic, Isotonic: &IsotonicParams{X: []float64{0, 1}, Y: []float64{0, 0.5}}}
+	p := CalibratePrediction(rules, "")
+	if p.RawChurnProbability == nil || *p.RawChurnProbability != 0.8 || p.ChurnProbability != 0.4 || p.CalibrationVersion != "v1" {
+		t.Errorf("Expected calibrated 0.4 with raw 0.8 and version v1, got %+v", p)
+	}
+
+	for name, other := range map[string]struct {
+		prediction ChurnPrediction
+		source     string
+	}{
+		"ticket":        {rules, SignalSourceTicket},
+		"older rules":   {ChurnPrediction{ModelName: RulesModelName, ModelVersion: "1", ChurnProbability: 0.8}, SignalSourceSurvey},
+		"linear shadow": {ChurnPrediction{ModelName: "churn-linear", ModelVersion: RulesModelVersion, ChurnProbability: 0.8}, SignalSourceSurvey},
+	} {
+		if p := CalibratePrediction(other.prediction, other.source); p.ChurnProbability != 0.8 || p.CalibrationVersion != "" {
+			t.Errorf("Expected the %s prediction to stay uncalibrated, got %+v", name, p)
+		}
+	}
+}
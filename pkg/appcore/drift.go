@@ -0,0 +1,400 @@
+package appcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DriftSettings are the thresholds used by the drift monitor. Configured by InitClients.
+var DriftSettings = DefaultDriftSettings()
+
+// DriftConfig holds the drift thresholds and where alerts go.
+type DriftConfig struct {
+	PSIThreshold    float64 // 0.2 is the usual "significant shift" cutoff
+	KSThreshold     float64
+	MinSamples      int    // features with fewer samples in either window are not judged
+	AlertWebhookURL string // empty disables alerts
+}
+
+// DefaultDriftSettings returns the thresholds used when no DRIFT_* variables are set.
+func DefaultDriftSettings() DriftConfig {
+	return DriftConfig{PSIThreshold: 0.2, KSThreshold: 0.1, MinSamples: 50}
+}
+
+// DriftWindow is a half-open time range [Start, End).
+type DriftWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// CategoryShift is the change in share of one category between windows.
+type CategoryShift struct {
+	Category  string  `json:"category"`
+	Reference float64 `json:"reference"`
+	Current   float64 `json:"current"`
+}
+
+// FeatureDrift compares one feature between the reference and current windows.
+type FeatureDrift struct {
+	Feature        string          `json:"feature"`
+	ReferenceCount int             `json:"reference_count"`
+	CurrentCount   int             `json:"current_count"`
+	PSI            float64         `json:"psi"`
+	KS             *float64        `json:"ks,omitempty"` // numeric features only
+	Drifted        bool            `json:"drifted"`
+	Insufficient   bool            `json:"insufficient_data,omitempty"`
+	Shifts         []CategoryShift `json:"shifts,omitempty"` // categorical features: largest changes first
+}
+
+// DriftReport is the result of one drift check.
+type DriftReport struct {
+	Reference    DriftWindow    `json:"reference"`
+	Current      DriftWindow    `json:"current"`
+	PSIThreshold float64        `json:"psi_threshold"`
+	KSThreshold  float64        `json:"ks_threshold"`
+	Features     []FeatureDrift `json:"features"`
+	Drifted      bool           `json:"drifted"`
+	CheckedAt    time.Time      `json:"checked_at"`
+}
+
+// DriftedFeatures returns the names of the features over a threshold.
+func (r DriftReport) DriftedFeatures() []string {
+	var names []string
+	for _, f := range r.Features {
+		if f.Drifted {
+			names = append(names, f.Feature)
+		}
+	}
+	return names
+}
+
+// --- Statistics ---
+
+const psiEpsilon = 1e-4
+
+// PSI is the population stability index of two distributions given as counts
+// per bin: sum((c - r) * ln(c / r)) over bin shares, with empty bins clamped.
+func PSI(reference, current []float64) float64 {
+	refTotal, curTotal := sum(reference), sum(current)
+	if refTotal == 0 || curTotal == 0 {
+		return 0
+	}
+	psi := 0.0
+	for i := range reference {
+		r := math.Max(reference[i]/refTotal, psiEpsilon)
+		c := math.Max(current[i]/curTotal, psiEpsilon)
+		psi += (c - r) * math.Log(c/r)
+	}
+	return psi
+}
+
+// KSStatistic is the two-sample Kolmogorov-Smirnov statistic: the largest gap
+// between the empirical distribution functions of a and b.
+func KSStatistic(a, b []float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	a, b = sortedCopy(a), sortedCopy(b)
+	i, j, ks := 0, 0, 0.0
+	for i < len(a) && j < len(b) {
+		x := math.Min(a[i], b[j])
+		for i < len(a) && a[i] <= x {
+			i++
+		}
+		for j < len(b) && b[j] <= x {
+			j++
+		}
+		ks = math.Max(ks, math.Abs(float64(i)/float64(len(a))-float64(j)/float64(len(b))))
+	}
+	return ks
+}
+
+// NumericPSI bins both samples at the deciles of reference and returns their PSI.
+func NumericPSI(reference, current []float64) float64 {
+	cuts := quantileCuts(reference, 10)
+	return PSI(histogram(reference, cuts), histogram(current, cuts))
+}
+
+// quantileCuts returns the distinct interior quantile cut points of values.
+func quantileCuts(values []float64, bins int) []float64 {
+	sorted := sortedCopy(values)
+	var cuts []float64
+	for i := 1; i < bins && len(sorted) > 0; i++ {
+		cut := sorted[i*len(sorted)/bins]
+		if len(cuts) == 0 || cut > cuts[len(cuts)-1] {
+			cuts = append(cuts, cut)
+		}
+	}
+	return cuts
+}
+
+// histogram counts values per bin; bin i holds values in (cuts[i-1], cuts[i]].
+func histogram(values, cuts []float64) []float64 {
+	counts := make([]float64, len(cuts)+1)
+	for _, v := range values {
+		counts[sort.SearchFloat64s(cuts, v)]++
+	}
+	return counts
+}
+
+func sortedCopy(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// NumericDrift compares two samples of a numeric feature with PSI and KS.
+func NumericDrift(feature string, reference, current []float64, cfg DriftConfig) FeatureDrift {
+	drift := FeatureDrift{Feature: feature, ReferenceCount: len(reference), CurrentCount: len(current)}
+	if len(reference) < cfg.MinSamples || len(current) < cfg.MinSamples || len(reference) == 0 || len(current) == 0 {
+		drift.Insufficient = true
+		return drift
+	}
+	ks := KSStatistic(reference, current)
+	drift.PSI, drift.KS = NumericPSI(reference, current), &ks
+	drift.Drifted = drift.PSI > cfg.PSIThreshold || ks > cfg.KSThreshold
+	return drift
+}
+
+// CategoricalDrift compares category counts with PSI. referenceN and currentN
+// are the number of observations, which for multi-valued features such as
+// topics differ from the total counts; shares in Shifts are per observation.
+func CategoricalDrift(feature string, reference, current map[string]int, referenceN, currentN int, cfg DriftConfig) FeatureDrift {
+	drift := FeatureDrift{Feature: feature, ReferenceCount: referenceN, CurrentCount: currentN}
+	if referenceN < cfg.MinSamples || currentN < cfg.MinSamples || referenceN == 0 || currentN == 0 {
+		drift.Insufficient = true
+		return drift
+	}
+	var categories []string
+	for c := range reference {
+		categories = append(categories, c)
+	}
+	for c := range current {
+		if _, ok := reference[c]; !ok {
+			categories = append(categories, c)
+		}
+	}
+	sort.Strings(categories)
+	ref, cur := make([]float64, len(categories)), make([]float64, len(categories))
+	for i, c := range categories {
+		ref[i], cur[i] = float64(reference[c]), float64(current[c])
+		drift.Shifts = append(drift.Shifts, CategoryShift{Category: c, Reference: ref[i] / float64(referenceN), Current: cur[i] / float64(currentN)})
+	}
+	sort.SliceStable(drift.Shifts, func(i, j int) bool {
+		return math.Abs(drift.Shifts[i].Current-drift.Shifts[i].Reference) > math.Abs(drift.Shifts[j].Current-drift.Shifts[j].Reference)
+	})
+	if len(drift.Shifts) > 5 {
+		drift.Shifts = drift.Shifts[:5]
+	}
+	drift.PSI = PSI(ref, cur)
+	drift.Drifted = drift.PSI > cfg.PSIThreshold
+	return drift
+}
+
+// --- Windows ---
+
+// DriftSample is the part of a customer_feedback row (and its primary prediction) the monitor compares.
+type DriftSample struct {
+	NLSScore         int
+	Sentiment        string
+	Topics           []string
+	FeedbackLength   *int     // nil when the text has expired under the retention policy
+	ChurnProbability *float64 // nil when no primary prediction was stored
+}
+
+// CompareDriftWindows builds the drift report of current against reference.
+func CompareDriftWindows(reference, current []DriftSample, cfg DriftConfig) []FeatureDrift {
+	type window struct {
+		nls, length, probability []float64
+		sentiment, topics        map[string]int
+	}
+	collect := func(samples []DriftSample) window {
+		w := window{sentiment: map[string]int{}, topics: map[string]int{}}
+		for _, s := range samples {
+			w.nls = append(w.nls, float64(s.NLSScore))
+			sentiment := s.Sentiment
+			if sentiment == "" {
+				sentiment = "UNKNOWN"
+			}
+			w.sentiment[sentiment]++
+			for _, topic := range s.Topics {
+				w.topics[topic]++
+			}
+			if s.FeedbackLength != nil {
+				w.length = append(w.length, float64(*s.FeedbackLength))
+			}
+			if s.ChurnProbability != nil {
+				w.probability = append(w.probability, *s.ChurnProbability)
+			}
+		}
+		return w
+	}
+	ref, cur := collect(reference), collect(current)
+	return []FeatureDrift{
+		NumericDrift("nls_score", ref.nls, cur.nls, cfg),
+		CategoricalDrift("comment_sentiment", ref.sentiment, cur.sentiment, len(reference), len(current), cfg),
+		CategoricalDrift("comment_topics", ref.topics, cur.topics, len(reference), len(current), cfg),
+		NumericDrift("feedback_length", ref.length, cur.length, cfg),
+		NumericDrift("churn_probability", ref.probability, cur.probability, cfg),
+	}
+}
+
+const driftBatchSize = 1000
+
+type driftRow struct {
+	ID               string   `json:"id"`
+	NLSScore         int      `json:"nls_score"`
+	FeedbackText     *string  `json:"feedback_text"`
+	CommentSentiment string   `json:"comment_sentiment"`
+	CommentTopics    []string `json:"comment_topics"`
+	ChurnPredictions []struct {
+		ChurnProbability float64   `json:"churn_probability"`
+		Shadow           bool      `json:"shadow"`
+		PredictedAt      time.Time `json:"predicted_at"`
+	} `json:"churn_predictions"`
+}
+
+// FetchDriftSamples reads the survey feedback created in window with its latest
+// primary prediction (rescore runs add newer ones). Support tickets are left
+// out: they have no NLS score and would shift its distribution.
+func FetchDriftSamples(window DriftWindow) ([]DriftSample, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	var samples []DriftSample
+	lastID := ""
+	for {
+		query := SupabaseClient.From("customer_feedback").
+			Select("id,nls_score,feedback_text,comment_sentiment,comment_topics,churn_predictions(churn_probability,shadow,predicted_at)", "", false).
+			Eq("source", SignalSourceSurvey).
+			Gte("created_at", window.Start.UTC().Format(time.RFC3339)).
+			Lt("created_at", window.End.UTC().Format(time.RFC3339))
+		if lastID != "" {
+			query = query.Lt("id", lastID)
+		}
+		rawData, _, err := query.Order("id", nil).Limit(driftBatchSize, "").Execute()
+		if err != nil {
+			return nil, fmt.Errorf("error selecting customer feedback for drift: %w", err)
+		}
+		var rows []driftRow
+		if err := json.Unmarshal(rawData, &rows); err != nil {
+			return nil, fmt.Errorf("error unmarshalling customer feedback for drift: %w", err)
+		}
+		for _, row := range rows {
+			sample := DriftSample{NLSScore: row.NLSScore, Sentiment: row.CommentSentiment, Topics: row.CommentTopics}
+			if row.FeedbackText != nil {
+				n := utf8.RuneCountInString(*row.FeedbackText)
+				sample.FeedbackLength = &n
+			}
+			var latest time.Time
+			for _, p := range row.ChurnPredictions {
+				if !p.Shadow && (sample.ChurnProbability == nil || p.PredictedAt.After(latest)) {
+					probability := p.ChurnProbability
+					sample.ChurnProbability, latest = &probability, p.PredictedAt
+				}
+			}
+			samples = append(samples, sample)
+		}
+		if len(rows) < driftBatchSize {
+			return samples, nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// CheckDrift compares the feedback of the currentDays before now with the
+// referenceDays before that.
+func CheckDrift(now time.Time, currentDays, referenceDays int, cfg DriftConfig) (DriftReport, error) {
+	day := 24 * time.Hour
+	current := DriftWindow{Start: now.Add(-time.Duration(currentDays) * day), End: now}
+	reference := DriftWindow{Start: current.Start.Add(-time.Duration(referenceDays) * day), End: current.Start}
+	report := DriftReport{Reference: reference, Current: current, PSIThreshold: cfg.PSIThreshold, KSThreshold: cfg.KSThreshold, CheckedAt: now}
+
+	referenceSamples, err := FetchDriftSamples(reference)
+	if err != nil {
+		return report, err
+	}
+	currentSamples, err := FetchDriftSamples(current)
+	if err != nil {
+		return report, err
+	}
+	report.Features = CompareDriftWindows(referenceSamples, currentSamples, cfg)
+	report.Drifted = len(report.DriftedFeatures()) > 0
+	return report, nil
+}
+
+// SendDriftAlert posts report to the configured alert webhook. The body is the
+// report plus a "text" summary, so Slack-compatible incoming webhooks show it as is.
+func SendDriftAlert(report DriftReport, cfg DriftConfig) error {
+	if cfg.AlertWebhookURL == "" {
+		return nil
+	}
+	text := fmt.Sprintf("Churn agent drift detected in %s (current window %s to %s).",
+		strings.Join(report.DriftedFeatures(), ", "), report.Current.Start.Format("2006-01-02"), report.Current.End.Format("2006-01-02"))
+	payload, err := json.Marshal(struct {
+		Text string `json:"text"`
+		DriftReport
+	}{text, report})
+	if err != nil {
+		return fmt.Errorf("error marshalling drift alert: %w", err)
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Post(cfg.AlertWebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error sending drift alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("drift alert webhook returned status %d: %s", resp.StatusCode, string(body))
+	}
+	log.Printf("Drift alert sent for %v.", report.DriftedFeatures())
+	return nil
+}
+
+// ConfigureDriftMonitor sets DriftSettings from DRIFT_PSI_THRESHOLD,
+// DRIFT_KS_THRESHOLD, DRIFT_MIN_SAMPLES and DRIFT_ALERT_WEBHOOK_URL.
+func ConfigureDriftMonitor() error {
+	cfg := DefaultDriftSettings()
+	for _, setting := range []struct {
+		name string
+		dest *float64
+	}{{"DRIFT_PSI_THRESHOLD", &cfg.PSIThreshold}, {"DRIFT_KS_THRESHOLD", &cfg.KSThreshold}} {
+		if raw := strings.TrimSpace(os.Getenv(setting.name)); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || v <= 0 {
+				return fmt.Errorf("%s must be a positive number, got %q", setting.name, raw)
+			}
+			*setting.dest = v
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("DRIFT_MIN_SAMPLES")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fmt.Errorf("DRIFT_MIN_SAMPLES must be a positive integer, got %q", raw)
+		}
+		cfg.MinSamples = n
+	}
+	cfg.AlertWebhookURL = strings.TrimSpace(os.Getenv("DRIFT_ALERT_WEBHOOK_URL"))
+	DriftSettings = cfg
+	return nil
+}
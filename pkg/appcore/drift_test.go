@@ -0,0 +1,51 @@
+package appcore
+
+import (
+	"testing"
+)
+
+// TestPSIAndKS tests the drift statistics on identical and shifted samples.
+func TestPSIAndKS(t *testing.T) {
+	var reference, shifted []float64
+	for i := 0; i < 100; i++ {
+		reference = append(reference, float64(i%10))
+		shifted = append(shifted, float64(i%10)+3)
+	}
+	if psi := NumericPSI(reference, reference); psi != 0 {
+		t.Errorf("Expected PSI 0 for identical samples, got %v", psi)
+	}
+	if ks := KSStatistic(reference, reference); ks != 0 {
+		t.Errorf("Expected KS 0 for identical samples, got %v", ks)
+	}
+	if psi := NumericPSI(reference, shifted); psi < 0.2 {
+		t.Errorf("Expected PSI above 0.2 for a shift of 3, got %v", psi)
+	}
+	if ks := KSStatistic(reference, shifted); ks < 0.29 || ks > 0.31 {
+		t.Errorf("Expected KS 0.3 for a shift of 3 over 0-9, got %v", ks)
+	}
+}
+
+// TestCompareDriftWindows tests that a changed sentiment mix is flagged and small windows are not judged.
+func TestCompareDriftWindows(t *testing.T) {
+	sample := func(sentiment string) DriftSample {
+		return DriftSample{NLSScore: 7, Sentiment: sentiment, Topics: []string{"pricing"}}
+	}
+	var reference, current []DriftSample
+	for i := 0; i < 100; i++ {
+		reference = append(reference, sample([]string{"POSITIVE", "NEGATIVE"}[i%2]))
+		current = append(current, sample([]string{"POSITIVE", "NEUTRAL", "NEUTRAL", "NEGATIVE"}[i%4]))
+	}
+	features := map[string]FeatureDrift{}
+	for _, f := range CompareDriftWindows(reference, current, DefaultDriftSettings()) {
+		features[f.Feature] = f
+	}
+	if f := features["comment_sentiment"]; !f.Drifted || len(f.Shifts) == 0 || f.Shifts[0].Category != "NEUTRAL" {
+		t.Errorf("Expected sentiment drift led by NEUTRAL, got %+v", f)
+	}
+	if features["nls_score"].Drifted || features["comment_topics"].Drifted {
+		t.Errorf("Expected unchanged NLS scores and topics not to drift, got %+v", features)
+	}
+	if f := features["churn_probability"]; !f.Insufficient || f.Drifted {
+		t.Errorf("Expected churn_probability without samples to be insufficient, got %+v", f)
+	}
+}
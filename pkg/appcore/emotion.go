@@ -0,0 +1,101 @@
+package appcore
+
+// EmotionLabels are the emotions scored by zero-shot classification.
+var EmotionLabels = []string{"anger", "frustration", "disappointment", "satisfaction"}
+
+// EmotionHypothesisTemplate phrases the zero-shot hypothesis for emotions.
+const EmotionHypothesisTemplate = "The writer of this feedback feels {}."
+
+// EmotionScoreThreshold is the minimum zero-shot score for an emotion to be reported.
+const EmotionScoreThreshold = 0.7
+
+// NegativeEmotions are the emotions used as churn features.
+var NegativeEmotions = []string{"anger", "frustration", "disappointment"}
+
+// GetEmotionScoresFromHF scores every EmotionLabels entry against the text with
+// the zero-shot model in models.
+func GetEmotionScoresFromHF(feedbackText string, models LanguageModels) ([]LabelScore, error) {
+	return GetZeroShotScoresWithModel(models.ZeroShot, feedbackText, EmotionLabels, EmotionHypothesisTemplate)
+}
+
+// SelectEmotions returns the emotions scoring above EmotionScoreThreshold, in EmotionLabels order.
+func SelectEmotions(scores []LabelScore) []string {
+	emotions := []string{}
+	for _, label := range EmotionLabels {
+		for _, ls := range scores {
+			if ls.Label == label && ls.Score > EmotionScoreThreshold {
+				emotions = append(emotions, label)
+			}
+		}
+	}
+	return emotions
+}
+
+// Urgency levels reported by DetectCancelIntent.
+const (
+	UrgencyNone   = "none"
+	UrgencyMedium = "medium"
+	UrgencyHigh   = "high"
+)
+
+// CancelIntent is the result of scanning feedback for explicit cancellation language.
+type CancelIntent struct {
+	Detected bool     `json:"detected"` // explicit cancel or refund language, not just a competitor mention
+	Urgency  string   `json:"urgency"`
+	Signals  []string `json:"signals,omitempty"` // the intent signals found, e.g. "cancel", "refund"
+}
+
+// Intent phrases per signal, matched with stemming and negation ("I don't want
+// to cancel" is not intent). Strong signals are an explicit request to leave and
+// include Spanish, German and Portuguese equivalents; a competitor mention is a hint.
+var (
+	cancelPhrases = NewPhraseMatcher([]string{
+		"cancel", "cancellation", "unsubscribe", "terminate my", "close my account", "not renew", "won't renew",
+		"cancelar", "cancelamento", "kündigen", "kündigung",
+	})
+	refundPhrases     = NewPhraseMatcher([]string{"refund", "money back", "chargeback", "reembolso", "rückerstattung"})
+	competitorPhrases = NewPhraseMatcher([]string{"switch to another", "move to another", "competitor", "other provider"})
+	// urgencyPhrases mark a near-term deadline, which raises urgency to high.
+	urgencyPhrases = NewPhraseMatcher([]string{"today", "now", "immediately", "asap", "next week", "this week", "end of the month", "end of month", "tomorrow"})
+)
+
+// DetectCancelIntent scans text for explicit cancellation, refund or competitor
+// language. Cancel or refund language is detected intent with high urgency; a
+// competitor mention alone is not, and is medium urgency unless paired with a
+// near-term deadline.
+func DetectCancelIntent(text string) CancelIntent {
+	intent := CancelIntent{Urgency: UrgencyNone, Signals: []string{}}
+	for _, signal := range []struct {
+		name    string
+		matcher *PhraseMatcher
+		strong  bool
+	}{
+		{"cancel", cancelPhrases, true},
+		{"refund", refundPhrases, true},
+		{"competitor", competitorPhrases, false},
+	} {
+		if AffirmedWeight(signal.matcher.Match(text)) == 0 {
+			continue
+		}
+		intent.Signals = append(intent.Signals, signal.name)
+		intent.Detected = intent.Detected || signal.strong
+	}
+	if len(intent.Signals) == 0 {
+		return intent
+	}
+	intent.Urgency = UrgencyMedium
+	if intent.Detected || len(urgencyPhrases.Match(text)) > 0 {
+		intent.Urgency = UrgencyHigh
+	}
+	return intent
+}
+
+func intersectStrings(values, allowed []string) []string {
+	var result []string
+	for _, v := range values {
+		if containsString(allowed, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
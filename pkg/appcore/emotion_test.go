@@ -0,0 +1,49 @@
+package appcore
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDetectCancelIntent tests explicit cancellation, competitor and deadline language.
+func TestDetectCancelIntent(t *testing.T) {
+	intent := DetectCancelIntent("I'm cancelling next week.")
+	if !intent.Detected || intent.Urgency != UrgencyHigh || strings.Join(intent.Signals, ",") != "cancel" {
+		t.Errorf("Expected high-urgency cancel intent, got %+v", intent)
+	}
+	intent = DetectCancelIntent("Thinking about switching to a competitor")
+	if intent.Detected || intent.Urgency != UrgencyMedium || strings.Join(intent.Signals, ",") != "competitor" {
+		t.Errorf("Expected a medium-urgency competitor signal without cancel intent, got %+v", intent)
+	}
+	intent = DetectCancelIntent("Quiero un reembolso")
+	if !intent.Detected || strings.Join(intent.Signals, ",") != "refund" {
+		t.Errorf("Expected refund intent in Spanish, got %+v", intent)
+	}
+	if intent := DetectCancelIntent("Cancellable meetings are a great feature"); intent.Detected {
+		t.Errorf("Expected no intent for a partial-word match, got %+v", intent)
+	}
+}
+
+// TestPredictChurn_CancelIntentOverridesNLS tests that explicit cancel intent overrides the NLS buckets.
+func TestPredictChurn_CancelIntentOverridesNLS(t *testing.T) {
+	customerData := CustomerData{
+		NLSScore:         6,
+		Feedback:         "I'm cancelling next week",
+		CommentSentiment: "NEUTRAL",
+		CancelIntent:     true,
+		IntentSignals:    []string{"cancel"},
+	}
+	if prediction := PredictChurn(customerData); prediction.ChurnProbability != 0.9 {
+		t.Errorf("Expected 0.9 for explicit cancel intent, got %v (%s)", prediction.ChurnProbability, prediction.Reason)
+	}
+
+	customerData = CustomerData{NLSScore: 6, CommentSentiment: "NEUTRAL", Emotions: []string{"frustration"}}
+	if prediction := PredictChurn(customerData); prediction.ChurnProbability != 0.6 || !strings.Contains(prediction.Reason, "frustration") {
+		t.Errorf("Expected 0.6 for frustration, got %v (%s)", prediction.ChurnProbability, prediction.Reason)
+	}
+
+	scores := []LabelScore{{Label: "satisfaction", Score: 0.9}, {Label: "anger", Score: 0.2}}
+	if got := SelectEmotions(scores); strings.Join(got, ",") != "satisfaction" {
+		t.Errorf("Expected only satisfaction above the threshold, got %v", got)
+	}
+}
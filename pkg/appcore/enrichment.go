@@ -0,0 +1,164 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TopicScore is the zero-shot score of one taxonomy topic.
+type TopicScore struct {
+	Topic        string  `json:"topic"`                   // taxonomy topic name
+	Label        string  `json:"label"`                   // label sent to the zero-shot model
+	Score        float64 `json:"score"`                   // 0 if the model was not called or failed
+	SynonymMatch bool    `json:"synonym_match,omitempty"` // a synonym appeared in the text
+	Source       string  `json:"source,omitempty"`        // TopicScoreSourceSynonym if the model did not score it
+}
+
+// TopicScoreSourceSynonym marks a stored topic score that comes from a synonym
+// match alone because the zero-shot call failed.
+const TopicScoreSourceSynonym = "synonym"
+
+// SynonymTopicScores returns the synonym-matched topics of scores, marked as
+// synonym-only with no model score. EnrichFeedback stores these when the
+// zero-shot call fails so re-thresholding keeps the topics found by synonym.
+func SynonymTopicScores(scores []TopicScore) []TopicScore {
+	var matched []TopicScore
+	for _, ts := range scores {
+		if ts.SynonymMatch {
+			matched = append(matched, TopicScore{Topic: ts.Topic, Label: ts.Label, SynonymMatch: true, Source: TopicScoreSourceSynonym})
+		}
+	}
+	return matched
+}
+
+// EnrichmentScores is stored in customer_feedback.enrichment_scores: every
+// sentiment label and every candidate topic with its confidence, not just the
+// labels that cleared a cutoff.
+type EnrichmentScores struct {
+	SentimentModel string       `json:"sentiment_model,omitempty"`
+	Sentiment      []LabelScore `json:"sentiment,omitempty"`
+	TopicModel     string       `json:"topic_model,omitempty"`
+	Topics         []TopicScore `json:"topics,omitempty"`
+	Emotions       []LabelScore `json:"emotions,omitempty"` // scored with TopicModel's zero-shot model
+}
+
+// SentimentConfidence returns the score of the stored sentiment label, or 0 if unknown.
+func (s *EnrichmentScores) SentimentConfidence(label string) float64 {
+	if s == nil {
+		return 0
+	}
+	for _, ls := range s.Sentiment {
+		if ls.Label == label {
+			return ls.Score
+		}
+	}
+	return 0
+}
+
+// NewEnrichmentScores builds the stored scores from the outputs of models.
+func NewEnrichmentScores(models LanguageModels, sentiment []LabelScore, topics []TopicScore) *EnrichmentScores {
+	scores := &EnrichmentScores{Sentiment: sentiment, Topics: topics}
+	if len(sentiment) > 0 {
+		scores.SentimentModel = models.Sentiment
+	}
+	for _, ts := range topics {
+		if ts.Source != TopicScoreSourceSynonym {
+			scores.TopicModel = models.ZeroShot
+			break
+		}
+	}
+	return scores
+}
+
+// RethresholdReport summarizes a RethresholdTopics run.
+type RethresholdReport struct {
+	DryRun          bool   `json:"dry_run"`
+	TaxonomyVersion string `json:"taxonomy_version"`
+	Scanned         int64  `json:"scanned"`
+	Changed         int64  `json:"changed"`
+	Skipped         int64  `json:"skipped"`         // rows whose product line is no longer in the taxonomy
+	NoTopicScores   int64  `json:"no_topic_scores"` // rows stored without topic scores, left unchanged
+}
+
+const rethresholdBatchSize = 500
+
+type rethresholdRow struct {
+	ID               string            `json:"id"`
+	ProductLine      string            `json:"product_line"`
+	CommentTopics    []string          `json:"comment_topics"`
+	TopicCategories  []string          `json:"topic_categories"`
+	TaxonomyVersion  string            `json:"taxonomy_version"`
+	EnrichmentScores *EnrichmentScores `json:"enrichment_scores"`
+}
+
+// RethresholdTopics recomputes comment_topics and topic_categories of stored
+// feedback from enrichment_scores using the thresholds in taxonomy, without
+// calling Hugging Face. Rows whose topics change get the taxonomy's version.
+// Rows without stored topic scores are left alone, since there is nothing to
+// re-threshold. With dryRun set it only counts the rows that would change.
+func RethresholdTopics(taxonomy *Taxonomy, dryRun bool) (RethresholdReport, error) {
+	report := RethresholdReport{DryRun: dryRun, TaxonomyVersion: taxonomy.Version}
+	if SupabaseClient == nil {
+		return report, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+
+	lastID := ""
+	for {
+		query := SupabaseClient.From("customer_feedback").
+			Select("id,product_line,comment_topics,topic_categories,taxonomy_version,enrichment_scores", "", false).
+			Not("enrichment_scores", "is", "null")
+		if lastID != "" {
+			query = query.Lt("id", lastID)
+		}
+		rawData, _, err := query.Order("id", nil).Limit(rethresholdBatchSize, "").Execute()
+		if err != nil {
+			return report, fmt.Errorf("error selecting customer feedback scores: %w", err)
+		}
+		var rows []rethresholdRow
+		if err := json.Unmarshal(rawData, &rows); err != nil {
+			return report, fmt.Errorf("error unmarshalling customer feedback scores: %w", err)
+		}
+
+		for _, row := range rows {
+			report.Scanned++
+			productLine := row.ProductLine
+			if productLine == "" {
+				productLine = DefaultProductLine
+			}
+			if !taxonomy.HasProductLine(productLine) {
+				report.Skipped++
+				continue
+			}
+			if len(row.EnrichmentScores.Topics) == 0 {
+				report.NoTopicScores++
+				continue
+			}
+			assignment := taxonomy.SelectTopics(productLine, row.EnrichmentScores.Topics)
+			if sameStrings(assignment.Topics, row.CommentTopics) && sameStrings(assignment.Categories, row.TopicCategories) {
+				continue
+			}
+			report.Changed++
+			if dryRun {
+				continue
+			}
+			update := map[string]interface{}{
+				"comment_topics":   assignment.Topics,
+				"topic_categories": assignment.Categories,
+				"taxonomy_version": taxonomy.Version,
+			}
+			if _, _, err := SupabaseClient.From("customer_feedback").Update(update, "minimal", "").Eq("id", row.ID).Execute(); err != nil {
+				return report, fmt.Errorf("error updating topics of customer feedback %s: %w", row.ID, err)
+			}
+		}
+
+		if len(rows) < rethresholdBatchSize {
+			return report, nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+func sameStrings(a, b []string) bool {
+	return strings.Join(a, "\x1f") == strings.Join(b, "\x1f") && len(a) == len(b)
+}
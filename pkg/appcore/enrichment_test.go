@@ -0,0 +1,39 @@
+package appcore
+
+import (
+	"testing"
+)
+
+// TestBestLabel tests picking the sentiment label and its stored confidence.
+func TestBestLabel(t *testing.T) {
+	scores := []LabelScore{{Label: "NEGATIVE", Score: 0.1}, {Label: "POSITIVE", Score: 0.9}}
+	best, ok := BestLabel(scores)
+	if !ok || best.Label != "POSITIVE" {
+		t.Errorf("Expected POSITIVE, got %+v %v", best, ok)
+	}
+	if _, ok := BestLabel(nil); ok {
+		t.Errorf("Expected no best label for empty scores")
+	}
+	if c := NewEnrichmentScores(ModelsForLanguage("en"), scores, nil).SentimentConfidence("POSITIVE"); c != 0.9 {
+		t.Errorf("Expected confidence 0.9, got %v", c)
+	}
+}
+
+// TestSynonymTopicScores tests that a failed zero-shot call still stores the
+// synonym matches, which re-thresholding then keeps.
+func TestSynonymTopicScores(t *testing.T) {
+	taxonomy := &Taxonomy{Version: "v1", DefaultThreshold: 0.5, ProductLines: map[string][]TopicDefinition{
+		DefaultProductLine: {{Name: "pricing", Synonyms: []string{"expensive"}}, {Name: "speed"}},
+	}}
+	assigned := taxonomy.AssignTopics("Way too expensive", DefaultProductLine, nil)
+	stored := SynonymTopicScores(assigned.Scores)
+	if len(stored) != 1 || stored[0].Topic != "pricing" || stored[0].Source != TopicScoreSourceSynonym {
+		t.Fatalf("Expected only the synonym-matched pricing topic, got %+v", stored)
+	}
+	if got := taxonomy.SelectTopics(DefaultProductLine, stored).Topics; len(got) != 1 || got[0] != "pricing" {
+		t.Errorf("Expected re-thresholding to keep pricing, got %v", got)
+	}
+	if model := NewEnrichmentScores(ModelsForLanguage("en"), nil, stored).TopicModel; model != "" {
+		t.Errorf("Expected no topic model for synonym-only scores, got %q", model)
+	}
+}
@@ -0,0 +1,145 @@
+package appcore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// ProblemContentType is the RFC 7807 media type used for every error response.
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes each error code to form the problem "type" URI reference.
+const ProblemTypeBase = "/problems/"
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// ErrorCode is a stable, machine-readable error identifier. Clients should branch
+// on Code, never on the human-readable detail text.
+type ErrorCode struct {
+	Code   string
+	Status int
+	Title  string
+}
+
+// Documented error codes. Adding a code is fine; renaming or removing one is a breaking change.
+var (
+	ErrInvalidJSON          = ErrorCode{"invalid_json", http.StatusBadRequest, "Request body is not valid JSON"}
+	ErrValidationFailed     = ErrorCode{"validation_failed", http.StatusBadRequest, "Request validation failed"}
+	ErrUnauthorized         = ErrorCode{"unauthorized", http.StatusUnauthorized, "Missing or invalid credentials"}
+	ErrForbidden            = ErrorCode{"forbidden", http.StatusForbidden, "Operation not permitted"}
+	ErrNotFound             = ErrorCode{"not_found", http.StatusNotFound, "Resource not found"}
+	ErrMethodNotAllowed     = ErrorCode{"method_not_allowed", http.StatusMethodNotAllowed, "Method not allowed"}
+	ErrConflict             = ErrorCode{"conflict", http.StatusConflict, "Resource already exists"}
+	ErrPayloadTooLarge      = ErrorCode{"payload_too_large", http.StatusRequestEntityTooLarge, "Request body too large"}
+	ErrInitializationFailed = ErrorCode{"initialization_failed", http.StatusInternalServerError, "Server initialization failed"}
+	ErrStorageFailed        = ErrorCode{"storage_failed", http.StatusInternalServerError, "Storage operation failed"}
+	ErrInternal             = ErrorCode{"internal_error", http.StatusInternalServerError, "Internal server error"}
+)
+
+// ErrorCodes lists every documented error code; it feeds the OpenAPI document.
+var ErrorCodes = []ErrorCode{
+	ErrInvalidJSON, ErrValidationFailed, ErrUnauthorized, ErrForbidden, ErrNotFound,
+	ErrMethodNotAllowed, ErrConflict, ErrPayloadTooLarge, ErrInitializationFailed, ErrStorageFailed, ErrInternal,
+}
+
+// Field-level error codes used in FieldError.Code.
+const (
+	FieldErrRequired     = "required"
+	FieldErrInvalidType  = "invalid_type"
+	FieldErrOutOfRange   = "out_of_range"
+	FieldErrTooLong      = "too_long"
+	FieldErrInvalidValue = "invalid_value"
+	FieldErrUnknownField = "unknown_field"
+)
+
+// FieldErrorCodes lists every documented field-level error code.
+var FieldErrorCodes = []string{
+	FieldErrRequired, FieldErrInvalidType, FieldErrOutOfRange, FieldErrTooLong, FieldErrInvalidValue, FieldErrUnknownField,
+}
+
+// APIError is an RFC 7807 problem document extended with a stable code, the
+// offending field (if any) and the request ID for support correlation.
+type APIError struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Field     string       `json:"field,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return e.Code + ": " + e.Detail
+	}
+	return e.Code + ": " + e.Title
+}
+
+// NewAPIError builds an APIError for a documented code.
+func NewAPIError(code ErrorCode, detail string) *APIError {
+	return &APIError{
+		Type:   ProblemTypeBase + code.Code,
+		Title:  code.Title,
+		Status: code.Status,
+		Detail: detail,
+		Code:   code.Code,
+	}
+}
+
+// NewValidationError builds a validation_failed problem for a single field.
+func NewValidationError(field, fieldCode, message string) *APIError {
+	apiErr := NewAPIError(ErrValidationFailed, message)
+	apiErr.Field = field
+	apiErr.Errors = []FieldError{{Field: field, Code: fieldCode, Message: message}}
+	return apiErr
+}
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+// RequestID returns the caller's X-Request-ID if it is well formed, otherwise a
+// new random ID. The ID is echoed back in the response header.
+func RequestID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		return id
+	}
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID.MatchString(id) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			log.Printf("Error generating request ID: %v", err)
+		}
+		id = hex.EncodeToString(b)
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return id
+}
+
+// RespondWithProblem writes an APIError as application/problem+json.
+func RespondWithProblem(w http.ResponseWriter, r *http.Request, apiErr *APIError) {
+	apiErr.RequestID = RequestID(w, r)
+	if apiErr.Instance == "" && r.URL != nil {
+		apiErr.Instance = r.URL.Path
+	}
+	response, err := json.Marshal(apiErr)
+	if err != nil {
+		log.Printf("Error marshalling problem response: %v", err)
+		response = []byte(`{"type":"/problems/internal_error","title":"Internal server error","status":500,"code":"internal_error"}`)
+		apiErr.Status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(apiErr.Status)
+	w.Write(response)
+}
+
+// RespondWithError writes a problem document for a documented error code.
+func RespondWithError(w http.ResponseWriter, r *http.Request, code ErrorCode, detail string) {
+	RespondWithProblem(w, r, NewAPIError(code, detail))
+}
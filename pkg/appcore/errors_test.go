@@ -0,0 +1,43 @@
+package appcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRespondWithProblem tests the problem+json body, the request ID round trip and the content type.
+func TestRespondWithProblem(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/predict", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+
+	RespondWithError(rec, req, ErrStorageFailed, "Failed to store customer data.")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ProblemContentType, ct)
+	}
+	if id := rec.Header().Get(RequestIDHeader); id != "req-123" {
+		t.Errorf("Expected request ID to be echoed, got %q", id)
+	}
+	var problem APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Error decoding problem body: %v", err)
+	}
+	if problem.Code != "storage_failed" || problem.Type != "/problems/storage_failed" || problem.Status != 500 ||
+		problem.RequestID != "req-123" || problem.Instance != "/predict" {
+		t.Errorf("Unexpected problem document: %+v", problem)
+	}
+
+	// A malformed request ID is replaced with a generated one.
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	rec = httptest.NewRecorder()
+	RespondWithError(rec, req, ErrInternal, "")
+	if id := rec.Header().Get(RequestIDHeader); len(id) != 32 {
+		t.Errorf("Expected a generated 32-character request ID, got %q", id)
+	}
+}
@@ -0,0 +1,239 @@
+package appcore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record types accepted by the bulk importer (cmd/import).
+const (
+	ImportTypeTickets  = "tickets"  // support ticket events, see TicketRequestSpec
+	ImportTypeAccounts = "accounts" // account attributes, see AccountRequestSpec
+	ImportTypeUsage    = "usage"    // usage periods, see UsageRequestSpec
+)
+
+// Import file formats.
+const (
+	ImportFormatJSONL = "jsonl" // one JSON object per line
+	ImportFormatCSV   = "csv"   // a header row of field names, then one record per row
+)
+
+// maxImportErrors caps how many failed records are listed in an ImportReport.
+const maxImportErrors = 20
+
+// importKind is one record type of the importer: the spec its records are
+// validated against, which also types CSV cells, and how one record is stored.
+type importKind struct {
+	spec    ObjectSpec
+	process func(record []byte) error
+}
+
+var importKinds = map[string]importKind{
+	ImportTypeTickets:  {spec: TicketRequestSpec, process: importTicket},
+	ImportTypeAccounts: {spec: AccountRequestSpec, process: importAccount},
+	ImportTypeUsage:    {spec: UsageRequestSpec, process: importUsage},
+}
+
+// ImportTypes lists the record types accepted by ImportRecords.
+func ImportTypes() []string {
+	return []string{ImportTypeTickets, ImportTypeAccounts, ImportTypeUsage}
+}
+
+// errImportSkipped marks a record that was already stored.
+var errImportSkipped = errors.New("already stored")
+
+func importTicket(record []byte) error {
+	req, apiErr := DecodeTicketRequest(bytes.NewReader(record))
+	if apiErr != nil {
+		return apiErr
+	}
+	if _, err := ProcessTicket(req); err != nil {
+		if errors.Is(err, ErrTicketEventExists) {
+			return errImportSkipped
+		}
+		return err
+	}
+	return nil
+}
+
+func importAccount(record []byte) error {
+	req, apiErr := DecodeAccountRequest(bytes.NewReader(record))
+	if apiErr != nil {
+		return apiErr
+	}
+	_, err := StoreAccountAttributes(req)
+	return err
+}
+
+func importUsage(record []byte) error {
+	req, apiErr := DecodeUsageRequest(bytes.NewReader(record))
+	if apiErr != nil {
+		return apiErr
+	}
+	_, err := StoreAccountUsage(req)
+	return err
+}
+
+// ImportError is a record that could not be imported.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportReport summarises an import. Ticket events that are already stored are
+// skipped and account attributes and usage periods are upserted, so an
+// interrupted import can simply be run again.
+type ImportReport struct {
+	Type     string        `json:"type"`
+	Read     int           `json:"read"`
+	Imported int           `json:"imported"`
+	Skipped  int           `json:"skipped"`
+	Failed   int           `json:"failed"`
+	Stopped  bool          `json:"stopped,omitempty"`
+	Errors   []ImportError `json:"errors,omitempty"` // the first failures
+}
+
+// ImportFormatForPath picks the import format from a file extension: .csv is
+// CSV, anything else JSON Lines.
+func ImportFormatForPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ImportFormatCSV
+	}
+	return ImportFormatJSONL
+}
+
+// ImportRecords reads records of the given type from r and stores each one
+// through the same validation and pipeline as the corresponding endpoint, at
+// most rate records per second (0 is unlimited). A record that fails is counted
+// and the import continues; an error is returned only if r cannot be read. It
+// stops between records when stop is closed.
+func ImportRecords(kind, format string, r io.Reader, rate float64, stop <-chan struct{}) (ImportReport, error) {
+	report := ImportReport{Type: kind}
+	k, ok := importKinds[kind]
+	if !ok {
+		return report, fmt.Errorf("unknown import type %q (known: %s)", kind, strings.Join(ImportTypes(), ", "))
+	}
+	limiter := newRateLimiter(rate)
+	errStopped := errors.New("stopped")
+	err := ReadImportRecords(format, r, k.spec, func(line int, record []byte) error {
+		select {
+		case <-stop:
+			return errStopped
+		case <-time.After(limiter.reserve(time.Now())):
+		}
+		report.Read++
+		switch err := k.process(record); {
+		case err == nil:
+			report.Imported++
+		case errors.Is(err, errImportSkipped):
+			report.Skipped++
+		default:
+			report.Failed++
+			if len(report.Errors) < maxImportErrors {
+				report.Errors = append(report.Errors, ImportError{Line: line, Error: err.Error()})
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errStopped) {
+		report.Stopped = true
+		return report, nil
+	}
+	return report, err
+}
+
+// ReadImportRecords calls fn with each record of r as a JSON object and the
+// line it starts on. JSON Lines records are passed as-is and blank lines are
+// ignored. CSV cells are converted to the type of the spec field named in the
+// header, and empty cells are left out; array and object cells hold JSON, e.g.
+// [{"feature": "exports", "count": 3}]. Cells that don't parse are passed as
+// strings so that validation reports them. An error from fn stops the read and
+// is returned.
+func ReadImportRecords(format string, r io.Reader, spec ObjectSpec, fn func(line int, record []byte) error) error {
+	switch format {
+	case ImportFormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxRequestBodyBytes)
+		line := 0
+		for scanner.Scan() {
+			line++
+			record := bytes.TrimSpace(scanner.Bytes())
+			if len(record) == 0 {
+				continue
+			}
+			if err := fn(line, append([]byte(nil), record...)); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error reading line %d: %w", line+1, err)
+		}
+		return nil
+	case ImportFormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("error reading CSV header: %w", err)
+		}
+		types := map[string]string{}
+		for _, field := range spec.Fields {
+			types[field.Name] = field.Type
+		}
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("error reading CSV: %w", err)
+			}
+			line, _ := reader.FieldPos(0)
+			record := map[string]interface{}{}
+			for i, cell := range row {
+				if i >= len(header) || cell == "" {
+					continue
+				}
+				name := strings.TrimSpace(header[i])
+				record[name] = csvValue(types[name], cell)
+			}
+			body, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("error encoding CSV line %d: %w", line, err)
+			}
+			if err := fn(line, body); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown import format %q (known: %s, %s)", format, ImportFormatJSONL, ImportFormatCSV)
+	}
+}
+
+func csvValue(fieldType, cell string) interface{} {
+	switch fieldType {
+	case "integer", "number":
+		if v, err := strconv.ParseFloat(strings.TrimSpace(cell), 64); err == nil {
+			return v
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(strings.TrimSpace(cell)); err == nil {
+			return v
+		}
+	case "array", "object":
+		var v interface{}
+		if err := json.Unmarshal([]byte(cell), &v); err == nil {
+			return v
+		}
+	}
+	return cell
+}
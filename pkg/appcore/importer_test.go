@@ -0,0 +1,39 @@
+package appcore
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestReadImportRecords tests JSON Lines and typed CSV records.
+func TestReadImportRecords(t *testing.T) {
+	var records []string
+	collect := func(line int, record []byte) error {
+		records = append(records, fmt.Sprintf("%d:%s", line, record))
+		return nil
+	}
+	jsonl := "{\"ticket_id\": \"T1\"}\n\n{\"ticket_id\": \"T2\"}\n"
+	if err := ReadImportRecords(ImportFormatJSONL, strings.NewReader(jsonl), TicketRequestSpec, collect); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1] != `3:{"ticket_id": "T2"}` {
+		t.Errorf("Unexpected JSON Lines records %v", records)
+	}
+
+	records = nil
+	csvData := "account_id,ticket_id,reopen_count,time_to_resolution_hours,priority\nacct_1,T1,2,,high\nacct_1,T2,x,1.5,\n"
+	if err := ReadImportRecords(ImportFormatCSV, strings.NewReader(csvData), TicketRequestSpec, collect); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`2:{"account_id":"acct_1","priority":"high","reopen_count":2,"ticket_id":"T1"}`,
+		`3:{"account_id":"acct_1","reopen_count":"x","ticket_id":"T2","time_to_resolution_hours":1.5}`,
+	}
+	if strings.Join(records, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %v, got %v", want, records)
+	}
+	if ImportFormatForPath("tickets.CSV") != ImportFormatCSV || ImportFormatForPath("tickets.jsonl") != ImportFormatJSONL {
+		t.Errorf("Unexpected formats for file extensions")
+	}
+}
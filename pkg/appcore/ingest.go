@@ -0,0 +1,467 @@
+package appcore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Survey payload formats accepted by POST /ingest/{source}.
+const (
+	IngestFormatTypeform     = "typeform"
+	IngestFormatSurveyMonkey = "surveymonkey"
+	IngestFormatDelighted    = "delighted"
+	IngestFormatQualtrics    = "qualtrics"
+	IngestFormatJSON         = "json" // any JSON object, mapped by dotted path
+)
+
+// IngestMapping names the payload fields that become the /predict request.
+// Keys are dotted paths into the payload ("event_data.score", "items.0.id")
+// plus the shortcuts each format adds, e.g. "answers.<ref>" for Typeform.
+type IngestMapping struct {
+	NLSScore     string `json:"nls_score"`
+	FeedbackText string `json:"feedback_text,omitempty"`
+	AccountID    string `json:"account_id,omitempty"`
+	ProductLine  string `json:"product_line,omitempty"`
+	ResponseID   string `json:"response_id,omitempty"` // the tool's ID of the response; redeliveries of it are stored once
+}
+
+// IngestSource is one configured survey tool, served at /ingest/{Name}.
+type IngestSource struct {
+	Name        string        `json:"name"`
+	Format      string        `json:"format"`
+	SecretEnv   string        `json:"secret_env"`              // environment variable holding the signing secret or token
+	APITokenEnv string        `json:"api_token_env,omitempty"` // SurveyMonkey, Qualtrics: token used to fetch the response
+	APIBaseURL  string        `json:"api_base_url,omitempty"`  // Qualtrics: https://<datacenter>.qualtrics.com/API/v3
+	Mapping     IngestMapping `json:"mapping"`
+	ProductLine string        `json:"product_line,omitempty"` // used when the mapping has no product_line or it is empty
+	Async       bool          `json:"async,omitempty"`        // queue a prediction job and answer 202 at once
+
+	// TokenInQuery accepts the token in the ?token= query parameter, for tools
+	// whose webhooks can't set an Authorization header. Query strings end up in
+	// proxy and access logs, so it is off unless the source needs it.
+	TokenInQuery bool `json:"token_in_query,omitempty"`
+
+	Secret   string `json:"-"`
+	APIToken string `json:"-"`
+}
+
+// defaultIngestMappings are used for the fields a source leaves unmapped, for
+// formats whose payload shape is fixed.
+var defaultIngestMappings = map[string]IngestMapping{
+	IngestFormatTypeform:     {ResponseID: "form_response.token"},
+	IngestFormatSurveyMonkey: {ResponseID: "id"},
+	IngestFormatDelighted:    {NLSScore: "event_data.score", FeedbackText: "event_data.comment", ResponseID: "event_data.id"},
+	IngestFormatQualtrics:    {ResponseID: "responseId"},
+}
+
+// IngestSources are the configured sources by name; see ConfigureIngestSources.
+var IngestSources = map[string]*IngestSource{}
+
+// SurveyMonkeyAPIBase is where SurveyMonkey response details are fetched from.
+var SurveyMonkeyAPIBase = "https://api.surveymonkey.com/v3"
+
+var ingestSourceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// LoadIngestSources parses a JSON array of sources, applies the default
+// mappings and reads each source's secret and API token from the environment.
+func LoadIngestSources(data []byte) (map[string]*IngestSource, error) {
+	var list []*IngestSource
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("ingest sources must be a JSON array: %w", err)
+	}
+	sources := make(map[string]*IngestSource, len(list))
+	for _, source := range list {
+		if !ingestSourceName.MatchString(source.Name) {
+			return nil, fmt.Errorf("ingest source name %q must be lower-case letters, digits, '-' or '_'", source.Name)
+		}
+		if _, ok := sources[source.Name]; ok {
+			return nil, fmt.Errorf("ingest source %q is defined twice", source.Name)
+		}
+		switch source.Format {
+		case IngestFormatTypeform, IngestFormatSurveyMonkey, IngestFormatDelighted, IngestFormatQualtrics, IngestFormatJSON:
+		default:
+			return nil, fmt.Errorf("ingest source %q has unknown format %q", source.Name, source.Format)
+		}
+		defaults := defaultIngestMappings[source.Format]
+		for _, field := range []struct {
+			dst      *string
+			fallback string
+		}{
+			{&source.Mapping.NLSScore, defaults.NLSScore},
+			{&source.Mapping.FeedbackText, defaults.FeedbackText},
+			{&source.Mapping.AccountID, defaults.AccountID},
+			{&source.Mapping.ProductLine, defaults.ProductLine},
+			{&source.Mapping.ResponseID, defaults.ResponseID},
+		} {
+			if *field.dst == "" {
+				*field.dst = field.fallback
+			}
+		}
+		if source.Mapping.NLSScore == "" {
+			return nil, fmt.Errorf("ingest source %q needs a mapping for nls_score", source.Name)
+		}
+		// The endpoint is public, so every source must authenticate its requests.
+		if source.SecretEnv == "" || strings.TrimSpace(os.Getenv(source.SecretEnv)) == "" {
+			return nil, fmt.Errorf("ingest source %q needs secret_env naming a set environment variable", source.Name)
+		}
+		source.Secret = strings.TrimSpace(os.Getenv(source.SecretEnv))
+		if source.APITokenEnv != "" {
+			source.APIToken = strings.TrimSpace(os.Getenv(source.APITokenEnv))
+		}
+		if source.TokenInQuery && (source.Format == IngestFormatTypeform || source.Format == IngestFormatSurveyMonkey) {
+			return nil, fmt.Errorf("ingest source %q is signed, token_in_query does not apply", source.Name)
+		}
+		if source.Format == IngestFormatQualtrics && source.APITokenEnv != "" {
+			if u, err := url.Parse(source.APIBaseURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				return nil, fmt.Errorf("ingest source %q needs api_base_url, e.g. https://<datacenter>.qualtrics.com/API/v3, to fetch Qualtrics responses", source.Name)
+			}
+		}
+		sources[source.Name] = source
+	}
+	return sources, nil
+}
+
+// ConfigureIngestSources sets IngestSources from the file at INGEST_SOURCES_PATH.
+// Without it /ingest answers 401 for every source.
+func ConfigureIngestSources() error {
+	path := strings.TrimSpace(os.Getenv("INGEST_SOURCES_PATH"))
+	if path == "" {
+		IngestSources = map[string]*IngestSource{}
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading ingest sources: %w", err)
+	}
+	sources, err := LoadIngestSources(data)
+	if err != nil {
+		return fmt.Errorf("error loading ingest sources %s: %w", path, err)
+	}
+	IngestSources = sources
+	log.Printf("Loaded %d survey ingest sources.", len(sources))
+	return nil
+}
+
+// Authenticate checks a request against the source's secret: Typeform's
+// Typeform-Signature and SurveyMonkey's Sm-Signature are verified as HMACs of
+// the body; other formats must send the secret as a bearer token, or in the
+// token query parameter if the source allows it (TokenInQuery).
+func (s *IngestSource) Authenticate(r *http.Request, body []byte) error {
+	switch s.Format {
+	case IngestFormatTypeform:
+		mac := hmac.New(sha256.New, []byte(s.Secret))
+		mac.Write(body)
+		expected := "sha256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(r.Header.Get("Typeform-Signature")), []byte(expected)) {
+			return fmt.Errorf("invalid Typeform-Signature")
+		}
+		return nil
+	case IngestFormatSurveyMonkey:
+		// The key is "<api key>&<api secret>" of the SurveyMonkey app.
+		mac := hmac.New(sha1.New, []byte(s.Secret))
+		mac.Write(body)
+		expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(r.Header.Get("Sm-Signature")), []byte(expected)) {
+			return fmt.Errorf("invalid Sm-Signature")
+		}
+		return nil
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" && s.TokenInQuery {
+		token = r.URL.Query().Get("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.Secret)) != 1 {
+		return fmt.Errorf("invalid or missing ingest token")
+	}
+	return nil
+}
+
+// Fields flattens a native payload into the keys that mappings refer to. It
+// returns invalid_json for a malformed payload and internal_error if a
+// SurveyMonkey or Qualtrics response could not be fetched, so that the tool
+// retries.
+func (s *IngestSource) Fields(body []byte) (map[string]string, *APIError) {
+	if s.Format == IngestFormatQualtrics {
+		if surveyID, responseID, ok := qualtricsNotification(body); ok {
+			payload, err := s.qualtricsResponse(surveyID, responseID)
+			if err != nil {
+				log.Printf("Error reading Qualtrics payload for ingest source %s: %v", s.Name, err)
+				return nil, NewAPIError(ErrInternal, "Could not fetch the Qualtrics response.")
+			}
+			body = payload
+		}
+	}
+	var payload interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, NewAPIError(ErrInvalidJSON, "Invalid JSON request body.")
+	}
+	if s.Format == IngestFormatSurveyMonkey {
+		var err error
+		if payload, err = s.surveyMonkeyDetails(payload); err != nil {
+			log.Printf("Error reading SurveyMonkey payload for ingest source %s: %v", s.Name, err)
+			return nil, NewAPIError(ErrInternal, "Could not fetch the SurveyMonkey response.")
+		}
+	}
+	fields := map[string]string{}
+	flattenJSON("", payload, fields)
+
+	object, _ := payload.(map[string]interface{})
+	switch s.Format {
+	case IngestFormatTypeform:
+		typeformAnswers(object, fields)
+	case IngestFormatSurveyMonkey:
+		surveyMonkeyAnswers(object, fields)
+	case IngestFormatQualtrics:
+		// API responses wrap the response in "result"; other payloads may not.
+		if result, ok := object["result"].(map[string]interface{}); ok {
+			unwrapped := map[string]string{}
+			flattenJSON("", result, unwrapped)
+			for key, value := range unwrapped {
+				if _, taken := fields[key]; !taken {
+					fields[key] = value
+				}
+			}
+		}
+	}
+	return fields, nil
+}
+
+// flattenJSON stores every scalar in value under its dotted path.
+func flattenJSON(prefix string, value interface{}, out map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			flattenJSON(join(key), item, out)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenJSON(join(strconv.Itoa(i)), item, out)
+		}
+	case string:
+		out[prefix] = v
+	case json.Number:
+		out[prefix] = v.String()
+	case bool:
+		out[prefix] = strconv.FormatBool(v)
+	}
+}
+
+// typeformAnswers adds "answers.<field ref>" and "answers.<field id>" for each
+// answer of a form_response, and "hidden.<name>" for its hidden fields.
+func typeformAnswers(payload map[string]interface{}, fields map[string]string) {
+	response, _ := payload["form_response"].(map[string]interface{})
+	if response == nil {
+		return
+	}
+	if hidden, ok := response["hidden"].(map[string]interface{}); ok {
+		flattenJSON("hidden", hidden, fields)
+	}
+	answers, _ := response["answers"].([]interface{})
+	for _, item := range answers {
+		answer, _ := item.(map[string]interface{})
+		field, _ := answer["field"].(map[string]interface{})
+		if answer == nil || field == nil {
+			continue
+		}
+		var value string
+		switch answerType, _ := answer["type"].(string); answerType {
+		case "choice":
+			choice, _ := answer["choice"].(map[string]interface{})
+			value, _ = choice["label"].(string)
+		case "choices":
+			choices, _ := answer["choices"].(map[string]interface{})
+			labels, _ := choices["labels"].([]interface{})
+			var parts []string
+			for _, label := range labels {
+				if s, ok := label.(string); ok {
+					parts = append(parts, s)
+				}
+			}
+			value = strings.Join(parts, ", ")
+		default:
+			// text, email, number, boolean, date, url, phone_number, file_url
+			scalar := map[string]string{}
+			flattenJSON("", answer[answerType], scalar)
+			value = scalar[""]
+		}
+		for _, key := range []string{"ref", "id"} {
+			if name, _ := field[key].(string); name != "" {
+				fields["answers."+name] = value
+			}
+		}
+	}
+}
+
+// surveyMonkeyAnswers adds "answers.<question id>" for each answered question
+// of a response details payload, preferring the simple_text of choices.
+func surveyMonkeyAnswers(payload map[string]interface{}, fields map[string]string) {
+	pages, _ := payload["pages"].([]interface{})
+	for _, item := range pages {
+		page, _ := item.(map[string]interface{})
+		questions, _ := page["questions"].([]interface{})
+		for _, item := range questions {
+			question, _ := item.(map[string]interface{})
+			id, _ := question["id"].(string)
+			answers, _ := question["answers"].([]interface{})
+			var parts []string
+			for _, a := range answers {
+				answer, _ := a.(map[string]interface{})
+				if text, _ := answer["simple_text"].(string); text != "" {
+					parts = append(parts, text)
+				} else if text, _ := answer["text"].(string); text != "" {
+					parts = append(parts, text)
+				}
+			}
+			if id != "" && len(parts) > 0 {
+				fields["answers."+id] = strings.Join(parts, "\n")
+			}
+		}
+	}
+}
+
+// surveyMonkeyDetails returns payload itself if it already holds a response,
+// or fetches the response details for a response_completed event notification.
+func (s *IngestSource) surveyMonkeyDetails(payload interface{}) (interface{}, error) {
+	event, _ := payload.(map[string]interface{})
+	if _, isResponse := event["pages"]; isResponse || event["object_type"] != "response" {
+		return payload, nil
+	}
+	resources, _ := event["resources"].(map[string]interface{})
+	surveyID, _ := resources["survey_id"].(string)
+	responseID, _ := event["object_id"].(string)
+	if surveyID == "" || responseID == "" {
+		return nil, fmt.Errorf("SurveyMonkey event has no survey_id or object_id")
+	}
+	if s.APIToken == "" {
+		return nil, fmt.Errorf("ingest source %q needs api_token_env to fetch SurveyMonkey responses", s.Name)
+	}
+	endpoint := fmt.Sprintf("%s/surveys/%s/responses/%s/details?simple=true", strings.TrimRight(SurveyMonkeyAPIBase, "/"), url.PathEscape(surveyID), url.PathEscape(responseID))
+	body, err := fetchSurveyResponse(endpoint, "Authorization", "Bearer "+s.APIToken)
+	if err != nil {
+		return nil, fmt.Errorf("SurveyMonkey response %s: %w", responseID, err)
+	}
+	var details interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&details); err != nil {
+		return nil, fmt.Errorf("error parsing SurveyMonkey response %s: %w", responseID, err)
+	}
+	return details, nil
+}
+
+// qualtricsNotification reads a Qualtrics event subscription notification
+// (surveyengine.completedResponse), which is form-encoded and carries only the
+// survey and response IDs.
+func qualtricsNotification(body []byte) (surveyID, responseID string, ok bool) {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) == 0 || trimmed[0] == '{' {
+		return "", "", false
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", "", false
+	}
+	surveyID, responseID = form.Get("SurveyID"), form.Get("ResponseID")
+	return surveyID, responseID, surveyID != "" && responseID != ""
+}
+
+// qualtricsResponse fetches a response from the Qualtrics API for an event
+// notification. The values are under "result", like in response exports.
+func (s *IngestSource) qualtricsResponse(surveyID, responseID string) ([]byte, error) {
+	if s.APIToken == "" {
+		return nil, fmt.Errorf("ingest source %q needs api_token_env and api_base_url to fetch Qualtrics responses", s.Name)
+	}
+	endpoint := fmt.Sprintf("%s/surveys/%s/responses/%s", strings.TrimRight(s.APIBaseURL, "/"), url.PathEscape(surveyID), url.PathEscape(responseID))
+	body, err := fetchSurveyResponse(endpoint, "X-API-TOKEN", s.APIToken)
+	if err != nil {
+		return nil, fmt.Errorf("Qualtrics response %s: %w", responseID, err)
+	}
+	return body, nil
+}
+
+// fetchSurveyResponse GETs a survey tool API endpoint with the given
+// authentication header and returns the body of a 200 response.
+func fetchSurveyResponse(endpoint, authHeader, authValue string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(authHeader, authValue)
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error reading: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// PredictRequest maps a flattened payload to a /predict request and validates
+// it like a /predict body. If the payload has a response ID, the request's
+// FeedbackID is derived from it, so a response the tool delivers twice is
+// stored once.
+func (s *IngestSource) PredictRequest(fields map[string]string) (ApiPredictRequest, *APIError) {
+	raw := strings.TrimSpace(fields[s.Mapping.NLSScore])
+	if raw == "" {
+		return ApiPredictRequest{}, NewValidationError("nls_score", FieldErrRequired, fmt.Sprintf("payload field %q (nls_score) is missing", s.Mapping.NLSScore))
+	}
+	score, err := strconv.ParseFloat(raw, 64)
+	if err != nil || score != math.Trunc(score) {
+		return ApiPredictRequest{}, NewValidationError("nls_score", FieldErrInvalidType, fmt.Sprintf("payload field %q (nls_score) must be a whole number, got %q", s.Mapping.NLSScore, raw))
+	}
+	body := map[string]interface{}{"nls_score": int(score)}
+	if text := fields[s.Mapping.FeedbackText]; s.Mapping.FeedbackText != "" && text != "" {
+		body["feedback_text"] = text
+	}
+	if id := strings.TrimSpace(fields[s.Mapping.AccountID]); s.Mapping.AccountID != "" && id != "" {
+		body["account_id"] = id
+	}
+	productLine := s.ProductLine
+	if line := strings.TrimSpace(fields[s.Mapping.ProductLine]); s.Mapping.ProductLine != "" && line != "" {
+		productLine = line
+	}
+	if productLine != "" {
+		body["product_line"] = productLine
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return ApiPredictRequest{}, NewAPIError(ErrInternal, "Could not build the prediction request.")
+	}
+	req, apiErr := DecodePredictRequest(bytes.NewReader(data))
+	if apiErr != nil {
+		return req, apiErr
+	}
+	if id := strings.TrimSpace(fields[s.Mapping.ResponseID]); s.Mapping.ResponseID != "" && id != "" {
+		req.FeedbackID = NameUUID("ingest:" + s.Name + ":" + id)
+	}
+	return req, nil
+}
@@ -0,0 +1,182 @@
+package appcore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestLoadIngestSources tests default mappings and that every source needs a secret.
+func TestLoadIngestSources(t *testing.T) {
+	t.Setenv("TEST_INGEST_SECRET", "s3cret")
+	sources, err := LoadIngestSources([]byte(`[{"name": "delighted", "format": "delighted", "secret_env": "TEST_INGEST_SECRET"}]`))
+	if err != nil {
+		t.Fatalf("Expected the source to load, got %v", err)
+	}
+	if source := sources["delighted"]; source.Mapping.NLSScore != "event_data.score" || source.Secret != "s3cret" {
+		t.Errorf("Expected Delighted defaults and the secret from the environment, got %+v", source)
+	}
+	for _, invalid := range []string{
+		`[{"name": "tf", "format": "typeform", "secret_env": "TEST_INGEST_SECRET"}]`,                                  // no nls_score mapping
+		`[{"name": "x", "format": "delighted", "secret_env": "TEST_INGEST_UNSET"}]`,                                   // secret not set
+		`[{"name": "x", "format": "googleforms", "secret_env": "TEST_INGEST_SECRET", "mapping": {"nls_score": "a"}}]`, // unknown format
+		`[{"name": "Bad Name", "format": "json", "secret_env": "TEST_INGEST_SECRET", "mapping": {"nls_score": "a"}}]`,
+		`[{"name": "tf", "format": "typeform", "secret_env": "TEST_INGEST_SECRET", "token_in_query": true, "mapping": {"nls_score": "a"}}]`,
+		`[{"name": "q", "format": "qualtrics", "secret_env": "TEST_INGEST_SECRET", "api_token_env": "TEST_INGEST_SECRET", "mapping": {"nls_score": "a"}}]`, // no api_base_url
+	} {
+		if _, err := LoadIngestSources([]byte(invalid)); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+// TestIngestSource_Typeform tests signature verification and mapping answers by field ref.
+func TestIngestSource_Typeform(t *testing.T) {
+	source := &IngestSource{Name: "typeform", Format: IngestFormatTypeform, Secret: "whsec",
+		Mapping: IngestMapping{NLSScore: "answers.nps", FeedbackText: "answers.why", AccountID: "hidden.account_id"}}
+	body := []byte(`{"event_type": "form_response", "form_response": {
+		"hidden": {"account_id": "acct_42"},
+		"answers": [
+			{"type": "number", "number": 3, "field": {"id": "f1", "ref": "nps", "type": "nps"}},
+			{"type": "text", "text": "Support never answers", "field": {"id": "f2", "ref": "why", "type": "long_text"}}
+		]}}`)
+
+	request := httptest.NewRequest(http.MethodPost, "/ingest/typeform", nil)
+	request.Header.Set("Typeform-Signature", "sha256=invalid")
+	if err := source.Authenticate(request, body); err == nil {
+		t.Errorf("Expected an invalid signature to be rejected")
+	}
+	mac := hmac.New(sha256.New, []byte("whsec"))
+	mac.Write(body)
+	request.Header.Set("Typeform-Signature", "sha256="+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	if err := source.Authenticate(request, body); err != nil {
+		t.Errorf("Expected a valid signature to be accepted, got %v", err)
+	}
+
+	fields, apiErr := source.Fields(body)
+	if apiErr != nil {
+		t.Fatalf("Expected the payload to flatten, got %v", apiErr)
+	}
+	req, apiErr := source.PredictRequest(fields)
+	if apiErr != nil {
+		t.Fatalf("Expected a valid request, got %v %+v", apiErr, apiErr.Errors)
+	}
+	if *req.NLSScore != 3 || req.FeedbackText != "Support never answers" || req.AccountID != "acct_42" || req.ProductLine != DefaultProductLine {
+		t.Errorf("Unexpected request %+v", req)
+	}
+}
+
+// TestIngestSource_TokenFormats tests token authentication and the Delighted and Qualtrics mappings.
+func TestIngestSource_TokenFormats(t *testing.T) {
+	delighted := &IngestSource{Name: "delighted", Format: IngestFormatDelighted, Secret: "tok",
+		Mapping: IngestMapping{NLSScore: "event_data.score", FeedbackText: "event_data.comment"}}
+	request := httptest.NewRequest(http.MethodPost, "/ingest/delighted", nil)
+	request.Header.Set("Authorization", "Bearer tok")
+	if err := delighted.Authenticate(request, nil); err != nil {
+		t.Errorf("Expected the bearer token to be accepted, got %v", err)
+	}
+	request = httptest.NewRequest(http.MethodPost, "/ingest/delighted?token=tok", nil)
+	if err := delighted.Authenticate(request, nil); err == nil {
+		t.Errorf("Expected the token query parameter to be rejected unless token_in_query is set")
+	}
+	delighted.TokenInQuery = true
+	if err := delighted.Authenticate(request, nil); err != nil {
+		t.Errorf("Expected the token query parameter to be accepted with token_in_query, got %v", err)
+	}
+	request = httptest.NewRequest(http.MethodPost, "/ingest/delighted?token=nope", nil)
+	if err := delighted.Authenticate(request, nil); err == nil {
+		t.Errorf("Expected a wrong token to be rejected")
+	}
+	fields, _ := delighted.Fields([]byte(`{"event_type": "survey_response.created", "event_data": {"score": 12, "comment": "ok"}}`))
+	if _, apiErr := delighted.PredictRequest(fields); apiErr == nil || apiErr.Errors[0].Field != "nls_score" {
+		t.Errorf("Expected an out-of-range score to fail validation on nls_score, got %v", apiErr)
+	}
+
+	qualtrics := &IngestSource{Name: "qualtrics", Format: IngestFormatQualtrics, Secret: "tok",
+		Mapping: IngestMapping{NLSScore: "values.QID1", FeedbackText: "values.QID2_TEXT"}}
+	fields, _ = qualtrics.Fields([]byte(`{"result": {"responseId": "R_1", "values": {"QID1": "9", "QID2_TEXT": "Love it"}}}`))
+	req, apiErr := qualtrics.PredictRequest(fields)
+	if apiErr != nil || *req.NLSScore != 9 || req.FeedbackText != "Love it" {
+		t.Errorf("Expected the unwrapped Qualtrics values to map, got %+v, %v", req, apiErr)
+	}
+	fields, _ = qualtrics.Fields([]byte(`{"values": {"QID1": "7.5"}}`))
+	if _, apiErr := qualtrics.PredictRequest(fields); apiErr == nil {
+		t.Errorf("Expected a fractional score to be rejected")
+	}
+}
+
+// TestIngestSource_ResponseID tests that the feedback ID is derived from the
+// source and its default response ID field, so redeliveries map to one ID.
+func TestIngestSource_ResponseID(t *testing.T) {
+	t.Setenv("TEST_INGEST_SECRET", "s3cret")
+	sources, err := LoadIngestSources([]byte(`[
+		{"name": "tf", "format": "typeform", "secret_env": "TEST_INGEST_SECRET", "mapping": {"nls_score": "answers.nps"}},
+		{"name": "delighted", "format": "delighted", "secret_env": "TEST_INGEST_SECRET"},
+		{"name": "q", "format": "qualtrics", "secret_env": "TEST_INGEST_SECRET", "mapping": {"nls_score": "values.QID1"}}]`))
+	if err != nil {
+		t.Fatalf("Expected the sources to load, got %v", err)
+	}
+	cases := []struct {
+		source, body, expected string
+	}{
+		{"tf", `{"form_response": {"token": "tok_1", "answers": [{"type": "number", "number": 3, "field": {"id": "f1", "ref": "nps"}}]}}`, NameUUID("ingest:tf:tok_1")},
+		{"delighted", `{"event_id": "e_1", "event_data": {"id": "42", "score": 8}}`, NameUUID("ingest:delighted:42")},
+		{"q", `{"result": {"responseId": "R_1", "values": {"QID1": "9"}}}`, NameUUID("ingest:q:R_1")},
+		{"q", `{"values": {"QID1": "9"}}`, ""},
+	}
+	for _, tc := range cases {
+		source := sources[tc.source]
+		fields, apiErr := source.Fields([]byte(tc.body))
+		if apiErr != nil {
+			t.Fatalf("Expected %s to flatten, got %v", tc.body, apiErr)
+		}
+		req, apiErr := source.PredictRequest(fields)
+		if apiErr != nil || req.FeedbackID != tc.expected {
+			t.Errorf("%s: expected feedback ID %q, got %q, %v", tc.body, tc.expected, req.FeedbackID, apiErr)
+		}
+	}
+}
+
+// TestIngestSource_QualtricsNotification tests that a form-encoded Qualtrics
+// event notification is answered by fetching the response from the API.
+func TestIngestSource_QualtricsNotification(t *testing.T) {
+	var path, token string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, token = r.URL.Path, r.Header.Get("X-API-TOKEN")
+		w.Write([]byte(`{"result": {"responseId": "R_1", "values": {"QID1": 2, "QID2_TEXT": "Too slow"}}, "meta": {"httpStatus": "200 - OK"}}`))
+	}))
+	defer api.Close()
+	source := &IngestSource{Name: "qualtrics", Format: IngestFormatQualtrics, Secret: "tok", APIToken: "api-tok", APIBaseURL: api.URL + "/API/v3",
+		Mapping: IngestMapping{NLSScore: "values.QID1", FeedbackText: "values.QID2_TEXT"}}
+
+	fields, apiErr := source.Fields([]byte("Topic=surveyengine.completedResponse.SV_1&Status=Complete&SurveyID=SV_1&ResponseID=R_1&BrandID=acme"))
+	if apiErr != nil {
+		t.Fatalf("Expected the response to be fetched, got %v", apiErr)
+	}
+	if path != "/API/v3/surveys/SV_1/responses/R_1" || token != "api-tok" {
+		t.Errorf("Expected GET /API/v3/surveys/SV_1/responses/R_1 with the API token, got %s with %q", path, token)
+	}
+	req, apiErr := source.PredictRequest(fields)
+	if apiErr != nil || *req.NLSScore != 2 || req.FeedbackText != "Too slow" {
+		t.Errorf("Expected the fetched values to map, got %+v, %v", req, apiErr)
+	}
+
+	source.APIToken = ""
+	if _, apiErr := source.Fields([]byte("SurveyID=SV_1&ResponseID=R_1")); apiErr == nil || apiErr.Code != ErrInternal.Code {
+		t.Errorf("Expected internal_error without an API token, so that Qualtrics retries, got %v", apiErr)
+	}
+}
+
+// TestIngestSource_SurveyMonkeyMalformed tests that unexpected shapes in a
+// response details payload are skipped rather than panicking.
+func TestIngestSource_SurveyMonkeyMalformed(t *testing.T) {
+	source := &IngestSource{Name: "surveymonkey", Format: IngestFormatSurveyMonkey, Secret: "key",
+		Mapping: IngestMapping{NLSScore: "answers.q1"}}
+	fields, apiErr := source.Fields([]byte(`{"pages": ["oops", {"questions": "nope"}, {"questions": [{"id": "q1", "answers": [{"simple_text": "4"}]}]}]}`))
+	if apiErr != nil || fields["answers.q1"] != "4" {
+		t.Errorf("Expected the well-formed question to map, got %v, %v", fields, apiErr)
+	}
+}
@@ -0,0 +1,468 @@
+package appcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Prediction job statuses. Queued jobs become running when a runner claims
+// them, and end as succeeded or failed.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// PredictionJob is a row of prediction_jobs: a /predict request accepted with
+// async=true, processed in the background by a JobRunner.
+type PredictionJob struct {
+	ID            string            `json:"id"`
+	Status        string            `json:"status"`
+	Request       ApiPredictRequest `json:"request"` // feedback_text is already redacted
+	Redactions    []Redaction       `json:"redactions,omitempty"`
+	CallbackURL   string            `json:"callback_url,omitempty"`
+	Result        *ApiResponse      `json:"result,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+	StartedAt     *time.Time        `json:"started_at,omitempty"`
+	CompletedAt   *time.Time        `json:"completed_at,omitempty"`
+
+	CallbackStatus string `json:"callback_status,omitempty"` // delivered or failed
+	CallbackError  string `json:"callback_error,omitempty"`
+}
+
+// JobAccepted is the 202 response to POST /predict?async=true.
+type JobAccepted struct {
+	JobID     string `json:"job_id"`
+	Status    string `json:"status"`
+	StatusURL string `json:"status_url"`
+}
+
+// JobStatus is what GET /jobs/{id} returns and what callbacks receive. Result is
+// set once the job has succeeded, Error once it has failed.
+type JobStatus struct {
+	JobID       string       `json:"job_id"`
+	Status      string       `json:"status"`
+	Attempts    int          `json:"attempts"`
+	CreatedAt   time.Time    `json:"created_at"`
+	StartedAt   *time.Time   `json:"started_at,omitempty"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+	Result      *ApiResponse `json:"result,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// JobStatus returns the client-facing view of the job.
+func (j PredictionJob) JobStatus() JobStatus {
+	status := JobStatus{JobID: j.ID, Status: j.Status, Attempts: j.Attempts, CreatedAt: j.CreatedAt, StartedAt: j.StartedAt, CompletedAt: j.CompletedAt}
+	switch j.Status {
+	case JobStatusSucceeded:
+		status.Result = j.Result
+	case JobStatusFailed:
+		status.Error = j.Error
+	}
+	return status
+}
+
+// JobStatusURL is the path clients poll for a job.
+func JobStatusURL(id string) string { return "/jobs/" + id }
+
+// ValidateCallbackURL checks that callbacks are enabled (JOB_CALLBACK_SECRET is
+// set, so receivers can tell them from forgeries) and that a callback URL is an
+// absolute http(s) URL whose host is not a private address. Hostnames are
+// checked again when the callback is sent, against the address they resolve to.
+func ValidateCallbackURL(raw string) error {
+	if JobRunnerSettings.CallbackSecret == "" {
+		return fmt.Errorf("callback_url is not enabled on this server (JOB_CALLBACK_SECRET is not set)")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return fmt.Errorf("callback_url must be an absolute http or https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("callback_url must not point to a private address")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if err := checkCallbackIP(ip); err != nil {
+			return fmt.Errorf("callback_url must not point to a private address")
+		}
+	}
+	return nil
+}
+
+// callbackBlockedNets are the special-purpose ranges that the net.IP predicates
+// in checkCallbackIP don't cover.
+var callbackBlockedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4", "64:ff9b::/96"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// checkCallbackIP rejects addresses a callback must not reach: loopback,
+// private, link-local (which includes the 169.254.169.254 cloud metadata
+// endpoint), multicast, unspecified and other non-public ranges.
+func checkCallbackIP(ip net.IP) error {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("address %s is not public", ip)
+	}
+	for _, n := range callbackBlockedNets {
+		if n.Contains(ip) {
+			return fmt.Errorf("address %s is not public", ip)
+		}
+	}
+	return nil
+}
+
+// NewCallbackClient returns the HTTP client callbacks are sent with. It checks
+// every address it connects to with checkCallbackIP, after DNS resolution, so a
+// hostname can't be pointed at an internal service between validation and
+// delivery. It does not follow redirects or use a proxy.
+func NewCallbackClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return checkCallbackIP(net.ParseIP(host))
+		},
+	}
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// CreatePredictionJob redacts the feedback text and stores the request as a
+// queued job. A request with a FeedbackID (an ingested response) gets it as its
+// job ID, so when the same request arrives again the job already stored for it
+// is returned instead.
+func CreatePredictionJob(req ApiPredictRequest) (PredictionJob, error) {
+	if SupabaseClient == nil {
+		return PredictionJob{}, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	jobID := req.FeedbackID
+	if jobID == "" {
+		jobID = NewUUID()
+	} else if existing, found, err := FetchPredictionJob(jobID); err != nil {
+		return PredictionJob{}, err
+	} else if found {
+		return existing, nil
+	}
+	feedbackText, redactions := FeedbackRedactor.Redact(req.FeedbackText)
+	if len(redactions) > 0 {
+		log.Printf("Redacted PII from feedback text: %+v", redactions)
+	}
+	req.FeedbackText = feedbackText
+	callbackURL := req.CallbackURL
+	req.CallbackURL = ""
+
+	now := time.Now().UTC()
+	job := PredictionJob{
+		ID: jobID, Status: JobStatusQueued, Request: req, Redactions: redactions,
+		CallbackURL: callbackURL, NextAttemptAt: now, CreatedAt: now,
+	}
+	if _, _, err := SupabaseClient.From("prediction_jobs").Insert(job, false, "", "minimal", "").Execute(); err != nil {
+		return job, fmt.Errorf("error storing prediction job: %w", err)
+	}
+	return job, nil
+}
+
+// FetchPredictionJob returns the job with the given ID; found is false if there is none.
+func FetchPredictionJob(id string) (job PredictionJob, found bool, err error) {
+	if SupabaseClient == nil {
+		return job, false, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, _, err := SupabaseClient.From("prediction_jobs").Select("*", "", false).Eq("id", id).Limit(1, "").Execute()
+	if err != nil {
+		return job, false, fmt.Errorf("error selecting prediction job: %w", err)
+	}
+	var jobs []PredictionJob
+	if err := json.Unmarshal(rawData, &jobs); err != nil {
+		return job, false, fmt.Errorf("error unmarshalling prediction job: %w", err)
+	}
+	if len(jobs) == 0 {
+		return job, false, nil
+	}
+	return jobs[0], true, nil
+}
+
+// --- Runner ---
+
+// jobWake lets the handler that queued a job start the runner's next poll early.
+var jobWake = make(chan struct{}, 1)
+
+// WakeJobRunner asks a JobRunner in this process to poll now. It never blocks.
+func WakeJobRunner() {
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+}
+
+// JobRunner claims queued prediction jobs and runs them through
+// ProcessRedactedFeedback. Claims are conditional updates, so several servers
+// can run a JobRunner against the same table. Jobs whose processing fails are
+// retried with backoff and fail after MaxAttempts; jobs left running by a
+// crashed server are requeued after StaleAfter. Finished jobs with a callback
+// URL get their JobStatus POSTed to it, signed like webhooks with CallbackSecret.
+type JobRunner struct {
+	Concurrency      int
+	MaxAttempts      int
+	BaseBackoff      time.Duration // delay before the second attempt, doubled per attempt
+	StaleAfter       time.Duration
+	PollInterval     time.Duration
+	CallbackSecret   string
+	CallbackAttempts int
+	CallbackTimeout  time.Duration
+
+	// CallbackClient sends callbacks; nil means NewCallbackClient(CallbackTimeout).
+	// Tests replace it.
+	CallbackClient *http.Client
+
+	// Process is the pipeline; nil means ProcessRedactedFeedback. Tests replace it.
+	Process func(req ApiPredictRequest, redactions []Redaction) (ApiResponse, error)
+}
+
+// JobRunnerSettings is the runner configuration in use. Configured by InitClients.
+var JobRunnerSettings = DefaultJobRunner()
+
+// DefaultJobRunner returns a runner with the default settings.
+func DefaultJobRunner() *JobRunner {
+	return &JobRunner{
+		Concurrency: 4, MaxAttempts: 3, BaseBackoff: 10 * time.Second, StaleAfter: 10 * time.Minute,
+		PollInterval: time.Second, CallbackAttempts: 3, CallbackTimeout: 10 * time.Second,
+	}
+}
+
+// Backoff returns the delay after the given (1-based) failed attempt.
+func (r *JobRunner) Backoff(attempt int) time.Duration {
+	d := r.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+	}
+	return d
+}
+
+// RunOnce requeues stale jobs, claims up to Concurrency due jobs and runs them.
+// It returns the number of jobs run.
+func (r *JobRunner) RunOnce(now time.Time) (int, error) {
+	if SupabaseClient == nil {
+		return 0, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	requeue := map[string]interface{}{"status": JobStatusQueued, "next_attempt_at": now}
+	if _, _, err := SupabaseClient.From("prediction_jobs").Update(requeue, "minimal", "").
+		Eq("status", JobStatusRunning).Lt("started_at", now.Add(-r.StaleAfter).UTC().Format(time.RFC3339Nano)).Execute(); err != nil {
+		log.Printf("Error requeuing stale prediction jobs: %v", err)
+	}
+
+	rawData, _, err := SupabaseClient.From("prediction_jobs").Select("*", "", false).
+		Eq("status", JobStatusQueued).Lte("next_attempt_at", now.UTC().Format(time.RFC3339Nano)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).Limit(r.Concurrency, "").Execute()
+	if err != nil {
+		return 0, fmt.Errorf("error selecting queued prediction jobs: %w", err)
+	}
+	var due []PredictionJob
+	if err := json.Unmarshal(rawData, &due); err != nil {
+		return 0, fmt.Errorf("error unmarshalling queued prediction jobs: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	run := 0
+	for _, candidate := range due {
+		if candidate.Attempts >= r.MaxAttempts {
+			// Only jobs requeued after a crash get here: don't let one crash the servers forever.
+			r.finish(candidate, fmt.Errorf("gave up after %d attempts that did not finish", candidate.Attempts))
+			continue
+		}
+		job, claimed, err := r.claim(candidate, now)
+		if err != nil {
+			log.Printf("Error claiming prediction job %s: %v", candidate.ID, err)
+			continue
+		}
+		if !claimed {
+			continue // another runner got it first
+		}
+		run++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.runJob(job)
+		}()
+	}
+	wg.Wait()
+	return run, nil
+}
+
+// claim marks a queued job as running, unless another runner already has.
+func (r *JobRunner) claim(job PredictionJob, now time.Time) (PredictionJob, bool, error) {
+	update := map[string]interface{}{"status": JobStatusRunning, "started_at": now, "attempts": job.Attempts + 1}
+	rawData, _, err := SupabaseClient.From("prediction_jobs").Update(update, "", "").
+		Eq("id", job.ID).Eq("status", JobStatusQueued).Execute()
+	if err != nil {
+		return job, false, err
+	}
+	var claimed []PredictionJob
+	if err := json.Unmarshal(rawData, &claimed); err != nil {
+		return job, false, fmt.Errorf("error unmarshalling claimed job: %w", err)
+	}
+	if len(claimed) == 0 {
+		return job, false, nil
+	}
+	return claimed[0], true, nil
+}
+
+// runJob processes a claimed job and records the outcome.
+func (r *JobRunner) runJob(job PredictionJob) {
+	process := r.Process
+	if process == nil {
+		process = ProcessRedactedFeedback
+	}
+	// The feedback ID is derived from the job, so a job requeued after its
+	// feedback was stored (see StaleAfter) doesn't store it again.
+	job.Request.FeedbackID = NameUUID("job:" + job.ID)
+	response, err := process(job.Request, job.Redactions)
+	if errors.Is(err, ErrFeedbackExists) {
+		log.Printf("Prediction job %s was already processed: feedback %s.", job.ID, job.Request.FeedbackID)
+		response, err = StoredPredictionResponse(job.Request)
+	}
+	now := time.Now().UTC()
+	var update map[string]interface{}
+	switch {
+	case err == nil:
+		job.Status, job.Result, job.Error, job.CompletedAt = JobStatusSucceeded, &response, "", &now
+		update = map[string]interface{}{"status": job.Status, "result": job.Result, "error": nil, "completed_at": now}
+	case job.Attempts >= r.MaxAttempts:
+		job.Status, job.Error, job.CompletedAt = JobStatusFailed, err.Error(), &now
+		update = map[string]interface{}{"status": job.Status, "error": job.Error, "completed_at": now}
+	default:
+		delay := r.Backoff(job.Attempts)
+		log.Printf("Prediction job %s failed (attempt %d), retrying in %v: %v", job.ID, job.Attempts, delay, err)
+		update = map[string]interface{}{"status": JobStatusQueued, "error": err.Error(), "next_attempt_at": now.Add(delay)}
+	}
+	if _, _, err := SupabaseClient.From("prediction_jobs").Update(update, "minimal", "").Eq("id", job.ID).Execute(); err != nil {
+		// The job stays running and is requeued after StaleAfter.
+		log.Printf("Error recording the outcome of prediction job %s: %v", job.ID, err)
+		return
+	}
+	if job.Status == JobStatusSucceeded || job.Status == JobStatusFailed {
+		r.completed(job)
+	}
+}
+
+// finish fails a job without running it.
+func (r *JobRunner) finish(job PredictionJob, err error) {
+	now := time.Now().UTC()
+	job.Status, job.Error, job.CompletedAt = JobStatusFailed, err.Error(), &now
+	update := map[string]interface{}{"status": job.Status, "error": job.Error, "completed_at": now}
+	if _, _, err := SupabaseClient.From("prediction_jobs").Update(update, "minimal", "").Eq("id", job.ID).Eq("status", JobStatusQueued).Execute(); err != nil {
+		log.Printf("Error failing prediction job %s: %v", job.ID, err)
+		return
+	}
+	r.completed(job)
+}
+
+// completed logs a finished job and sends its callback.
+func (r *JobRunner) completed(job PredictionJob) {
+	log.Printf("Prediction job %s %s after %d attempt(s).", job.ID, job.Status, job.Attempts)
+	if job.CallbackURL != "" {
+		r.sendCallback(job)
+	}
+}
+
+// sendCallback POSTs the finished job's JobStatus to its callback URL, retrying
+// a few times, and records whether it was delivered. Callbacks are only sent
+// signed and only to public addresses (see NewCallbackClient).
+func (r *JobRunner) sendCallback(job PredictionJob) {
+	body, err := json.Marshal(job.JobStatus())
+	if err != nil {
+		log.Printf("Error marshalling callback for job %s: %v", job.ID, err)
+		return
+	}
+	client := r.CallbackClient
+	if client == nil {
+		client = NewCallbackClient(r.CallbackTimeout)
+	}
+	update := map[string]interface{}{"callback_status": "delivered", "callback_error": nil}
+	for attempt := 1; ; attempt++ {
+		if r.CallbackSecret == "" {
+			err = fmt.Errorf("callbacks are disabled: JOB_CALLBACK_SECRET is not set")
+			break
+		}
+		_, err = postWebhook(client, job.CallbackURL, r.CallbackSecret, job.ID, body)
+		if err == nil || attempt >= r.CallbackAttempts {
+			break
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	if err != nil {
+		log.Printf("Callback for prediction job %s failed: %v", job.ID, err)
+		update = map[string]interface{}{"callback_status": "failed", "callback_error": err.Error()}
+	}
+	if _, _, err := SupabaseClient.From("prediction_jobs").Update(update, "minimal", "").Eq("id", job.ID).Execute(); err != nil {
+		log.Printf("Error recording the callback of prediction job %s: %v", job.ID, err)
+	}
+}
+
+// Run runs jobs until stop is closed, polling every PollInterval or as soon as
+// WakeJobRunner is called.
+func (r *JobRunner) Run(stop <-chan struct{}) {
+	log.Printf("Prediction job runner started (concurrency %d).", r.Concurrency)
+	for {
+		n, err := r.RunOnce(time.Now())
+		if err != nil {
+			log.Printf("Prediction job runner: %v", err)
+		}
+		wait := r.PollInterval
+		if n > 0 {
+			wait = 0 // there may be more
+		}
+		select {
+		case <-stop:
+			log.Println("Prediction job runner stopped.")
+			return
+		case <-jobWake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ConfigureJobRunner sets JobRunnerSettings from JOB_CONCURRENCY,
+// JOB_MAX_ATTEMPTS and JOB_CALLBACK_SECRET.
+func ConfigureJobRunner() error {
+	runner := DefaultJobRunner()
+	for name, dst := range map[string]*int{"JOB_CONCURRENCY": &runner.Concurrency, "JOB_MAX_ATTEMPTS": &runner.MaxAttempts} {
+		if raw := strings.TrimSpace(os.Getenv(name)); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 {
+				return fmt.Errorf("%s must be a positive integer, got %q", name, raw)
+			}
+			*dst = n
+		}
+	}
+	runner.CallbackSecret = os.Getenv("JOB_CALLBACK_SECRET")
+	JobRunnerSettings = runner
+	return nil
+}
@@ -0,0 +1,150 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPredictionJob_JobStatus tests that clients see the result only once a job
+// has succeeded and the error only once it has failed.
+func TestPredictionJob_JobStatus(t *testing.T) {
+	job := PredictionJob{ID: "job-1", Status: JobStatusQueued, Result: &ApiResponse{CustomerID: "c1"}, Error: "storage failed", Attempts: 1}
+	if status := job.JobStatus(); status.Result != nil || status.Error != "" {
+		t.Errorf("Expected a retrying job to show neither result nor error, got %+v", status)
+	}
+	job.Status = JobStatusSucceeded
+	if status := job.JobStatus(); status.Result == nil || status.Result.CustomerID != "c1" || status.Error != "" {
+		t.Errorf("Expected a succeeded job to show its result, got %+v", status)
+	}
+	job.Status = JobStatusFailed
+	if status := job.JobStatus(); status.Result != nil || status.Error != "storage failed" {
+		t.Errorf("Expected a failed job to show its error, got %+v", status)
+	}
+	if d := DefaultJobRunner().Backoff(3); d != 40*time.Second {
+		t.Errorf("Expected the third retry after 40s, got %v", d)
+	}
+}
+
+// TestJobRunner_RunJobAlreadyStored tests that a job's feedback ID is derived
+// from the job, and that a rerun whose feedback is already stored succeeds with
+// the stored prediction instead of failing.
+func TestJobRunner_RunJobAlreadyStored(t *testing.T) {
+	feedbackID := NameUUID("job:job-1")
+	fake := newFakeSupabase(t, func(req fakeRequest) fakeResponse {
+		if req.Method == "GET" && req.Path == "churn_predictions" && req.Query.Get("id") == "" {
+			return fakeResponse{Body: `[{"id":"p1","customer_feedback_id":"` + feedbackID + `","churn_probability":0.9,"shadow":true,"predicted_at":"2026-10-18T09:00:00Z"},
+				{"id":"p2","customer_feedback_id":"` + feedbackID + `","churn_probability":0.7,"reason":"Low NLS score","model_name":"churn-rules","predicted_at":"2026-10-18T09:00:00Z"}]`}
+		}
+		return fakeResponse{}
+	})
+	var got string
+	runner := DefaultJobRunner()
+	runner.Process = func(req ApiPredictRequest, _ []Redaction) (ApiResponse, error) {
+		got = req.FeedbackID
+		return ApiResponse{}, fmt.Errorf("error storing customer data and churn prediction: %w", ErrFeedbackExists)
+	}
+	nls := 3
+	runner.runJob(PredictionJob{ID: "job-1", Status: JobStatusRunning, Request: ApiPredictRequest{NLSScore: &nls, AccountID: "acct_1"}, Attempts: 1})
+
+	if got != feedbackID {
+		t.Errorf("Expected the feedback ID derived from the job, got %q", got)
+	}
+	updates := fake.Requests("PATCH", "prediction_jobs")
+	if len(updates) != 1 {
+		t.Fatalf("Expected one job update, got %d", len(updates))
+	}
+	var update struct {
+		Status string      `json:"status"`
+		Result ApiResponse `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(updates[0].Body), &update); err != nil {
+		t.Fatalf("Expected a JSON update, got %v", err)
+	}
+	if update.Status != JobStatusSucceeded || update.Result.CustomerID != feedbackID || update.Result.ChurnProbability != 0.7 || update.Result.AccountID != "acct_1" {
+		t.Errorf("Expected the job to succeed with the stored primary prediction, got %+v", update)
+	}
+}
+
+// TestCreatePredictionJob_Redelivered tests that a request with a feedback ID
+// is queued under it once, and a redelivery returns the job already stored.
+func TestCreatePredictionJob_Redelivered(t *testing.T) {
+	stored := false
+	fake := newFakeSupabase(t, func(req fakeRequest) fakeResponse {
+		if req.Method == "GET" && stored {
+			return fakeResponse{Body: `[{"id":"` + req.Query.Get("id")[len("eq."):] + `","status":"running","attempts":1}]`}
+		}
+		if req.Method == "POST" {
+			stored = true
+		}
+		return fakeResponse{}
+	})
+	nls := 3
+	req := ApiPredictRequest{NLSScore: &nls, FeedbackID: NameUUID("ingest:tf:tok_1")}
+	job, err := CreatePredictionJob(req)
+	if err != nil || job.ID != req.FeedbackID || job.Status != JobStatusQueued {
+		t.Fatalf("Expected a job queued under the feedback ID, got %+v, %v", job, err)
+	}
+	again, err := CreatePredictionJob(req)
+	if err != nil || again.ID != job.ID || again.Status != JobStatusRunning {
+		t.Errorf("Expected the stored job to be returned, got %+v, %v", again, err)
+	}
+	if inserts := fake.Requests("POST", "prediction_jobs"); len(inserts) != 1 {
+		t.Errorf("Expected one job insert, got %d", len(inserts))
+	}
+}
+
+// TestValidateCallbackURL tests that only absolute http(s) callback URLs on
+// public hosts are accepted, and only when callbacks are signed.
+func TestValidateCallbackURL(t *testing.T) {
+	defer func(runner *JobRunner) { JobRunnerSettings = runner }(JobRunnerSettings)
+	JobRunnerSettings = DefaultJobRunner()
+	if err := ValidateCallbackURL("https://example.com/hook"); err == nil {
+		t.Errorf("Expected callbacks to be refused without JOB_CALLBACK_SECRET")
+	}
+
+	JobRunnerSettings.CallbackSecret = "secret"
+	for _, valid := range []string{"https://example.com/hook", "http://93.184.216.34:9000/cb?x=1"} {
+		if err := ValidateCallbackURL(valid); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{
+		"/relative", "ftp://example.com", "https://", "not a url",
+		"http://localhost:9000/cb", "http://127.0.0.1/cb", "http://10.0.0.5/cb", "http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/cb", "http://[fd00:ec2::254]/cb", "http://0.0.0.0/cb", "http://100.100.100.200/cb",
+	} {
+		if err := ValidateCallbackURL(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+	if _, apiErr := DecodePredictRequest(strings.NewReader(`{"nls_score": 3, "callback_url": "https://example.com/hook"}`)); apiErr != nil {
+		t.Errorf("Expected callback_url to be an accepted request field, got %v", apiErr)
+	}
+}
+
+// TestNewCallbackClient tests that callbacks don't connect to private addresses,
+// whatever the URL's host resolves to, and don't follow redirects.
+func TestNewCallbackClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	client := NewCallbackClient(time.Second)
+	for _, target := range []string{server.URL, "http://localhost:" + port} {
+		if _, err := postWebhook(client, target, "secret", "job-1", []byte(`{}`)); err == nil || !strings.Contains(err.Error(), "not public") {
+			t.Errorf("Expected the callback to %s to be refused, got %v", target, err)
+		}
+	}
+
+	redirect := httptest.NewServer(http.RedirectHandler("http://169.254.169.254/", http.StatusFound))
+	defer redirect.Close()
+	client.Transport = http.DefaultTransport // the test server itself is on loopback
+	if code, err := postWebhook(client, redirect.URL, "secret", "job-1", []byte(`{}`)); err == nil || code != http.StatusFound {
+		t.Errorf("Expected the redirect to be returned as a failure, got %d, %v", code, err)
+	}
+}
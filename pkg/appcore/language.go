@@ -0,0 +1,247 @@
+package appcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// UndeterminedLanguage is returned by DetectLanguage when the text is too short
+// or has too few known words to tell.
+const UndeterminedLanguage = "und"
+
+// Multilingual models used for languages without their own mapping.
+const (
+	MultilingualSentimentModelID = "cardiffnlp/twitter-xlm-roberta-base-sentiment"
+	MultilingualZeroShotModelID  = "joeddav/xlm-roberta-large-xnli"
+)
+
+// LanguageModels are the Hugging Face models used to enrich text in one language.
+type LanguageModels struct {
+	Sentiment string `json:"sentiment"`
+	ZeroShot  string `json:"zero_shot"`
+}
+
+// LanguageModelMap maps an ISO 639-1 code to its models. The "*" entry is used
+// for any detected language without its own entry; undetermined text uses "en".
+// LANGUAGE_MODELS (JSON) entries are merged over these defaults by InitClients.
+var LanguageModelMap = defaultLanguageModels()
+
+func defaultLanguageModels() map[string]LanguageModels {
+	return map[string]LanguageModels{
+		"en": {Sentiment: SentimentModelID, ZeroShot: ZeroShotModelID},
+		"*":  {Sentiment: MultilingualSentimentModelID, ZeroShot: MultilingualZeroShotModelID},
+	}
+}
+
+// ModelsForLanguage returns the models to use for text in lang.
+func ModelsForLanguage(lang string) LanguageModels {
+	if models, ok := LanguageModelMap[lang]; ok {
+		return models
+	}
+	if lang != UndeterminedLanguage && lang != "" {
+		if models, ok := LanguageModelMap["*"]; ok {
+			return models
+		}
+	}
+	return LanguageModelMap["en"]
+}
+
+// NormalizeSentimentLabel maps model-specific labels ("negative", "NEG", ...)
+// to POSITIVE, NEGATIVE or NEUTRAL; anything else is uppercased.
+func NormalizeSentimentLabel(label string) string {
+	switch strings.ToLower(label) {
+	case "positive", "pos":
+		return "POSITIVE"
+	case "negative", "neg":
+		return "NEGATIVE"
+	case "neutral", "neu":
+		return "NEUTRAL"
+	}
+	return strings.ToUpper(label)
+}
+
+// --- Language detection ---
+
+// languageStopwords are frequent function words per language. Detection counts
+// them, so it needs no model call and works on redacted text.
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "was", "it", "to", "of", "i", "you", "not", "with", "this", "that", "for", "very", "but", "are", "have", "my"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "es", "muy", "pero", "no", "con", "por", "para", "un", "una", "mi", "está", "lo", "del"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "sehr", "aber", "ich", "mit", "ein", "eine", "zu", "es", "war", "für", "auf", "mein", "den"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "é", "muito", "mas", "não", "com", "por", "para", "um", "uma", "meu", "está", "do", "da"},
+	"fr": {"le", "la", "les", "de", "et", "est", "très", "mais", "pas", "je", "avec", "pour", "un", "une", "mon", "du", "ce", "que"},
+}
+
+// languageMarkers are characters that strongly suggest a language.
+var languageMarkers = map[string]string{
+	"es": "ñ¿¡",
+	"de": "äöüß",
+	"pt": "ãõ",
+}
+
+// minLanguageEvidence is the minimum score for a detection to count.
+const minLanguageEvidence = 2
+
+// DetectLanguage guesses the ISO 639-1 language of text from stopword and
+// character evidence. It returns UndeterminedLanguage with confidence 0 when
+// there is not enough evidence.
+func DetectLanguage(text string) (string, float64) {
+	tokens := Tokenize(text)
+	scores := map[string]int{}
+	for lang, stopwords := range languageStopwords {
+		for _, token := range tokens {
+			if containsString(stopwords, token.Text) {
+				scores[lang]++
+			}
+		}
+	}
+	lower := strings.ToLower(text)
+	for lang, markers := range languageMarkers {
+		if strings.ContainsAny(lower, markers) {
+			scores[lang] += minLanguageEvidence
+		}
+	}
+
+	best, bestScore, total := UndeterminedLanguage, 0, 0
+	for _, lang := range []string{"en", "es", "de", "pt", "fr"} { // fixed order keeps ties deterministic
+		total += scores[lang]
+		if scores[lang] > bestScore {
+			best, bestScore = lang, scores[lang]
+		}
+	}
+	if bestScore < minLanguageEvidence {
+		return UndeterminedLanguage, 0
+	}
+	return best, float64(bestScore) / float64(total)
+}
+
+// --- Translation ---
+
+// Translator translates text between ISO 639-1 languages.
+type Translator interface {
+	Name() string
+	Translate(text, source, target string) (string, error)
+}
+
+// FeedbackTranslator translates non-English feedback to English before
+// enrichment; nil (the default) disables translation. Configured by InitClients.
+var FeedbackTranslator Translator
+
+// TranslationTargetLanguage is the language feedback is translated into.
+const TranslationTargetLanguage = "en"
+
+// HuggingFaceTranslator uses the Helsinki-NLP opus-mt models, e.g. opus-mt-es-en.
+type HuggingFaceTranslator struct{}
+
+func (HuggingFaceTranslator) Name() string { return "huggingface" }
+
+func (HuggingFaceTranslator) Translate(text, source, target string) (string, error) {
+	modelID := fmt.Sprintf("Helsinki-NLP/opus-mt-%s-%s", source, target)
+	responseBody, err := callHuggingFaceCached(modelID, text, nil, HFSentimentRequest{Inputs: text})
+	if err != nil {
+		return "", fmt.Errorf("translation API call failed: %w", err)
+	}
+	var response []struct {
+		TranslationText string `json:"translation_text"`
+	}
+	if err := json.Unmarshal(responseBody, &response); err != nil || len(response) == 0 {
+		return "", fmt.Errorf("translation response format unexpected or empty: %s", string(responseBody))
+	}
+	return response[0].TranslationText, nil
+}
+
+// LibreTranslateTranslator calls a LibreTranslate-compatible /translate endpoint.
+type LibreTranslateTranslator struct {
+	URL    string
+	APIKey string
+}
+
+func (LibreTranslateTranslator) Name() string { return "libretranslate" }
+
+func (t LibreTranslateTranslator) Translate(text, source, target string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"q": text, "source": source, "target": target, "format": "text", "api_key": t.APIKey})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling translation request: %w", err)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(t.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("error sending translation request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading translation response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translation request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	var response struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("error unmarshalling translation response: %w", err)
+	}
+	return response.TranslatedText, nil
+}
+
+// PrepareForEnrichment detects the language of (redacted) feedback and, if a
+// translator is configured and the text is not already in the target language,
+// translates it. It returns the text to enrich, the detected language, the
+// language of the returned text and the translator used (empty if none). A
+// failed translation falls back to the original text and that language's models.
+func PrepareForEnrichment(feedbackText string) (text, detected, enrichmentLanguage, translatedBy string) {
+	detected, _ = DetectLanguage(feedbackText)
+	if FeedbackTranslator == nil || detected == UndeterminedLanguage || detected == TranslationTargetLanguage {
+		return feedbackText, detected, detected, ""
+	}
+	translated, err := FeedbackTranslator.Translate(feedbackText, detected, TranslationTargetLanguage)
+	if err != nil || strings.TrimSpace(translated) == "" {
+		log.Printf("Warning: Could not translate feedback from %s: %v", detected, err)
+		return feedbackText, detected, detected, ""
+	}
+	return translated, detected, TranslationTargetLanguage, FeedbackTranslator.Name()
+}
+
+// ConfigureLanguageSupport sets LanguageModelMap from LANGUAGE_MODELS (a JSON
+// object merged over the defaults) and FeedbackTranslator from
+// TRANSLATION_BACKEND ("off", "huggingface" or "libretranslate", which needs
+// TRANSLATION_URL and optionally TRANSLATION_API_KEY).
+func ConfigureLanguageSupport() error {
+	LanguageModelMap = defaultLanguageModels()
+	if raw := strings.TrimSpace(os.Getenv("LANGUAGE_MODELS")); raw != "" {
+		var custom map[string]LanguageModels
+		if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+			return fmt.Errorf("LANGUAGE_MODELS must be a JSON object of language to {\"sentiment\", \"zero_shot\"} models: %w", err)
+		}
+		for lang, models := range custom {
+			if models.Sentiment == "" || models.ZeroShot == "" {
+				return fmt.Errorf("LANGUAGE_MODELS entry %q needs both sentiment and zero_shot models", lang)
+			}
+			LanguageModelMap[lang] = models
+		}
+	}
+
+	switch backend := strings.ToLower(strings.TrimSpace(os.Getenv("TRANSLATION_BACKEND"))); backend {
+	case "", "off", "none":
+		FeedbackTranslator = nil
+	case "huggingface":
+		FeedbackTranslator = HuggingFaceTranslator{}
+	case "libretranslate":
+		url := strings.TrimSpace(os.Getenv("TRANSLATION_URL"))
+		if url == "" {
+			return fmt.Errorf("TRANSLATION_URL must be set when TRANSLATION_BACKEND=libretranslate")
+		}
+		FeedbackTranslator = LibreTranslateTranslator{URL: url, APIKey: os.Getenv("TRANSLATION_API_KEY")}
+	default:
+		return fmt.Errorf("TRANSLATION_BACKEND must be 'off', 'huggingface' or 'libretranslate', got %q", backend)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package appcore
+
+import (
+	"testing"
+)
+
+// TestDetectLanguage tests stopword and accent based language detection.
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]string{
+		"The service was very slow and I am not happy with it":       "en",
+		"El servicio es muy lento y no estoy contento con el precio": "es",
+		"Der Service ist sehr langsam und ich bin nicht zufrieden":   "de",
+		"O atendimento é muito lento e não estou satisfeito":         "pt",
+		"Great!":   UndeterminedLanguage,
+		"":         UndeterminedLanguage,
+		"Año malo": "es", // accent evidence alone is enough
+	}
+	for text, expected := range cases {
+		if got, _ := DetectLanguage(text); got != expected {
+			t.Errorf("DetectLanguage(%q): expected %s, got %s", text, expected, got)
+		}
+	}
+}
+
+// TestModelsForLanguage tests the language-to-model mapping and label normalization.
+func TestModelsForLanguage(t *testing.T) {
+	if got := ModelsForLanguage("en").Sentiment; got != SentimentModelID {
+		t.Errorf("Expected English sentiment model, got %s", got)
+	}
+	if got := ModelsForLanguage("es").Sentiment; got != MultilingualSentimentModelID {
+		t.Errorf("Expected multilingual model for Spanish, got %s", got)
+	}
+	if got := ModelsForLanguage(UndeterminedLanguage).ZeroShot; got != ZeroShotModelID {
+		t.Errorf("Expected English zero-shot model for undetermined text, got %s", got)
+	}
+	if NormalizeSentimentLabel("negative") != "NEGATIVE" || NormalizeSentimentLabel("Neutral") != "NEUTRAL" || NormalizeSentimentLabel("POSITIVE") != "POSITIVE" {
+		t.Errorf("Expected model-specific labels to be normalized")
+	}
+}
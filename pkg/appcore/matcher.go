@@ -0,0 +1,229 @@
+package appcore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Token is one word of a tokenized text.
+type Token struct {
+	Text      string // lowercased word, apostrophes normalized to '
+	Stem      string
+	ClauseEnd bool // followed by punctuation that ends a clause
+}
+
+// Tokenize lowercases text and splits it into words on anything that is not a
+// letter, digit or in-word apostrophe ("don't" stays one word). Tokens followed
+// by . , ; : ! ? or a newline are marked as ending a clause.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	var word []rune
+	flush := func() {
+		w := strings.Trim(string(word), "'")
+		word = word[:0]
+		if w != "" {
+			tokens = append(tokens, Token{Text: w, Stem: Stem(w)})
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		case (r == '\'' || r == '’') && len(word) > 0:
+			word = append(word, '\'')
+		default:
+			flush()
+			if strings.ContainsRune(".,;:!?\n", r) && len(tokens) > 0 {
+				tokens[len(tokens)-1].ClauseEnd = true
+			}
+		}
+	}
+	flush()
+	return tokens
+}
+
+// Stem reduces an English word to a crude stem so that inflections match:
+// "cancelled", "cancelling" and "cancellation" all become "cancel", and
+// "terrible"/"terribly" and "happy"/"happily" share a stem. It only needs to be
+// consistent, not linguistically exact, because keywords are stemmed the same way.
+func Stem(word string) string {
+	w := strings.ToLower(word)
+	if strings.ContainsRune(w, '\'') || len(w) <= 3 {
+		return w
+	}
+	if strings.HasSuffix(w, "ies") && len(w) > 4 {
+		w = strings.TrimSuffix(w, "ies") + "y"
+	}
+	undouble := false
+	for _, suffix := range []string{"ations", "ation", "ingly", "edly", "ings", "ing", "ed", "ly", "es", "s"} {
+		if !strings.HasSuffix(w, suffix) || len(w)-len(suffix) < 3 {
+			continue
+		}
+		if (suffix == "s" && strings.HasSuffix(w, "ss")) || (suffix == "ed" && strings.HasSuffix(w, "eed")) {
+			break
+		}
+		if suffix == "ly" && strings.HasSuffix(w, "bly") {
+			w = strings.TrimSuffix(w, "y") // terribly -> terribl, like terrible -> terribl
+			break
+		}
+		w = strings.TrimSuffix(w, suffix)
+		undouble = suffix != "s" && suffix != "es" && suffix != "ly"
+		break
+	}
+	if n := len(w); undouble && n > 3 && w[n-1] == w[n-2] && !strings.ContainsRune("aeiou", rune(w[n-1])) {
+		w = w[:n-1] // cancell -> cancel, stopp -> stop
+	}
+	if n := len(w); n > 4 && w[n-1] == 'e' {
+		w = w[:n-1]
+	}
+	if n := len(w); n > 3 && w[n-1] == 'y' {
+		w = w[:n-1] + "i"
+	}
+	return w
+}
+
+// negators start a negation scope; words ending in n't are negators too.
+var negators = []string{"not", "no", "never", "cannot", "hardly", "without", "nothing", "nor", "neither", "dont", "doesnt", "didnt", "isnt", "wasnt", "arent", "werent", "wont", "cant", "couldnt", "wouldnt", "shouldnt"}
+
+// negationScope is how many tokens after a negator are negated, unless a clause ends first.
+const negationScope = 3
+
+// Intensifiers scale the weight of the next matched phrase.
+var (
+	intensifiers = map[string]float64{"very": 1.5, "really": 1.5, "extremely": 2, "so": 1.5, "too": 1.5, "totally": 1.5, "absolutely": 2, "incredibly": 2, "super": 1.5}
+	downtoners   = map[string]float64{"somewhat": 0.5, "slightly": 0.5, "bit": 0.5, "little": 0.5, "kinda": 0.5, "fairly": 0.5}
+)
+
+func isNegator(token Token) bool {
+	return strings.HasSuffix(token.Text, "n't") || containsString(negators, token.Text)
+}
+
+// PhraseMatch is one occurrence of a phrase in a text.
+type PhraseMatch struct {
+	Phrase  string  `json:"phrase"`
+	Negated bool    `json:"negated"` // within the scope of a preceding negator ("not bad at all")
+	Weight  float64 `json:"weight"`  // 1, scaled by a preceding intensifier or downtoner
+}
+
+// PhraseMatcher finds whole-word, stemmed occurrences of a list of phrases.
+type PhraseMatcher struct {
+	phrases []string
+	stems   [][]string
+	version string
+}
+
+// NewPhraseMatcher compiles phrases (single words or multi-word phrases).
+func NewPhraseMatcher(phrases []string) *PhraseMatcher {
+	m := &PhraseMatcher{}
+	for _, phrase := range phrases {
+		tokens := Tokenize(phrase)
+		if len(tokens) == 0 {
+			continue
+		}
+		stems := make([]string, len(tokens))
+		for i, t := range tokens {
+			stems[i] = t.Stem
+		}
+		m.phrases = append(m.phrases, strings.TrimSpace(phrase))
+		m.stems = append(m.stems, stems)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(m.phrases, "\n")))
+	m.version = "kw-" + hex.EncodeToString(sum[:])[:12]
+	return m
+}
+
+// Version identifies the matcher's phrase list: matchers built from the same
+// phrases, in the same order, have the same version.
+func (m *PhraseMatcher) Version() string {
+	return m.version
+}
+
+// Match returns every occurrence of the matcher's phrases in text, in text order.
+func (m *PhraseMatcher) Match(text string) []PhraseMatch {
+	tokens := Tokenize(text)
+	var matches []PhraseMatch
+	negatedUntil := -1 // index of the last token in the current negation scope
+	for i, token := range tokens {
+		for p, stems := range m.stems {
+			if !matchesAt(tokens, i, stems) {
+				continue
+			}
+			weight := 1.0
+			if i > 0 {
+				if w, ok := intensifiers[tokens[i-1].Text]; ok {
+					weight = w
+				} else if w, ok := downtoners[tokens[i-1].Text]; ok {
+					weight = w
+				}
+			}
+			matches = append(matches, PhraseMatch{Phrase: m.phrases[p], Negated: i <= negatedUntil, Weight: weight})
+		}
+
+		switch {
+		case isNegator(token):
+			negatedUntil = i + negationScope
+		case token.Text == "but":
+			negatedUntil = -1
+		}
+		if token.ClauseEnd && negatedUntil >= i {
+			negatedUntil = -1
+		}
+	}
+	return matches
+}
+
+func matchesAt(tokens []Token, i int, stems []string) bool {
+	if i+len(stems) > len(tokens) {
+		return false
+	}
+	for j, stem := range stems {
+		if tokens[i+j].Stem != stem {
+			return false
+		}
+		if j < len(stems)-1 && tokens[i+j].ClauseEnd {
+			return false // phrases don't span clauses
+		}
+	}
+	return true
+}
+
+// AffirmedWeight sums the weights of the matches that are not negated.
+func AffirmedWeight(matches []PhraseMatch) float64 {
+	total := 0.0
+	for _, match := range matches {
+		if !match.Negated {
+			total += match.Weight
+		}
+	}
+	return total
+}
+
+// DefaultNegativeFeedbackPhrases are the keywords PredictChurn has always used.
+var DefaultNegativeFeedbackPhrases = []string{"bad", "poor", "terrible", "unhappy"}
+
+// NegativeFeedbackMatcher finds negative keywords for PredictChurn. It is
+// configured from NEGATIVE_FEEDBACK_PHRASES by InitClients.
+var NegativeFeedbackMatcher = NewPhraseMatcher(DefaultNegativeFeedbackPhrases)
+
+// ConfigureKeywordMatcher sets NegativeFeedbackMatcher from NEGATIVE_FEEDBACK_PHRASES,
+// a JSON array of words or phrases that replaces the defaults, e.g.
+// ["bad", "poor", "terrible", "unhappy", "waste of money", "not worth"].
+func ConfigureKeywordMatcher() error {
+	phrases := DefaultNegativeFeedbackPhrases
+	if raw := strings.TrimSpace(os.Getenv("NEGATIVE_FEEDBACK_PHRASES")); raw != "" {
+		phrases = nil // don't decode into the defaults' backing array
+		if err := json.Unmarshal([]byte(raw), &phrases); err != nil {
+			return fmt.Errorf("NEGATIVE_FEEDBACK_PHRASES must be a JSON array of strings: %w", err)
+		}
+		if len(phrases) == 0 {
+			return fmt.Errorf("NEGATIVE_FEEDBACK_PHRASES must not be empty")
+		}
+	}
+	NegativeFeedbackMatcher = NewPhraseMatcher(phrases)
+	return nil
+}
@@ -0,0 +1,61 @@
+package appcore
+
+import (
+	"testing"
+)
+
+// TestPhraseMatcher_NegationAndBoundaries tests word boundaries, stemming, negation and intensifiers.
+func TestPhraseMatcher_NegationAndBoundaries(t *testing.T) {
+	matcher := NewPhraseMatcher([]string{"bad", "terrible", "happy", "waste of money"})
+	cases := []struct {
+		text     string
+		expected float64
+	}{
+		{"not bad at all", 0},
+		{"I got a badge", 0},
+		{"I am unhappy", 0},
+		{"terribly slow", 1},
+		{"It was bad. Not great either.", 1},
+		{"It's not the price, it's bad support", 1}, // negation ends at the comma
+		{"very bad", 1.5},
+		{"slightly bad", 0.5},
+		{"a total waste of money", 1},
+		{"never a waste of money", 0},
+	}
+	for _, tc := range cases {
+		if got := AffirmedWeight(matcher.Match(tc.text)); got != tc.expected {
+			t.Errorf("%q: expected weight %v, got %v (%+v)", tc.text, tc.expected, got, matcher.Match(tc.text))
+		}
+	}
+}
+
+// TestStem tests that inflections share a stem.
+func TestStem(t *testing.T) {
+	groups := [][]string{
+		{"cancel", "cancelled", "canceled", "cancelling", "cancellation", "cancels"},
+		{"terrible", "terribly"},
+		{"happy", "happily"},
+		{"disappointed", "disappointing"},
+	}
+	for _, group := range groups {
+		for _, word := range group[1:] {
+			if Stem(word) != Stem(group[0]) {
+				t.Errorf("Expected %q and %q to share a stem, got %q and %q", word, group[0], Stem(word), Stem(group[0]))
+			}
+		}
+	}
+	if Stem("badge") == Stem("bad") {
+		t.Errorf("Expected badge and bad to have different stems")
+	}
+}
+
+// TestPredictChurn_NegatedKeyword tests that negated keywords no longer count as negative feedback.
+func TestPredictChurn_NegatedKeyword(t *testing.T) {
+	customerData := CustomerData{NLSScore: 4, Feedback: "Honestly not bad at all.", CommentSentiment: "POSITIVE"}
+	if prediction := PredictChurn(customerData); prediction.ChurnProbability != 0.4 {
+		t.Errorf("Expected 0.4 for negated keyword, got %v (%s)", prediction.ChurnProbability, prediction.Reason)
+	}
+	if intent := DetectCancelIntent("I don't want to cancel, just fix it"); intent.Detected {
+		t.Errorf("Expected negated cancel language not to be intent, got %+v", intent)
+	}
+}
@@ -0,0 +1,62 @@
+package appcore
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing metric, exported in Prometheus text format
+// by WriteMetrics. Counters are per process, so they are only meaningful for the
+// long-running cmd/server, not for individual serverless invocations.
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Uint64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() { c.value.Add(1) }
+
+// Add adds n to the counter.
+func (c *Counter) Add(n uint64) { c.value.Add(n) }
+
+// Value returns the current count.
+func (c *Counter) Value() uint64 { return c.value.Load() }
+
+var (
+	metricsMu sync.Mutex
+	counters  = map[string]*Counter{}
+)
+
+// NewCounter registers (or returns the already registered) counter with the given name.
+func NewCounter(name, help string) *Counter {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if c, ok := counters[name]; ok {
+		return c
+	}
+	c := &Counter{name: name, help: help}
+	counters[name] = c
+	return c
+}
+
+// WriteMetrics writes every registered counter in Prometheus text exposition format.
+func WriteMetrics(w io.Writer) error {
+	metricsMu.Lock()
+	snapshot := make([]*Counter, 0, len(counters))
+	for _, c := range counters {
+		snapshot = append(snapshot, c)
+	}
+	metricsMu.Unlock()
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].name < snapshot[j].name })
+
+	for _, c := range snapshot {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.Value()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
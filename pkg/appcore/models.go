@@ -0,0 +1,302 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ChurnModel scores customer feedback. Implementations must be safe for concurrent use.
+type ChurnModel interface {
+	Name() string
+	Version() string
+	Predict(data CustomerData) ChurnPrediction
+}
+
+// RulesModel is the rule-based model implemented by PredictChurn.
+type RulesModel struct{}
+
+func (RulesModel) Name() string                              { return RulesModelName }
+func (RulesModel) Version() string                           { return RulesModelVersion }
+func (RulesModel) Predict(data CustomerData) ChurnPrediction { return PredictChurn(data) }
+
+// --- Linear models ---
+
+// ChurnFeatureNames are the features available to linear models, see ChurnFeatures.
+var ChurnFeatureNames = []string{
+	"nls_score", "low_nls", "negative_keywords", "negative_sentiment",
+	"negative_aspects", "negative_emotions", "cancel_intent", "competitor",
+	"ticket", "escalated_ticket", "negative_tickets", "urgent_tickets", "ticket_reopens", "slow_resolutions",
+	"login_change", "usage_collapse", "seat_utilization", "features_adopted", "tenure_years", "renewal_soon",
+}
+
+// ChurnFeatures extracts the linear model features from enriched feedback:
+// nls_score is scaled to 0-1, counts are plain counts and the rest are 0 or 1.
+// Tickets have no NLS score, so nls_score and low_nls are 0 for them; the
+// ticket counts, usage and attributes come from the account signals and are 0
+// when unknown. login_change is the fractional change in logins (-0.6 for a
+// 60% drop) and renewal_soon means renewal within RenewalSoonDays.
+func ChurnFeatures(data CustomerData) map[string]float64 {
+	tickets := data.AccountSignals.TicketSummary()
+	features := map[string]float64{
+		"negative_aspects":  float64(len(NegativeChurnAspects(data.TopicSentiments))),
+		"negative_emotions": float64(len(intersectStrings(data.Emotions, NegativeEmotions))),
+		"negative_tickets":  float64(tickets.NegativeTickets),
+		"urgent_tickets":    float64(tickets.UrgentTickets),
+		"ticket_reopens":    float64(tickets.Reopens),
+		"slow_resolutions":  float64(tickets.SlowResolutions),
+	}
+	if usage := data.AccountSignals.UsageSummary(); usage != nil {
+		features["features_adopted"] = float64(usage.FeaturesAdopted)
+		if usage.LoginChange != nil {
+			features["login_change"] = *usage.LoginChange
+		}
+		if usage.SeatUtilization != nil {
+			features["seat_utilization"] = *usage.SeatUtilization
+		}
+	}
+	attributes := data.AccountSignals.AttributeSummary()
+	if attributes != nil && attributes.TenureMonths != nil {
+		features["tenure_years"] = float64(*attributes.TenureMonths) / 12
+	}
+	isTicket := data.Source == SignalSourceTicket
+	if !isTicket {
+		features["nls_score"] = float64(data.NLSScore) / 10
+	}
+	if data.Feedback != "" {
+		features["negative_keywords"] = AffirmedWeight(NegativeFeedbackMatcher.Match(data.Feedback))
+	}
+	for name, on := range map[string]bool{
+		"low_nls":            !isTicket && data.NLSScore < 5,
+		"negative_sentiment": strings.ToUpper(data.CommentSentiment) == "NEGATIVE",
+		"cancel_intent":      data.CancelIntent,
+		"competitor":         containsString(data.IntentSignals, "competitor"),
+		"ticket":             isTicket,
+		"escalated_ticket":   isTicket && data.Ticket.Escalated(),
+		"usage_collapse":     data.AccountSignals.UsageSummary().Collapsing(),
+		"renewal_soon":       attributes != nil && attributes.DaysToRenewal != nil && *attributes.DaysToRenewal >= 0 && *attributes.DaysToRenewal <= RenewalSoonDays,
+	} {
+		if on {
+			features[name] = 1
+		}
+	}
+	return features
+}
+
+// LinearModel is a logistic regression over ChurnFeatures, loaded from
+// CHURN_MODELS_PATH: P(churn) = 1 / (1 + exp(-(Intercept + sum(weight * feature)))).
+type LinearModel struct {
+	ModelName    string             `json:"name"`
+	ModelVersion string             `json:"version"`
+	Intercept    float64            `json:"intercept"`
+	Weights      map[string]float64 `json:"weights"`
+}
+
+func (m *LinearModel) Name() string    { return m.ModelName }
+func (m *LinearModel) Version() string { return m.ModelVersion }
+
+// Validate checks that the model is named and versioned and only uses known features.
+func (m *LinearModel) Validate() error {
+	if strings.TrimSpace(m.ModelName) == "" || strings.TrimSpace(m.ModelVersion) == "" {
+		return fmt.Errorf("linear model needs a name and a version")
+	}
+	if m.ModelName == RulesModelName {
+		return fmt.Errorf("model name %q is reserved for the rule-based model", RulesModelName)
+	}
+	for feature := range m.Weights {
+		if !containsString(ChurnFeatureNames, feature) {
+			return fmt.Errorf("model %s uses unknown feature %q (known: %s)", m.ModelName, feature, strings.Join(ChurnFeatureNames, ", "))
+		}
+	}
+	return nil
+}
+
+// Predict scores data; the reason names the features that raised the score most.
+func (m *LinearModel) Predict(data CustomerData) ChurnPrediction {
+	prediction := PredictChurn(data) // for the version metadata; the score is replaced below
+	features := ChurnFeatures(data)
+	z := m.Intercept
+	type contribution struct {
+		feature string
+		value   float64
+	}
+	var contributions []contribution
+	for _, feature := range ChurnFeatureNames {
+		if c := m.Weights[feature] * features[feature]; c != 0 {
+			z += c
+			contributions = append(contributions, contribution{feature, c})
+		}
+	}
+	sort.SliceStable(contributions, func(i, j int) bool { return contributions[i].value > contributions[j].value })
+	var drivers []string
+	for _, c := range contributions {
+		if c.value > 0 && len(drivers) < 2 {
+			drivers = append(drivers, c.feature)
+		}
+	}
+
+	prediction.ModelName, prediction.ModelVersion = m.ModelName, m.ModelVersion
+	prediction.ChurnProbability = 1 / (1 + math.Exp(-z))
+	prediction.Reason = "Linear model score."
+	if len(drivers) > 0 {
+		prediction.Reason = "Linear model score, driven by " + strings.Join(drivers, " and ") + "."
+	}
+	return prediction
+}
+
+// --- Primary, shadow and rollout models ---
+
+// ModelRollout sends Percent of customers to Model as their primary model.
+type ModelRollout struct {
+	Model   string `json:"model"`
+	Percent int    `json:"percent"`
+}
+
+// ModelRouting decides which models score each request. The primary model's
+// score is returned to the client; shadow models are scored and stored but
+// never returned. Customers in a rollout get the rollout model as primary and
+// the default primary as a shadow, so both arms are always stored.
+type ModelRouting struct {
+	Models   map[string]ChurnModel
+	Primary  string
+	Shadows  []string
+	Rollouts []ModelRollout
+}
+
+// ChurnModels routes every prediction. Configured by InitClients; the default
+// scores everything with the rules and nothing else.
+var ChurnModels = DefaultModelRouting()
+
+// DefaultModelRouting uses RulesModel as the only model.
+func DefaultModelRouting() *ModelRouting {
+	return &ModelRouting{Models: map[string]ChurnModel{RulesModelName: RulesModel{}}, Primary: RulesModelName}
+}
+
+// Validate checks that every referenced model exists and that rollouts add up to at most 100%.
+func (r *ModelRouting) Validate() error {
+	if _, ok := r.Models[r.Primary]; !ok {
+		return fmt.Errorf("primary model %q is not defined", r.Primary)
+	}
+	for _, name := range r.Shadows {
+		if _, ok := r.Models[name]; !ok {
+			return fmt.Errorf("shadow model %q is not defined", name)
+		}
+	}
+	total := 0
+	for _, rollout := range r.Rollouts {
+		if _, ok := r.Models[rollout.Model]; !ok {
+			return fmt.Errorf("rollout model %q is not defined", rollout.Model)
+		}
+		if rollout.Model == r.Primary {
+			return fmt.Errorf("rollout model %q is already the primary model", rollout.Model)
+		}
+		if rollout.Percent < 0 || rollout.Percent > 100 {
+			return fmt.Errorf("rollout percent for %q must be between 0 and 100", rollout.Model)
+		}
+		total += rollout.Percent
+	}
+	if total > 100 {
+		return fmt.Errorf("rollout percentages add up to %d%%, more than 100%%", total)
+	}
+	return nil
+}
+
+// RolloutBucket maps an account to a stable bucket from 0 to 99.
+func RolloutBucket(accountID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(strings.TrimSpace(accountID)))
+	return int(h.Sum32() % 100)
+}
+
+// Assign returns the primary model for an account and the shadow models. Rollout
+// buckets are consecutive ranges in Rollouts order, so an account keeps its model
+// as long as the rollouts before it are unchanged. Requests without an account
+// ID always get the default primary.
+func (r *ModelRouting) Assign(accountID string) (primary string, shadows []string) {
+	primary = r.Primary
+	if strings.TrimSpace(accountID) != "" {
+		bucket, lower := RolloutBucket(accountID), 0
+		for _, rollout := range r.Rollouts {
+			if bucket >= lower && bucket < lower+rollout.Percent {
+				primary = rollout.Model
+				shadows = append(shadows, r.Primary)
+				break
+			}
+			lower += rollout.Percent
+		}
+	}
+	for _, name := range r.Shadows {
+		if name != primary && !containsString(shadows, name) {
+			shadows = append(shadows, name)
+		}
+	}
+	return primary, shadows
+}
+
+// Score runs the primary and shadow models for data and calibrates each score
+// with its own model's calibration for data's source, if any. The returned
+// predictions are tagged with their model; only the first is the primary.
+func (r *ModelRouting) Score(data CustomerData) (primary ChurnPrediction, shadows []ChurnPrediction) {
+	primaryName, shadowNames := r.Assign(data.AccountID)
+	primary = CalibratePrediction(r.Models[primaryName].Predict(data), data.Source)
+	for _, name := range shadowNames {
+		shadow := CalibratePrediction(r.Models[name].Predict(data), data.Source)
+		shadow.Shadow = true
+		shadows = append(shadows, shadow)
+	}
+	return primary, shadows
+}
+
+// ConfigureChurnModels sets ChurnModels from:
+//   - CHURN_MODELS_PATH: a JSON array of linear model definitions
+//   - CHURN_PRIMARY_MODEL: the default primary model (defaults to the rules)
+//   - CHURN_SHADOW_MODELS: comma-separated models scored in shadow
+//   - CHURN_MODEL_ROLLOUT: JSON array of {"model", "percent"} for traffic splitting
+func ConfigureChurnModels() error {
+	routing := DefaultModelRouting()
+	if path := strings.TrimSpace(os.Getenv("CHURN_MODELS_PATH")); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading churn models: %w", err)
+		}
+		var models []*LinearModel
+		if err := json.Unmarshal(data, &models); err != nil {
+			return fmt.Errorf("error parsing churn models %s: %w", path, err)
+		}
+		for _, model := range models {
+			if err := model.Validate(); err != nil {
+				return err
+			}
+			if _, ok := routing.Models[model.ModelName]; ok {
+				return fmt.Errorf("churn model %q is defined twice", model.ModelName)
+			}
+			routing.Models[model.ModelName] = model
+		}
+	}
+	if primary := strings.TrimSpace(os.Getenv("CHURN_PRIMARY_MODEL")); primary != "" {
+		routing.Primary = primary
+	}
+	for _, name := range strings.Split(os.Getenv("CHURN_SHADOW_MODELS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			routing.Shadows = append(routing.Shadows, name)
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("CHURN_MODEL_ROLLOUT")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &routing.Rollouts); err != nil {
+			return fmt.Errorf("CHURN_MODEL_ROLLOUT must be a JSON array of {\"model\", \"percent\"}: %w", err)
+		}
+	}
+	if err := routing.Validate(); err != nil {
+		return err
+	}
+	ChurnModels = routing
+	if len(routing.Shadows) > 0 || len(routing.Rollouts) > 0 {
+		log.Printf("Churn models: primary %s, shadows %v, rollouts %+v.", routing.Primary, routing.Shadows, routing.Rollouts)
+	}
+	return nil
+}
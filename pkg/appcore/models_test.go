@@ -0,0 +1,64 @@
+package appcore
+
+import (
+	"strconv"
+	"testing"
+)
+
+// TestModelRouting_StickyRollout tests that rollout assignment is sticky per account and close to the configured share.
+func TestModelRouting_StickyRollout(t *testing.T) {
+	routing := DefaultModelRouting()
+	routing.Models["churn-linear"] = &LinearModel{ModelName: "churn-linear", ModelVersion: "1"}
+	routing.Rollouts = []ModelRollout{{Model: "churn-linear", Percent: 20}}
+	if err := routing.Validate(); err != nil {
+		t.Fatalf("Expected a valid routing, got %v", err)
+	}
+
+	inRollout := 0
+	for i := 0; i < 2000; i++ {
+		account := "acct_" + strconv.Itoa(i)
+		primary, shadows := routing.Assign(account)
+		if again, _ := routing.Assign(account); again != primary {
+			t.Fatalf("Expected sticky assignment for %s, got %s then %s", account, primary, again)
+		}
+		if primary == "churn-linear" {
+			inRollout++
+			if len(shadows) != 1 || shadows[0] != RulesModelName {
+				t.Errorf("Expected the default primary as shadow in the rollout arm, got %v", shadows)
+			}
+		}
+	}
+	if inRollout < 300 || inRollout > 500 {
+		t.Errorf("Expected about 20%% of 2000 accounts in the rollout, got %d", inRollout)
+	}
+	if primary, _ := routing.Assign(""); primary != RulesModelName {
+		t.Errorf("Expected requests without an account to use the default primary, got %s", primary)
+	}
+
+	routing.Rollouts = append(routing.Rollouts, ModelRollout{Model: "churn-linear", Percent: 90})
+	if err := routing.Validate(); err == nil {
+		t.Errorf("Expected rollouts over 100%% to be rejected")
+	}
+}
+
+// TestModelRouting_ShadowScores tests that shadow models are scored and tagged but the primary is returned first.
+func TestModelRouting_ShadowScores(t *testing.T) {
+	routing := DefaultModelRouting()
+	routing.Models["churn-linear"] = &LinearModel{ModelName: "churn-linear", ModelVersion: "1", Intercept: -2, Weights: map[string]float64{"cancel_intent": 4}}
+	routing.Shadows = []string{"churn-linear"}
+
+	data := CustomerData{AccountID: "acct_1", NLSScore: 6, Feedback: "I will cancel", CancelIntent: true, IntentSignals: []string{"cancel"}}
+	primary, shadows := routing.Score(data)
+	if primary.ModelName != RulesModelName || primary.Shadow || primary.ChurnProbability != 0.9 {
+		t.Errorf("Expected the rules as primary with 0.9, got %+v", primary)
+	}
+	if len(shadows) != 1 || !shadows[0].Shadow || shadows[0].ModelName != "churn-linear" || shadows[0].ModelVersion != "1" {
+		t.Fatalf("Expected one tagged churn-linear shadow prediction, got %+v", shadows)
+	}
+	if p := shadows[0].ChurnProbability; p < 0.88 || p > 0.89 || shadows[0].Reason != "Linear model score, driven by cancel_intent." {
+		t.Errorf("Expected sigmoid(2) driven by cancel_intent, got %v (%s)", p, shadows[0].Reason)
+	}
+	if err := (&LinearModel{ModelName: "x", ModelVersion: "1", Weights: map[string]float64{"shoe_size": 1}}).Validate(); err == nil {
+		t.Errorf("Expected unknown features to be rejected")
+	}
+}
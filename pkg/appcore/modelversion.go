@@ -0,0 +1,77 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// The rule-based model implemented by PredictChurn. Bump RulesModelVersion
+// whenever its rules, thresholds or probabilities change, so that predictions
+// made by different logic are never compared as if they were the same model.
+// Rows written before versioning have no model_version.
+const (
+	RulesModelName    = "churn-rules"
+	RulesModelVersion = "8" // usage collapse, support tickets, cancel intent, emotions, aspect sentiment and negation-aware keywords
+)
+
+// HighRiskProbability is the (calibrated) probability from which a prediction counts as high risk.
+const HighRiskProbability = 0.6
+
+// ModelVersionFilter selects the predictions compared by CompareModelVersions.
+type ModelVersionFilter struct {
+	ModelName string
+	Versions  []string // empty: every version
+	Since     time.Time
+	Until     time.Time
+}
+
+// ModelVersionStats summarizes the predictions made by one model version.
+type ModelVersionStats struct {
+	ModelName           string         `json:"model_name"`
+	ModelVersion        string         `json:"model_version"` // "" for unversioned rows
+	Predictions         int            `json:"predictions"`
+	ShadowPredictions   int            `json:"shadow_predictions"` // included in Predictions
+	FirstPredictedAt    time.Time      `json:"first_predicted_at"`
+	LastPredictedAt     time.Time      `json:"last_predicted_at"`
+	MeanProbability     float64        `json:"mean_probability"`
+	MeanRawProbability  float64        `json:"mean_raw_probability"`
+	HighRiskShare       float64        `json:"high_risk_share"` // share with probability >= HighRiskProbability
+	Reasons             map[string]int `json:"reasons"`
+	RulesVersions       []string       `json:"rules_versions"`
+	TaxonomyVersions    []string       `json:"taxonomy_versions"`
+	CalibrationVersions []string       `json:"calibration_versions"`
+	SentimentModels     []string       `json:"sentiment_models"`
+	ZeroShotModels      []string       `json:"zero_shot_models"`
+}
+
+// CompareModelVersions summarizes the stored predictions matching filter per
+// model version, ordered by when each version was first used. The predictions
+// are aggregated by the model_version_stats database function.
+func CompareModelVersions(filter ModelVersionFilter) ([]ModelVersionStats, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	params := map[string]interface{}{"high_risk_probability": HighRiskProbability}
+	if filter.ModelName != "" {
+		params["model_name"] = filter.ModelName
+	}
+	if len(filter.Versions) > 0 {
+		params["versions"] = filter.Versions
+	}
+	if !filter.Since.IsZero() {
+		params["since"] = filter.Since.UTC().Format(time.RFC3339)
+	}
+	if !filter.Until.IsZero() {
+		params["until"] = filter.Until.UTC().Format(time.RFC3339)
+	}
+	body, err := callRPC("model_version_stats", params)
+	if err != nil {
+		return nil, fmt.Errorf("error summarizing churn predictions: %w", err)
+	}
+	stats := []ModelVersionStats{}
+	if err := json.Unmarshal([]byte(body), &stats); err != nil {
+		return nil, fmt.Errorf("error unmarshalling model version stats: %w", err)
+	}
+	return stats, nil
+}
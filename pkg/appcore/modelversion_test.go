@@ -0,0 +1,64 @@
+package appcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestPredictChurn_RecordsModelVersion tests that every prediction records the model and enrichment versions.
+func TestPredictChurn_RecordsModelVersion(t *testing.T) {
+	customerData := CustomerData{
+		NLSScore:         9,
+		TaxonomyVersion:  "builtin-1",
+		EnrichmentScores: &EnrichmentScores{SentimentModel: SentimentModelID, TopicModel: ZeroShotModelID},
+	}
+	prediction := PredictChurn(customerData)
+	if prediction.ModelName != RulesModelName || prediction.ModelVersion != RulesModelVersion {
+		t.Errorf("Expected model %s/%s, got %s/%s", RulesModelName, RulesModelVersion, prediction.ModelName, prediction.ModelVersion)
+	}
+	if prediction.TaxonomyVersion != "builtin-1" || prediction.SentimentModel != SentimentModelID || prediction.ZeroShotModel != ZeroShotModelID {
+		t.Errorf("Expected taxonomy and enrichment models to be recorded, got %+v", prediction)
+	}
+	if prediction.RulesVersion == "" || prediction.RulesVersion != NewPhraseMatcher(DefaultNegativeFeedbackPhrases).Version() {
+		t.Errorf("Expected the default keyword set version, got %q", prediction.RulesVersion)
+	}
+	if NewPhraseMatcher([]string{"bad", "awful"}).Version() == prediction.RulesVersion {
+		t.Errorf("Expected a different keyword set to have a different version")
+	}
+}
+
+// TestCompareModelVersions tests that the filter is passed to the aggregating
+// database function and its rows are returned as they are.
+func TestCompareModelVersions(t *testing.T) {
+	fake := newFakeSupabase(t, func(req fakeRequest) fakeResponse {
+		return fakeResponse{Body: `[{"model_name": "churn-rules", "model_version": "6", "predictions": 2, "shadow_predictions": 0,
+			"first_predicted_at": "2026-09-03T00:00:00+00:00", "last_predicted_at": "2026-09-04T00:00:00+00:00",
+			"mean_probability": 0.45, "mean_raw_probability": 0.5, "high_risk_share": 0.5, "reasons": {"a": 2},
+			"rules_versions": [], "taxonomy_versions": ["builtin-1"], "calibration_versions": [], "sentiment_models": [], "zero_shot_models": []}]`}
+	})
+	since := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	stats, err := CompareModelVersions(ModelVersionFilter{ModelName: RulesModelName, Versions: []string{"5", "6"}, Since: since})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Predictions != 2 || stats[0].Reasons["a"] != 2 || !stats[0].LastPredictedAt.Equal(since.Add(72*time.Hour)) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	calls := fake.Requests(http.MethodPost, "rpc/model_version_stats")
+	if len(calls) != 1 {
+		t.Fatalf("Expected one model_version_stats call, got %d", len(calls))
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(calls[0].Body), &params); err != nil {
+		t.Fatal(err)
+	}
+	if params["model_name"] != RulesModelName || params["since"] != "2026-09-01T00:00:00Z" || params["high_risk_probability"] != HighRiskProbability || params["until"] != nil {
+		t.Errorf("Unexpected parameters %v", params)
+	}
+	if versions, _ := params["versions"].([]interface{}); len(versions) != 2 {
+		t.Errorf("Expected the versions to be passed, got %v", params["versions"])
+	}
+}
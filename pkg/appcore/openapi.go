@@ -0,0 +1,276 @@
+package appcore
+
+// OpenAPIVersion is the version of the published API description, bumped whenever
+// a request or response spec changes.
+const OpenAPIVersion = "2.10.0"
+
+// schemaFor converts a FieldSpec into an OpenAPI 3 schema object. Request
+// schemas are closed (additionalProperties: false) because ValidateJSON rejects
+// unknown fields; response schemas stay open so new fields don't break clients.
+func schemaFor(spec FieldSpec, closed bool) map[string]interface{} {
+	if spec.Ref != "" {
+		schema := map[string]interface{}{"allOf": []interface{}{schemaRef(spec.Ref)}}
+		if spec.Description != "" {
+			schema["description"] = spec.Description
+		}
+		return schema
+	}
+	schema := map[string]interface{}{"type": spec.Type}
+	if spec.Description != "" {
+		schema["description"] = spec.Description
+	}
+	if spec.Minimum != nil {
+		schema["minimum"] = *spec.Minimum
+	}
+	if spec.Maximum != nil {
+		schema["maximum"] = *spec.Maximum
+	}
+	if spec.MaxLength > 0 {
+		schema["maxLength"] = spec.MaxLength
+	}
+	if len(spec.Enum) > 0 {
+		schema["enum"] = spec.Enum
+	}
+	if spec.Items != nil {
+		schema["items"] = schemaFor(*spec.Items, closed)
+	}
+	if spec.Type == "object" {
+		for k, v := range objectSchema(spec.Properties, closed) {
+			schema[k] = v
+		}
+	}
+	if spec.Example != nil {
+		schema["example"] = spec.Example
+	}
+	return schema
+}
+
+func objectSchema(fields []FieldSpec, closed bool) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, field := range fields {
+		properties[field.Name] = schemaFor(field, closed)
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if closed {
+		schema["additionalProperties"] = false
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// OpenAPISchema converts an ObjectSpec into an OpenAPI 3 schema object.
+func (s ObjectSpec) OpenAPISchema(closed bool) map[string]interface{} {
+	schema := objectSchema(s.Fields, closed)
+	if s.Description != "" {
+		schema["description"] = s.Description
+	}
+	return schema
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(schemaName string) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schemaRef(schemaName)},
+	}
+}
+
+func errorResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			ProblemContentType: map[string]interface{}{"schema": schemaRef(problemSpec().Name)},
+		},
+	}
+}
+
+// problemSpec describes the RFC 7807 body of every error response, with the
+// documented error codes as enums.
+func problemSpec() ObjectSpec {
+	codes := make([]string, 0, len(ErrorCodes))
+	for _, code := range ErrorCodes {
+		codes = append(codes, code.Code)
+	}
+	return ObjectSpec{
+		Name:        "Problem",
+		Description: "RFC 7807 problem details. Branch on `code`, not on `detail`.",
+		Fields: []FieldSpec{
+			{Name: "type", Type: "string", Required: true, Example: ProblemTypeBase + ErrValidationFailed.Code},
+			{Name: "title", Type: "string", Required: true, Example: ErrValidationFailed.Title},
+			{Name: "status", Type: "integer", Required: true, Example: ErrValidationFailed.Status},
+			{Name: "detail", Type: "string", Description: "Human-readable explanation; wording may change.", Example: "nls_score must be between 0 and 10"},
+			{Name: "instance", Type: "string", Example: "/predict"},
+			{Name: "code", Type: "string", Required: true, Enum: codes, Description: "Stable machine-readable error code."},
+			{Name: "field", Type: "string", Description: "The (first) request field that caused the error.", Example: "nls_score"},
+			{Name: "request_id", Type: "string", Description: "Matches the X-Request-ID response header."},
+			{Name: "errors", Type: "array", Description: "Every invalid field, for validation_failed.", Items: &FieldSpec{Type: "object", Properties: []FieldSpec{
+				{Name: "field", Type: "string", Required: true, Example: "nls_score"},
+				{Name: "code", Type: "string", Required: true, Enum: FieldErrorCodes, Example: FieldErrOutOfRange},
+				{Name: "message", Type: "string", Required: true, Example: "nls_score must be between 0 and 10"},
+			}}},
+		},
+	}
+}
+
+// OpenAPIDocument builds the OpenAPI 3 description of the public API from the
+// same specs that PredictHandler validates against.
+func OpenAPIDocument() map[string]interface{} {
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Churn Prediction API",
+			"version":     OpenAPIVersion,
+			"description": "Predicts customer churn from NLS scores and feedback text.",
+		},
+		"paths": map[string]interface{}{
+			"/predict": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "predictChurn",
+					"summary":     "Store customer feedback and predict churn",
+					"parameters": []interface{}{
+						map[string]interface{}{
+							"name": "async", "in": "query", "schema": map[string]interface{}{"type": "boolean", "default": false},
+							"description": "Store the feedback as a job and return 202 at once; the prediction runs in the background.",
+						},
+					},
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent(PredictRequestSpec.Name),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "Prediction stored.", "content": jsonContent(PredictResponseSpec.Name)},
+						"202": map[string]interface{}{"description": "Job queued (async=true).", "content": jsonContent(JobAcceptedSpec.Name)},
+						"400": errorResponse("invalid_json or validation_failed."),
+						"405": errorResponse("method_not_allowed."),
+						"413": errorResponse("payload_too_large."),
+						"500": errorResponse("initialization_failed, storage_failed or internal_error."),
+					},
+				},
+			},
+			"/jobs/{id}": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getPredictionJob",
+					"summary":     "Poll an async prediction job",
+					"parameters": []interface{}{
+						map[string]interface{}{"name": "id", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "Job status, with the result once it has succeeded.", "content": jsonContent(JobStatusSpec.Name)},
+						"404": errorResponse("not_found."),
+						"405": errorResponse("method_not_allowed."),
+						"500": errorResponse("initialization_failed or storage_failed."),
+					},
+				},
+			},
+			"/ingest/{source}": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "ingestSurveyResponse",
+					"summary":     "Predict churn from a survey tool's webhook payload",
+					"description": "Accepts the native payload of the configured source (Typeform, SurveyMonkey, Delighted, Qualtrics or generic JSON), maps it to a PredictRequest and runs the same pipeline as /predict. Requests are authenticated with the tool's signature header or the source's token (as a bearer token, or in the token query parameter for sources that allow it).",
+					"parameters": []interface{}{
+						map[string]interface{}{"name": "source", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+					},
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object", "description": "The survey tool's native webhook payload."}},
+						},
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "Prediction stored, or the stored prediction of a response delivered again.", "content": jsonContent(PredictResponseSpec.Name)},
+						"202": map[string]interface{}{"description": "Job queued (async sources), or the job of a response delivered again.", "content": jsonContent(JobAcceptedSpec.Name)},
+						"400": errorResponse("invalid_json, or validation_failed when a mapped field is missing or invalid."),
+						"401": errorResponse("unauthorized: invalid signature or token, or unknown source."),
+						"405": errorResponse("method_not_allowed."),
+						"413": errorResponse("payload_too_large."),
+						"500": errorResponse("initialization_failed, storage_failed or internal_error."),
+					},
+				},
+			},
+			"/signals/tickets": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "ingestSupportTicket",
+					"summary":     "Store a support ticket event and predict churn",
+					"description": "The ticket text is enriched like survey feedback, and the event is scored together with the account's support tickets from the last 30 days. Requires the signals token (SIGNALS_API_TOKEN or ADMIN_API_TOKEN) as a bearer token.",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent(TicketRequestSpec.Name),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "Prediction stored.", "content": jsonContent(PredictResponseSpec.Name)},
+						"400": errorResponse("invalid_json or validation_failed."),
+						"401": errorResponse("unauthorized: invalid or missing signals token."),
+						"403": errorResponse("forbidden: signals endpoints are disabled."),
+						"405": errorResponse("method_not_allowed."),
+						"409": errorResponse("conflict: this ticket_id and event_at are already stored."),
+						"413": errorResponse("payload_too_large."),
+						"500": errorResponse("initialization_failed or storage_failed."),
+					},
+				},
+			},
+			"/signals/accounts": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "storeAccountAttributes",
+					"summary":     "Store account attributes (plan, MRR, tenure, renewal date)",
+					"description": "Attributes are read by the churn models when the account's feedback and tickets are scored. Omitted fields keep their stored value. Requires the signals token (SIGNALS_API_TOKEN or ADMIN_API_TOKEN) as a bearer token.",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent(AccountRequestSpec.Name),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "The stored attributes.", "content": map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}}},
+						"400": errorResponse("invalid_json or validation_failed."),
+						"401": errorResponse("unauthorized: invalid or missing signals token."),
+						"403": errorResponse("forbidden: signals endpoints are disabled."),
+						"405": errorResponse("method_not_allowed."),
+						"413": errorResponse("payload_too_large."),
+						"500": errorResponse("initialization_failed or storage_failed."),
+					},
+				},
+			},
+			"/signals/usage": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "storeAccountUsage",
+					"summary":     "Store an account's product usage for one period",
+					"description": "The latest ended period is compared with the three periods before it; a drop in logins of 50% or more raises the churn score of the account's feedback and tickets. Requires the signals token (SIGNALS_API_TOKEN or ADMIN_API_TOKEN) as a bearer token.",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent(UsageRequestSpec.Name),
+					},
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{"description": "The stored usage period.", "content": map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}}},
+						"400": errorResponse("invalid_json or validation_failed."),
+						"401": errorResponse("unauthorized: invalid or missing signals token."),
+						"403": errorResponse("forbidden: signals endpoints are disabled."),
+						"405": errorResponse("method_not_allowed."),
+						"413": errorResponse("payload_too_large."),
+						"500": errorResponse("initialization_failed or storage_failed."),
+					},
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				PredictRequestSpec.Name:  PredictRequestSpec.OpenAPISchema(true),
+				PredictResponseSpec.Name: PredictResponseSpec.OpenAPISchema(false),
+				TicketRequestSpec.Name:   TicketRequestSpec.OpenAPISchema(true),
+				AccountRequestSpec.Name:  AccountRequestSpec.OpenAPISchema(true),
+				UsageRequestSpec.Name:    UsageRequestSpec.OpenAPISchema(true),
+				JobAcceptedSpec.Name:     JobAcceptedSpec.OpenAPISchema(false),
+				JobStatusSpec.Name:       JobStatusSpec.OpenAPISchema(false),
+				problemSpec().Name:       problemSpec().OpenAPISchema(false),
+			},
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package appcore
+
+import (
+	"testing"
+)
+
+// TestOpenAPIDocument_MatchesRequestSpec tests that the published schema carries the validation rules.
+func TestOpenAPIDocument_MatchesRequestSpec(t *testing.T) {
+	doc := OpenAPIDocument()
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	request := schemas["PredictRequest"].(map[string]interface{})
+	properties := request["properties"].(map[string]interface{})
+
+	if request["additionalProperties"] != false {
+		t.Errorf("Expected request schema to reject additional properties")
+	}
+	nls := properties["nls_score"].(map[string]interface{})
+	if nls["type"] != "integer" || nls["minimum"] != 0.0 || nls["maximum"] != 10.0 {
+		t.Errorf("Expected nls_score to be an integer between 0 and 10, got %v", nls)
+	}
+	if properties["feedback_text"].(map[string]interface{})["maxLength"] != MaxFeedbackTextLength {
+		t.Errorf("Expected feedback_text maxLength %d", MaxFeedbackTextLength)
+	}
+	if required := request["required"].([]string); len(required) != 1 || required[0] != "nls_score" {
+		t.Errorf("Expected only nls_score to be required, got %v", required)
+	}
+}
+
+// TestOpenAPIDocument_AsyncJobs tests that the async mode and the job endpoint are documented.
+func TestOpenAPIDocument_AsyncJobs(t *testing.T) {
+	doc := OpenAPIDocument()
+	paths := doc["paths"].(map[string]interface{})
+	responses := paths["/predict"].(map[string]interface{})["post"].(map[string]interface{})["responses"].(map[string]interface{})
+	if _, ok := responses["202"]; !ok {
+		t.Errorf("Expected /predict to document the 202 async response")
+	}
+	if _, ok := paths["/jobs/{id}"]; !ok {
+		t.Errorf("Expected /jobs/{id} to be documented")
+	}
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	result := schemas["JobStatus"].(map[string]interface{})["properties"].(map[string]interface{})["result"].(map[string]interface{})
+	if ref := result["allOf"].([]interface{})[0].(map[string]interface{})["$ref"]; ref != "#/components/schemas/PredictResponse" {
+		t.Errorf("Expected the job result to reference PredictResponse, got %v", ref)
+	}
+}
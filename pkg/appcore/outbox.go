@@ -0,0 +1,351 @@
+package appcore
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// EventPredictionCreated is written to the outbox for every stored primary prediction.
+const EventPredictionCreated = "prediction.created"
+
+// EventEnvelope is the JSON published to every sink.
+type EventEnvelope struct {
+	ID        string          `json:"id"` // stable across redeliveries; consumers de-duplicate on it
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// PredictionCreatedData is the data of a prediction.created event.
+type PredictionCreatedData struct {
+	Feedback   CustomerData    `json:"feedback"`
+	Prediction ChurnPrediction `json:"prediction"`
+}
+
+// OutboxEvent is a row of outbox_events.
+type OutboxEvent struct {
+	ID             string          `json:"id"`
+	EventType      string          `json:"event_type"`
+	AggregateID    string          `json:"aggregate_id"` // the customer_feedback ID
+	Payload        json.RawMessage `json:"payload"`      // the EventEnvelope
+	CreatedAt      time.Time       `json:"created_at"`
+	PublishedAt    *time.Time      `json:"published_at"`
+	PublishedSinks []string        `json:"published_sinks"` // sinks that already accepted the event
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	FailedAt       *time.Time      `json:"failed_at,omitempty"` // set after MaxAttempts; the relay gives up
+}
+
+// ErrFeedbackExists is returned by StoreFeedbackWithPredictions when a feedback
+// row with the same ID is already stored, e.g. for a redelivered queue message.
+// Nothing is written in that case.
+var ErrFeedbackExists = errors.New("feedback already stored")
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating UUID: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	return formatUUID(b)
+}
+
+// feedbackNamespace is the namespace of name-based feedback IDs.
+var feedbackNamespace = []byte{0x6b, 0x3d, 0x9e, 0x52, 0x1c, 0x4a, 0x4f, 0x0b, 0x9a, 0x77, 0x2e, 0x51, 0xd8, 0x0c, 0x3f, 0x64}
+
+// NameUUID returns the name-based (version 5) UUID of name, so the same name
+// always maps to the same feedback ID.
+func NameUUID(name string) string {
+	h := sha1.New()
+	h.Write(feedbackNamespace)
+	h.Write([]byte(name))
+	b := h.Sum(nil)[:16]
+	b[6] = b[6]&0x0f | 0x50 // version 5
+	return formatUUID(b)
+}
+
+func formatUUID(b []byte) string {
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// NewOutboxEvent wraps data in an envelope and an outbox row.
+func NewOutboxEvent(eventType, aggregateID string, data interface{}) (OutboxEvent, error) {
+	now := time.Now().UTC()
+	event := OutboxEvent{ID: NewUUID(), EventType: eventType, AggregateID: aggregateID, CreatedAt: now, NextAttemptAt: now}
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return event, fmt.Errorf("error marshalling %s event data: %w", eventType, err)
+	}
+	event.Payload, err = json.Marshal(EventEnvelope{ID: event.ID, Type: eventType, CreatedAt: now, Data: rawData})
+	if err != nil {
+		return event, fmt.Errorf("error marshalling %s event: %w", eventType, err)
+	}
+	return event, nil
+}
+
+// StoreFeedbackWithPredictions stores the feedback row, its predictions (the
+// primary first, then any shadows), a prediction.created outbox event and the
+// primary prediction's webhook deliveries in a single transaction through the
+// store_prediction database function, so events and deliveries exist exactly
+// when the rows do. IDs are generated here because the events have to
+// reference them. A caller-supplied data.ID makes the store
+// idempotent: if that feedback is already stored nothing is written and
+// ErrFeedbackExists is returned. Otherwise it returns the feedback ID and the
+// predictions as stored.
+func StoreFeedbackWithPredictions(data CustomerData, predictions []ChurnPrediction) (string, []ChurnPrediction, error) {
+	if SupabaseClient == nil {
+		return "", nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	if len(predictions) == 0 {
+		return "", nil, fmt.Errorf("at least one prediction is required")
+	}
+	if data.ID == "" {
+		data.ID = NewUUID()
+	}
+	if data.CreatedAt.IsZero() {
+		data.CreatedAt = time.Now()
+	}
+	for i := range predictions {
+		predictions[i].ID = NewUUID()
+		predictions[i].CustomerID = data.ID
+		if predictions[i].PredictedAt.IsZero() {
+			predictions[i].PredictedAt = time.Now()
+		}
+	}
+
+	event, err := NewOutboxEvent(EventPredictionCreated, data.ID, PredictionCreatedData{Feedback: data, Prediction: predictions[0]})
+	if err != nil {
+		return "", nil, err
+	}
+	deliveries, err := predictionWebhookDeliveries(data, predictions[0])
+	if err != nil {
+		return "", nil, fmt.Errorf("error matching webhook subscriptions: %w", err)
+	}
+	params := map[string]interface{}{"feedback": data, "predictions": predictions, "events": []OutboxEvent{event}, "deliveries": deliveries}
+	body, err := callRPC("store_prediction", params)
+	if err != nil {
+		return "", nil, fmt.Errorf("error storing feedback, predictions and outbox event: %w", err)
+	}
+	// store_prediction returns NULL when the feedback ID was already taken.
+	if strings.TrimSpace(body) == "null" {
+		return data.ID, nil, ErrFeedbackExists
+	}
+	if len(deliveries) > 0 {
+		log.Printf("Queued %d webhook deliveries.", len(deliveries))
+	}
+	return data.ID, predictions, nil
+}
+
+// callRPC calls a database function and returns its JSON result. The client
+// returns only the response body, so a PostgREST error object in the body is
+// turned into an error.
+func callRPC(name string, params interface{}) (string, error) {
+	body := SupabaseClient.Rpc(name, "", params)
+	if body == "" {
+		return "", fmt.Errorf("rpc %s: no response", name)
+	}
+	apiErr := &rpcError{Name: name}
+	if json.Unmarshal([]byte(body), apiErr) == nil && apiErr.Message != "" {
+		return "", apiErr
+	}
+	return body, nil
+}
+
+// rpcError is a PostgREST error returned by a database function. Code is the
+// Postgres SQLSTATE, e.g. 23505 for a unique violation.
+type rpcError struct {
+	Name    string `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc %s failed (%s): %s %s", e.Name, e.Code, e.Message, e.Details)
+}
+
+// isUniqueViolation reports whether err is a database function failing on a
+// unique constraint.
+func isUniqueViolation(err error) bool {
+	var rpcErr *rpcError
+	return errors.As(err, &rpcErr) && rpcErr.Code == "23505"
+}
+
+// --- Relay ---
+
+// OutboxRelay publishes pending outbox events to every sink, oldest first.
+// Delivery is at least once: the sinks that accepted an event are recorded
+// after each pass, so a sink can see an event again if the relay stops before
+// recording it, but a failure in one sink is only retried on that sink.
+type OutboxRelay struct {
+	Sinks       []EventSink
+	BatchSize   int
+	Interval    time.Duration // poll interval when the outbox is drained
+	BaseBackoff time.Duration // wait after the first failed attempt, doubled per attempt up to MaxBackoff
+	MaxBackoff  time.Duration
+	MaxAttempts int // failed attempts after which an event is marked failed
+}
+
+// OutboxRelaySettings are the relay settings in use. Configured by InitClients;
+// its Sinks are empty (and the relay disabled) unless OUTBOX_SINKS is set.
+var OutboxRelaySettings = DefaultOutboxRelay()
+
+// DefaultOutboxRelay returns a relay without sinks and with the default timings.
+func DefaultOutboxRelay() *OutboxRelay {
+	return &OutboxRelay{BatchSize: 100, Interval: 5 * time.Second, BaseBackoff: 5 * time.Second, MaxBackoff: 10 * time.Minute, MaxAttempts: 20}
+}
+
+// Backoff returns how long to wait after the given (1-based) failed attempt.
+func (r *OutboxRelay) Backoff(attempts int) time.Duration {
+	d := r.BaseBackoff
+	for i := 1; i < attempts && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	return d
+}
+
+// RelayOnce publishes up to BatchSize due events, oldest first, to the sinks
+// that haven't accepted them yet. An event a sink rejects doesn't hold back the
+// rest of the batch: the sinks that did accept it are recorded, and it is
+// retried on the others after a backoff, or marked failed after MaxAttempts.
+// It returns the number of events published to every sink and the errors of
+// the events that weren't.
+func (r *OutboxRelay) RelayOnce(now time.Time) (int, error) {
+	if SupabaseClient == nil {
+		return 0, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, _, err := SupabaseClient.From("outbox_events").Select("*", "", false).
+		Is("published_at", "null").Is("failed_at", "null").Lte("next_attempt_at", now.UTC().Format(time.RFC3339Nano)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).Limit(r.BatchSize, "").Execute()
+	if err != nil {
+		return 0, fmt.Errorf("error selecting pending outbox events: %w", err)
+	}
+	var events []OutboxEvent
+	if err := json.Unmarshal(rawData, &events); err != nil {
+		return 0, fmt.Errorf("error unmarshalling outbox events: %w", err)
+	}
+
+	sinkNames := make([]string, len(r.Sinks))
+	for i, sink := range r.Sinks {
+		sinkNames[i] = sink.Name()
+	}
+	var published []string
+	var errs []error
+	for _, event := range events {
+		done := make(map[string]bool, len(event.PublishedSinks))
+		for _, name := range event.PublishedSinks {
+			done[name] = true
+		}
+		var failures []string
+		for _, sink := range r.Sinks {
+			if done[sink.Name()] {
+				continue
+			}
+			if err := sink.Publish(event); err != nil {
+				failures = append(failures, fmt.Sprintf("sink %s: %v", sink.Name(), err))
+				continue
+			}
+			done[sink.Name()] = true
+			event.PublishedSinks = append(event.PublishedSinks, sink.Name())
+		}
+		if len(failures) == 0 {
+			published = append(published, event.ID)
+			continue
+		}
+
+		event.Attempts++
+		lastError := strings.Join(failures, "; ")
+		update := map[string]interface{}{"published_sinks": event.PublishedSinks, "attempts": event.Attempts, "last_error": lastError, "next_attempt_at": now.Add(r.Backoff(event.Attempts))}
+		if event.Attempts >= r.MaxAttempts {
+			update["failed_at"] = now
+			log.Printf("Outbox event %s failed after %d attempts: %s", event.ID, event.Attempts, lastError)
+		}
+		if _, _, err := SupabaseClient.From("outbox_events").Update(update, "minimal", "").Eq("id", event.ID).Execute(); err != nil {
+			log.Printf("Error scheduling retry of outbox event %s: %v", event.ID, err)
+		}
+		errs = append(errs, fmt.Errorf("outbox event %s: %s", event.ID, lastError))
+	}
+
+	if len(published) > 0 {
+		update := map[string]interface{}{"published_at": time.Now(), "published_sinks": sinkNames, "last_error": nil}
+		if _, _, err := SupabaseClient.From("outbox_events").Update(update, "minimal", "").In("id", published).Execute(); err != nil {
+			// The events will be published again: at least once, not exactly once.
+			return 0, errors.Join(append(errs, fmt.Errorf("error marking %d outbox events published: %w", len(published), err))...)
+		}
+	}
+	return len(published), errors.Join(errs...)
+}
+
+// Run relays events until stop is closed. A full batch is followed immediately
+// by the next one; otherwise it waits Interval.
+func (r *OutboxRelay) Run(stop <-chan struct{}) {
+	names := make([]string, len(r.Sinks))
+	for i, sink := range r.Sinks {
+		names[i] = sink.Name()
+	}
+	log.Printf("Outbox relay started (sinks: %s).", strings.Join(names, ", "))
+	for {
+		n, err := r.RelayOnce(time.Now())
+		if err != nil {
+			log.Printf("Outbox relay: %v", err)
+		}
+		wait := r.Interval
+		if err == nil && n == r.BatchSize {
+			wait = 0
+		}
+		select {
+		case <-stop:
+			log.Println("Outbox relay stopped.")
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ConfigureOutbox sets OutboxRelaySettings from OUTBOX_SINKS (see
+// NewEventSinksFromEnv), OUTBOX_BATCH_SIZE, OUTBOX_MAX_ATTEMPTS and
+// OUTBOX_RELAY_INTERVAL.
+func ConfigureOutbox() error {
+	relay := DefaultOutboxRelay()
+	sinks, err := NewEventSinksFromEnv()
+	if err != nil {
+		return err
+	}
+	relay.Sinks = sinks
+	for name, dst := range map[string]*int{"OUTBOX_BATCH_SIZE": &relay.BatchSize, "OUTBOX_MAX_ATTEMPTS": &relay.MaxAttempts} {
+		if raw := strings.TrimSpace(os.Getenv(name)); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 {
+				return fmt.Errorf("%s must be a positive integer, got %q", name, raw)
+			}
+			*dst = n
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("OUTBOX_RELAY_INTERVAL")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("OUTBOX_RELAY_INTERVAL must be a positive Go duration, got %q", raw)
+		}
+		relay.Interval = d
+	}
+	OutboxRelaySettings = relay
+	return nil
+}
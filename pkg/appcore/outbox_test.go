@@ -0,0 +1,161 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNewOutboxEvent tests the UUID format and that the envelope carries the event ID and data.
+func TestNewOutboxEvent(t *testing.T) {
+	id := NewUUID()
+	if len(id) != 36 || id[14] != '4' || !strings.ContainsRune("89ab", rune(id[19])) || strings.Count(id, "-") != 4 {
+		t.Errorf("Expected a version 4 UUID, got %q", id)
+	}
+	if NewUUID() == id {
+		t.Errorf("Expected a new UUID on every call")
+	}
+
+	event, err := NewOutboxEvent(EventPredictionCreated, id, PredictionCreatedData{
+		Feedback:   CustomerData{ID: id, AccountID: "acct_1", NLSScore: 3},
+		Prediction: ChurnPrediction{CustomerID: id, ChurnProbability: 0.8},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var envelope struct {
+		ID   string                `json:"id"`
+		Type string                `json:"type"`
+		Data PredictionCreatedData `json:"data"`
+	}
+	if err := json.Unmarshal(event.Payload, &envelope); err != nil {
+		t.Fatalf("Expected a JSON envelope, got %v", err)
+	}
+	if envelope.ID != event.ID || envelope.Type != EventPredictionCreated || event.AggregateID != id {
+		t.Errorf("Expected the envelope to match the outbox row, got %+v", envelope)
+	}
+	if envelope.Data.Feedback.AccountID != "acct_1" || envelope.Data.Prediction.ChurnProbability != 0.8 {
+		t.Errorf("Expected the feedback and prediction in the envelope, got %+v", envelope.Data)
+	}
+}
+
+// TestIsUniqueViolation tests that unique violations raised by database
+// functions are recognised through wrapping.
+func TestIsUniqueViolation(t *testing.T) {
+	err := fmt.Errorf("error storing customer data and churn prediction: %w", &rpcError{Name: "store_prediction", Code: "23505", Message: "duplicate key value"})
+	if !isUniqueViolation(err) {
+		t.Errorf("Expected a wrapped 23505 to be a unique violation")
+	}
+	if isUniqueViolation(&rpcError{Name: "store_prediction", Code: "23503"}) || isUniqueViolation(fmt.Errorf("timeout")) || isUniqueViolation(nil) {
+		t.Errorf("Expected other errors not to be unique violations")
+	}
+}
+
+// TestStoreFeedbackWithPredictions_Deliveries tests that webhook deliveries for
+// matching subscriptions are stored by store_prediction with the prediction,
+// not inserted separately.
+func TestStoreFeedbackWithPredictions_Deliveries(t *testing.T) {
+	fake := newFakeSupabase(t, func(req fakeRequest) fakeResponse {
+		switch req.Path {
+		case "webhook_subscriptions":
+			return fakeResponse{Body: `[{"id":"sub_high","url":"https://example.com/a","min_churn_probability":0.7,"active":true},
+				{"id":"sub_low","url":"https://example.com/b","min_churn_probability":0.95,"active":true}]`}
+		case "rpc/store_prediction":
+			return fakeResponse{Body: `"stored"`}
+		}
+		return fakeResponse{}
+	})
+
+	predictions := []ChurnPrediction{{ChurnProbability: 0.8}, {ChurnProbability: 0.99, Shadow: true}}
+	id, stored, err := StoreFeedbackWithPredictions(CustomerData{AccountID: "acct_1"}, predictions)
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("Expected the feedback to be stored, got %v, %v", stored, err)
+	}
+	calls := fake.Requests("POST", "rpc/store_prediction")
+	if len(calls) != 1 {
+		t.Fatalf("Expected one store_prediction call, got %d", len(calls))
+	}
+	var params struct {
+		Deliveries []WebhookDelivery `json:"deliveries"`
+	}
+	if err := json.Unmarshal([]byte(calls[0].Body), &params); err != nil {
+		t.Fatalf("Expected JSON params, got %v", err)
+	}
+	if len(params.Deliveries) != 1 || params.Deliveries[0].SubscriptionID != "sub_high" || params.Deliveries[0].ID == "" {
+		t.Fatalf("Expected one delivery with an ID for the matching subscription, got %+v", params.Deliveries)
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(params.Deliveries[0].Payload, &event); err != nil || event.Data.CustomerID != id || event.Data.ChurnProbability != 0.8 {
+		t.Errorf("Expected the primary prediction of the stored feedback in the event, got %+v, %v", event, err)
+	}
+	if len(fake.Requests("POST", "webhook_deliveries")) != 0 {
+		t.Errorf("Expected no separate webhook_deliveries insert")
+	}
+}
+
+// recordingSink is an EventSink that records the events it accepts and rejects
+// the ones in fail.
+type recordingSink struct {
+	name      string
+	fail      map[string]bool
+	published []string
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Publish(event OutboxEvent) error {
+	if s.fail[event.ID] {
+		return fmt.Errorf("unavailable")
+	}
+	s.published = append(s.published, event.ID)
+	return nil
+}
+
+// TestOutboxRelay_RelayOnce tests that a failing event doesn't hold back the
+// rest of its batch, that retries skip the sinks that already accepted an event
+// and that an event is marked failed after MaxAttempts.
+func TestOutboxRelay_RelayOnce(t *testing.T) {
+	fake := newFakeSupabase(t, func(req fakeRequest) fakeResponse {
+		if req.Method == "GET" {
+			return fakeResponse{Body: `[{"id":"e1","published_sinks":["a"],"attempts":1},{"id":"e2","attempts":2},{"id":"e3"},{"id":"e4"}]`}
+		}
+		return fakeResponse{}
+	})
+	a := &recordingSink{name: "a", fail: map[string]bool{"e4": true}}
+	b := &recordingSink{name: "b", fail: map[string]bool{"e2": true}}
+	relay := DefaultOutboxRelay()
+	relay.Sinks = []EventSink{a, b}
+	relay.MaxAttempts = 3
+
+	n, err := relay.RelayOnce(time.Now())
+	if n != 2 || err == nil || !strings.Contains(err.Error(), "e2") || !strings.Contains(err.Error(), "e4") {
+		t.Fatalf("Expected e1 and e3 published and errors for e2 and e4, got %d, %v", n, err)
+	}
+	if strings.Join(a.published, ",") != "e2,e3" || strings.Join(b.published, ",") != "e1,e3,e4" {
+		t.Errorf("Expected e1 to skip sink a and e4 to reach sink b, got a=%v b=%v", a.published, b.published)
+	}
+	if q := fake.Requests("GET", "outbox_events")[0].Query; q.Get("failed_at") != "is.null" {
+		t.Errorf("Expected failed events to be skipped, got %v", q)
+	}
+
+	updates := map[string]map[string]interface{}{}
+	for _, req := range fake.Requests("PATCH", "outbox_events") {
+		var update map[string]interface{}
+		if err := json.Unmarshal([]byte(req.Body), &update); err != nil {
+			t.Fatalf("Expected a JSON update, got %v", err)
+		}
+		updates[req.Query.Get("id")] = update
+	}
+	if _, ok := updates["in.(e1,e3)"]["published_at"]; !ok {
+		t.Errorf("Expected e1 and e3 to be marked published, got %v", updates)
+	}
+	e2, e4 := updates["eq.e2"], updates["eq.e4"]
+	if fmt.Sprint(e2["published_sinks"]) != "[a]" || e2["attempts"] != 3.0 || e2["failed_at"] == nil {
+		t.Errorf("Expected e2 to record sink a and fail on its third attempt, got %v", e2)
+	}
+	if fmt.Sprint(e4["published_sinks"]) != "[b]" || e4["attempts"] != 1.0 || e4["failed_at"] != nil {
+		t.Errorf("Expected e4 to record sink b and be retried, got %v", e4)
+	}
+}
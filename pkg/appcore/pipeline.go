@@ -0,0 +1,206 @@
+package appcore
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ProcessFeedback runs the full prediction pipeline for a validated request:
+// PII redaction, language detection, Hugging Face enrichment, churn scoring with
+// the configured models, a single transactional store of the feedback, its
+// predictions and the outbox event, and webhook notification. It is shared by
+// POST /predict and the queue worker. Enrichment failures are logged and
+// degrade the result; only a storage failure is returned as an error, in which
+// case nothing was stored.
+func ProcessFeedback(req ApiPredictRequest) (ApiResponse, error) {
+	// Redact PII before the text is sent to Hugging Face or stored.
+	feedbackText, redactions := FeedbackRedactor.Redact(req.FeedbackText)
+	if len(redactions) > 0 {
+		log.Printf("Redacted PII from feedback text: %+v", redactions)
+	}
+	req.FeedbackText = feedbackText
+	return ProcessRedactedFeedback(req, redactions)
+}
+
+// ProcessRedactedFeedback is ProcessFeedback for a request whose feedback_text
+// has already been redacted, with the redactions that were applied. Async jobs
+// redact before the request is queued so that raw PII is never stored.
+func ProcessRedactedFeedback(req ApiPredictRequest, redactions []Redaction) (ApiResponse, error) {
+	customerData := EnrichFeedback(CustomerData{
+		ID:          req.FeedbackID,
+		AccountID:   req.AccountID,
+		NLSScore:    *req.NLSScore,
+		Feedback:    req.FeedbackText,
+		ProductLine: req.ProductLine,
+		Redactions:  redactions,
+		Source:      SignalSourceSurvey,
+	})
+	return scoreAndStore(customerData)
+}
+
+// StoredPredictionResponse rebuilds the response of a request whose feedback
+// was already stored under req.FeedbackID from its primary prediction. The
+// enrichment isn't stored with the prediction, so only the score is returned.
+func StoredPredictionResponse(req ApiPredictRequest) (ApiResponse, error) {
+	predictions, err := FetchChurnPredictions([]string{req.FeedbackID})
+	if err != nil {
+		return ApiResponse{}, err
+	}
+	for _, p := range predictions {
+		if p.Shadow || p.RescoreRunID != "" {
+			continue
+		}
+		return ApiResponse{
+			CustomerID: req.FeedbackID, AccountID: req.AccountID, ChurnProbability: p.ChurnProbability,
+			RawChurnProbability: p.RawChurnProbability, CalibrationVersion: p.CalibrationVersion, Reason: p.Reason,
+			TaxonomyVersion: p.TaxonomyVersion, ModelName: p.ModelName,
+			ModelVersion: p.ModelVersion, RulesVersion: p.RulesVersion,
+		}, nil
+	}
+	return ApiResponse{}, fmt.Errorf("feedback %s is stored without a primary prediction", req.FeedbackID)
+}
+
+// scoreAndStore looks up the account signals for enriched customerData, scores
+// it with the configured models and stores it with its predictions and the
+// webhook deliveries they trigger. It is shared by survey feedback and ticket
+// events. If customerData.ID is already stored it returns an error wrapping
+// ErrFeedbackExists and queues nothing.
+func scoreAndStore(customerData CustomerData) (ApiResponse, error) {
+	// Account features are stored with the signal so its score can be reproduced;
+	// features that can't be read are left out of the score.
+	asOf := customerData.CreatedAt
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	accountSignals, err := LookupAccountSignals(customerData, asOf)
+	if err != nil {
+		log.Printf("Warning: Could not look up account signals: %v", err)
+	}
+	customerData.AccountSignals = accountSignals
+
+	// Only the primary model's score is returned; shadow scores are stored for evaluation.
+	churnPrediction, shadowPredictions := ChurnModels.Score(customerData)
+	predictions := append([]ChurnPrediction{churnPrediction}, shadowPredictions...)
+
+	// The feedback, its predictions, the prediction.created outbox event and the
+	// webhook deliveries for high-risk predictions are written in one transaction.
+	log.Printf("Storing customer data and churn prediction (%s %s, %d shadow) in Supabase...", churnPrediction.ModelName, churnPrediction.ModelVersion, len(shadowPredictions))
+	customerID, predictions, err := StoreFeedbackWithPredictions(customerData, predictions)
+	if err != nil {
+		return ApiResponse{}, fmt.Errorf("error storing customer data and churn prediction: %w", err)
+	}
+	log.Printf("Customer data and churn prediction stored successfully. ID: %s\n", customerID)
+	customerData.ID = customerID
+	churnPrediction = predictions[0]
+
+	response := ApiResponse{
+		CustomerID:          customerID,
+		AccountID:           customerData.AccountID,
+		ChurnProbability:    churnPrediction.ChurnProbability,
+		RawChurnProbability: churnPrediction.RawChurnProbability,
+		CalibrationVersion:  churnPrediction.CalibrationVersion,
+		Reason:              churnPrediction.Reason,
+		CommentSentiment:    customerData.CommentSentiment,
+		CommentTopics:       customerData.CommentTopics,
+		TopicCategories:     customerData.TopicCategories,
+		TopicSentiments:     customerData.TopicSentiments,
+		ProductLine:         customerData.ProductLine,
+		TaxonomyVersion:     customerData.TaxonomyVersion,
+		Language:            customerData.Language,
+		Emotions:            customerData.Emotions,
+		CancelIntent:        customerData.CancelIntent,
+		Urgency:             customerData.Urgency,
+		ModelName:           churnPrediction.ModelName,
+		ModelVersion:        churnPrediction.ModelVersion,
+		RulesVersion:        churnPrediction.RulesVersion,
+		SentimentModel:      churnPrediction.SentimentModel,
+		ZeroShotModel:       churnPrediction.ZeroShotModel,
+	}
+	return response, nil
+}
+
+// EnrichFeedback runs language detection, Hugging Face enrichment and intent
+// detection on data.Feedback, which must already be redacted, and returns data
+// with the enrichment fields set. The caller's AccountID, NLSScore, ProductLine
+// and Redactions are kept. A failed sentiment call leaves comment_sentiment
+// UNKNOWN; other failures leave their fields empty.
+func EnrichFeedback(data CustomerData) CustomerData {
+	feedbackText := data.Feedback
+
+	// Detect the language and, if configured, translate before enrichment.
+	// The stored feedback_text is always the original (redacted) text.
+	enrichmentText, language, enrichmentLanguage, translatedBy := PrepareForEnrichment(feedbackText)
+	models := ModelsForLanguage(enrichmentLanguage)
+	log.Printf("Feedback language: %s (enriching as %s, translated by %q)", language, enrichmentLanguage, translatedBy)
+
+	log.Println("Fetching sentiment from Hugging Face...")
+	sentiment := "NEUTRAL"
+	sentimentScores, errSentiment := GetSentimentScoresWithModel(models.Sentiment, enrichmentText)
+	if errSentiment != nil {
+		log.Printf("Warning: Could not get sentiment from Hugging Face: %v", errSentiment)
+		sentiment = "UNKNOWN"
+	} else if best, ok := BestLabel(sentimentScores); ok {
+		sentiment = best.Label
+	}
+	log.Printf("Sentiment received: %s", sentiment)
+
+	log.Println("Fetching emotions from Hugging Face...")
+	emotionScores, errEmotions := GetEmotionScoresFromHF(enrichmentText, models)
+	if errEmotions != nil {
+		log.Printf("Warning: Could not get emotions from Hugging Face: %v", errEmotions)
+	}
+	emotions := SelectEmotions(emotionScores)
+
+	// Cancellation phrases are matched in the translated text and, when translated, the original too.
+	intentText := enrichmentText
+	if translatedBy != "" {
+		intentText += "\n" + feedbackText
+	}
+	intent := DetectCancelIntent(intentText)
+	log.Printf("Emotions: %v; cancel intent: %+v", emotions, intent)
+
+	taxonomy := TopicTaxonomy
+	log.Printf("Fetching topics from Hugging Face (taxonomy %s, product line %s)...", taxonomy.Version, data.ProductLine)
+	topics, errTopics := taxonomy.ExtractTopics(enrichmentText, data.ProductLine, models)
+	if errTopics != nil {
+		log.Printf("Warning: Could not get topics from Hugging Face: %v", errTopics)
+	}
+	log.Printf("Topics received: %v (categories %v)", topics.Topics, topics.Categories)
+	topicScores := topics.Scores
+	if errTopics != nil {
+		// Don't persist zero scores for a failed call as if the model had produced
+		// them, but keep the synonym matches so re-thresholding doesn't drop them.
+		topicScores = SynonymTopicScores(topicScores)
+	}
+
+	var topicSentiments []TopicSentiment
+	if len(topics.Topics) > 0 {
+		log.Println("Fetching per-topic sentiment from Hugging Face...")
+		var errAspects error
+		topicSentiments, errAspects = taxonomy.ExtractTopicSentiments(enrichmentText, data.ProductLine, topics.Topics, models)
+		if errAspects != nil {
+			log.Printf("Warning: Could not get per-topic sentiment from Hugging Face: %v", errAspects)
+		}
+		log.Printf("Topic sentiments received: %+v", topicSentiments)
+	}
+
+	data.CommentSentiment = sentiment
+	data.CommentTopics = topics.Topics
+	data.TopicCategories = topics.Categories
+	data.TopicSentiments = topicSentiments
+	data.TaxonomyVersion = taxonomy.Version
+	data.Language = language
+	data.TranslatedBy = translatedBy
+	data.Emotions = emotions
+	data.CancelIntent = intent.Detected
+	data.Urgency = intent.Urgency
+	data.IntentSignals = intent.Signals
+	data.EnrichmentScores = NewEnrichmentScores(models, sentimentScores, topicScores)
+	data.EnrichmentScores.Emotions = emotionScores
+	if len(emotionScores) > 0 {
+		data.EnrichmentScores.TopicModel = models.ZeroShot // emotions use the zero-shot model even without topics
+	}
+
+	return data
+}
@@ -0,0 +1,405 @@
+package appcore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// ErasureMode selects how a data subject's feedback rows are erased.
+type ErasureMode string
+
+const (
+	// ErasureModeDelete deletes customer_feedback rows; churn_predictions follow via ON DELETE CASCADE.
+	ErasureModeDelete ErasureMode = "delete"
+	// ErasureModeAnonymize keeps the rows for aggregate reporting but removes the
+	// feedback text, the link to the account and the ticket and account details.
+	ErasureModeAnonymize ErasureMode = "anonymize"
+)
+
+const (
+	// pageSize is how many rows are read per request; PostgREST silently caps
+	// larger selects at its max-rows setting (1000 by default).
+	pageSize = 1000
+	// idBatchSize bounds how many IDs are sent in one In filter, keeping URLs short.
+	idBatchSize = 100
+)
+
+// CustomerFeedbackExport is one stored feedback row (including its enrichment)
+// together with every churn prediction made for it.
+type CustomerFeedbackExport struct {
+	CustomerData
+	Predictions []ChurnPrediction `json:"predictions"`
+}
+
+// MarshalJSON adds the predictions to the feedback row's fields; without it the
+// embedded CustomerData.MarshalJSON would be promoted and drop them.
+func (e CustomerFeedbackExport) MarshalJSON() ([]byte, error) {
+	row, err := json.Marshal(e.CustomerData)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(row, &fields); err != nil {
+		return nil, err
+	}
+	if fields["predictions"], err = json.Marshal(e.Predictions); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// CustomerExport is everything stored for an account, as returned to a data subject.
+type CustomerExport struct {
+	AccountID  string                   `json:"account_id"`
+	ExportedAt time.Time                `json:"exported_at"`
+	Feedback   []CustomerFeedbackExport `json:"feedback"`
+	Outcome    *ChurnOutcome            `json:"churn_outcome,omitempty"`
+	Attributes *AccountAttributes       `json:"account_attributes,omitempty"`
+	Usage      []AccountUsage           `json:"account_usage"`
+}
+
+// ErasureRecord is the audit record written for every erasure request. The
+// account ID itself is not kept; SubjectHash lets us confirm a request was
+// handled without storing the identifier we were asked to forget.
+type ErasureRecord struct {
+	ID             string      `json:"id,omitempty"`
+	SubjectHash    string      `json:"subject_hash"`
+	Mode           ErasureMode `json:"erasure_mode"`
+	FeedbackRows   int         `json:"feedback_rows"`
+	PredictionRows int         `json:"prediction_rows"`
+	OutcomeRows    int         `json:"outcome_rows"`
+	WebhookRows    int         `json:"webhook_rows"`
+	OutboxRows     int         `json:"outbox_rows"`
+	JobRows        int         `json:"job_rows"`
+	AccountRows    int         `json:"account_rows"`
+	CacheRows      int         `json:"cache_rows"`
+	RequestedBy    string      `json:"requested_by,omitempty"`
+	Reason         string      `json:"reason,omitempty"`
+	ErasedAt       time.Time   `json:"erased_at"`
+}
+
+// SubjectHash returns the SHA-256 of an account ID as stored in erasure audit records.
+func SubjectHash(accountID string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(accountID)))
+	return hex.EncodeToString(sum[:])
+}
+
+// FetchCustomerFeedback returns all customer_feedback rows for an account, oldest first.
+func FetchCustomerFeedback(accountID string) ([]CustomerData, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, err := selectAllByID("customer_feedback", "*", func(query *postgrest.FilterBuilder) *postgrest.FilterBuilder {
+		return query.Eq("account_id", accountID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching customer feedback: %w", err)
+	}
+	var rows []CustomerData
+	if err := json.Unmarshal(rawData, &rows); err != nil {
+		return nil, fmt.Errorf("error unmarshalling customer feedback: %w", err)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].CreatedAt.Before(rows[j].CreatedAt) })
+	return rows, nil
+}
+
+// FetchChurnPredictions returns all churn_predictions rows for the given feedback IDs.
+func FetchChurnPredictions(feedbackIDs []string) ([]ChurnPrediction, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	var predictions []ChurnPrediction
+	for start := 0; start < len(feedbackIDs); start += idBatchSize {
+		batch := feedbackIDs[start:min(start+idBatchSize, len(feedbackIDs))]
+		rawData, err := selectAllByID("churn_predictions", "*", func(query *postgrest.FilterBuilder) *postgrest.FilterBuilder {
+			return query.In("customer_feedback_id", batch)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error fetching churn predictions: %w", err)
+		}
+		var rows []ChurnPrediction
+		if err := json.Unmarshal(rawData, &rows); err != nil {
+			return nil, fmt.Errorf("error unmarshalling churn predictions: %w", err)
+		}
+		predictions = append(predictions, rows...)
+	}
+	sort.Slice(predictions, func(i, j int) bool { return predictions[i].PredictedAt.Before(predictions[j].PredictedAt) })
+	return predictions, nil
+}
+
+// selectAllByID selects the filtered rows of table a page at a time, in id
+// order, until a page comes back empty, and returns them as one JSON array.
+// columns must include id.
+func selectAllByID(table, columns string, filter func(*postgrest.FilterBuilder) *postgrest.FilterBuilder) ([]byte, error) {
+	rows := []json.RawMessage{}
+	lastID := ""
+	for {
+		query := filter(SupabaseClient.From(table).Select(columns, "", false))
+		if lastID != "" {
+			query = query.Gt("id", lastID)
+		}
+		rawData, _, err := query.Order("id", &postgrest.OrderOpts{Ascending: true}).Limit(pageSize, "").Execute()
+		if err != nil {
+			return nil, err
+		}
+		var page []json.RawMessage
+		if err := json.Unmarshal(rawData, &page); err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return json.Marshal(rows)
+		}
+		var last struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(page[len(page)-1], &last); err != nil || last.ID == "" {
+			return nil, fmt.Errorf("%s page without row ids", table)
+		}
+		rows = append(rows, page...)
+		lastID = last.ID
+	}
+}
+
+// ExportCustomerData collects every feedback row, its enrichment and its
+// predictions for an account, with its churn outcome, attributes and usage.
+func ExportCustomerData(accountID string) (CustomerExport, error) {
+	export := CustomerExport{AccountID: accountID, ExportedAt: time.Now(), Feedback: []CustomerFeedbackExport{}, Usage: []AccountUsage{}}
+
+	feedback, err := FetchCustomerFeedback(accountID)
+	if err != nil {
+		return export, err
+	}
+	predictions, err := FetchChurnPredictions(feedbackIDs(feedback))
+	if err != nil {
+		return export, err
+	}
+
+	byFeedback := map[string][]ChurnPrediction{}
+	for _, p := range predictions {
+		byFeedback[p.CustomerID] = append(byFeedback[p.CustomerID], p)
+	}
+	for _, row := range feedback {
+		rowPredictions := byFeedback[row.ID]
+		if rowPredictions == nil {
+			rowPredictions = []ChurnPrediction{}
+		}
+		export.Feedback = append(export.Feedback, CustomerFeedbackExport{CustomerData: row, Predictions: rowPredictions})
+	}
+
+	outcomes, err := FetchChurnOutcomes(accountID)
+	if err != nil {
+		return export, err
+	}
+	if len(outcomes) > 0 {
+		export.Outcome = &outcomes[0]
+	}
+
+	if export.Attributes, err = FetchAccountAttributes(accountID); err != nil {
+		return export, err
+	}
+	usage, err := FetchAccountUsage(accountID, time.Time{}, time.Time{})
+	if err != nil {
+		return export, err
+	}
+	export.Usage = append(export.Usage, usage...)
+	return export, nil
+}
+
+// FetchChurnOutcomes returns the churn_outcomes rows for an account (at most one).
+func FetchChurnOutcomes(accountID string) ([]ChurnOutcome, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, _, err := SupabaseClient.From("churn_outcomes").Select("*", "", false).Eq("account_id", accountID).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching churn outcomes: %w", err)
+	}
+	var outcomes []ChurnOutcome
+	if err := json.Unmarshal(rawData, &outcomes); err != nil {
+		return nil, fmt.Errorf("error unmarshalling churn outcomes: %w", err)
+	}
+	return outcomes, nil
+}
+
+// EraseCustomerData erases everything stored for an account and writes an audit record.
+// In delete mode the feedback rows are removed and their predictions cascade; in
+// anonymize mode the feedback text, account link, ticket fields and account
+// signals are cleared and predictions are kept. The account's churn outcome,
+// attributes, usage, async prediction jobs, webhook deliveries and outbox events
+// (whose payloads name the account) and the cached enrichment responses for its
+// feedback texts are deleted in both modes.
+func EraseCustomerData(accountID string, mode ErasureMode, requestedBy, reason string) (ErasureRecord, error) {
+	record := ErasureRecord{
+		SubjectHash: SubjectHash(accountID),
+		Mode:        mode,
+		RequestedBy: requestedBy,
+		Reason:      reason,
+	}
+	if SupabaseClient == nil {
+		return record, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	if mode != ErasureModeDelete && mode != ErasureModeAnonymize {
+		return record, fmt.Errorf("unknown erasure mode %q (expected delete or anonymize)", mode)
+	}
+
+	// The texts are read first: the cached enrichment responses are found by them.
+	feedback, err := FetchCustomerFeedback(accountID)
+	if err != nil {
+		return record, err
+	}
+	if record.CacheRows, err = eraseEnrichmentCache(feedback); err != nil {
+		return record, err
+	}
+
+	switch mode {
+	case ErasureModeDelete:
+		_, count, err := SupabaseClient.From("churn_predictions").Select("id,customer_feedback!inner(account_id)", "exact", true).
+			Eq("customer_feedback.account_id", accountID).Execute()
+		if err != nil {
+			return record, fmt.Errorf("error counting churn predictions: %w", err)
+		}
+		record.PredictionRows = int(count)
+		_, count, err = SupabaseClient.From("customer_feedback").Delete("minimal", "exact").Eq("account_id", accountID).Execute()
+		if err != nil {
+			return record, fmt.Errorf("error deleting customer feedback: %w", err)
+		}
+		record.FeedbackRows = int(count)
+	case ErasureModeAnonymize:
+		update := map[string]interface{}{"feedback_text": nil, "account_id": nil, "redactions": nil, "ticket": nil, "account_signals": nil}
+		_, count, err := SupabaseClient.From("customer_feedback").Update(update, "minimal", "exact").Eq("account_id", accountID).Execute()
+		if err != nil {
+			return record, fmt.Errorf("error anonymizing customer feedback: %w", err)
+		}
+		record.FeedbackRows = int(count)
+	}
+
+	outcomes, err := FetchChurnOutcomes(accountID)
+	if err != nil {
+		return record, err
+	}
+	if record.OutcomeRows = len(outcomes); record.OutcomeRows > 0 {
+		if _, _, err := SupabaseClient.From("churn_outcomes").Delete("minimal", "").Eq("account_id", accountID).Execute(); err != nil {
+			return record, fmt.Errorf("error deleting churn outcome: %w", err)
+		}
+	}
+
+	// Attempts and dead letters cascade from the deliveries.
+	_, count, err := SupabaseClient.From("webhook_deliveries").Delete("minimal", "exact").Eq("payload->data->>account_id", accountID).Execute()
+	if err != nil {
+		return record, fmt.Errorf("error deleting webhook deliveries: %w", err)
+	}
+	record.WebhookRows = int(count)
+
+	// Published events are deleted too: the outbox copy holds the feedback text.
+	_, count, err = SupabaseClient.From("outbox_events").Delete("minimal", "exact").Eq("payload->data->feedback->>account_id", accountID).Execute()
+	if err != nil {
+		return record, fmt.Errorf("error deleting outbox events: %w", err)
+	}
+	record.OutboxRows = int(count)
+
+	// Jobs hold a copy of the request, including the (redacted) feedback text.
+	_, count, err = SupabaseClient.From("prediction_jobs").Delete("minimal", "exact").Eq("request->>account_id", accountID).Execute()
+	if err != nil {
+		return record, fmt.Errorf("error deleting prediction jobs: %w", err)
+	}
+	record.JobRows = int(count)
+
+	for _, table := range []string{"account_attributes", "account_usage"} {
+		_, count, err = SupabaseClient.From(table).Delete("minimal", "exact").Eq("account_id", accountID).Execute()
+		if err != nil {
+			return record, fmt.Errorf("error deleting %s: %w", table, err)
+		}
+		record.AccountRows += int(count)
+	}
+
+	record.ErasedAt = time.Now()
+	if _, _, err := SupabaseClient.From("data_erasure_audit").Insert(record, false, "", "minimal", "").Execute(); err != nil {
+		// The erasure itself has already happened; make sure the missing audit record is visible.
+		log.Printf("Erasure (%s) of %d feedback rows completed but the audit record could not be written: %v", mode, record.FeedbackRows, err)
+		return record, fmt.Errorf("erasure completed but audit record failed: %w", err)
+	}
+	log.Printf("Erased data for subject %s (%s): %d feedback rows, %d prediction rows, %d cached responses.", record.SubjectHash[:12], mode, record.FeedbackRows, record.PredictionRows, record.CacheRows)
+	return record, nil
+}
+
+// eraseEnrichmentCache deletes the cached responses for the feedback texts: for
+// the text itself, its clauses (aspect sentiment) and, where the cached
+// translation shows what it was translated to, the translated text and its
+// clauses. Responses for texts translated by an uncached backend can't be found
+// and expire with ENRICHMENT_CACHE_TTL.
+func eraseEnrichmentCache(feedback []CustomerData) (int, error) {
+	textHashes := map[string]bool{}
+	addText := func(text string) {
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		textHashes[EnrichmentTextHash(text)] = true
+		for _, clause := range SplitClauses(text) {
+			textHashes[EnrichmentTextHash(clause)] = true
+		}
+	}
+	var originals []string
+	for _, row := range feedback {
+		if strings.TrimSpace(row.Feedback) != "" {
+			addText(row.Feedback)
+			originals = append(originals, EnrichmentTextHash(row.Feedback))
+		}
+	}
+
+	for start := 0; start < len(originals); start += idBatchSize {
+		rawData, _, err := SupabaseClient.From("enrichment_cache").Select("response", "", false).
+			In("text_hash", originals[start:min(start+idBatchSize, len(originals))]).Execute()
+		if err != nil {
+			return 0, fmt.Errorf("error reading cached translations: %w", err)
+		}
+		var rows []enrichmentCacheRow
+		if err := json.Unmarshal(rawData, &rows); err != nil {
+			return 0, fmt.Errorf("error unmarshalling cached translations: %w", err)
+		}
+		for _, row := range rows {
+			var translation []struct {
+				TranslationText string `json:"translation_text"`
+			}
+			if json.Unmarshal(row.Response, &translation) == nil && len(translation) > 0 {
+				addText(translation[0].TranslationText)
+			}
+		}
+	}
+
+	if lru, ok := HFCache.(*LRUCache); ok {
+		lru.DeleteTexts(textHashes)
+	}
+	hashes := make([]string, 0, len(textHashes))
+	for hash := range textHashes {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	deleted := 0
+	for start := 0; start < len(hashes); start += idBatchSize {
+		_, count, err := SupabaseClient.From("enrichment_cache").Delete("minimal", "exact").
+			In("text_hash", hashes[start:min(start+idBatchSize, len(hashes))]).Execute()
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting cached enrichment responses: %w", err)
+		}
+		deleted += int(count)
+	}
+	return deleted, nil
+}
+
+func feedbackIDs(rows []CustomerData) []string {
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.ID != "" {
+			ids = append(ids, row.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,165 @@
+package appcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCustomerFeedbackExport_JSON tests that exported feedback rows keep their predictions.
+func TestCustomerFeedbackExport_JSON(t *testing.T) {
+	export := CustomerFeedbackExport{
+		CustomerData: CustomerData{ID: "f1", NLSScore: 3},
+		Predictions:  []ChurnPrediction{{CustomerID: "f1", ChurnProbability: 0.8}},
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		ID          string            `json:"id"`
+		NLSScore    int               `json:"nls_score"`
+		Predictions []ChurnPrediction `json:"predictions"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID != "f1" || decoded.NLSScore != 3 || len(decoded.Predictions) != 1 {
+		t.Errorf("Expected the row with its prediction, got %s", data)
+	}
+}
+
+// erasureFake answers the requests EraseCustomerData makes for an account with
+// one feedback row (text text), one churn outcome and the given cached responses.
+func erasureFake(t *testing.T, text string, cached string) *fakeSupabase {
+	return newFakeSupabase(t, func(req fakeRequest) fakeResponse {
+		switch {
+		case req.Method == http.MethodGet && req.Path == "customer_feedback":
+			if req.Query.Get("id") != "" {
+				return fakeResponse{}
+			}
+			return fakeResponse{Body: `[{"id": "f1", "account_id": "acct_1", "feedback_text": ` + strconv.Quote(text) + `}]`}
+		case req.Method == http.MethodGet && req.Path == "enrichment_cache":
+			return fakeResponse{Body: cached}
+		case req.Method == http.MethodGet && req.Path == "churn_outcomes":
+			return fakeResponse{Body: `[{"account_id": "acct_1", "churned": true}]`}
+		case req.Method == http.MethodHead && req.Path == "churn_predictions":
+			return fakeResponse{Count: 3}
+		case req.Method == http.MethodDelete || req.Method == http.MethodPatch:
+			return fakeResponse{Count: 1}
+		}
+		return fakeResponse{Status: http.StatusCreated}
+	})
+}
+
+// TestEraseCustomerData_Delete tests that delete mode removes the account's rows
+// in every table by account, not by a list of IDs, and records the counts.
+func TestEraseCustomerData_Delete(t *testing.T) {
+	fake := erasureFake(t, "Too expensive, but support is great", "[]")
+
+	record, err := EraseCustomerData("acct_1", ErasureModeDelete, "dpo", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deleted := map[string]bool{}
+	for _, req := range fake.Requests(http.MethodDelete, "customer_feedback") {
+		if req.Query.Get("account_id") != "eq.acct_1" || req.Query.Get("id") != "" {
+			t.Errorf("Expected the feedback to be deleted by account, got %v", req.Query)
+		}
+	}
+	for _, table := range []string{"customer_feedback", "churn_outcomes", "webhook_deliveries", "outbox_events", "prediction_jobs", "account_attributes", "account_usage", "enrichment_cache"} {
+		deleted[table] = len(fake.Requests(http.MethodDelete, table)) > 0
+		if !deleted[table] {
+			t.Errorf("Expected %s to be erased", table)
+		}
+	}
+	if len(fake.Requests(http.MethodPatch, "customer_feedback")) != 0 {
+		t.Errorf("Expected no anonymization in delete mode")
+	}
+	cacheDelete := fake.Requests(http.MethodDelete, "enrichment_cache")[0].Query.Get("text_hash")
+	for _, text := range []string{"Too expensive, but support is great", "Too expensive", "support is great"} {
+		if !strings.Contains(cacheDelete, EnrichmentTextHash(text)) {
+			t.Errorf("Expected the cached responses for %q to be deleted, got %s", text, cacheDelete)
+		}
+	}
+	if record.FeedbackRows != 1 || record.PredictionRows != 3 || record.OutcomeRows != 1 || record.CacheRows != 1 || record.AccountRows != 2 {
+		t.Errorf("Unexpected erasure record %+v", record)
+	}
+	audit := fake.Requests(http.MethodPost, "data_erasure_audit")
+	if len(audit) != 1 || !strings.Contains(audit[0].Body, `"cache_rows":1`) || strings.Contains(audit[0].Body, "acct_1") {
+		t.Errorf("Expected one audit record without the account ID, got %+v", audit)
+	}
+}
+
+// TestEraseCustomerData_Anonymize tests that anonymize mode keeps the rows but
+// clears the text, account, ticket and signals, and erases cached responses for
+// the translated text too.
+func TestEraseCustomerData_Anonymize(t *testing.T) {
+	fake := erasureFake(t, "Demasiado caro", `[{"response": [{"translation_text": "Too expensive"}]}]`)
+
+	record, err := EraseCustomerData("acct_1", ErasureModeAnonymize, "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fake.Requests(http.MethodDelete, "customer_feedback")) != 0 {
+		t.Errorf("Expected the feedback rows to be kept")
+	}
+	updates := fake.Requests(http.MethodPatch, "customer_feedback")
+	if len(updates) != 1 || updates[0].Query.Get("account_id") != "eq.acct_1" {
+		t.Fatalf("Expected one update by account, got %+v", updates)
+	}
+	var cleared map[string]interface{}
+	if err := json.Unmarshal([]byte(updates[0].Body), &cleared); err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{"feedback_text", "account_id", "redactions", "ticket", "account_signals"} {
+		if value, ok := cleared[column]; !ok || value != nil {
+			t.Errorf("Expected %s to be cleared, got %s", column, updates[0].Body)
+		}
+	}
+	cacheDelete := fake.Requests(http.MethodDelete, "enrichment_cache")[0].Query.Get("text_hash")
+	if !strings.Contains(cacheDelete, EnrichmentTextHash("Demasiado caro")) || !strings.Contains(cacheDelete, EnrichmentTextHash("Too expensive")) {
+		t.Errorf("Expected the cached responses for the text and its translation to be deleted, got %s", cacheDelete)
+	}
+	if record.FeedbackRows != 1 || record.PredictionRows != 0 || len(fake.Requests(http.MethodDelete, "account_usage")) != 1 {
+		t.Errorf("Unexpected erasure record %+v", record)
+	}
+}
+
+// TestEraseCustomerData_UnknownMode tests that an unknown mode erases nothing.
+func TestEraseCustomerData_UnknownMode(t *testing.T) {
+	fake := erasureFake(t, "text", "[]")
+	if _, err := EraseCustomerData("acct_1", ErasureMode("shred"), "", ""); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("Expected no requests, got %+v", fake.requests)
+	}
+}
+
+// TestFetchCustomerFeedback_Pages tests that the export reads past PostgREST's
+// max-rows in id order.
+func TestFetchCustomerFeedback_Pages(t *testing.T) {
+	fake := newFakeSupabase(t, func(req fakeRequest) fakeResponse {
+		switch req.Query.Get("id") {
+		case "":
+			return fakeResponse{Body: `[{"id": "a", "created_at": "2026-02-01T00:00:00Z"}, {"id": "b", "created_at": "2026-01-01T00:00:00Z"}]`}
+		case "gt.b":
+			return fakeResponse{Body: `[{"id": "c", "created_at": "2026-03-01T00:00:00Z"}]`}
+		}
+		return fakeResponse{}
+	})
+
+	rows, err := FetchCustomerFeedback("acct_1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rows) != 3 || rows[0].ID != "b" || rows[2].ID != "c" {
+		t.Errorf("Expected all 3 rows oldest first, got %+v", rows)
+	}
+	requests := fake.Requests(http.MethodGet, "customer_feedback")
+	if len(requests) != 3 || requests[0].Query.Get("order") != "id.asc.nullslast" || requests[0].Query.Get("limit") != "1000" {
+		t.Errorf("Expected 3 paged requests, got %+v", requests)
+	}
+}
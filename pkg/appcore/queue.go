@@ -0,0 +1,313 @@
+package appcore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// QueueMessage is one feedback message: a /predict request body.
+type QueueMessage struct {
+	ID       string
+	Key      string // identifies the request across redeliveries; the feedback ID is derived from it
+	Body     []byte
+	Attempts int // deliveries so far, including this one
+
+	ref interface{} // backend handle used to acknowledge the message
+}
+
+// FeedbackQueue is a source of feedback messages with at-least-once delivery.
+// Receive is called from a single goroutine; Ack, Retry and DeadLetter may be
+// called concurrently from the workers.
+type FeedbackQueue interface {
+	Name() string
+	// Receive waits up to timeout for the next message; it returns nil, nil when none arrived.
+	Receive(timeout time.Duration) (*QueueMessage, error)
+	// Ack removes a processed message.
+	Ack(msg *QueueMessage) error
+	// Retry hands a message back for redelivery after roughly delay.
+	Retry(msg *QueueMessage, delay time.Duration) error
+	// DeadLetter moves a message that must not be retried to the poison queue.
+	DeadLetter(msg *QueueMessage, reason string) error
+	Close() error
+}
+
+// PoisonMessage is what dead-lettered messages look like on the poison queue.
+type PoisonMessage struct {
+	MessageID string    `json:"message_id"`
+	Body      string    `json:"body"`
+	Attempts  int       `json:"attempts"`
+	Reason    string    `json:"reason"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// --- In-process queue ---
+
+// ChannelQueue is an in-process FeedbackQueue for tests and local development.
+type ChannelQueue struct {
+	messages chan *QueueMessage
+	mu       sync.Mutex
+	next     int
+	dead     []PoisonMessage
+}
+
+// NewChannelQueue returns an empty queue holding up to size messages.
+func NewChannelQueue(size int) *ChannelQueue {
+	return &ChannelQueue{messages: make(chan *QueueMessage, size)}
+}
+
+func (q *ChannelQueue) Name() string { return "channel" }
+
+// Publish enqueues a /predict request body.
+func (q *ChannelQueue) Publish(body []byte) string {
+	q.mu.Lock()
+	q.next++
+	id := strconv.Itoa(q.next)
+	q.mu.Unlock()
+	q.messages <- &QueueMessage{ID: id, Key: "channel/" + id, Body: body}
+	return id
+}
+
+func (q *ChannelQueue) Receive(timeout time.Duration) (*QueueMessage, error) {
+	select {
+	case msg := <-q.messages:
+		msg.Attempts++
+		return msg, nil
+	case <-time.After(timeout):
+		return nil, nil
+	}
+}
+
+func (q *ChannelQueue) Ack(msg *QueueMessage) error { return nil }
+
+func (q *ChannelQueue) Retry(msg *QueueMessage, delay time.Duration) error {
+	time.AfterFunc(delay, func() { q.messages <- msg })
+	return nil
+}
+
+func (q *ChannelQueue) DeadLetter(msg *QueueMessage, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dead = append(q.dead, PoisonMessage{MessageID: msg.ID, Body: string(msg.Body), Attempts: msg.Attempts, Reason: reason, FailedAt: time.Now()})
+	return nil
+}
+
+// DeadLetters returns the messages dead-lettered so far.
+func (q *ChannelQueue) DeadLetters() []PoisonMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]PoisonMessage(nil), q.dead...)
+}
+
+func (q *ChannelQueue) Close() error { return nil }
+
+// --- Worker ---
+
+var (
+	workerProcessed = NewCounter("churn_worker_messages_processed_total", "Queued feedback messages processed and acknowledged.")
+	workerRetried   = NewCounter("churn_worker_messages_retried_total", "Queued feedback messages handed back for retry.")
+	workerPoisoned  = NewCounter("churn_worker_messages_dead_lettered_total", "Queued feedback messages moved to the poison queue.")
+)
+
+// FeedbackWorker consumes a FeedbackQueue and runs every message through
+// ProcessFeedback. Messages that fail validation are dead-lettered at once;
+// messages whose processing fails are retried with backoff and dead-lettered
+// after MaxAttempts deliveries. The feedback ID is derived from the message
+// key, so a message delivered again after it was stored is acknowledged
+// without storing it twice.
+type FeedbackWorker struct {
+	Queue       FeedbackQueue
+	Concurrency int
+	MaxAttempts int
+	BaseBackoff time.Duration // delay before the second delivery, doubled per attempt up to MaxBackoff
+	MaxBackoff  time.Duration
+
+	// Process is the pipeline; nil means ProcessFeedback. Tests replace it.
+	Process func(req ApiPredictRequest) (ApiResponse, error)
+}
+
+// DefaultFeedbackWorker returns a worker for queue with the default settings.
+func DefaultFeedbackWorker(queue FeedbackQueue) *FeedbackWorker {
+	return &FeedbackWorker{Queue: queue, Concurrency: 4, MaxAttempts: 5, BaseBackoff: 30 * time.Second, MaxBackoff: 30 * time.Minute}
+}
+
+// Backoff returns how long to wait before redelivering a message that failed
+// on the given (1-based) attempt.
+func (w *FeedbackWorker) Backoff(attempt int) time.Duration {
+	d := w.BaseBackoff
+	for i := 1; i < attempt && d < w.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.MaxBackoff {
+		d = w.MaxBackoff
+	}
+	return d
+}
+
+// Handle processes one message and acknowledges, retries or dead-letters it.
+func (w *FeedbackWorker) Handle(msg *QueueMessage) error {
+	req, apiErr := DecodePredictRequest(bytes.NewReader(msg.Body))
+	if apiErr != nil {
+		workerPoisoned.Inc()
+		log.Printf("Queue message %s is invalid, dead-lettering it: %v %+v", msg.ID, apiErr, apiErr.Errors)
+		return w.Queue.DeadLetter(msg, "invalid request: "+apiErr.Error())
+	}
+	if msg.Key != "" {
+		req.FeedbackID = NameUUID(msg.Key)
+	}
+	process := w.Process
+	if process == nil {
+		process = ProcessFeedback
+	}
+	response, err := process(req)
+	if errors.Is(err, ErrFeedbackExists) {
+		workerProcessed.Inc()
+		log.Printf("Queue message %s was already processed: feedback %s.", msg.ID, req.FeedbackID)
+		return w.Queue.Ack(msg)
+	}
+	if err != nil {
+		if msg.Attempts >= w.MaxAttempts {
+			workerPoisoned.Inc()
+			log.Printf("Queue message %s failed %d times, dead-lettering it: %v", msg.ID, msg.Attempts, err)
+			return w.Queue.DeadLetter(msg, err.Error())
+		}
+		workerRetried.Inc()
+		delay := w.Backoff(msg.Attempts)
+		log.Printf("Queue message %s failed (attempt %d), retrying in %v: %v", msg.ID, msg.Attempts, delay, err)
+		return w.Queue.Retry(msg, delay)
+	}
+	workerProcessed.Inc()
+	log.Printf("Queue message %s processed: feedback %s, churn probability %.2f.", msg.ID, response.CustomerID, response.ChurnProbability)
+	return w.Queue.Ack(msg)
+}
+
+// Run consumes messages until stop is closed, then waits for the messages in
+// flight. A message is only received when a worker is free to take it, so
+// unprocessed messages stay on the queue for other consumers.
+func (w *FeedbackWorker) Run(stop <-chan struct{}) {
+	log.Printf("Feedback worker started (queue %s, concurrency %d).", w.Queue.Name(), w.Concurrency)
+	work := make(chan *QueueMessage)
+	var wg sync.WaitGroup
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range work {
+				if err := w.Handle(msg); err != nil {
+					// Unacknowledged messages are redelivered by the backend.
+					log.Printf("Error settling queue message %s: %v", msg.ID, err)
+				}
+			}
+		}()
+	}
+
+	for {
+		select {
+		case <-stop:
+			close(work)
+			wg.Wait()
+			log.Println("Feedback worker stopped.")
+			return
+		default:
+		}
+		msg, err := w.Queue.Receive(time.Second)
+		if err != nil {
+			log.Printf("Error receiving from queue %s: %v", w.Queue.Name(), err)
+			select {
+			case <-stop:
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		if msg != nil {
+			work <- msg
+		}
+	}
+}
+
+// NewFeedbackQueueFromEnv connects to the queue at FEEDBACK_QUEUE_URL:
+//   - nats://[user:pass@|token@]host:4222 (tls:// for TLS) consumes the JetStream
+//     stream FEEDBACK_QUEUE_NAME (default FEEDBACK) through the durable pull
+//     consumer FEEDBACK_QUEUE_CONSUMER and publishes poison messages to the
+//     subject FEEDBACK_QUEUE_DEAD_LETTER (default feedback.dead).
+//     FEEDBACK_QUEUE_CREDS (a .creds file), FEEDBACK_QUEUE_NKEY_SEED (an nkey
+//     seed file) and FEEDBACK_QUEUE_TLS_CA (a CA bundle) configure authentication
+//     and TLS.
+//   - redis://[:password@]host:6379[/db] (rediss:// for TLS) consumes the stream
+//     FEEDBACK_QUEUE_NAME (default feedback) as the consumer group
+//     FEEDBACK_QUEUE_CONSUMER and adds poison messages to the stream
+//     FEEDBACK_QUEUE_DEAD_LETTER (default feedback.dead)
+//
+// FEEDBACK_QUEUE_CONSUMER defaults to churn-worker.
+func NewFeedbackQueueFromEnv() (FeedbackQueue, error) {
+	env := func(name, fallback string) string {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			return v
+		}
+		return fallback
+	}
+	raw := env("FEEDBACK_QUEUE_URL", "")
+	if raw == "" {
+		return nil, fmt.Errorf("FEEDBACK_QUEUE_URL must be set in worker mode")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FEEDBACK_QUEUE_URL: %w", err)
+	}
+	consumer := env("FEEDBACK_QUEUE_CONSUMER", "churn-worker")
+	deadLetter := env("FEEDBACK_QUEUE_DEAD_LETTER", "feedback.dead")
+	switch u.Scheme {
+	case "nats", "tls":
+		var opts []nats.Option
+		if creds := env("FEEDBACK_QUEUE_CREDS", ""); creds != "" {
+			opts = append(opts, nats.UserCredentials(creds))
+		}
+		if seed := env("FEEDBACK_QUEUE_NKEY_SEED", ""); seed != "" {
+			opt, err := nats.NkeyOptionFromSeed(seed)
+			if err != nil {
+				return nil, fmt.Errorf("invalid FEEDBACK_QUEUE_NKEY_SEED: %w", err)
+			}
+			opts = append(opts, opt)
+		}
+		if ca := env("FEEDBACK_QUEUE_TLS_CA", ""); ca != "" {
+			opts = append(opts, nats.RootCAs(ca))
+		}
+		return DialNATSQueue(raw, env("FEEDBACK_QUEUE_NAME", "FEEDBACK"), consumer, deadLetter, opts...)
+	case "redis", "rediss":
+		return DialRedisQueue(raw, env("FEEDBACK_QUEUE_NAME", "feedback"), consumer, deadLetter)
+	default:
+		return nil, fmt.Errorf("unsupported FEEDBACK_QUEUE_URL scheme %q (expected nats, tls, redis or rediss)", u.Scheme)
+	}
+}
+
+// ConfigureFeedbackWorker returns a worker for queue configured from
+// WORKER_CONCURRENCY, WORKER_MAX_ATTEMPTS and WORKER_RETRY_BACKOFF.
+func ConfigureFeedbackWorker(queue FeedbackQueue) (*FeedbackWorker, error) {
+	w := DefaultFeedbackWorker(queue)
+	for name, dst := range map[string]*int{"WORKER_CONCURRENCY": &w.Concurrency, "WORKER_MAX_ATTEMPTS": &w.MaxAttempts} {
+		if raw := strings.TrimSpace(os.Getenv(name)); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%s must be a positive integer, got %q", name, raw)
+			}
+			*dst = n
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("WORKER_RETRY_BACKOFF")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("WORKER_RETRY_BACKOFF must be a positive Go duration, got %q", raw)
+		}
+		w.BaseBackoff = d
+	}
+	return w, nil
+}
@@ -0,0 +1,171 @@
+package appcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSQueue consumes a JetStream stream through a durable pull consumer. The
+// stream and consumer are created by the operator, for example:
+//
+//	nats stream add FEEDBACK --subjects 'feedback.in' --retention work
+//	nats consumer add FEEDBACK churn-worker --pull --ack explicit --wait 5m --max-deliver -1
+//
+// Producers should set the Nats-Msg-Id header, which identifies the request
+// across re-sends; messages without it are identified by their stream
+// sequence. While a message is being processed it is marked in progress every
+// third of the consumer's ack wait, so slow messages are not redelivered to
+// another worker. Messages are acknowledged, retried with a delayed NAK and,
+// when dead-lettered, published to DeadSubject as a PoisonMessage and
+// terminated.
+type NATSQueue struct {
+	Stream      string
+	Consumer    string
+	DeadSubject string
+
+	conn    *nats.Conn
+	sub     *nats.Subscription
+	ackWait time.Duration
+}
+
+// natsRef is the backend handle of a received message.
+type natsRef struct {
+	msg  *nats.Msg
+	done chan struct{}
+	once sync.Once
+}
+
+// DialNATSQueue connects to rawURL (nats:// or tls://, with a user and password
+// or a token in the URL if needed) and binds to the durable consumer.
+func DialNATSQueue(rawURL, stream, consumer, deadLetter string, opts ...nats.Option) (*NATSQueue, error) {
+	opts = append([]nats.Option{nats.Name("churn-worker"), nats.MaxReconnects(-1)}, opts...)
+	conn, err := nats.Connect(rawURL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to NATS: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error opening JetStream context: %w", err)
+	}
+	sub, err := js.PullSubscribe("", consumer, nats.Bind(stream, consumer))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error binding to JetStream consumer %s/%s: %w", stream, consumer, err)
+	}
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error reading JetStream consumer %s/%s: %w", stream, consumer, err)
+	}
+	return &NATSQueue{Stream: stream, Consumer: consumer, DeadSubject: deadLetter, conn: conn, sub: sub, ackWait: info.Config.AckWait}, nil
+}
+
+func (q *NATSQueue) Name() string { return "nats:" + q.Stream + "/" + q.Consumer }
+
+// Receive fetches one message from the consumer, waiting up to timeout for it.
+func (q *NATSQueue) Receive(timeout time.Duration) (*QueueMessage, error) {
+	msgs, err := q.sub.Fetch(1, nats.MaxWait(timeout))
+	if errors.Is(err, nats.ErrTimeout) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching NATS message: %w", err)
+	}
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	m := msgs[0]
+	meta, err := m.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("error reading NATS message metadata: %w", err)
+	}
+	id := m.Header.Get(nats.MsgIdHdr)
+	if id == "" {
+		id = strconv.FormatUint(meta.Sequence.Stream, 10)
+	}
+	ref := &natsRef{msg: m, done: make(chan struct{})}
+	go q.keepInProgress(ref)
+	return &QueueMessage{
+		ID:       id,
+		Key:      "nats:" + q.Stream + "/" + id,
+		Body:     m.Data,
+		Attempts: int(meta.NumDelivered),
+		ref:      ref,
+	}, nil
+}
+
+// keepInProgress resets the message's ack wait until it is settled.
+func (q *NATSQueue) keepInProgress(ref *natsRef) {
+	if q.ackWait <= 0 {
+		return
+	}
+	ticker := time.NewTicker(q.ackWait / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ref.done:
+			return
+		case <-ticker.C:
+			if err := ref.msg.InProgress(); err != nil {
+				log.Printf("Warning: Could not mark NATS message in progress: %v", err)
+			}
+		}
+	}
+}
+
+// settle stops keeping msg in progress and returns the underlying message.
+func (q *NATSQueue) settle(msg *QueueMessage) (*nats.Msg, error) {
+	ref, ok := msg.ref.(*natsRef)
+	if !ok {
+		return nil, fmt.Errorf("message %s was not received from NATS", msg.ID)
+	}
+	ref.once.Do(func() { close(ref.done) })
+	return ref.msg, nil
+}
+
+func (q *NATSQueue) Ack(msg *QueueMessage) error {
+	m, err := q.settle(msg)
+	if err != nil {
+		return err
+	}
+	return m.Ack()
+}
+
+func (q *NATSQueue) Retry(msg *QueueMessage, delay time.Duration) error {
+	m, err := q.settle(msg)
+	if err != nil {
+		return err
+	}
+	return m.NakWithDelay(delay)
+}
+
+func (q *NATSQueue) DeadLetter(msg *QueueMessage, reason string) error {
+	m, err := q.settle(msg)
+	if err != nil {
+		return err
+	}
+	poison, err := json.Marshal(PoisonMessage{MessageID: msg.ID, Body: string(msg.Body), Attempts: msg.Attempts, Reason: reason, FailedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := q.conn.Publish(q.DeadSubject, poison); err != nil {
+		return fmt.Errorf("error publishing to NATS dead-letter subject: %w", err)
+	}
+	if err := q.conn.Flush(); err != nil {
+		return fmt.Errorf("error publishing to NATS dead-letter subject: %w", err)
+	}
+	return m.Term()
+}
+
+func (q *NATSQueue) Close() error {
+	q.conn.Close()
+	return nil
+}
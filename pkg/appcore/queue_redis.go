@@ -0,0 +1,238 @@
+package appcore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisQueue consumes a Redis stream through a consumer group. Producers add
+// entries with a "body" field holding the /predict request and, optionally, a
+// "message_id" field that identifies the request across re-sends:
+//
+//	XADD feedback * message_id 'survey-123' body '{"nls_score": 3, "feedback_text": "..."}'
+//
+// While a message is being processed its pending entry is refreshed every
+// ClaimIdle/3; entries idle for ClaimIdle belonged to a crashed worker and are
+// reclaimed with XAUTOCLAIM. Retried messages are acknowledged and parked in
+// the sorted set "<Stream>:delayed" until their delay has passed, then added
+// back to the stream with their message ID and attempt count. Poison messages
+// are added to the DeadStream with the PoisonMessage fields. Requires Redis 6.2
+// or later; rediss:// URLs connect over TLS.
+type RedisQueue struct {
+	Stream     string
+	Group      string
+	Consumer   string
+	DeadStream string
+	ClaimIdle  time.Duration
+
+	client *redis.Client
+}
+
+// DialRedisQueue connects to rawURL and creates the consumer group if needed.
+func DialRedisQueue(rawURL, stream, group, deadLetter string) (*RedisQueue, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	host, _ := os.Hostname()
+	q := &RedisQueue{
+		Stream: stream, Group: group, DeadStream: deadLetter, ClaimIdle: 5 * time.Minute,
+		Consumer: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		client:   redis.NewClient(opts),
+	}
+	err = q.client.XGroupCreateMkStream(context.Background(), stream, group, "0").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		q.client.Close()
+		return nil, fmt.Errorf("error creating Redis consumer group: %w", err)
+	}
+	return q, nil
+}
+
+func (q *RedisQueue) Name() string { return "redis:" + q.Stream }
+
+func (q *RedisQueue) delayedKey() string { return q.Stream + ":delayed" }
+
+// redisDelayed is a retried message waiting in the delayed set.
+type redisDelayed struct {
+	MessageID string `json:"message_id"`
+	Body      string `json:"body"`
+	Attempts  string `json:"attempts"`
+}
+
+// promoteDelayed moves retried messages whose delay has passed (score <= ARGV[1])
+// from the delayed set KEYS[2] back onto the stream KEYS[1].
+var promoteDelayed = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, member in ipairs(due) do
+	local m = cjson.decode(member)
+	redis.call('XADD', KEYS[1], '*', 'message_id', m.message_id, 'body', m.body, 'attempts', m.attempts)
+	redis.call('ZREM', KEYS[2], member)
+end
+return #due
+`)
+
+// redisRef is the backend handle of a received message.
+type redisRef struct {
+	entryID string
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Receive moves due retries back onto the stream, reclaims an entry abandoned
+// by a crashed worker if there is one, and otherwise blocks for a new entry.
+func (q *RedisQueue) Receive(timeout time.Duration) (*QueueMessage, error) {
+	ctx := context.Background()
+	if err := promoteDelayed.Run(ctx, q.client, []string{q.Stream, q.delayedKey()}, time.Now().UnixMilli()).Err(); err != nil {
+		return nil, fmt.Errorf("error promoting delayed Redis messages: %w", err)
+	}
+
+	claimed, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream: q.Stream, Group: q.Group, Consumer: q.Consumer, MinIdle: q.ClaimIdle, Start: "0-0", Count: 1,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("XAUTOCLAIM: %w", err)
+	}
+	if len(claimed) > 0 {
+		pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: q.Stream, Group: q.Group, Start: claimed[0].ID, End: claimed[0].ID, Count: 1,
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("XPENDING: %w", err)
+		}
+		deliveries := 1
+		if len(pending) > 0 {
+			deliveries = int(pending[0].RetryCount)
+		}
+		return q.message(claimed[0], deliveries), nil
+	}
+
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: q.Group, Consumer: q.Consumer, Streams: []string{q.Stream, ">"}, Count: 1, Block: timeout,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("XREADGROUP: %w", err)
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, nil
+	}
+	return q.message(streams[0].Messages[0], 1), nil
+}
+
+// message converts a stream entry delivered deliveries times and starts
+// keeping it claimed until it is settled.
+func (q *RedisQueue) message(entry redis.XMessage, deliveries int) *QueueMessage {
+	field := func(name string) string {
+		value, _ := entry.Values[name].(string)
+		return value
+	}
+	id := field("message_id")
+	if id == "" {
+		id = entry.ID
+	}
+	previous, _ := strconv.Atoi(field("attempts"))
+	ref := &redisRef{entryID: entry.ID, done: make(chan struct{})}
+	go q.keepClaimed(ref)
+	return &QueueMessage{
+		ID:       id,
+		Key:      q.Name() + "/" + id,
+		Body:     []byte(field("body")),
+		Attempts: previous + deliveries,
+		ref:      ref,
+	}
+}
+
+// keepClaimed resets the entry's idle time until it is settled, so that other
+// workers don't reclaim a message that is still being processed.
+func (q *RedisQueue) keepClaimed(ref *redisRef) {
+	ticker := time.NewTicker(q.ClaimIdle / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ref.done:
+			return
+		case <-ticker.C:
+			err := q.client.XClaimJustID(context.Background(), &redis.XClaimArgs{
+				Stream: q.Stream, Group: q.Group, Consumer: q.Consumer, Messages: []string{ref.entryID},
+			}).Err()
+			if err != nil {
+				log.Printf("Warning: Could not refresh Redis message %s: %v", ref.entryID, err)
+			}
+		}
+	}
+}
+
+// settle stops keeping msg claimed and returns its entry ID.
+func (q *RedisQueue) settle(msg *QueueMessage) (string, error) {
+	ref, ok := msg.ref.(*redisRef)
+	if !ok {
+		return "", fmt.Errorf("message %s was not received from Redis", msg.ID)
+	}
+	ref.once.Do(func() { close(ref.done) })
+	return ref.entryID, nil
+}
+
+func (q *RedisQueue) Ack(msg *QueueMessage) error {
+	entryID, err := q.settle(msg)
+	if err != nil {
+		return err
+	}
+	return q.client.XAck(context.Background(), q.Stream, q.Group, entryID).Err()
+}
+
+// Retry acknowledges the entry and parks the message in the delayed set; Receive
+// adds it back to the stream once delay has passed.
+func (q *RedisQueue) Retry(msg *QueueMessage, delay time.Duration) error {
+	entryID, err := q.settle(msg)
+	if err != nil {
+		return err
+	}
+	member, err := json.Marshal(redisDelayed{MessageID: msg.ID, Body: string(msg.Body), Attempts: strconv.Itoa(msg.Attempts)})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, q.delayedKey(), redis.Z{Score: float64(time.Now().Add(delay).UnixMilli()), Member: string(member)})
+		pipe.XAck(ctx, q.Stream, q.Group, entryID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error scheduling Redis retry: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) DeadLetter(msg *QueueMessage, reason string) error {
+	entryID, err := q.settle(msg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: q.DeadStream, Values: []interface{}{
+			"message_id", msg.ID, "body", string(msg.Body), "attempts", strconv.Itoa(msg.Attempts),
+			"reason", reason, "failed_at", time.Now().UTC().Format(time.RFC3339),
+		}})
+		pipe.XAck(ctx, q.Stream, q.Group, entryID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error adding to Redis dead-letter stream: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) Close() error { return q.client.Close() }
@@ -0,0 +1,109 @@
+package appcore
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestFeedbackWorker_AckRetryDeadLetter tests that the worker acknowledges processed
+// messages, retries failures and dead-letters invalid or repeatedly failing messages.
+func TestFeedbackWorker_AckRetryDeadLetter(t *testing.T) {
+	queue := NewChannelQueue(10)
+	worker := DefaultFeedbackWorker(queue)
+	worker.Concurrency, worker.MaxAttempts, worker.BaseBackoff = 2, 3, time.Millisecond
+	var mu sync.Mutex
+	processed := map[string]int{}
+	worker.Process = func(req ApiPredictRequest) (ApiResponse, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		processed[req.FeedbackText]++
+		if req.FeedbackText == "storage down" {
+			return ApiResponse{}, fmt.Errorf("storage failed")
+		}
+		return ApiResponse{CustomerID: "id"}, nil
+	}
+
+	queue.Publish([]byte(`{"nls_score": 3, "feedback_text": "ok"}`))
+	queue.Publish([]byte(`{"nls_score": 30, "feedback_text": "invalid"}`))
+	queue.Publish([]byte(`{"nls_score": 3, "feedback_text": "storage down"}`))
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() { worker.Run(stop); close(done) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(queue.DeadLetters()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	dead := queue.DeadLetters()
+	if len(dead) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %+v", dead)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if processed["ok"] != 1 || processed["invalid"] != 0 || processed["storage down"] != 3 {
+		t.Errorf("Expected ok once, invalid never and the failing message 3 times, got %v", processed)
+	}
+	for _, poison := range dead {
+		if strings.Contains(poison.Body, "storage down") && (poison.Attempts != 3 || poison.Reason != "storage failed") {
+			t.Errorf("Expected the failing message dead-lettered after 3 attempts, got %+v", poison)
+		}
+		if strings.Contains(poison.Body, "invalid") && !strings.HasPrefix(poison.Reason, "invalid request") {
+			t.Errorf("Expected the invalid message dead-lettered as invalid, got %+v", poison)
+		}
+	}
+}
+
+// TestFeedbackWorker_Redelivery tests that a message carries the same feedback ID
+// on every delivery and that one already stored is acknowledged, not retried.
+func TestFeedbackWorker_Redelivery(t *testing.T) {
+	queue := NewChannelQueue(10)
+	worker := DefaultFeedbackWorker(queue)
+	var ids []string
+	worker.Process = func(req ApiPredictRequest) (ApiResponse, error) {
+		ids = append(ids, req.FeedbackID)
+		if len(ids) == 1 {
+			return ApiResponse{}, fmt.Errorf("storage failed")
+		}
+		return ApiResponse{}, fmt.Errorf("error storing customer data and churn prediction: %w", ErrFeedbackExists)
+	}
+	queue.Publish([]byte(`{"nls_score": 3, "feedback_text": "ok"}`))
+	for i := 0; i < 2; i++ {
+		msg, err := queue.Receive(time.Second)
+		if err != nil || msg == nil {
+			t.Fatalf("Expected delivery %d, got %v, %v", i+1, msg, err)
+		}
+		worker.BaseBackoff = time.Millisecond
+		if err := worker.Handle(msg); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if msg, _ := queue.Receive(50 * time.Millisecond); msg != nil {
+		t.Errorf("Expected the stored message to be acknowledged, got a redelivery %+v", msg)
+	}
+	if len(ids) != 2 || ids[0] == "" || ids[0] != ids[1] {
+		t.Errorf("Expected the same feedback ID on both deliveries, got %v", ids)
+	}
+	if len(queue.DeadLetters()) != 0 {
+		t.Errorf("Expected no dead letters, got %+v", queue.DeadLetters())
+	}
+}
+
+// TestNameUUID tests that name-based UUIDs are stable, distinct and version 5.
+func TestNameUUID(t *testing.T) {
+	id := NameUUID("redis:feedback/1-0")
+	if len(id) != 36 || id[14] != '5' || !strings.ContainsRune("89ab", rune(id[19])) || strings.Count(id, "-") != 4 {
+		t.Errorf("Expected a version 5 UUID, got %q", id)
+	}
+	if NameUUID("redis:feedback/1-0") != id {
+		t.Errorf("Expected the same UUID for the same name")
+	}
+	if NameUUID("redis:feedback/2-0") == id {
+		t.Errorf("Expected different UUIDs for different names")
+	}
+}
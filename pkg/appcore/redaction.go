@@ -0,0 +1,272 @@
+package appcore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RedactionMode controls how detected PII is replaced in feedback text.
+type RedactionMode string
+
+const (
+	RedactionModeMask RedactionMode = "mask" // "[EMAIL]"
+	RedactionModeHash RedactionMode = "hash" // "[EMAIL:1f2e3d4c5b6a]", keyed so values can still be correlated
+	RedactionModeDrop RedactionMode = "drop" // removed entirely
+)
+
+// Redaction records one kind of PII removed from a feedback text. It is stored
+// alongside the row so we can show what was redacted without keeping the values.
+type Redaction struct {
+	Type  string        `json:"type"`
+	Mode  RedactionMode `json:"mode"`
+	Count int           `json:"count"`
+}
+
+type piiPattern struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(match string) bool // optional extra check, e.g. Luhn for card numbers
+}
+
+// Redactor detects and replaces PII in free text before it leaves our boundary
+// (Hugging Face calls) or is persisted (customer_feedback).
+type Redactor struct {
+	mode     RedactionMode
+	hashKey  []byte
+	patterns []piiPattern
+}
+
+// Built-in patterns, in priority order: when matches overlap the earlier pattern wins.
+var builtinPIIPatterns = []piiPattern{
+	{name: "email", re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{name: "credit_card", re: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`), valid: isLuhnValid},
+	{name: "phone", re: regexp.MustCompile(`(?:\+\d{1,3}[\s.\-]?)?(?:\(\d{1,4}\)[\s.\-]?)?\d{2,4}(?:[\s.\-]?\d{2,4}){1,3}`), valid: isPlausiblePhone},
+}
+
+// FeedbackRedactor is the redactor applied to incoming feedback text. It is
+// configured from the environment by InitClients.
+var FeedbackRedactor *Redactor
+
+// defaultRedactor is used when Redact is called on a nil *Redactor (e.g. before
+// InitClients has run), so text is masked with the built-in patterns rather than
+// passed through or panicking.
+var defaultRedactor = &Redactor{mode: RedactionModeMask, patterns: builtinPIIPatterns}
+
+// NewRedactor builds a Redactor with the built-in patterns plus any custom
+// patterns (name -> regular expression).
+func NewRedactor(mode RedactionMode, hashKey []byte, customPatterns map[string]string) (*Redactor, error) {
+	switch mode {
+	case RedactionModeMask, RedactionModeDrop:
+	case RedactionModeHash:
+		if len(hashKey) == 0 {
+			return nil, fmt.Errorf("hash redaction mode requires a hash key")
+		}
+	default:
+		return nil, fmt.Errorf("unknown redaction mode %q (expected mask, hash or drop)", mode)
+	}
+
+	r := &Redactor{mode: mode, hashKey: hashKey}
+	r.patterns = append(r.patterns, builtinPIIPatterns...)
+
+	// Sort custom pattern names so overlap resolution is deterministic.
+	names := make([]string, 0, len(customPatterns))
+	for name := range customPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(customPatterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom redaction pattern %q: %w", name, err)
+		}
+		r.patterns = append(r.patterns, piiPattern{name: name, re: re})
+	}
+	return r, nil
+}
+
+// NewRedactorFromEnv configures a Redactor from PII_REDACTION_MODE (default "mask"),
+// PII_REDACTION_HASH_KEY (required for "hash") and PII_REDACTION_PATTERNS, a JSON
+// object mapping pattern names to regular expressions.
+func NewRedactorFromEnv() (*Redactor, error) {
+	mode := RedactionMode(strings.ToLower(strings.TrimSpace(os.Getenv("PII_REDACTION_MODE"))))
+	if mode == "" {
+		mode = RedactionModeMask
+	}
+	var customPatterns map[string]string
+	if raw := strings.TrimSpace(os.Getenv("PII_REDACTION_PATTERNS")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &customPatterns); err != nil {
+			return nil, fmt.Errorf("error parsing PII_REDACTION_PATTERNS: %w", err)
+		}
+	}
+	return NewRedactor(mode, []byte(os.Getenv("PII_REDACTION_HASH_KEY")), customPatterns)
+}
+
+type piiMatch struct {
+	start, end int
+	name       string
+}
+
+// Redact returns text with all detected PII replaced according to the redactor's
+// mode, plus a record of what was redacted (nil if nothing was found). A nil
+// Redactor masks with the built-in patterns.
+func (r *Redactor) Redact(text string) (string, []Redaction) {
+	if r == nil {
+		r = defaultRedactor
+	}
+	if strings.TrimSpace(text) == "" {
+		return text, nil
+	}
+
+	// Detect on the original text so replacements (e.g. hex digests) are never re-matched.
+	var matches []piiMatch
+	for _, p := range r.patterns {
+		for _, loc := range p.re.FindAllStringIndex(text, -1) {
+			if p.valid != nil && !p.valid(text[loc[0]:loc[1]]) {
+				continue
+			}
+			if overlapsAny(matches, loc[0], loc[1]) {
+				continue
+			}
+			matches = append(matches, piiMatch{start: loc[0], end: loc[1], name: p.name})
+		}
+	}
+	if len(matches) == 0 {
+		return text, nil
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	var b strings.Builder
+	counts := map[string]int{}
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.start])
+		b.WriteString(r.replacement(m.name, text[m.start:m.end]))
+		counts[m.name]++
+		last = m.end
+	}
+	b.WriteString(text[last:])
+
+	redacted := b.String()
+	if r.mode == RedactionModeDrop {
+		redacted = strings.Join(strings.Fields(redacted), " ")
+	}
+
+	redactions := make([]Redaction, 0, len(counts))
+	for _, p := range r.patterns {
+		if n, ok := counts[p.name]; ok {
+			redactions = append(redactions, Redaction{Type: p.name, Mode: r.mode, Count: n})
+			delete(counts, p.name)
+		}
+	}
+	return redacted, redactions
+}
+
+func (r *Redactor) replacement(name, value string) string {
+	label := strings.ToUpper(name)
+	switch r.mode {
+	case RedactionModeDrop:
+		return ""
+	case RedactionModeHash:
+		return "[" + label + ":" + r.digest(value)[:12] + "]"
+	default:
+		return "[" + label + "]"
+	}
+}
+
+// digest returns a keyed SHA-256 of the value, normalised so that formatting
+// differences ("+1 555-0100" vs "+15550100") hash identically.
+func (r *Redactor) digest(value string) string {
+	normalized := strings.ToLower(strings.Map(func(c rune) rune {
+		if c == ' ' || c == '-' || c == '.' || c == '(' || c == ')' {
+			return -1
+		}
+		return c
+	}, value))
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func overlapsAny(matches []piiMatch, start, end int) bool {
+	for _, m := range matches {
+		if start < m.end && m.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+func digitsOf(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= '0' && c <= '9' {
+			return c
+		}
+		return -1
+	}, s)
+}
+
+// isLuhnValid reports whether the digits in s form a 13-19 digit number that
+// passes the Luhn checksum, which keeps order numbers and the like out of the card bucket.
+func isLuhnValid(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// datePrefix matches candidates that start like a numeric date: 2026-10-01,
+// 2026.10.01, 01.10.2026 or 01-10-2026.
+var datePrefix = regexp.MustCompile(`^(?:(\d{4})[\-.](\d{1,2})[\-.](\d{1,2})|(\d{1,2})[\-.](\d{1,2})[\-.](\d{4}))\b`)
+
+// isPlausiblePhone filters phone candidates down to 7-15 digits (E.164 allows at
+// most 15) written like a phone number, with a leading + or separated digit
+// groups, that do not read as a calendar date. A bare run of digits is more
+// likely an order, invoice or ticket number and is kept.
+func isPlausiblePhone(s string) bool {
+	n := len(digitsOf(s))
+	if n < 7 || n > 15 || isDateLike(s) {
+		return false
+	}
+	return strings.HasPrefix(s, "+") || strings.ContainsAny(s, "() .-")
+}
+
+// isDateLike reports whether s starts with a numeric date whose month and day
+// are in range (either order for the day-first forms).
+func isDateLike(s string) bool {
+	m := datePrefix.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	if m[1] != "" {
+		return validMonthDay(m[2], m[3])
+	}
+	return validMonthDay(m[5], m[4]) || validMonthDay(m[4], m[5])
+}
+
+func validMonthDay(month, day string) bool {
+	mo, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	return mo >= 1 && mo <= 12 && d >= 1 && d <= 31
+}
@@ -0,0 +1,109 @@
+package appcore
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRedact_MaskMode tests that emails, phone numbers and card numbers are masked and recorded.
+func TestRedact_MaskMode(t *testing.T) {
+	redactor, err := NewRedactor(RedactionModeMask, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error creating redactor: %v", err)
+	}
+	text := "Email me at jane.doe@example.com or call +1 415-555-0134. Card 4111 1111 1111 1111 was charged twice."
+	expectedText := "Email me at [EMAIL] or call [PHONE]. Card [CREDIT_CARD] was charged twice."
+
+	redacted, redactions := redactor.Redact(text)
+
+	if redacted != expectedText {
+		t.Errorf("Expected redacted text '%s', got '%s'", expectedText, redacted)
+	}
+	if len(redactions) != 3 {
+		t.Fatalf("Expected 3 redaction records, got %d: %+v", len(redactions), redactions)
+	}
+	for _, r := range redactions {
+		if r.Count != 1 || r.Mode != RedactionModeMask {
+			t.Errorf("Expected one masked %s, got %+v", r.Type, r)
+		}
+	}
+}
+
+// TestRedact_NoPII tests that ordinary feedback (including short numbers) is left untouched.
+func TestRedact_NoPII(t *testing.T) {
+	redactor, _ := NewRedactor(RedactionModeMask, nil, nil)
+	text := "I gave you a 3 because the 2024 pricing went up 15%."
+
+	redacted, redactions := redactor.Redact(text)
+
+	if redacted != text {
+		t.Errorf("Expected text to be unchanged, got '%s'", redacted)
+	}
+	if redactions != nil {
+		t.Errorf("Expected no redactions, got %+v", redactions)
+	}
+}
+
+// TestRedact_HashAndDropModes tests the hash and drop modes along with a custom pattern.
+func TestRedact_HashAndDropModes(t *testing.T) {
+	custom := map[string]string{"account_number": `ACC-\d{6}`}
+
+	hasher, err := NewRedactor(RedactionModeHash, []byte("test-key"), custom)
+	if err != nil {
+		t.Fatalf("Unexpected error creating hash redactor: %v", err)
+	}
+	first, _ := hasher.Redact("Account ACC-123456 is broken.")
+	second, redactions := hasher.Redact("Still waiting on ACC-123456!")
+	if !strings.HasPrefix(first, "Account [ACCOUNT_NUMBER:") || strings.Contains(first, "123456") {
+		t.Errorf("Expected account number to be hashed, got '%s'", first)
+	}
+	if first[8:37] != second[17:46] {
+		t.Errorf("Expected identical values to hash identically, got '%s' and '%s'", first, second)
+	}
+	if len(redactions) != 1 || redactions[0].Type != "account_number" || redactions[0].Mode != RedactionModeHash {
+		t.Errorf("Expected one hashed account_number redaction, got %+v", redactions)
+	}
+
+	dropper, _ := NewRedactor(RedactionModeDrop, nil, custom)
+	dropped, _ := dropper.Redact("Write to bob@example.com about ACC-654321 please.")
+	if dropped != "Write to about please." {
+		t.Errorf("Expected PII to be dropped, got '%s'", dropped)
+	}
+
+	if _, err := NewRedactor(RedactionModeHash, nil, nil); err == nil {
+		t.Errorf("Expected an error for hash mode without a key")
+	}
+}
+
+// TestRedact_DatesAreNotPhones tests that ISO and day-first dates survive while
+// a real phone number next to them is still masked.
+func TestRedact_DatesAreNotPhones(t *testing.T) {
+	redactor, _ := NewRedactor(RedactionModeMask, nil, nil)
+	text := "Renewal is on 2026-10-01 (or 01.10.2026), call 415-555-0134 before 2026-09-15 10:30."
+	expected := "Renewal is on 2026-10-01 (or 01.10.2026), call [PHONE] before 2026-09-15 10:30."
+
+	if redacted, _ := redactor.Redact(text); redacted != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, redacted)
+	}
+}
+
+// TestRedact_OrderNumbersAreNotPhones tests that bare order and ticket numbers
+// are kept while phone numbers written with + or separators are masked.
+func TestRedact_OrderNumbersAreNotPhones(t *testing.T) {
+	redactor, _ := NewRedactor(RedactionModeMask, nil, nil)
+	text := "Order 100234987 and ticket #5567123 are still open, call +14155550134 or (415) 555 0134."
+	expected := "Order 100234987 and ticket #5567123 are still open, call [PHONE] or [PHONE]."
+
+	if redacted, _ := redactor.Redact(text); redacted != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, redacted)
+	}
+}
+
+// TestRedact_NilRedactor tests that an unconfigured redactor masks with the built-in patterns instead of panicking.
+func TestRedact_NilRedactor(t *testing.T) {
+	var redactor *Redactor
+	redacted, redactions := redactor.Redact("Reach me at jane.doe@example.com")
+	if redacted != "Reach me at [EMAIL]" || len(redactions) != 1 {
+		t.Errorf("Expected the email to be masked, got '%s' %+v", redacted, redactions)
+	}
+}
@@ -0,0 +1,370 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Rescore modes: what is redone for each selected feedback row.
+const (
+	RescoreEnrich = "enrich" // re-run Hugging Face enrichment and update the feedback row
+	RescoreScore  = "score"  // score the stored enrichment with the current churn models
+	RescoreBoth   = "both"   // re-enrich, then score
+)
+
+// Rescore run statuses.
+const (
+	RescoreRunning   = "running"
+	RescorePaused    = "paused" // stopped at its row limit or interrupted; can be resumed
+	RescoreCompleted = "completed"
+	RescoreFailed    = "failed" // stopped after repeated failures; can be resumed
+)
+
+// rescoreBatchSize is how many feedback rows are selected per query.
+const rescoreBatchSize = 100
+
+// rescoreMaxConsecutiveFailures stops a run when Hugging Face or Supabase keeps
+// failing, instead of burning through the selection. The failed rows are
+// retried when the run is resumed.
+const rescoreMaxConsecutiveFailures = 5
+
+// RescoreOptions select stored feedback to re-enrich and/or re-score. Rows
+// matching every set filter are selected; at least one filter is required.
+type RescoreOptions struct {
+	Since            *time.Time `json:"since,omitempty"` // created_at >= Since
+	Until            *time.Time `json:"until,omitempty"` // created_at < Until
+	ModelName        string     `json:"model_name,omitempty"`
+	ModelVersion     string     `json:"model_version,omitempty"`     // feedback with a primary prediction from this model version
+	UnknownSentiment bool       `json:"unknown_sentiment,omitempty"` // comment_sentiment = 'UNKNOWN', left by Hugging Face outages
+	Mode             string     `json:"mode"`
+	Rate             float64    `json:"rate"` // feedback rows per second; 0 means unlimited
+}
+
+// Validate checks the mode, the rate and that the selection is bounded by a filter.
+func (o RescoreOptions) Validate() error {
+	switch o.Mode {
+	case RescoreEnrich, RescoreScore, RescoreBoth:
+	default:
+		return fmt.Errorf("mode must be %s, %s or %s, got %q", RescoreEnrich, RescoreScore, RescoreBoth, o.Mode)
+	}
+	if o.Rate < 0 {
+		return fmt.Errorf("rate must not be negative")
+	}
+	if o.Since != nil && o.Until != nil && !o.Since.Before(*o.Until) {
+		return fmt.Errorf("since must be before until")
+	}
+	if o.Since == nil && o.Until == nil && o.ModelName == "" && o.ModelVersion == "" && !o.UnknownSentiment {
+		return fmt.Errorf("select feedback with since, until, model_name, model_version or unknown_sentiment")
+	}
+	return nil
+}
+
+// ParseRescoreTime parses a date (2006-01-02, UTC midnight) or an RFC 3339 timestamp.
+func ParseRescoreTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("expected a date (YYYY-MM-DD) or an RFC 3339 timestamp, got %q", value)
+	}
+	return t, nil
+}
+
+// RescoreRun is a rescore run and its checkpoint, as stored in rescore_runs.
+// Rows are processed in descending id order; LastFeedbackID is the last row
+// that was rescored, so a resumed run continues after it and retries a trailing
+// streak of failed rows.
+type RescoreRun struct {
+	ID             string         `json:"id"`
+	Status         string         `json:"status"`
+	Options        RescoreOptions `json:"options"`
+	LastFeedbackID string         `json:"last_feedback_id,omitempty"`
+	Scanned        int64          `json:"scanned"`
+	Enriched       int64          `json:"enriched"`
+	Scored         int64          `json:"scored"`
+	Failed         int64          `json:"failed"`
+	Error          string         `json:"error,omitempty"`
+	StartedAt      time.Time      `json:"started_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+}
+
+// StartRescoreRun validates opts and stores a new run.
+func StartRescoreRun(opts RescoreOptions) (RescoreRun, error) {
+	if err := opts.Validate(); err != nil {
+		return RescoreRun{}, err
+	}
+	if SupabaseClient == nil {
+		return RescoreRun{}, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	now := time.Now().UTC()
+	run := RescoreRun{ID: NewUUID(), Status: RescoreRunning, Options: opts, StartedAt: now, UpdatedAt: now}
+	if _, _, err := SupabaseClient.From("rescore_runs").Insert(run, false, "", "minimal", "").Execute(); err != nil {
+		return run, fmt.Errorf("error storing rescore run: %w", err)
+	}
+	return run, nil
+}
+
+// FetchRescoreRun loads a run by ID; found is false if it does not exist.
+func FetchRescoreRun(id string) (run RescoreRun, found bool, err error) {
+	if SupabaseClient == nil {
+		return run, false, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, _, err := SupabaseClient.From("rescore_runs").Select("*", "", false).Eq("id", id).Execute()
+	if err != nil {
+		return run, false, fmt.Errorf("error selecting rescore run %s: %w", id, err)
+	}
+	var runs []RescoreRun
+	if err := json.Unmarshal(rawData, &runs); err != nil {
+		return run, false, fmt.Errorf("error unmarshalling rescore run: %w", err)
+	}
+	if len(runs) == 0 {
+		return run, false, nil
+	}
+	return runs[0], true, nil
+}
+
+// checkpoint stores the run's progress.
+func (run *RescoreRun) checkpoint() error {
+	run.UpdatedAt = time.Now().UTC()
+	update := map[string]interface{}{
+		"status":           run.Status,
+		"last_feedback_id": nil,
+		"scanned":          run.Scanned,
+		"enriched":         run.Enriched,
+		"scored":           run.Scored,
+		"failed":           run.Failed,
+		"error":            run.Error,
+		"updated_at":       run.UpdatedAt,
+		"completed_at":     run.CompletedAt,
+	}
+	if run.LastFeedbackID != "" {
+		update["last_feedback_id"] = run.LastFeedbackID
+	}
+	if _, _, err := SupabaseClient.From("rescore_runs").Update(update, "minimal", "").Eq("id", run.ID).Execute(); err != nil {
+		return fmt.Errorf("error checkpointing rescore run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+// rateLimiter spaces calls at least interval apart.
+type rateLimiter struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// reserve returns how long to wait at now before the next call may start.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	if l.interval == 0 {
+		return 0
+	}
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	return wait
+}
+
+// ContinueRescoreRun processes the run's selection after its checkpoint, at
+// most limit rows (0 means all), until stop is closed. Re-enriched rows have
+// their enrichment columns updated in place; new scores are inserted as new
+// churn_predictions rows tagged with the run ID, so earlier predictions are
+// kept. Rescored predictions do not send webhooks or prediction.created events.
+// The checkpoint is stored after every row and the run ends paused, completed
+// or failed; the returned error is only set for failures.
+func ContinueRescoreRun(run *RescoreRun, limit int, stop <-chan struct{}) error {
+	if SupabaseClient == nil {
+		return fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	if run.Status == RescoreCompleted {
+		return nil
+	}
+	run.Status, run.Error = RescoreRunning, ""
+	limiter := newRateLimiter(run.Options.Rate)
+	processed, failures := 0, 0
+	cursor := run.LastFeedbackID
+
+	finish := func(status string, err error) error {
+		run.Status = status
+		if status == RescoreCompleted {
+			now := time.Now().UTC()
+			run.CompletedAt = &now
+		}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		if cpErr := run.checkpoint(); cpErr != nil {
+			log.Printf("Warning: %v", cpErr)
+		}
+		return err
+	}
+
+	for {
+		rows, err := selectRescoreRows(run.Options, cursor)
+		if err != nil {
+			return finish(RescoreFailed, err)
+		}
+		for _, row := range rows {
+			if limit > 0 && processed >= limit {
+				return finish(RescorePaused, nil)
+			}
+			select {
+			case <-stop:
+				return finish(RescorePaused, nil)
+			case <-time.After(limiter.reserve(time.Now())):
+			}
+
+			processed++
+			run.Scanned++
+			cursor = row.ID
+			enriched, scored, err := rescoreFeedback(row, run.Options.Mode, run.ID)
+			if enriched {
+				run.Enriched++
+			}
+			if scored {
+				run.Scored++
+			}
+			if err != nil {
+				run.Failed++
+				failures++
+				log.Printf("Rescoring feedback %s failed: %v", row.ID, err)
+				if failures >= rescoreMaxConsecutiveFailures {
+					return finish(RescoreFailed, fmt.Errorf("%d consecutive rows failed, last: %w", failures, err))
+				}
+			} else {
+				// Isolated failures are skipped; a streak is retried on resume.
+				failures, run.LastFeedbackID = 0, row.ID
+			}
+			if err := run.checkpoint(); err != nil {
+				return finish(RescoreFailed, err)
+			}
+		}
+		if len(rows) < rescoreBatchSize {
+			return finish(RescoreCompleted, nil)
+		}
+	}
+}
+
+// selectRescoreRows returns the next batch of feedback matching opts after afterID.
+// Rows whose text was cleared by the retention policy or an erasure request are
+// never selected: re-enriching them would overwrite their enrichment with that
+// of an empty comment, and scoring them would drop the keyword signals.
+func selectRescoreRows(opts RescoreOptions, afterID string) ([]CustomerData, error) {
+	columns := "*"
+	if opts.ModelName != "" || opts.ModelVersion != "" {
+		columns = "*,churn_predictions!inner(model_name,model_version,shadow)"
+	}
+	query := SupabaseClient.From("customer_feedback").Select(columns, "", false).Not("feedback_text", "is", "null")
+	if opts.Since != nil {
+		query = query.Gte("created_at", opts.Since.UTC().Format(time.RFC3339))
+	}
+	if opts.Until != nil {
+		query = query.Lt("created_at", opts.Until.UTC().Format(time.RFC3339))
+	}
+	if opts.UnknownSentiment {
+		query = query.Eq("comment_sentiment", "UNKNOWN")
+	}
+	if opts.ModelName != "" || opts.ModelVersion != "" {
+		query = query.Eq("churn_predictions.shadow", "false")
+		if opts.ModelName != "" {
+			query = query.Eq("churn_predictions.model_name", opts.ModelName)
+		}
+		if opts.ModelVersion != "" {
+			query = query.Eq("churn_predictions.model_version", opts.ModelVersion)
+		}
+	}
+	if afterID != "" {
+		query = query.Lt("id", afterID)
+	}
+	rawData, _, err := query.Order("id", nil).Limit(rescoreBatchSize, "").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error selecting customer feedback to rescore: %w", err)
+	}
+	var rows []CustomerData
+	if err := json.Unmarshal(rawData, &rows); err != nil {
+		return nil, fmt.Errorf("error unmarshalling customer feedback to rescore: %w", err)
+	}
+	return rows, nil
+}
+
+// rescoreFeedback redoes one feedback row according to mode and reports what
+// was written.
+func rescoreFeedback(data CustomerData, mode, runID string) (enriched, scored bool, err error) {
+	if mode == RescoreEnrich || mode == RescoreBoth {
+		data, err = reenrichFeedback(data)
+		if err != nil {
+			return false, false, err
+		}
+		enriched = true
+	}
+	if mode == RescoreScore || mode == RescoreBoth {
+		if err := StoreChurnPredictions(RescorePredictions(data, runID)); err != nil {
+			return enriched, false, err
+		}
+		scored = true
+	}
+	return enriched, scored, nil
+}
+
+// reenrichFeedback re-runs enrichment on the stored (redacted) text and updates
+// the feedback row's enrichment columns. A row is left untouched if sentiment
+// is still unavailable, so an ongoing outage doesn't overwrite anything.
+func reenrichFeedback(data CustomerData) (CustomerData, error) {
+	enriched := EnrichFeedback(CustomerData{
+		ID:          data.ID,
+		AccountID:   data.AccountID,
+		NLSScore:    data.NLSScore,
+		Feedback:    data.Feedback,
+		CreatedAt:   data.CreatedAt,
+		ProductLine: data.ProductLine,
+		Redactions:  data.Redactions,
+		Source:      data.Source,
+		Ticket:      data.Ticket,
+	})
+	enriched.AccountSignals = data.AccountSignals
+	if strings.EqualFold(enriched.CommentSentiment, "UNKNOWN") {
+		return data, fmt.Errorf("sentiment is still unavailable from Hugging Face")
+	}
+	update := map[string]interface{}{
+		"comment_sentiment": enriched.CommentSentiment,
+		"comment_topics":    enriched.CommentTopics,
+		"topic_categories":  enriched.TopicCategories,
+		"topic_sentiments":  enriched.TopicSentiments,
+		"taxonomy_version":  enriched.TaxonomyVersion,
+		"language":          enriched.Language,
+		"translated_by":     enriched.TranslatedBy,
+		"emotions":          enriched.Emotions,
+		"cancel_intent":     enriched.CancelIntent,
+		"urgency":           enriched.Urgency,
+		"intent_signals":    enriched.IntentSignals,
+		"enrichment_scores": enriched.EnrichmentScores,
+	}
+	if _, _, err := SupabaseClient.From("customer_feedback").Update(update, "minimal", "").Eq("id", data.ID).Execute(); err != nil {
+		return data, fmt.Errorf("error updating enrichment of customer feedback %s: %w", data.ID, err)
+	}
+	return enriched, nil
+}
+
+// RescorePredictions scores data with the current churn models: the primary
+// prediction first, then the shadows, all tagged with runID.
+func RescorePredictions(data CustomerData, runID string) []ChurnPrediction {
+	primary, shadows := ChurnModels.Score(data)
+	predictions := append([]ChurnPrediction{primary}, shadows...)
+	for i := range predictions {
+		predictions[i].CustomerID = data.ID
+		predictions[i].RescoreRunID = runID
+	}
+	return predictions
+}
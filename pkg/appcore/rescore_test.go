@@ -0,0 +1,61 @@
+package appcore
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRescoreOptions_Validate tests that a rescore run needs a known mode and a bounded selection.
+func TestRescoreOptions_Validate(t *testing.T) {
+	since, err := ParseRescoreTime("2024-06-01")
+	if err != nil || !since.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected a date to parse as UTC midnight, got %v, %v", since, err)
+	}
+	until, err := ParseRescoreTime("2024-05-01T12:00:00Z")
+	if err != nil {
+		t.Fatalf("Expected an RFC 3339 time to parse, got %v", err)
+	}
+	if _, err := ParseRescoreTime("June 1st"); err == nil {
+		t.Errorf("Expected an unparseable date to be rejected")
+	}
+
+	valid := []RescoreOptions{
+		{UnknownSentiment: true, Mode: RescoreEnrich},
+		{ModelVersion: "1.0.0", Mode: RescoreScore, Rate: 0.5},
+		{Since: &until, Until: &since, Mode: RescoreBoth},
+	}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", opts, err)
+		}
+	}
+	invalid := []RescoreOptions{
+		{UnknownSentiment: true, Mode: "all"},
+		{Mode: RescoreBoth}, // no filter: would rescore every row
+		{UnknownSentiment: true, Mode: RescoreScore, Rate: -1},
+		{Since: &since, Until: &until, Mode: RescoreBoth}, // since after until
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+}
+
+// TestRescorePredictions tests that rescored predictions are new rows for the
+// same feedback, primary first and tagged with the run.
+func TestRescorePredictions(t *testing.T) {
+	data := CustomerData{ID: "feedback-1", NLSScore: 2, Feedback: "Terrible support", CommentSentiment: "NEGATIVE"}
+	predictions := RescorePredictions(data, "run-1")
+	if len(predictions) == 0 || predictions[0].Shadow {
+		t.Fatalf("Expected the primary prediction first, got %+v", predictions)
+	}
+	for _, p := range predictions {
+		if p.ID != "" || p.CustomerID != "feedback-1" || p.RescoreRunID != "run-1" {
+			t.Errorf("Expected a new prediction for feedback-1 tagged with run-1, got %+v", p)
+		}
+	}
+	if predictions[0].ChurnProbability != 0.8 {
+		t.Errorf("Expected the current rules to score 0.8, got %.2f", predictions[0].ChurnProbability)
+	}
+}
@@ -0,0 +1,240 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionAction selects what happens to customer_feedback rows whose raw text has expired.
+type RetentionAction string
+
+const (
+	// RetentionActionNullText clears feedback_text but keeps the row (NLS score, enrichment, predictions).
+	RetentionActionNullText RetentionAction = "null_text"
+	// RetentionActionDeleteRow deletes the whole row; its churn_predictions cascade.
+	RetentionActionDeleteRow RetentionAction = "delete_row"
+)
+
+// DefaultFeedbackTextRetentionMonths is our data policy's retention for raw comments.
+const DefaultFeedbackTextRetentionMonths = 18
+
+// retentionBatchSize bounds how many row IDs are sent in one PostgREST filter.
+const retentionBatchSize = 500
+
+// RetentionPolicy says how long raw feedback text and predictions are kept.
+// A zero number of months means "keep forever".
+type RetentionPolicy struct {
+	FeedbackTextMonths int             `json:"feedback_text_months"`
+	PredictionMonths   int             `json:"prediction_months"`
+	Action             RetentionAction `json:"action"`
+}
+
+// RetentionReport summarises what a purge run did, or would do in dry-run mode.
+type RetentionReport struct {
+	DryRun             bool            `json:"dry_run"`
+	Policy             RetentionPolicy `json:"policy"`
+	FeedbackTextCutoff *time.Time      `json:"feedback_text_cutoff,omitempty"`
+	PredictionCutoff   *time.Time      `json:"prediction_cutoff,omitempty"`
+	FeedbackRows       int64           `json:"feedback_rows"`   // rows whose text was (or would be) cleared or deleted
+	PredictionRows     int64           `json:"prediction_rows"` // expired predictions deleted (or that would be)
+	// Rows of the retentionTables deleted (or that would be), by table.
+	TableRows map[string]int64 `json:"table_rows,omitempty"`
+}
+
+// retentionTable is a table holding copies of feedback or of what was derived
+// from it; rows whose Column is older than the feedback text cutoff are deleted.
+type retentionTable struct {
+	Table  string
+	Column string
+}
+
+// retentionTables expire with the feedback text.
+var retentionTables = []retentionTable{
+	{Table: "enrichment_cache", Column: "created_at"},   // responses echo the text (translations, zero-shot inputs)
+	{Table: "webhook_deliveries", Column: "created_at"}, // payloads carry customer IDs, topics and predictions; attempts and dead letters cascade
+	{Table: "outbox_events", Column: "created_at"},      // payloads carry the feedback row
+	{Table: "prediction_jobs", Column: "created_at"},    // requests and results carry the (redacted) text
+}
+
+// RetentionPolicyFromEnv reads RETENTION_FEEDBACK_TEXT_MONTHS (default 18),
+// RETENTION_PREDICTION_MONTHS (default 0, keep forever) and RETENTION_ACTION
+// ("null_text", the default, or "delete_row").
+func RetentionPolicyFromEnv() (RetentionPolicy, error) {
+	policy := RetentionPolicy{
+		FeedbackTextMonths: DefaultFeedbackTextRetentionMonths,
+		Action:             RetentionActionNullText,
+	}
+	var err error
+	if policy.FeedbackTextMonths, err = envMonths("RETENTION_FEEDBACK_TEXT_MONTHS", policy.FeedbackTextMonths); err != nil {
+		return policy, err
+	}
+	if policy.PredictionMonths, err = envMonths("RETENTION_PREDICTION_MONTHS", policy.PredictionMonths); err != nil {
+		return policy, err
+	}
+	if action := strings.TrimSpace(os.Getenv("RETENTION_ACTION")); action != "" {
+		policy.Action = RetentionAction(strings.ToLower(action))
+	}
+	if policy.Action != RetentionActionNullText && policy.Action != RetentionActionDeleteRow {
+		return policy, fmt.Errorf("RETENTION_ACTION must be 'null_text' or 'delete_row', got %q", policy.Action)
+	}
+	return policy, nil
+}
+
+func envMonths(name string, fallback int) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback, nil
+	}
+	months, err := strconv.Atoi(raw)
+	if err != nil || months < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative whole number of months, got %q", name, raw)
+	}
+	return months, nil
+}
+
+// Cutoffs returns the creation/prediction times before which data has expired,
+// or nil where the policy keeps data forever.
+func (p RetentionPolicy) Cutoffs(now time.Time) (feedbackTextCutoff, predictionCutoff *time.Time) {
+	if p.FeedbackTextMonths > 0 {
+		cutoff := now.AddDate(0, -p.FeedbackTextMonths, 0)
+		feedbackTextCutoff = &cutoff
+	}
+	if p.PredictionMonths > 0 {
+		cutoff := now.AddDate(0, -p.PredictionMonths, 0)
+		predictionCutoff = &cutoff
+	}
+	return feedbackTextCutoff, predictionCutoff
+}
+
+// ApplyRetentionPolicy enforces the policy against customer_feedback,
+// churn_predictions and the retentionTables, which use the feedback text
+// cutoff. With dryRun set it only counts the rows that would change.
+func ApplyRetentionPolicy(policy RetentionPolicy, now time.Time, dryRun bool) (RetentionReport, error) {
+	report := RetentionReport{DryRun: dryRun, Policy: policy}
+	if SupabaseClient == nil {
+		return report, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	report.FeedbackTextCutoff, report.PredictionCutoff = policy.Cutoffs(now)
+
+	if report.FeedbackTextCutoff != nil {
+		n, err := purgeExpiredFeedback(policy.Action, report.FeedbackTextCutoff.UTC().Format(time.RFC3339), dryRun)
+		report.FeedbackRows = n
+		if err != nil {
+			return report, err
+		}
+		report.TableRows = make(map[string]int64, len(retentionTables))
+		for _, table := range retentionTables {
+			n, err := purgeExpiredRows(table, report.FeedbackTextCutoff.UTC().Format(time.RFC3339), dryRun)
+			report.TableRows[table.Table] = n
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+	if report.PredictionCutoff != nil {
+		n, err := purgeExpiredPredictions(report.PredictionCutoff.UTC().Format(time.RFC3339), dryRun)
+		report.PredictionRows = n
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func purgeExpiredFeedback(action RetentionAction, cutoff string, dryRun bool) (int64, error) {
+	selectExpired := func(columns, count string, head bool) ([]byte, int64, error) {
+		query := SupabaseClient.From("customer_feedback").Select(columns, count, head).Lt("created_at", cutoff)
+		if action == RetentionActionNullText {
+			// Rows whose text is already gone don't need touching again.
+			query = query.Not("feedback_text", "is", "null")
+		}
+		if !head {
+			query = query.Limit(retentionBatchSize, "")
+		}
+		return query.Execute()
+	}
+
+	if dryRun {
+		_, count, err := selectExpired("id", "exact", true)
+		if err != nil {
+			return 0, fmt.Errorf("error counting expired customer feedback: %w", err)
+		}
+		return count, nil
+	}
+
+	var total int64
+	for {
+		rawData, _, err := selectExpired("id", "", false)
+		if err != nil {
+			return total, fmt.Errorf("error selecting expired customer feedback: %w", err)
+		}
+		ids, err := rowIDs(rawData)
+		if err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		switch action {
+		case RetentionActionDeleteRow:
+			_, _, err = SupabaseClient.From("customer_feedback").Delete("minimal", "").In("id", ids).Execute()
+		default:
+			_, _, err = SupabaseClient.From("customer_feedback").Update(map[string]interface{}{"feedback_text": nil}, "minimal", "").In("id", ids).Execute()
+		}
+		if err != nil {
+			return total, fmt.Errorf("error applying retention (%s) to customer feedback: %w", action, err)
+		}
+		total += int64(len(ids))
+		if len(ids) < retentionBatchSize {
+			return total, nil
+		}
+	}
+}
+
+func purgeExpiredPredictions(cutoff string, dryRun bool) (int64, error) {
+	if dryRun {
+		_, count, err := SupabaseClient.From("churn_predictions").Select("id", "exact", true).Lt("predicted_at", cutoff).Execute()
+		if err != nil {
+			return 0, fmt.Errorf("error counting expired churn predictions: %w", err)
+		}
+		return count, nil
+	}
+	_, count, err := SupabaseClient.From("churn_predictions").Delete("minimal", "exact").Lt("predicted_at", cutoff).Execute()
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired churn predictions: %w", err)
+	}
+	return count, nil
+}
+
+func purgeExpiredRows(table retentionTable, cutoff string, dryRun bool) (int64, error) {
+	if dryRun {
+		_, count, err := SupabaseClient.From(table.Table).Select("*", "exact", true).Lt(table.Column, cutoff).Execute()
+		if err != nil {
+			return 0, fmt.Errorf("error counting expired %s rows: %w", table.Table, err)
+		}
+		return count, nil
+	}
+	_, count, err := SupabaseClient.From(table.Table).Delete("minimal", "exact").Lt(table.Column, cutoff).Execute()
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired %s rows: %w", table.Table, err)
+	}
+	return count, nil
+}
+
+func rowIDs(rawData []byte) ([]string, error) {
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rawData, &rows); err != nil {
+		return nil, fmt.Errorf("error unmarshalling row IDs: %w", err)
+	}
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids, nil
+}
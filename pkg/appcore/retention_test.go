@@ -0,0 +1,44 @@
+package appcore
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRetentionPolicyFromEnv_DefaultsAndCutoffs tests the 18-month default and the cutoff calculation.
+func TestRetentionPolicyFromEnv_DefaultsAndCutoffs(t *testing.T) {
+	t.Setenv("RETENTION_FEEDBACK_TEXT_MONTHS", "")
+	t.Setenv("RETENTION_PREDICTION_MONTHS", "")
+	t.Setenv("RETENTION_ACTION", "")
+
+	policy, err := RetentionPolicyFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policy.FeedbackTextMonths != 18 || policy.PredictionMonths != 0 || policy.Action != RetentionActionNullText {
+		t.Errorf("Expected default policy {18 0 null_text}, got %+v", policy)
+	}
+
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	textCutoff, predictionCutoff := policy.Cutoffs(now)
+	if textCutoff == nil || !textCutoff.Equal(time.Date(2025, 4, 18, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected feedback text cutoff 2025-04-18, got %v", textCutoff)
+	}
+	if predictionCutoff != nil {
+		t.Errorf("Expected predictions to be kept forever, got cutoff %v", predictionCutoff)
+	}
+}
+
+// TestRetentionPolicyFromEnv_Invalid tests that bad configuration is rejected rather than ignored.
+func TestRetentionPolicyFromEnv_Invalid(t *testing.T) {
+	t.Setenv("RETENTION_FEEDBACK_TEXT_MONTHS", "eighteen")
+	if _, err := RetentionPolicyFromEnv(); err == nil {
+		t.Errorf("Expected an error for a non-numeric retention period")
+	}
+
+	t.Setenv("RETENTION_FEEDBACK_TEXT_MONTHS", "18")
+	t.Setenv("RETENTION_ACTION", "archive")
+	if _, err := RetentionPolicyFromEnv(); err == nil {
+		t.Errorf("Expected an error for an unknown retention action")
+	}
+}
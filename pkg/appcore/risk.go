@@ -0,0 +1,72 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RenewalMaxBoost is how much a renewal raises an account's priority: the
+// boost grows linearly from 1 at RenewalSoonDays before the renewal date to
+// 1 + RenewalMaxBoost on it.
+const RenewalMaxBoost = 1.0
+
+// RankedAccount is an account's current churn risk, weighted by revenue and
+// renewal date, as returned by GET /risk/ranked.
+type RankedAccount struct {
+	AccountID          string    `json:"account_id"`
+	ChurnProbability   float64   `json:"churn_probability"`
+	Reason             string    `json:"reason"`
+	ModelName          string    `json:"model_name,omitempty"`
+	ModelVersion       string    `json:"model_version,omitempty"`
+	CustomerFeedbackID string    `json:"customer_feedback_id"` // the feedback or ticket the probability came from
+	Source             string    `json:"source"`
+	PredictedAt        time.Time `json:"predicted_at"`
+
+	Plan          string   `json:"plan,omitempty"`
+	MRR           *float64 `json:"mrr,omitempty"`
+	RenewalDate   string   `json:"renewal_date,omitempty"`
+	DaysToRenewal *int     `json:"days_to_renewal,omitempty"`
+	RevenueAtRisk *float64 `json:"revenue_at_risk,omitempty"` // churn_probability × mrr
+	PriorityScore *float64 `json:"priority_score,omitempty"`  // revenue_at_risk × the renewal boost
+}
+
+// RiskRankOptions select the accounts ranked by RankAccountsAtRisk.
+type RiskRankOptions struct {
+	Since          time.Time // only accounts with feedback or tickets since then
+	Limit          int
+	MinProbability float64
+}
+
+// RankAccountsAtRisk ranks the accounts with feedback or tickets since
+// opts.Since by the highest current primary prediction among those signals,
+// weighted by the account's MRR and renewal date as of now. Each signal counts
+// with its latest primary prediction (rescore runs add newer ones); shadow
+// predictions are ignored. The account_risk database function joins the
+// account attributes, computes the priority and returns the top opts.Limit
+// accounts (all if it is 0), so accounts aren't cut off by the API's row limit
+// before they are ranked.
+func RankAccountsAtRisk(opts RiskRankOptions, now time.Time) ([]RankedAccount, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	params := map[string]interface{}{
+		"since":             opts.Since.UTC().Format(time.RFC3339),
+		"min_probability":   opts.MinProbability,
+		"as_of":             now.UTC().Format(dateLayout),
+		"renewal_soon_days": RenewalSoonDays,
+		"renewal_max_boost": RenewalMaxBoost,
+	}
+	if opts.Limit > 0 {
+		params["max_accounts"] = opts.Limit
+	}
+	body, err := callRPC("account_risk", params)
+	if err != nil {
+		return nil, fmt.Errorf("error ranking account risk: %w", err)
+	}
+	var accounts []RankedAccount
+	if err := json.Unmarshal([]byte(body), &accounts); err != nil {
+		return nil, fmt.Errorf("error unmarshalling account risk: %w", err)
+	}
+	return accounts, nil
+}
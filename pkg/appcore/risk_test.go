@@ -0,0 +1,49 @@
+package appcore
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestRankAccountsAtRisk tests that ranking and the limit are left to the
+// account_risk function and that its ranked rows are returned in order.
+func TestRankAccountsAtRisk(t *testing.T) {
+	fake := newFakeSupabase(t, func(req fakeRequest) fakeResponse {
+		return fakeResponse{Body: `[
+			{"account_id": "renewing", "churn_probability": 0.4, "plan": "pro", "mrr": 1000, "renewal_date": "2026-10-18", "days_to_renewal": 0, "revenue_at_risk": 400, "priority_score": 800},
+			{"account_id": "unknown", "churn_probability": 0.9, "mrr": null, "renewal_date": null, "days_to_renewal": null, "revenue_at_risk": null, "priority_score": null}]`}
+	})
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	ranked, err := RankAccountsAtRisk(RiskRankOptions{Since: now.AddDate(0, 0, -90), Limit: 2, MinProbability: 0.3}, now)
+	if err != nil || len(ranked) != 2 {
+		t.Fatalf("Expected two ranked accounts, got %+v, %v", ranked, err)
+	}
+	if r := ranked[0]; r.AccountID != "renewing" || r.PriorityScore == nil || *r.PriorityScore != 800 || r.DaysToRenewal == nil || *r.DaysToRenewal != 0 || r.RenewalDate != "2026-10-18" {
+		t.Errorf("Expected the renewing account first with its priority, got %+v", r)
+	}
+	if r := ranked[1]; r.RevenueAtRisk != nil || r.PriorityScore != nil || r.MRR != nil {
+		t.Errorf("Expected no revenue at risk without an MRR, got %+v", r)
+	}
+
+	calls := fake.Requests("POST", "rpc/account_risk")
+	if len(calls) != 1 {
+		t.Fatalf("Expected one account_risk call, got %d", len(calls))
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(calls[0].Body), &params); err != nil {
+		t.Fatalf("Expected JSON params, got %v", err)
+	}
+	if params["max_accounts"] != 2.0 || params["as_of"] != "2026-10-18" || params["min_probability"] != 0.3 ||
+		params["renewal_soon_days"] != float64(RenewalSoonDays) || params["renewal_max_boost"] != RenewalMaxBoost {
+		t.Errorf("Unexpected account_risk params %v", params)
+	}
+
+	if _, err := RankAccountsAtRisk(RiskRankOptions{Since: now}, now); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var unlimited map[string]interface{}
+	if err := json.Unmarshal([]byte(fake.Requests("POST", "rpc/account_risk")[1].Body), &unlimited); err != nil || unlimited["max_accounts"] != nil {
+		t.Errorf("Expected no max_accounts without a limit, got %v", unlimited)
+	}
+}
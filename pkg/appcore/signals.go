@@ -0,0 +1,91 @@
+package appcore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Signal sources: what a customer_feedback row was created from.
+const (
+	SignalSourceSurvey = "survey" // an NPS survey response (POST /predict, /ingest)
+	SignalSourceTicket = "ticket" // a support ticket event (POST /signals/tickets)
+)
+
+// AccountSignals are account-level features looked up when a signal is scored.
+// They are stored with the signal in account_signals, so a score can be
+// reproduced from the row alone.
+type AccountSignals struct {
+	Tickets    *TicketSignals    `json:"tickets,omitempty"`
+	Usage      *UsageSignals     `json:"usage,omitempty"`
+	Attributes *AttributeSignals `json:"attributes,omitempty"`
+}
+
+// TicketSummary returns the ticket signals, or zero values if there are none.
+func (s *AccountSignals) TicketSummary() TicketSignals {
+	if s == nil || s.Tickets == nil {
+		return TicketSignals{}
+	}
+	return *s.Tickets
+}
+
+// UsageSummary returns the usage signals, or nil if there are none.
+func (s *AccountSignals) UsageSummary() *UsageSignals {
+	if s == nil {
+		return nil
+	}
+	return s.Usage
+}
+
+// AttributeSummary returns the account attributes, or nil if there are none.
+func (s *AccountSignals) AttributeSummary() *AttributeSignals {
+	if s == nil {
+		return nil
+	}
+	return s.Attributes
+}
+
+// LookupAccountSignals is the feature lookup step of the pipeline: it gathers
+// the account's ticket, usage and attribute features as of asOf, the time of
+// the signal being scored, so that imported history sees only what was known
+// then. Attributes are the account's current ones, as they are not versioned.
+// Feedback without an account_id has none. A ticket event counts towards its
+// own account's ticket signals. Features that could not be read are left out
+// and reported in the error, together with the ones that were found.
+func LookupAccountSignals(data CustomerData, asOf time.Time) (*AccountSignals, error) {
+	if data.AccountID == "" {
+		return nil, nil
+	}
+	signals := &AccountSignals{}
+	var errs []error
+
+	events, err := FetchTicketEvents(data.AccountID, asOf.Add(-TicketSignalWindow), asOf)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error looking up ticket signals: %w", err))
+	}
+	if data.Source == SignalSourceTicket {
+		events = append(events, data)
+	}
+	if len(events) > 0 {
+		tickets := SummarizeTickets(events)
+		signals.Tickets = &tickets
+	}
+
+	periods, err := FetchAccountUsage(data.AccountID, asOf.Add(-UsageSignalWindow), asOf)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error looking up usage signals: %w", err))
+	}
+	signals.Usage = SummarizeUsage(periods, asOf)
+
+	attributes, err := FetchAccountAttributes(data.AccountID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error looking up account attributes: %w", err))
+	} else if attributes != nil {
+		signals.Attributes = attributes.Signals(asOf)
+	}
+
+	if signals.Tickets == nil && signals.Usage == nil && signals.Attributes == nil {
+		signals = nil
+	}
+	return signals, errors.Join(errs...)
+}
@@ -0,0 +1,223 @@
+package appcore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventSink publishes outbox events downstream. Publish must return nil only
+// once the sink has accepted the event; the relay retries anything else.
+type EventSink interface {
+	Name() string
+	Publish(event OutboxEvent) error
+}
+
+// sinkTimeout bounds a single publish to any network sink.
+const sinkTimeout = 10 * time.Second
+
+// WebhookSink POSTs each envelope to URL, signed with Secret like subscription
+// webhooks (X-Churn-Signature) and with the event ID in X-Churn-Event-ID.
+type WebhookSink struct {
+	URL    string
+	Secret string
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Publish(event OutboxEvent) error {
+	_, err := PostWebhook(s.URL, s.Secret, event.ID, event.Payload, sinkTimeout)
+	return err
+}
+
+// NATSSink publishes each envelope to Subject on a NATS server, using the core
+// text protocol: CONNECT, PUB, then PING and wait for PONG so the publish is
+// known to have reached the server. URL is nats://[user:pass@]host:port.
+type NATSSink struct {
+	URL     string
+	Subject string
+}
+
+func (s *NATSSink) Name() string { return "nats" }
+
+func (s *NATSSink) Publish(event OutboxEvent) error {
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid NATS URL %q", s.URL)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, sinkTimeout)
+	if err != nil {
+		return fmt.Errorf("error connecting to NATS: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(sinkTimeout))
+	reader := bufio.NewReader(conn)
+	if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("unexpected NATS greeting %q: %v", strings.TrimSpace(line), err)
+	}
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "churn-outbox-relay"}
+	if u.User != nil {
+		options["user"] = u.User.Username()
+		options["pass"], _ = u.User.Password()
+	}
+	connect, _ := json.Marshal(options)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "CONNECT %s\r\nPUB %s %d\r\n", connect, s.Subject, len(event.Payload))
+	buf.Write(event.Payload)
+	buf.WriteString("\r\nPING\r\n")
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("error publishing to NATS: %w", err)
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("error waiting for NATS acknowledgement: %w", err)
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", line)
+		}
+	}
+}
+
+// KafkaRESTSink produces each envelope to Topic through a Confluent-compatible
+// Kafka REST Proxy at URL, keyed by the aggregate (feedback) ID so events for
+// the same feedback land on the same partition.
+type KafkaRESTSink struct {
+	URL   string
+	Topic string
+}
+
+func (s *KafkaRESTSink) Name() string { return "kafka" }
+
+func (s *KafkaRESTSink) Publish(event OutboxEvent) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{{"key": event.AggregateID, "value": event.Payload}},
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling Kafka records: %w", err)
+	}
+	endpoint := strings.TrimRight(s.URL, "/") + "/topics/" + url.PathEscape(s.Topic)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating Kafka REST request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := (&http.Client{Timeout: sinkTimeout}).Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending to Kafka REST Proxy: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Kafka REST Proxy returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	// The proxy answers 200 even when a record was rejected; the error is per offset.
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if json.Unmarshal(respBody, &result) == nil {
+		for _, offset := range result.Offsets {
+			if offset.ErrorCode != nil || offset.Error != "" {
+				return fmt.Errorf("Kafka REST Proxy rejected the record: %s", offset.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// FileSink appends each envelope as a JSON line to Path. Meant for tests and
+// local development.
+type FileSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+func (s *FileSink) Publish(event OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening event file: %w", err)
+	}
+	defer f.Close()
+	var line bytes.Buffer
+	if err := json.Compact(&line, event.Payload); err != nil {
+		return fmt.Errorf("error encoding event %s: %w", event.ID, err)
+	}
+	line.WriteByte('\n')
+	if _, err := f.Write(line.Bytes()); err != nil {
+		return fmt.Errorf("error writing event file: %w", err)
+	}
+	return nil
+}
+
+// NewEventSinksFromEnv builds the sinks named in OUTBOX_SINKS (comma-separated,
+// published to in that order):
+//   - webhook: OUTBOX_WEBHOOK_URL, signed with OUTBOX_WEBHOOK_SECRET
+//   - nats: OUTBOX_NATS_URL, subject OUTBOX_NATS_SUBJECT (default churn.events)
+//   - kafka: OUTBOX_KAFKA_REST_URL, topic OUTBOX_KAFKA_TOPIC (default churn.events)
+//   - file: OUTBOX_FILE_PATH
+func NewEventSinksFromEnv() ([]EventSink, error) {
+	env := func(name, fallback string) string {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			return v
+		}
+		return fallback
+	}
+	var sinks []EventSink
+	for _, name := range strings.Split(os.Getenv("OUTBOX_SINKS"), ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "":
+		case "webhook":
+			sink := &WebhookSink{URL: env("OUTBOX_WEBHOOK_URL", ""), Secret: env("OUTBOX_WEBHOOK_SECRET", "")}
+			if sink.URL == "" || sink.Secret == "" {
+				return nil, fmt.Errorf("the webhook outbox sink needs OUTBOX_WEBHOOK_URL and OUTBOX_WEBHOOK_SECRET")
+			}
+			sinks = append(sinks, sink)
+		case "nats":
+			sink := &NATSSink{URL: env("OUTBOX_NATS_URL", ""), Subject: env("OUTBOX_NATS_SUBJECT", "churn.events")}
+			if sink.URL == "" {
+				return nil, fmt.Errorf("the nats outbox sink needs OUTBOX_NATS_URL")
+			}
+			sinks = append(sinks, sink)
+		case "kafka":
+			sink := &KafkaRESTSink{URL: env("OUTBOX_KAFKA_REST_URL", ""), Topic: env("OUTBOX_KAFKA_TOPIC", "churn.events")}
+			if sink.URL == "" {
+				return nil, fmt.Errorf("the kafka outbox sink needs OUTBOX_KAFKA_REST_URL")
+			}
+			sinks = append(sinks, sink)
+		case "file":
+			sink := &FileSink{Path: env("OUTBOX_FILE_PATH", "")}
+			if sink.Path == "" {
+				return nil, fmt.Errorf("the file outbox sink needs OUTBOX_FILE_PATH")
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q (expected webhook, nats, kafka or file)", name)
+		}
+	}
+	return sinks, nil
+}
@@ -0,0 +1,54 @@
+package appcore
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFileSink_AppendsJSONLines tests that each published event becomes one JSON line.
+func TestFileSink_AppendsJSONLines(t *testing.T) {
+	path := t.TempDir() + "/events.jsonl"
+	t.Setenv("OUTBOX_SINKS", "file")
+	t.Setenv("OUTBOX_FILE_PATH", path)
+	sinks, err := NewEventSinksFromEnv()
+	if err != nil || len(sinks) != 1 || sinks[0].Name() != "file" {
+		t.Fatalf("Expected one file sink, got %v, %v", sinks, err)
+	}
+	for i := 0; i < 2; i++ {
+		event, _ := NewOutboxEvent(EventPredictionCreated, NewUUID(), map[string]int{"n": i})
+		if err := sinks[0].Publish(event); err != nil {
+			t.Fatalf("Expected publish to succeed, got %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the event file, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"data":{"n":1}`) {
+		t.Errorf("Expected two JSON lines, got %q", lines)
+	}
+}
+
+// TestNewEventSinksFromEnv_Errors tests that unknown or incomplete sinks are rejected.
+func TestNewEventSinksFromEnv_Errors(t *testing.T) {
+	t.Setenv("OUTBOX_SINKS", "")
+	if sinks, err := NewEventSinksFromEnv(); err != nil || len(sinks) != 0 {
+		t.Errorf("Expected no sinks by default, got %v, %v", sinks, err)
+	}
+	t.Setenv("OUTBOX_SINKS", "nats, kafka")
+	t.Setenv("OUTBOX_NATS_URL", "nats://localhost:4222")
+	t.Setenv("OUTBOX_KAFKA_REST_URL", "")
+	if _, err := NewEventSinksFromEnv(); err == nil {
+		t.Errorf("Expected an error for the kafka sink without OUTBOX_KAFKA_REST_URL")
+	}
+	t.Setenv("OUTBOX_SINKS", "sqs")
+	if _, err := NewEventSinksFromEnv(); err == nil {
+		t.Errorf("Expected an error for an unknown sink")
+	}
+	if d := DefaultOutboxRelay().Backoff(4); d != 40*time.Second {
+		t.Errorf("Expected the fourth retry after 40s, got %v", d)
+	}
+}
@@ -0,0 +1,83 @@
+package appcore
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/supabase-community/supabase-go"
+)
+
+// fakeRequest is a PostgREST request received by fakeSupabase.
+type fakeRequest struct {
+	Method string
+	Path   string     // table or "rpc/<function>"
+	Query  url.Values // filters, e.g. Query.Get("account_id") == "eq.acct_1"
+	Body   string
+}
+
+// fakeResponse answers a fakeRequest; Count is sent in Content-Range.
+type fakeResponse struct {
+	Status int
+	Body   string
+	Count  int64
+}
+
+// fakeSupabase stands in for PostgREST: SupabaseClient points at it until the
+// test ends, and every request is recorded and answered by respond.
+type fakeSupabase struct {
+	mu       sync.Mutex
+	requests []fakeRequest
+}
+
+func newFakeSupabase(t *testing.T, respond func(req fakeRequest) fakeResponse) *fakeSupabase {
+	t.Helper()
+	fake := &fakeSupabase{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := fakeRequest{Method: r.Method, Path: strings.TrimPrefix(r.URL.Path, "/rest/v1/"), Query: r.URL.Query(), Body: string(body)}
+		fake.mu.Lock()
+		fake.requests = append(fake.requests, req)
+		fake.mu.Unlock()
+
+		resp := respond(req)
+		if resp.Status == 0 {
+			resp.Status = http.StatusOK
+		}
+		if resp.Body == "" && resp.Status < 300 && r.Method == http.MethodGet {
+			resp.Body = "[]"
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("*/%d", resp.Count))
+		w.WriteHeader(resp.Status)
+		io.WriteString(w, resp.Body)
+	}))
+	previous := SupabaseClient
+	client, err := supabase.NewClient(server.URL, "test-key", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error creating the Supabase client: %v", err)
+	}
+	SupabaseClient = client
+	t.Cleanup(func() {
+		SupabaseClient = previous
+		server.Close()
+	})
+	return fake
+}
+
+// Requests returns the requests received with the given method and path.
+func (f *fakeSupabase) Requests(method, path string) []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matched []fakeRequest
+	for _, req := range f.requests {
+		if req.Method == method && req.Path == path {
+			matched = append(matched, req)
+		}
+	}
+	return matched
+}
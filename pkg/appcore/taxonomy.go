@@ -0,0 +1,233 @@
+package appcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultProductLine is used when a request does not name a product line.
+const DefaultProductLine = "default"
+
+// TopicDefinition is one topic in the taxonomy.
+type TopicDefinition struct {
+	Name        string   `json:"name"`                  // stored in comment_topics
+	Parent      string   `json:"parent,omitempty"`      // category stored in topic_categories
+	Description string   `json:"description,omitempty"` // zero-shot label; defaults to Name
+	Threshold   float64  `json:"threshold,omitempty"`   // minimum zero-shot score; defaults to the taxonomy's
+	Synonyms    []string `json:"synonyms,omitempty"`    // words or phrases that assign the topic directly
+}
+
+// label is the text sent to the zero-shot model for this topic.
+func (d TopicDefinition) label() string {
+	if d.Description != "" {
+		return d.Description
+	}
+	return d.Name
+}
+
+// Taxonomy is the versioned set of topics per product line, loaded at startup.
+type Taxonomy struct {
+	Version            string                       `json:"version"`
+	HypothesisTemplate string                       `json:"hypothesis_template,omitempty"` // e.g. "This feedback is about {}."
+	DefaultThreshold   float64                      `json:"default_threshold,omitempty"`
+	ProductLines       map[string][]TopicDefinition `json:"product_lines"`
+}
+
+// TopicAssignment is the result of classifying one feedback text.
+type TopicAssignment struct {
+	Topics     []string
+	Categories []string
+	Scores     []TopicScore // every topic of the product line, matched or not
+}
+
+// TopicTaxonomy is the taxonomy used by /predict. InitClients loads it from
+// TOPIC_TAXONOMY_PATH, falling back to BuiltinTaxonomy.
+var TopicTaxonomy = BuiltinTaxonomy()
+
+// BuiltinTaxonomy returns the original six topics as a single product line.
+func BuiltinTaxonomy() *Taxonomy {
+	names := []string{"service", "product quality", "pricing", "customer support", "speed", "ease of use"}
+	topics := make([]TopicDefinition, 0, len(names))
+	for _, name := range names {
+		topics = append(topics, TopicDefinition{Name: name})
+	}
+	return &Taxonomy{
+		Version:          "builtin-1",
+		DefaultThreshold: TopicScoreThreshold,
+		ProductLines:     map[string][]TopicDefinition{DefaultProductLine: topics},
+	}
+}
+
+// LoadTaxonomy reads and validates a taxonomy JSON file.
+func LoadTaxonomy(path string) (*Taxonomy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading taxonomy file: %w", err)
+	}
+	var t Taxonomy
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("error parsing taxonomy file %s: %w", path, err)
+	}
+	if t.DefaultThreshold == 0 {
+		t.DefaultThreshold = TopicScoreThreshold
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid taxonomy file %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// Validate checks that the taxonomy has a version and a default product line,
+// that topic names and zero-shot labels are unique per product line, and that
+// thresholds lie in (0, 1].
+func (t *Taxonomy) Validate() error {
+	if strings.TrimSpace(t.Version) == "" {
+		return fmt.Errorf("version is required")
+	}
+	if t.DefaultThreshold <= 0 || t.DefaultThreshold > 1 {
+		return fmt.Errorf("default_threshold must be in (0, 1], got %g", t.DefaultThreshold)
+	}
+	if t.HypothesisTemplate != "" && !strings.Contains(t.HypothesisTemplate, "{}") {
+		return fmt.Errorf("hypothesis_template must contain {}")
+	}
+	if _, ok := t.ProductLines[DefaultProductLine]; !ok {
+		return fmt.Errorf("product_lines must include %q", DefaultProductLine)
+	}
+	for line, topics := range t.ProductLines {
+		if len(topics) == 0 {
+			return fmt.Errorf("product line %q has no topics", line)
+		}
+		names, labels := map[string]bool{}, map[string]bool{}
+		for _, topic := range topics {
+			if strings.TrimSpace(topic.Name) == "" {
+				return fmt.Errorf("product line %q has a topic without a name", line)
+			}
+			if names[topic.Name] {
+				return fmt.Errorf("product line %q defines topic %q twice", line, topic.Name)
+			}
+			if labels[topic.label()] {
+				return fmt.Errorf("product line %q uses label %q for more than one topic", line, topic.label())
+			}
+			if topic.Threshold < 0 || topic.Threshold > 1 {
+				return fmt.Errorf("topic %q threshold must be in (0, 1], got %g", topic.Name, topic.Threshold)
+			}
+			names[topic.Name], labels[topic.label()] = true, true
+		}
+	}
+	return nil
+}
+
+// HasProductLine reports whether the taxonomy defines topics for productLine.
+func (t *Taxonomy) HasProductLine(productLine string) bool {
+	_, ok := t.ProductLines[productLine]
+	return ok
+}
+
+// ProductLineNames returns the configured product lines in sorted order.
+func (t *Taxonomy) ProductLineNames() []string {
+	names := make([]string, 0, len(t.ProductLines))
+	for name := range t.ProductLines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *Taxonomy) topicsFor(productLine string) []TopicDefinition {
+	if productLine == "" {
+		productLine = DefaultProductLine
+	}
+	return t.ProductLines[productLine]
+}
+
+// CandidateLabels returns the zero-shot labels for a product line.
+func (t *Taxonomy) CandidateLabels(productLine string) []string {
+	topics := t.topicsFor(productLine)
+	labels := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		labels = append(labels, topic.label())
+	}
+	return labels
+}
+
+// ExtractTopics classifies feedback text against a product line's topics via
+// zero-shot classification (with models.ZeroShot) plus synonym matching. If the
+// model call fails, synonym matches are still returned alongside the error.
+func (t *Taxonomy) ExtractTopics(feedbackText, productLine string, models LanguageModels) (TopicAssignment, error) {
+	scores, err := GetZeroShotScoresWithModel(models.ZeroShot, feedbackText, t.CandidateLabels(productLine), t.HypothesisTemplate)
+	return t.AssignTopics(feedbackText, productLine, scores), err
+}
+
+// AssignTopics scores every topic of a product line from the zero-shot label
+// scores and synonym matches, then selects the matching topics (see SelectTopics).
+func (t *Taxonomy) AssignTopics(feedbackText, productLine string, scores []LabelScore) TopicAssignment {
+	scoreByLabel := make(map[string]float64, len(scores))
+	for _, ls := range scores {
+		scoreByLabel[ls.Label] = ls.Score
+	}
+	topics := t.topicsFor(productLine)
+	topicScores := make([]TopicScore, 0, len(topics))
+	for _, topic := range topics {
+		ts := TopicScore{Topic: topic.Name, Label: topic.label(), Score: scoreByLabel[topic.label()]}
+		ts.SynonymMatch = mentionsAny(feedbackText, topic.Synonyms)
+		topicScores = append(topicScores, ts)
+	}
+	return t.SelectTopics(productLine, topicScores)
+}
+
+// SelectTopics picks, in taxonomy order, the topics whose score exceeds their
+// threshold or that matched a synonym, plus their parent categories. Scores are
+// matched to topics by name, so stored scores can be re-thresholded after the
+// taxonomy's thresholds change without calling Hugging Face again.
+func (t *Taxonomy) SelectTopics(productLine string, scores []TopicScore) TopicAssignment {
+	byTopic := make(map[string]TopicScore, len(scores))
+	for _, ts := range scores {
+		byTopic[ts.Topic] = ts
+	}
+
+	result := TopicAssignment{Topics: []string{}, Categories: []string{}, Scores: scores}
+	seenCategory := map[string]bool{}
+	for _, topic := range t.topicsFor(productLine) {
+		threshold := topic.Threshold
+		if threshold == 0 {
+			threshold = t.DefaultThreshold
+		}
+		ts, ok := byTopic[topic.Name]
+		if !ok || (!ts.SynonymMatch && ts.Score <= threshold) {
+			continue
+		}
+		result.Topics = append(result.Topics, topic.Name)
+		if topic.Parent != "" && !seenCategory[topic.Parent] {
+			seenCategory[topic.Parent] = true
+			result.Categories = append(result.Categories, topic.Parent)
+		}
+	}
+	return result
+}
+
+// mentionsAny reports whether text contains any of the phrases as whole,
+// stemmed words. Negation is irrelevant here: "not expensive" is still about pricing.
+func mentionsAny(text string, phrases []string) bool {
+	return len(phrases) > 0 && len(NewPhraseMatcher(phrases).Match(text)) > 0
+}
+
+// ConfigureTopicTaxonomy sets TopicTaxonomy from TOPIC_TAXONOMY_PATH, or the
+// built-in taxonomy when it is unset.
+func ConfigureTopicTaxonomy() error {
+	path := strings.TrimSpace(os.Getenv("TOPIC_TAXONOMY_PATH"))
+	if path == "" {
+		TopicTaxonomy = BuiltinTaxonomy()
+		return nil
+	}
+	t, err := LoadTaxonomy(path)
+	if err != nil {
+		return err
+	}
+	TopicTaxonomy = t
+	log.Printf("Topic taxonomy %s loaded from %s (product lines: %v).", t.Version, path, t.ProductLineNames())
+	return nil
+}
@@ -0,0 +1,88 @@
+package appcore
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestTaxonomy_AssignTopics tests per-topic thresholds, synonyms and parent categories.
+func TestTaxonomy_AssignTopics(t *testing.T) {
+	taxonomy := &Taxonomy{
+		Version:          "test-1",
+		DefaultThreshold: 0.8,
+		ProductLines: map[string][]TopicDefinition{
+			DefaultProductLine: {{Name: "pricing"}},
+			"saas": {
+				{Name: "onboarding", Parent: "experience", Description: "onboarding and account setup", Threshold: 0.6},
+				{Name: "billing errors", Parent: "commercial", Synonyms: []string{"double charged"}},
+				{Name: "integrations", Parent: "product", Synonyms: []string{"api"}},
+			},
+		},
+	}
+	if err := taxonomy.Validate(); err != nil {
+		t.Fatalf("Expected valid taxonomy, got %v", err)
+	}
+
+	scores := []LabelScore{{Label: "onboarding and account setup", Score: 0.65}, {Label: "integrations", Score: 0.5}}
+	got := taxonomy.AssignTopics("I was Double-Charged after a rapid setup.", "saas", scores)
+	if strings.Join(got.Topics, ",") != "onboarding,billing errors" {
+		t.Errorf("Expected topics [onboarding billing errors], got %v", got.Topics)
+	}
+	if strings.Join(got.Categories, ",") != "experience,commercial" {
+		t.Errorf("Expected categories [experience commercial], got %v", got.Categories)
+	}
+
+	// Synonyms match whole words only: "rapid" must not match "api".
+	if got := taxonomy.AssignTopics("rapid", "saas", nil); len(got.Topics) != 0 {
+		t.Errorf("Expected no topics for a partial-word synonym match, got %v", got.Topics)
+	}
+	// The default threshold applies when a topic has none, and the empty product line means default.
+	if got := taxonomy.AssignTopics("", "", []LabelScore{{Label: "pricing", Score: 0.8}}); len(got.Topics) != 0 {
+		t.Errorf("Expected a score equal to the threshold not to match, got %v", got.Topics)
+	}
+}
+
+// TestTaxonomy_Validate tests that invalid taxonomies are rejected at load time.
+func TestTaxonomy_Validate(t *testing.T) {
+	if err := BuiltinTaxonomy().Validate(); err != nil {
+		t.Errorf("Expected built-in taxonomy to be valid, got %v", err)
+	}
+	invalid := map[string]*Taxonomy{
+		"missing version":      {DefaultThreshold: 0.8, ProductLines: map[string][]TopicDefinition{DefaultProductLine: {{Name: "a"}}}},
+		"missing default line": {Version: "v", DefaultThreshold: 0.8, ProductLines: map[string][]TopicDefinition{"saas": {{Name: "a"}}}},
+		"duplicate topic":      {Version: "v", DefaultThreshold: 0.8, ProductLines: map[string][]TopicDefinition{DefaultProductLine: {{Name: "a"}, {Name: "a"}}}},
+		"threshold too high":   {Version: "v", DefaultThreshold: 0.8, ProductLines: map[string][]TopicDefinition{DefaultProductLine: {{Name: "a", Threshold: 1.5}}}},
+		"bad template":         {Version: "v", DefaultThreshold: 0.8, HypothesisTemplate: "About this.", ProductLines: map[string][]TopicDefinition{DefaultProductLine: {{Name: "a"}}}},
+	}
+	for name, taxonomy := range invalid {
+		if err := taxonomy.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+// TestTaxonomy_SelectTopicsFromStoredScores tests re-thresholding persisted scores.
+func TestTaxonomy_SelectTopicsFromStoredScores(t *testing.T) {
+	stored := []TopicScore{
+		{Topic: "pricing", Label: "price and cost", Score: 0.75},
+		{Topic: "speed", Label: "speed", Score: 0.2, SynonymMatch: true},
+		{Topic: "retired topic", Label: "retired topic", Score: 0.99},
+	}
+	taxonomy := &Taxonomy{Version: "v1", DefaultThreshold: 0.8, ProductLines: map[string][]TopicDefinition{
+		DefaultProductLine: {{Name: "pricing"}, {Name: "speed"}},
+	}}
+	if got := taxonomy.SelectTopics(DefaultProductLine, stored).Topics; strings.Join(got, ",") != "speed" {
+		t.Errorf("Expected only the synonym match at threshold 0.8, got %v", got)
+	}
+
+	taxonomy.ProductLines[DefaultProductLine][0].Threshold = 0.7
+	if got := taxonomy.SelectTopics(DefaultProductLine, stored).Topics; strings.Join(got, ",") != "pricing,speed" {
+		t.Errorf("Expected pricing after lowering its threshold to 0.7, got %v", got)
+	}
+
+	// AssignTopics keeps a score for every topic, matched or not, for persistence.
+	assigned := taxonomy.AssignTopics("no match here", DefaultProductLine, []LabelScore{{Label: "pricing", Score: 0.1}})
+	if len(assigned.Scores) != 2 || assigned.Scores[0].Score != 0.1 || len(assigned.Topics) != 0 {
+		t.Errorf("Expected scores for both topics and no matches, got %+v", assigned)
+	}
+}
@@ -0,0 +1,266 @@
+package appcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// Ticket priorities, lowest first.
+const (
+	TicketPriorityLow    = "low"
+	TicketPriorityNormal = "normal"
+	TicketPriorityHigh   = "high"
+	TicketPriorityUrgent = "urgent"
+)
+
+// TicketPriorities lists the accepted ticket priorities.
+var TicketPriorities = []string{TicketPriorityLow, TicketPriorityNormal, TicketPriorityHigh, TicketPriorityUrgent}
+
+const (
+	// TicketSignalWindow is how far back an account's tickets count towards its ticket signals.
+	TicketSignalWindow = 30 * 24 * time.Hour
+	// SlowResolutionHours is the time to resolution above which a ticket counts as slow.
+	SlowResolutionHours = 72
+	// NegativeTicketThreshold is the number of negative tickets in the window that
+	// raises an account's churn risk regardless of the signal being scored.
+	NegativeTicketThreshold = 3
+)
+
+// ErrTicketEventExists is returned by ProcessTicket for a ticket event that is
+// already stored, so that replayed imports and webhook retries are not scored twice.
+var ErrTicketEventExists = errors.New("ticket event already stored")
+
+// TicketDetails are the support ticket fields of a customer_feedback row with
+// source 'ticket'. The ticket text itself is stored in feedback_text.
+type TicketDetails struct {
+	TicketID              string    `json:"ticket_id"`
+	Priority              string    `json:"priority,omitempty"`
+	TimeToResolutionHours *float64  `json:"time_to_resolution_hours,omitempty"` // nil while the ticket is open
+	ReopenCount           int       `json:"reopen_count"`
+	EventAt               time.Time `json:"event_at"` // UTC; identifies the event together with TicketID
+}
+
+// Escalated reports whether the ticket had high or urgent priority, was
+// reopened at least twice or took longer than SlowResolutionHours to resolve.
+func (t *TicketDetails) Escalated() bool {
+	if t == nil {
+		return false
+	}
+	return t.Priority == TicketPriorityHigh || t.Priority == TicketPriorityUrgent ||
+		t.ReopenCount >= 2 || t.slowResolution()
+}
+
+func (t *TicketDetails) slowResolution() bool {
+	return t.TimeToResolutionHours != nil && *t.TimeToResolutionHours > SlowResolutionHours
+}
+
+// TicketSignals summarise an account's support tickets over TicketSignalWindow.
+// Each ticket counts once, using its latest event.
+type TicketSignals struct {
+	Tickets         int `json:"tickets"`
+	NegativeTickets int `json:"negative_tickets"` // tickets with a NEGATIVE event
+	UrgentTickets   int `json:"urgent_tickets"`   // high or urgent priority
+	Reopens         int `json:"reopens"`          // reopen_count summed over tickets
+	SlowResolutions int `json:"slow_resolutions"` // resolved after more than SlowResolutionHours
+}
+
+// SummarizeTickets computes the ticket signals for a set of ticket events.
+// Events without ticket details are ignored.
+func SummarizeTickets(events []CustomerData) TicketSignals {
+	latest := map[string]*TicketDetails{}
+	negative := map[string]bool{}
+	for i := range events {
+		ticket := events[i].Ticket
+		if ticket == nil {
+			continue
+		}
+		if current, ok := latest[ticket.TicketID]; !ok || ticket.EventAt.After(current.EventAt) {
+			latest[ticket.TicketID] = ticket
+		}
+		if strings.ToUpper(events[i].CommentSentiment) == "NEGATIVE" {
+			negative[ticket.TicketID] = true
+		}
+	}
+
+	var signals TicketSignals
+	for id, ticket := range latest {
+		signals.Tickets++
+		if negative[id] {
+			signals.NegativeTickets++
+		}
+		if ticket.Priority == TicketPriorityHigh || ticket.Priority == TicketPriorityUrgent {
+			signals.UrgentTickets++
+		}
+		signals.Reopens += ticket.ReopenCount
+		if ticket.slowResolution() {
+			signals.SlowResolutions++
+		}
+	}
+	return signals
+}
+
+// FetchTicketEvents returns the ticket events stored for an account between since and until.
+func FetchTicketEvents(accountID string, since, until time.Time) ([]CustomerData, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, _, err := SupabaseClient.From("customer_feedback").
+		Select("id,account_id,created_at,comment_sentiment,source,ticket", "", false).
+		Eq("source", SignalSourceTicket).
+		Eq("account_id", accountID).
+		Gte("created_at", since.UTC().Format(time.RFC3339)).
+		Lte("created_at", until.UTC().Format(time.RFC3339Nano)).
+		Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching ticket events: %w", err)
+	}
+	var events []CustomerData
+	if err := json.Unmarshal(rawData, &events); err != nil {
+		return nil, fmt.Errorf("error unmarshalling ticket events: %w", err)
+	}
+	return events, nil
+}
+
+// TicketEventExists reports whether the event of a ticket at eventAt is already stored.
+func TicketEventExists(ticketID string, eventAt time.Time) (bool, error) {
+	if SupabaseClient == nil {
+		return false, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, _, err := SupabaseClient.From("customer_feedback").
+		Select("id", "", false).
+		Eq("source", SignalSourceTicket).
+		Eq("ticket->>ticket_id", ticketID).
+		Eq("ticket->>event_at", eventAt.UTC().Format(time.RFC3339Nano)).
+		Limit(1, "").
+		Execute()
+	if err != nil {
+		return false, fmt.Errorf("error looking up ticket event: %w", err)
+	}
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rawData, &rows); err != nil {
+		return false, fmt.Errorf("error unmarshalling ticket event: %w", err)
+	}
+	return len(rows) > 0, nil
+}
+
+// ApiTicketRequest is a support ticket event, as accepted by POST /signals/tickets
+// and cmd/import.
+type ApiTicketRequest struct {
+	AccountID             string   `json:"account_id"`
+	TicketID              string   `json:"ticket_id"`
+	TicketText            string   `json:"ticket_text"`
+	Priority              string   `json:"priority,omitempty"`
+	TimeToResolutionHours *float64 `json:"time_to_resolution_hours,omitempty"`
+	ReopenCount           int      `json:"reopen_count,omitempty"`
+	EventAt               string   `json:"event_at,omitempty"`
+	ProductLine           string   `json:"product_line,omitempty"`
+}
+
+// TicketRequestSpec is the single definition of the /signals/tickets request body.
+var TicketRequestSpec = ObjectSpec{
+	Name:        "TicketRequest",
+	Description: "Support ticket event to enrich and score for churn risk.",
+	Fields: []FieldSpec{
+		{Name: "account_id", Type: "string", Required: true, MaxLength: 256,
+			Description: "Your stable identifier for the customer; the ticket counts towards this account's ticket signals.", Example: "acct_1234"},
+		{Name: "ticket_id", Type: "string", Required: true, MaxLength: 256,
+			Description: "Help desk ticket ID. Send one event per ticket update; the latest event per ticket is used for the account's ticket signals.", Example: "ZD-48213"},
+		{Name: "ticket_text", Type: "string", MaxLength: MaxFeedbackTextLength,
+			Description: "Ticket subject and description, or the latest customer message. PII is redacted before enrichment and storage.", Example: "Exports have been failing for a week and nobody has replied."},
+		{Name: "priority", Type: "string", Enum: TicketPriorities, Description: "Help desk priority. Defaults to \"normal\".", Example: TicketPriorityHigh},
+		{Name: "time_to_resolution_hours", Type: "number", Minimum: floatPtr(0),
+			Description: "Hours from creation to resolution; omit while the ticket is open.", Example: 96.5},
+		{Name: "reopen_count", Type: "integer", Minimum: floatPtr(0), Description: "Times the ticket was reopened.", Example: 1},
+		{Name: "event_at", Type: "string", MaxLength: 64,
+			Description: "RFC 3339 time of the ticket event. Defaults to now; with ticket_id it identifies the event, and a repeated event is rejected with 409 conflict.", Example: "2026-10-01T09:30:00Z"},
+		{Name: "product_line", Type: "string", MaxLength: 64,
+			Description: "Product line whose topic taxonomy is used. Defaults to \"default\".", Example: "default"},
+	},
+}
+
+// DecodeTicketRequest validates a /signals/tickets body against TicketRequestSpec and decodes it.
+func DecodeTicketRequest(body io.Reader) (ApiTicketRequest, *APIError) {
+	var req ApiTicketRequest
+	if apiErr := DecodeJSONBody(body, TicketRequestSpec, &req); apiErr != nil {
+		return req, apiErr
+	}
+	if strings.TrimSpace(req.AccountID) == "" {
+		return req, NewValidationError("account_id", FieldErrInvalidValue, "account_id must not be empty")
+	}
+	if strings.TrimSpace(req.TicketID) == "" {
+		return req, NewValidationError("ticket_id", FieldErrInvalidValue, "ticket_id must not be empty")
+	}
+	if req.EventAt != "" {
+		if _, err := time.Parse(time.RFC3339, req.EventAt); err != nil {
+			return req, NewValidationError("event_at", FieldErrInvalidValue, "event_at must be an RFC 3339 time")
+		}
+	}
+	if req.Priority == "" {
+		req.Priority = TicketPriorityNormal
+	}
+	if req.ProductLine == "" {
+		req.ProductLine = DefaultProductLine
+	}
+	if !TopicTaxonomy.HasProductLine(req.ProductLine) {
+		return req, NewValidationError("product_line", FieldErrInvalidValue,
+			fmt.Sprintf("product_line must be one of %v", TopicTaxonomy.ProductLineNames()))
+	}
+	return req, nil
+}
+
+// ProcessTicket runs the prediction pipeline for a validated ticket event: PII
+// redaction, the same enrichment as survey feedback, a lookup of the account's
+// ticket signals and churn scoring. The event is stored as a customer_feedback
+// row with source 'ticket' and created_at set to event_at. It returns
+// ErrTicketEventExists if the event is already stored, including when a
+// concurrent request stores it first (idx_customer_feedback_ticket_events is
+// unique).
+func ProcessTicket(req ApiTicketRequest) (ApiResponse, error) {
+	eventAt := time.Now().UTC()
+	if req.EventAt != "" {
+		parsed, err := time.Parse(time.RFC3339, req.EventAt)
+		if err != nil {
+			return ApiResponse{}, fmt.Errorf("invalid event_at: %w", err)
+		}
+		eventAt = parsed.UTC()
+	}
+	exists, err := TicketEventExists(req.TicketID, eventAt)
+	if err != nil {
+		return ApiResponse{}, err
+	}
+	if exists {
+		return ApiResponse{}, ErrTicketEventExists
+	}
+
+	ticketText, redactions := FeedbackRedactor.Redact(req.TicketText)
+	if len(redactions) > 0 {
+		log.Printf("Redacted PII from ticket text: %+v", redactions)
+	}
+	customerData := EnrichFeedback(CustomerData{
+		AccountID:   req.AccountID,
+		Feedback:    ticketText,
+		CreatedAt:   eventAt,
+		ProductLine: req.ProductLine,
+		Redactions:  redactions,
+		Source:      SignalSourceTicket,
+		Ticket: &TicketDetails{
+			TicketID:              req.TicketID,
+			Priority:              req.Priority,
+			TimeToResolutionHours: req.TimeToResolutionHours,
+			ReopenCount:           req.ReopenCount,
+			EventAt:               eventAt,
+		},
+	})
+	response, err := scoreAndStore(customerData)
+	if isUniqueViolation(err) {
+		return ApiResponse{}, ErrTicketEventExists
+	}
+	return response, err
+}
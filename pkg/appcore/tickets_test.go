@@ -0,0 +1,94 @@
+package appcore
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSummarizeTickets tests that each ticket counts once, with its latest event.
+func TestSummarizeTickets(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	slow, fast := 100.0, 4.0
+	events := []CustomerData{
+		{CommentSentiment: "NEGATIVE", Source: SignalSourceTicket, Ticket: &TicketDetails{TicketID: "T1", Priority: TicketPriorityUrgent, EventAt: start}},
+		{CommentSentiment: "POSITIVE", Source: SignalSourceTicket, Ticket: &TicketDetails{TicketID: "T1", Priority: TicketPriorityNormal, ReopenCount: 2, TimeToResolutionHours: &slow, EventAt: start.Add(time.Hour)}},
+		{CommentSentiment: "POSITIVE", Source: SignalSourceTicket, Ticket: &TicketDetails{TicketID: "T2", Priority: TicketPriorityHigh, TimeToResolutionHours: &fast, EventAt: start}},
+		{CommentSentiment: "NEGATIVE", NLSScore: 2},
+	}
+	got := SummarizeTickets(events)
+	want := TicketSignals{Tickets: 2, NegativeTickets: 1, UrgentTickets: 1, Reopens: 2, SlowResolutions: 1}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+// TestPredictChurn_Tickets tests the ticket rules and the negative ticket threshold.
+func TestPredictChurn_Tickets(t *testing.T) {
+	resolution := 96.0
+	tests := []struct {
+		name        string
+		data        CustomerData
+		probability float64
+	}{
+		{"escalated negative ticket", CustomerData{Source: SignalSourceTicket, CommentSentiment: "NEGATIVE",
+			Ticket: &TicketDetails{TicketID: "T1", Priority: TicketPriorityNormal, TimeToResolutionHours: &resolution}}, 0.8},
+		{"negative ticket", CustomerData{Source: SignalSourceTicket, CommentSentiment: "NEGATIVE",
+			Ticket: &TicketDetails{TicketID: "T1", Priority: TicketPriorityLow}}, 0.6},
+		{"neutral urgent ticket", CustomerData{Source: SignalSourceTicket, CommentSentiment: "NEUTRAL",
+			Ticket: &TicketDetails{TicketID: "T1", Priority: TicketPriorityUrgent}}, 0.4},
+		{"promoter with negative tickets", CustomerData{NLSScore: 9, CommentSentiment: "POSITIVE",
+			AccountSignals: &AccountSignals{Tickets: &TicketSignals{Tickets: 4, NegativeTickets: NegativeTicketThreshold}}}, 0.8},
+		{"promoter with few negative tickets", CustomerData{NLSScore: 9, CommentSentiment: "POSITIVE",
+			AccountSignals: &AccountSignals{Tickets: &TicketSignals{Tickets: 4, NegativeTickets: 1}}}, 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PredictChurn(tt.data); got.ChurnProbability != tt.probability {
+				t.Errorf("Expected probability %v, got %v (%s)", tt.probability, got.ChurnProbability, got.Reason)
+			}
+		})
+	}
+
+	features := ChurnFeatures(CustomerData{Source: SignalSourceTicket, Ticket: &TicketDetails{Priority: TicketPriorityHigh}})
+	if features["ticket"] != 1 || features["escalated_ticket"] != 1 || features["low_nls"] != 0 || features["nls_score"] != 0 {
+		t.Errorf("Unexpected ticket features %v", features)
+	}
+}
+
+// TestDecodeTicketRequest tests ticket validation and that ticket rows store a null nls_score.
+func TestDecodeTicketRequest(t *testing.T) {
+	req, apiErr := DecodeTicketRequest(strings.NewReader(`{"account_id": "acct_1", "ticket_id": "T1", "ticket_text": "Still broken", "reopen_count": 2}`))
+	if apiErr != nil {
+		t.Fatalf("Expected a valid request, got %v %+v", apiErr, apiErr.Errors)
+	}
+	if req.Priority != TicketPriorityNormal || req.ProductLine != DefaultProductLine || req.ReopenCount != 2 {
+		t.Errorf("Expected defaults to be applied, got %+v", req)
+	}
+
+	tests := []struct {
+		body  string
+		field string
+	}{
+		{`{"ticket_id": "T1"}`, "account_id"},
+		{`{"account_id": "acct_1", "ticket_id": "  "}`, "ticket_id"},
+		{`{"account_id": "acct_1", "ticket_id": "T1", "priority": "critical"}`, "priority"},
+		{`{"account_id": "acct_1", "ticket_id": "T1", "reopen_count": -1}`, "reopen_count"},
+		{`{"account_id": "acct_1", "ticket_id": "T1", "event_at": "yesterday"}`, "event_at"},
+	}
+	for _, tt := range tests {
+		if _, apiErr := DecodeTicketRequest(strings.NewReader(tt.body)); apiErr == nil || apiErr.Field != tt.field {
+			t.Errorf("Expected %s to fail on %s, got %v", tt.body, tt.field, apiErr)
+		}
+	}
+
+	row, err := json.Marshal(CustomerData{AccountID: "acct_1", Source: SignalSourceTicket, Ticket: &TicketDetails{TicketID: "T1"}})
+	if err != nil || !strings.Contains(string(row), `"nls_score":null`) || !strings.Contains(string(row), `"source":"ticket"`) {
+		t.Errorf("Expected a ticket row with a null nls_score, got %s (%v)", row, err)
+	}
+	row, _ = json.Marshal(CustomerData{NLSScore: 0})
+	if !strings.Contains(string(row), `"nls_score":0`) || !strings.Contains(string(row), `"source":"survey"`) {
+		t.Errorf("Expected a survey row with nls_score 0, got %s", row)
+	}
+}
@@ -0,0 +1,297 @@
+package appcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// MaxFeedbackTextLength is the longest feedback_text (in characters) accepted by /predict.
+const MaxFeedbackTextLength = 5000
+
+// maxRequestBodyBytes caps how much of a request body is read before validation.
+const maxRequestBodyBytes = 1 << 20
+
+// FieldSpec describes one JSON field. The same specs drive request validation
+// and the OpenAPI document served at /openapi.json, so the two cannot drift.
+type FieldSpec struct {
+	Name        string
+	Type        string // "string", "integer", "number", "boolean", "array" or "object"
+	Description string
+	Required    bool
+	Minimum     *float64
+	Maximum     *float64
+	MaxLength   int         // for strings, in characters; 0 means unlimited
+	Enum        []string    // allowed values for strings
+	Items       *FieldSpec  // element spec for arrays
+	Properties  []FieldSpec // nested fields for objects
+	Ref         string      // name of a component schema that describes the value (documentation only)
+	Example     interface{}
+}
+
+// ObjectSpec describes a JSON object such as a request or response body.
+type ObjectSpec struct {
+	Name        string
+	Description string
+	Fields      []FieldSpec
+}
+
+// FieldError is a validation failure for a single field. Code is one of the
+// FieldErr* constants.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+// PredictRequestSpec is the single definition of the /predict request body.
+var PredictRequestSpec = ObjectSpec{
+	Name:        "PredictRequest",
+	Description: "Customer survey response to score for churn risk.",
+	Fields: []FieldSpec{
+		{Name: "nls_score", Type: "integer", Required: true, Minimum: floatPtr(0), Maximum: floatPtr(10),
+			Description: "Net Promoter Score given by the customer.", Example: 8},
+		{Name: "feedback_text", Type: "string", MaxLength: MaxFeedbackTextLength,
+			Description: "Free-text comment. Optional and may be empty; PII is redacted before enrichment and storage.", Example: "Great service, very happy!"},
+		{Name: "account_id", Type: "string", MaxLength: 256,
+			Description: "Your stable identifier for the customer, used for data subject export and erasure.", Example: "acct_1234"},
+		{Name: "product_line", Type: "string", MaxLength: 64,
+			Description: "Product line whose topic taxonomy is used. Defaults to \"default\"; unknown product lines are rejected.", Example: "default"},
+		{Name: "callback_url", Type: "string", MaxLength: 2048,
+			Description: "Only with async=true: absolute http(s) URL on a public address that the finished job status is POSTed to. Rejected unless the server has a callback signing secret.", Example: "https://example.com/churn-callback"},
+	},
+}
+
+// PredictResponseSpec is the single definition of the /predict success response body.
+var PredictResponseSpec = ObjectSpec{
+	Name:        "PredictResponse",
+	Description: "Churn prediction for the stored feedback.",
+	Fields: []FieldSpec{
+		{Name: "customer_id", Type: "string", Required: true, Description: "ID of the stored customer_feedback row."},
+		{Name: "account_id", Type: "string", Description: "The account_id supplied with the request, if any."},
+		{Name: "churn_probability", Type: "number", Required: true, Minimum: floatPtr(0), Maximum: floatPtr(1), Example: 0.8,
+			Description: "Calibrated probability that the customer churns; equals raw_churn_probability when no calibration is loaded."},
+		{Name: "raw_churn_probability", Type: "number", Minimum: floatPtr(0), Maximum: floatPtr(1), Description: "Model output before calibration."},
+		{Name: "calibration_version", Type: "string", Description: "Version of the calibration applied, if any."},
+		{Name: "reason", Type: "string", Required: true, Description: "Human-readable explanation of the score."},
+		{Name: "comment_sentiment", Type: "string", Enum: []string{"POSITIVE", "NEGATIVE", "NEUTRAL", "UNKNOWN"}},
+		{Name: "comment_topics", Type: "array", Items: &FieldSpec{Type: "string"}},
+		{Name: "topic_categories", Type: "array", Items: &FieldSpec{Type: "string"}, Description: "Parent categories of the matched topics."},
+		{Name: "topic_sentiments", Type: "array", Description: "Sentiment of the clauses about each extracted topic.", Items: &FieldSpec{Type: "object", Properties: []FieldSpec{
+			{Name: "topic", Type: "string", Required: true, Example: "pricing"},
+			{Name: "sentiment", Type: "string", Required: true, Enum: []string{"POSITIVE", "NEGATIVE", "NEUTRAL"}, Example: "NEGATIVE"},
+			{Name: "score", Type: "number", Required: true, Minimum: floatPtr(0), Maximum: floatPtr(1), Example: 0.97},
+		}}},
+		{Name: "product_line", Type: "string", Description: "Product line whose taxonomy was applied."},
+		{Name: "taxonomy_version", Type: "string", Description: "Version of the topic taxonomy used for comment_topics."},
+		{Name: "language", Type: "string", Description: "Detected ISO 639-1 language of feedback_text, or \"und\" if undetermined.", Example: "es"},
+		{Name: "emotions", Type: "array", Items: &FieldSpec{Type: "string", Enum: EmotionLabels}, Description: "Emotions detected in the feedback."},
+		{Name: "cancel_intent", Type: "boolean", Required: true, Description: "Explicit cancellation or refund language was found; it overrides the NLS-based score."},
+		{Name: "urgency", Type: "string", Enum: []string{UrgencyNone, UrgencyMedium, UrgencyHigh}, Description: "How soon the customer may leave, from cancellation and deadline language."},
+		{Name: "model_name", Type: "string", Required: true, Description: "Model that produced churn_probability.", Example: RulesModelName},
+		{Name: "model_version", Type: "string", Required: true, Description: "Version of that model; predictions are only comparable within a version.", Example: RulesModelVersion},
+		{Name: "rules_version", Type: "string", Description: "Version of the negative keyword set used by the rules."},
+		{Name: "sentiment_model", Type: "string", Description: "Hugging Face model used for sentiment."},
+		{Name: "zero_shot_model", Type: "string", Description: "Hugging Face zero-shot model used for topics and emotions."},
+	},
+}
+
+// JobAcceptedSpec is the 202 response to POST /predict?async=true.
+var JobAcceptedSpec = ObjectSpec{
+	Name:        "JobAccepted",
+	Description: "The feedback was stored as a job; poll status_url or wait for the callback.",
+	Fields: []FieldSpec{
+		{Name: "job_id", Type: "string", Required: true, Example: "6f1c2a3b-4d5e-4f60-8a7b-9c0d1e2f3a4b"},
+		{Name: "status", Type: "string", Required: true, Enum: []string{JobStatusQueued}},
+		{Name: "status_url", Type: "string", Required: true, Example: "/jobs/6f1c2a3b-4d5e-4f60-8a7b-9c0d1e2f3a4b"},
+	},
+}
+
+// JobStatusSpec is the GET /jobs/{id} response and the callback body.
+var JobStatusSpec = ObjectSpec{
+	Name:        "JobStatus",
+	Description: "Status of an async prediction job.",
+	Fields: []FieldSpec{
+		{Name: "job_id", Type: "string", Required: true},
+		{Name: "status", Type: "string", Required: true, Enum: []string{JobStatusQueued, JobStatusRunning, JobStatusSucceeded, JobStatusFailed}},
+		{Name: "attempts", Type: "integer", Required: true, Description: "Processing attempts so far; failed attempts are retried."},
+		{Name: "created_at", Type: "string", Required: true},
+		{Name: "started_at", Type: "string", Description: "When the latest attempt started."},
+		{Name: "completed_at", Type: "string", Description: "When the job succeeded or failed."},
+		{Name: "result", Type: "object", Ref: PredictResponseSpec.Name, Description: "The prediction, once the job has succeeded."},
+		{Name: "error", Type: "string", Description: "Why the job failed, once it has failed."},
+	},
+}
+
+// ValidateJSON checks a JSON body against the spec: it must be an object, may
+// only contain known fields, and every field must match its type and constraints.
+// A non-nil error means the body is not valid JSON at all.
+func (s ObjectSpec) ValidateJSON(body []byte) ([]FieldError, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("request body is not a JSON object: %w", err)
+	}
+	if fields == nil {
+		return nil, fmt.Errorf("request body is not a JSON object")
+	}
+	return validateObject("", s.Fields, fields), nil
+}
+
+func validateObject(prefix string, specs []FieldSpec, fields map[string]json.RawMessage) []FieldError {
+	var errs []FieldError
+	known := map[string]bool{}
+	for _, spec := range specs {
+		known[spec.Name] = true
+		raw, present := fields[spec.Name]
+		if !present || string(raw) == "null" {
+			if spec.Required {
+				errs = append(errs, FieldError{Field: prefix + spec.Name, Code: FieldErrRequired, Message: spec.Name + " is required"})
+			}
+			continue
+		}
+		errs = append(errs, validateValue(prefix+spec.Name, spec, raw)...)
+	}
+
+	// Report unknown fields in a stable order.
+	var unknown []string
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, FieldError{Field: prefix + name, Code: FieldErrUnknownField, Message: "unknown field"})
+	}
+	return errs
+}
+
+func validateValue(path string, spec FieldSpec, raw json.RawMessage) []FieldError {
+	fail := func(code, format string, args ...interface{}) []FieldError {
+		return []FieldError{{Field: path, Code: code, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	switch spec.Type {
+	case "string":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fail(FieldErrInvalidType, "%s must be a string", spec.Name)
+		}
+		if spec.MaxLength > 0 && utf8.RuneCountInString(v) > spec.MaxLength {
+			return fail(FieldErrTooLong, "%s must be at most %d characters", spec.Name, spec.MaxLength)
+		}
+		if len(spec.Enum) > 0 && !containsString(spec.Enum, v) {
+			return fail(FieldErrInvalidValue, "%s must be one of %v", spec.Name, spec.Enum)
+		}
+	case "integer", "number":
+		var v float64
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fail(FieldErrInvalidType, "%s must be a number", spec.Name)
+		}
+		if spec.Type == "integer" && v != math.Trunc(v) {
+			return fail(FieldErrInvalidType, "%s must be an integer", spec.Name)
+		}
+		belowMin := spec.Minimum != nil && v < *spec.Minimum
+		aboveMax := spec.Maximum != nil && v > *spec.Maximum
+		switch {
+		case (belowMin || aboveMax) && spec.Minimum != nil && spec.Maximum != nil:
+			return fail(FieldErrOutOfRange, "%s must be between %g and %g", spec.Name, *spec.Minimum, *spec.Maximum)
+		case belowMin:
+			return fail(FieldErrOutOfRange, "%s must be at least %g", spec.Name, *spec.Minimum)
+		case aboveMax:
+			return fail(FieldErrOutOfRange, "%s must be at most %g", spec.Name, *spec.Maximum)
+		}
+	case "boolean":
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fail(FieldErrInvalidType, "%s must be a boolean", spec.Name)
+		}
+	case "array":
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return fail(FieldErrInvalidType, "%s must be an array", spec.Name)
+		}
+		var errs []FieldError
+		if spec.Items != nil {
+			for i, item := range items {
+				errs = append(errs, validateValue(fmt.Sprintf("%s[%d]", path, i), *spec.Items, item)...)
+			}
+		}
+		return errs
+	case "object":
+		var nested map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &nested); err != nil || nested == nil {
+			return fail(FieldErrInvalidType, "%s must be an object", spec.Name)
+		}
+		return validateObject(path+".", spec.Properties, nested)
+	}
+	return nil
+}
+
+func containsString(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadRequestBody reads at most maxRequestBodyBytes of a request body.
+func ReadRequestBody(body io.Reader) ([]byte, *APIError) {
+	data, err := io.ReadAll(io.LimitReader(body, maxRequestBodyBytes+1))
+	if err != nil {
+		return nil, NewAPIError(ErrInvalidJSON, "Could not read request body.")
+	}
+	if len(data) > maxRequestBodyBytes {
+		return nil, NewAPIError(ErrPayloadTooLarge, fmt.Sprintf("Request body exceeds %d bytes.", maxRequestBodyBytes))
+	}
+	return data, nil
+}
+
+// DecodeJSONBody validates a request body against spec and decodes it into dst.
+// It returns an invalid_json, payload_too_large or validation_failed problem on failure.
+func DecodeJSONBody(body io.Reader, spec ObjectSpec, dst interface{}) *APIError {
+	data, apiErr := ReadRequestBody(body)
+	if apiErr != nil {
+		return apiErr
+	}
+	fieldErrs, err := spec.ValidateJSON(data)
+	if err != nil {
+		return NewAPIError(ErrInvalidJSON, "Invalid JSON request body.")
+	}
+	if len(fieldErrs) > 0 {
+		apiErr := NewValidationError(fieldErrs[0].Field, fieldErrs[0].Code, fieldErrs[0].Message)
+		apiErr.Errors = fieldErrs
+		return apiErr
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return NewAPIError(ErrInvalidJSON, "Invalid JSON request body.")
+	}
+	return nil
+}
+
+// DecodePredictRequest validates a /predict body against PredictRequestSpec and decodes it.
+// product_line is checked against the loaded TopicTaxonomy.
+func DecodePredictRequest(body io.Reader) (ApiPredictRequest, *APIError) {
+	var req ApiPredictRequest
+	if apiErr := DecodeJSONBody(body, PredictRequestSpec, &req); apiErr != nil {
+		return req, apiErr
+	}
+	if req.ProductLine == "" {
+		req.ProductLine = DefaultProductLine
+	}
+	if !TopicTaxonomy.HasProductLine(req.ProductLine) {
+		return req, NewValidationError("product_line", FieldErrInvalidValue,
+			fmt.Sprintf("product_line must be one of %v", TopicTaxonomy.ProductLineNames()))
+	}
+	return req, nil
+}
@@ -0,0 +1,67 @@
+package appcore
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDecodePredictRequest_Valid tests that a valid body decodes, including empty feedback text.
+func TestDecodePredictRequest_Valid(t *testing.T) {
+	req, apiErr := DecodePredictRequest(strings.NewReader(`{"nls_score": 7, "feedback_text": "", "account_id": "acct_1"}`))
+	if apiErr != nil {
+		t.Fatalf("Expected valid request, got %v %+v", apiErr, apiErr.Errors)
+	}
+	if req.NLSScore == nil || *req.NLSScore != 7 || req.AccountID != "acct_1" {
+		t.Errorf("Expected nls_score 7 and account_id acct_1, got %+v", req)
+	}
+}
+
+// TestDecodePredictRequest_FieldErrors tests field-level error codes for every kind of invalid input.
+func TestDecodePredictRequest_FieldErrors(t *testing.T) {
+	testCases := []struct {
+		body          string
+		expectedField string
+		expectedCode  string
+		expectedMsg   string
+	}{
+		{`{"feedback_text": "hi"}`, "nls_score", FieldErrRequired, "nls_score is required"},
+		{`{"nls_score": null}`, "nls_score", FieldErrRequired, "nls_score is required"},
+		{`{"nls_score": 11}`, "nls_score", FieldErrOutOfRange, "nls_score must be between 0 and 10"},
+		{`{"nls_score": 7.5}`, "nls_score", FieldErrInvalidType, "nls_score must be an integer"},
+		{`{"nls_score": "7"}`, "nls_score", FieldErrInvalidType, "nls_score must be a number"},
+		{`{"nls_score": 7, "feedback_text": 5}`, "feedback_text", FieldErrInvalidType, "feedback_text must be a string"},
+		{`{"nls_score": 7, "feedback_text": "` + strings.Repeat("a", MaxFeedbackTextLength+1) + `"}`, "feedback_text", FieldErrTooLong, "feedback_text must be at most 5000 characters"},
+		{`{"nls_score": 7, "score": 7}`, "score", FieldErrUnknownField, "unknown field"},
+	}
+	for _, tc := range testCases {
+		_, apiErr := DecodePredictRequest(strings.NewReader(tc.body))
+		if apiErr == nil || apiErr.Code != ErrValidationFailed.Code || apiErr.Status != 400 {
+			t.Errorf("Body %.40s: expected a 400 validation_failed problem, got %v", tc.body, apiErr)
+			continue
+		}
+		if apiErr.Field != tc.expectedField || len(apiErr.Errors) != 1 {
+			t.Errorf("Body %.40s: expected a single error on %s, got field %q errors %+v", tc.body, tc.expectedField, apiErr.Field, apiErr.Errors)
+			continue
+		}
+		if fe := apiErr.Errors[0]; fe.Code != tc.expectedCode || fe.Message != tc.expectedMsg {
+			t.Errorf("Body %.40s: expected {%s %s}, got %+v", tc.body, tc.expectedCode, tc.expectedMsg, fe)
+		}
+	}
+
+	_, apiErr := DecodePredictRequest(strings.NewReader(`{"nls_score": 5, "feedback_text": "Test", }`))
+	if apiErr == nil || apiErr.Code != ErrInvalidJSON.Code {
+		t.Errorf("Expected malformed JSON to return invalid_json, got %v", apiErr)
+	}
+}
+
+// TestDecodePredictRequest_ProductLine tests the default and unknown product lines.
+func TestDecodePredictRequest_ProductLine(t *testing.T) {
+	req, apiErr := DecodePredictRequest(strings.NewReader(`{"nls_score": 5}`))
+	if apiErr != nil || req.ProductLine != DefaultProductLine {
+		t.Errorf("Expected default product line, got %q (%v)", req.ProductLine, apiErr)
+	}
+	_, apiErr = DecodePredictRequest(strings.NewReader(`{"nls_score": 5, "product_line": "hardware"}`))
+	if apiErr == nil || apiErr.Field != "product_line" || apiErr.Errors[0].Code != FieldErrInvalidValue {
+		t.Errorf("Expected invalid_value for unknown product line, got %+v", apiErr)
+	}
+}
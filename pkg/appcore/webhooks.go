@@ -0,0 +1,565 @@
+package appcore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Webhook headers sent with every delivery.
+const (
+	WebhookSignatureHeader = "X-Churn-Signature" // t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">
+	WebhookEventIDHeader   = "X-Churn-Event-ID"  // stable across retries, for de-duplication
+)
+
+// WebhookEventHighRisk is the event type sent for predictions matching a subscription.
+const WebhookEventHighRisk = "prediction.high_risk"
+
+// Delivery states of webhook_deliveries rows.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookDead      = "dead"
+)
+
+// WebhookConfig controls delivery timeouts and retries. Configured by InitClients.
+type WebhookConfig struct {
+	Timeout      time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration // wait before the 2nd attempt; each later wait is 4x longer
+	PollInterval time.Duration // how often the standalone server looks for due deliveries
+	BatchSize    int           // due deliveries sent per poll
+}
+
+// WebhookSettings are the delivery settings in use.
+var WebhookSettings = DefaultWebhookSettings()
+
+// DefaultWebhookSettings returns the settings used when no WEBHOOK_* variables are set.
+func DefaultWebhookSettings() WebhookConfig {
+	return WebhookConfig{Timeout: 5 * time.Second, MaxAttempts: 5, BaseBackoff: 30 * time.Second, PollInterval: 2 * time.Second, BatchSize: 100}
+}
+
+// ClaimLease is how long a claimed delivery stays invisible to other workers:
+// long enough for one attempt, after which an unfinished claim (e.g. a crashed
+// worker) becomes due again.
+func (c WebhookConfig) ClaimLease() time.Duration {
+	return 2*c.Timeout + 30*time.Second
+}
+
+// Backoff returns how long to wait after the given (1-based) failed attempt.
+func (c WebhookConfig) Backoff(attempt int) time.Duration {
+	return time.Duration(float64(c.BaseBackoff) * math.Pow(4, float64(attempt-1)))
+}
+
+// WebhookRule matches predictions by overall comment sentiment and topics. Empty
+// fields match anything; every listed topic must be among the comment topics.
+type WebhookRule struct {
+	Sentiment string   `json:"sentiment,omitempty"`
+	Topics    []string `json:"topics,omitempty"`
+}
+
+// WebhookSubscription is a row of webhook_subscriptions: where to send
+// predictions and which predictions to send.
+type WebhookSubscription struct {
+	ID                  string        `json:"id,omitempty"`
+	URL                 string        `json:"url"`
+	Secret              string        `json:"secret,omitempty"` // only returned when the subscription is created
+	Description         string        `json:"description,omitempty"`
+	MinChurnProbability *float64      `json:"min_churn_probability,omitempty"`
+	Rules               []WebhookRule `json:"rules,omitempty"`
+	Active              bool          `json:"active"`
+	CreatedAt           time.Time     `json:"created_at,omitempty"`
+}
+
+// PredictionEventData is the prediction sent in a webhook event.
+type PredictionEventData struct {
+	CustomerID       string           `json:"customer_id"`
+	AccountID        string           `json:"account_id,omitempty"`
+	ChurnProbability float64          `json:"churn_probability"`
+	Reason           string           `json:"reason"`
+	ModelName        string           `json:"model_name,omitempty"`
+	ModelVersion     string           `json:"model_version,omitempty"`
+	CommentSentiment string           `json:"comment_sentiment,omitempty"`
+	CommentTopics    []string         `json:"comment_topics,omitempty"`
+	TopicSentiments  []TopicSentiment `json:"topic_sentiments,omitempty"`
+	CancelIntent     bool             `json:"cancel_intent"`
+	Urgency          string           `json:"urgency,omitempty"`
+	PredictedAt      time.Time        `json:"predicted_at"`
+}
+
+// WebhookEvent is the JSON body POSTed to a subscription.
+type WebhookEvent struct {
+	ID        string              `json:"id"`
+	Type      string              `json:"type"`
+	CreatedAt time.Time           `json:"created_at"`
+	Matched   []string            `json:"matched"` // why the subscription matched, e.g. "min_churn_probability"
+	Data      PredictionEventData `json:"data"`
+}
+
+// NewPredictionEventData builds the event data of a stored prediction.
+func NewPredictionEventData(data CustomerData, prediction ChurnPrediction) PredictionEventData {
+	return PredictionEventData{
+		CustomerID:       prediction.CustomerID,
+		AccountID:        data.AccountID,
+		ChurnProbability: prediction.ChurnProbability,
+		Reason:           prediction.Reason,
+		ModelName:        prediction.ModelName,
+		ModelVersion:     prediction.ModelVersion,
+		CommentSentiment: data.CommentSentiment,
+		CommentTopics:    data.CommentTopics,
+		TopicSentiments:  data.TopicSentiments,
+		CancelIntent:     data.CancelIntent,
+		Urgency:          data.Urgency,
+		PredictedAt:      prediction.PredictedAt,
+	}
+}
+
+// Match returns why the subscription matches event, or nil if it does not.
+func (s WebhookSubscription) Match(event PredictionEventData) []string {
+	var matched []string
+	if s.MinChurnProbability != nil && event.ChurnProbability >= *s.MinChurnProbability {
+		matched = append(matched, "min_churn_probability")
+	}
+	for i, rule := range s.Rules {
+		if rule.Sentiment != "" && !strings.EqualFold(rule.Sentiment, event.CommentSentiment) {
+			continue
+		}
+		if len(intersectStrings(rule.Topics, event.CommentTopics)) < len(rule.Topics) {
+			continue
+		}
+		matched = append(matched, "rules["+strconv.Itoa(i)+"]")
+	}
+	return matched
+}
+
+// SignWebhookPayload returns the WebhookSignatureHeader value for body.
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks a WebhookSignatureHeader value as a receiver
+// would, rejecting signatures older than tolerance.
+func VerifyWebhookSignature(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			switch k {
+			case "t":
+				t = v
+			case "v1":
+				v1 = v
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || v1 == "" {
+		return fmt.Errorf("malformed webhook signature header")
+	}
+	timestamp := time.Unix(unix, 0)
+	if now.Sub(timestamp) > tolerance || timestamp.Sub(now) > tolerance {
+		return fmt.Errorf("webhook signature timestamp outside tolerance")
+	}
+	expected := SignWebhookPayload(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte("t="+t+",v1="+v1)) {
+		return fmt.Errorf("webhook signature mismatch")
+	}
+	return nil
+}
+
+// PostWebhook sends one signed delivery and returns the response status. Any
+// non-2xx status is an error. Subscription URLs are user-supplied, so it uses
+// NewCallbackClient: only public addresses, no redirects.
+func PostWebhook(url, secret, eventID string, body []byte, timeout time.Duration) (int, error) {
+	return postWebhook(NewCallbackClient(timeout), url, secret, eventID, body)
+}
+
+// postWebhook is PostWebhook with the given client.
+func postWebhook(client *http.Client, url, secret, eventID string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventIDHeader, eventID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, time.Now(), body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return resp.StatusCode, nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating random ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// --- Subscriptions ---
+
+// CreateWebhookSubscription stores an active subscription, generating a secret
+// if none is given, and returns it including the secret.
+func CreateWebhookSubscription(sub WebhookSubscription) (WebhookSubscription, error) {
+	if SupabaseClient == nil {
+		return sub, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	if sub.Secret == "" {
+		sub.Secret = "whsec_" + randomHex(24)
+	}
+	sub.Active = true
+	sub.CreatedAt = time.Now()
+	rawData, _, err := SupabaseClient.From("webhook_subscriptions").Insert(sub, false, "", "", "").Execute()
+	if err != nil {
+		return sub, fmt.Errorf("error storing webhook subscription: %w", err)
+	}
+	var rows []WebhookSubscription
+	if err := json.Unmarshal(rawData, &rows); err != nil || len(rows) == 0 {
+		return sub, fmt.Errorf("error unmarshalling webhook subscription: %v", err)
+	}
+	return rows[0], nil
+}
+
+// FetchWebhookSubscriptions returns the subscriptions, only active ones if activeOnly is set.
+func FetchWebhookSubscriptions(activeOnly bool) ([]WebhookSubscription, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	query := SupabaseClient.From("webhook_subscriptions").Select("*", "", false)
+	if activeOnly {
+		query = query.Eq("active", "true")
+	}
+	rawData, _, err := query.Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error selecting webhook subscriptions: %w", err)
+	}
+	var subs []WebhookSubscription
+	if err := json.Unmarshal(rawData, &subs); err != nil {
+		return nil, fmt.Errorf("error unmarshalling webhook subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// DeactivateWebhookSubscription stops deliveries to a subscription; its delivery log is kept.
+func DeactivateWebhookSubscription(id string) error {
+	if SupabaseClient == nil {
+		return fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	update := map[string]interface{}{"active": false}
+	if _, _, err := SupabaseClient.From("webhook_subscriptions").Update(update, "minimal", "").Eq("id", id).Execute(); err != nil {
+		return fmt.Errorf("error deactivating webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// --- Deliveries ---
+
+// WebhookDelivery is a row of webhook_deliveries: one event for one subscription,
+// with its retry state.
+type WebhookDelivery struct {
+	ID             string          `json:"id,omitempty"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookAttempt is a row of webhook_delivery_attempts, the delivery log.
+type WebhookAttempt struct {
+	DeliveryID     string    `json:"delivery_id"`
+	SubscriptionID string    `json:"subscription_id"`
+	EventID        string    `json:"event_id"`
+	Attempt        int       `json:"attempt"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMS     int64     `json:"duration_ms"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+// WebhookDeadLetter is a row of webhook_dead_letters: a delivery that ran out of attempts.
+type WebhookDeadLetter struct {
+	DeliveryID     string          `json:"delivery_id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	URL            string          `json:"url"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error,omitempty"`
+	DeadAt         time.Time       `json:"dead_at"`
+}
+
+// predictionWebhookDeliveries builds a pending webhook delivery for every
+// active subscription the prediction matches. Nothing is sent or stored here:
+// StoreFeedbackWithPredictions queues the deliveries in the same transaction as
+// the prediction, and DeliverDueWebhooks (the standalone server's delivery loop
+// or cmd/webhooks) sends them, so /predict latency doesn't grow with the number
+// of subscribers. Shadow predictions are never sent.
+func predictionWebhookDeliveries(data CustomerData, prediction ChurnPrediction) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	if prediction.Shadow {
+		return deliveries, nil
+	}
+	subs, err := FetchWebhookSubscriptions(true)
+	if err != nil {
+		return nil, err
+	}
+	event := NewPredictionEventData(data, prediction)
+	now := time.Now()
+	for _, sub := range subs {
+		matched := sub.Match(event)
+		if len(matched) == 0 {
+			continue
+		}
+		eventID := "evt_" + randomHex(12)
+		payload, err := json.Marshal(WebhookEvent{ID: eventID, Type: WebhookEventHighRisk, CreatedAt: now.UTC(), Matched: matched, Data: event})
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling webhook event: %w", err)
+		}
+		// The ID is set here because jsonb_populate_recordset doesn't apply column defaults.
+		deliveries = append(deliveries, WebhookDelivery{ID: NewUUID(), SubscriptionID: sub.ID, EventID: eventID, EventType: WebhookEventHighRisk, Payload: payload, Status: WebhookPending, NextAttemptAt: &now, CreatedAt: now})
+	}
+	return deliveries, nil
+}
+
+// claimWebhookDelivery takes a due delivery for one attempt by moving its
+// next_attempt_at past the claim lease. The update only matches while the
+// delivery is still pending and due, so when two workers race for it exactly
+// one gets the row back.
+func claimWebhookDelivery(delivery WebhookDelivery, now time.Time, cfg WebhookConfig) (bool, error) {
+	update := map[string]interface{}{"next_attempt_at": now.Add(cfg.ClaimLease())}
+	rawData, _, err := SupabaseClient.From("webhook_deliveries").Update(update, "representation", "").
+		Eq("id", delivery.ID).Eq("status", WebhookPending).Lte("next_attempt_at", now.UTC().Format(time.RFC3339Nano)).
+		Execute()
+	if err != nil {
+		return false, fmt.Errorf("error claiming webhook delivery %s: %w", delivery.ID, err)
+	}
+	var rows []WebhookDelivery
+	if err := json.Unmarshal(rawData, &rows); err != nil {
+		return false, fmt.Errorf("error unmarshalling claimed webhook delivery %s: %w", delivery.ID, err)
+	}
+	return len(rows) == 1, nil
+}
+
+// attemptWebhookDelivery makes one attempt, logs it and moves the delivery to
+// delivered, back to pending with a backoff, or to the dead-letter table.
+func attemptWebhookDelivery(delivery WebhookDelivery, sub WebhookSubscription, cfg WebhookConfig) error {
+	started := time.Now()
+	status, sendErr := PostWebhook(sub.URL, sub.Secret, delivery.EventID, delivery.Payload, cfg.Timeout)
+	delivery.Attempts++
+
+	attempt := WebhookAttempt{
+		DeliveryID: delivery.ID, SubscriptionID: sub.ID, EventID: delivery.EventID, Attempt: delivery.Attempts,
+		StatusCode: status, DurationMS: time.Since(started).Milliseconds(), AttemptedAt: started,
+	}
+	if sendErr != nil {
+		attempt.Error = sendErr.Error()
+	}
+	if _, _, err := SupabaseClient.From("webhook_delivery_attempts").Insert(attempt, false, "", "minimal", "").Execute(); err != nil {
+		log.Printf("Error logging webhook attempt for delivery %s: %v", delivery.ID, err)
+	}
+
+	switch {
+	case sendErr == nil:
+		update := map[string]interface{}{"status": WebhookDelivered, "attempts": delivery.Attempts, "last_status_code": status, "last_error": nil, "delivered_at": time.Now(), "next_attempt_at": nil}
+		if _, _, err := SupabaseClient.From("webhook_deliveries").Update(update, "minimal", "").Eq("id", delivery.ID).Execute(); err != nil {
+			return fmt.Errorf("error updating webhook delivery %s: %w", delivery.ID, err)
+		}
+		return nil
+	case delivery.Attempts >= cfg.MaxAttempts:
+		delivery.LastStatusCode = status
+		if err := deadLetterWebhookDelivery(delivery, sub, attempt.Error); err != nil {
+			return err
+		}
+	default:
+		update := map[string]interface{}{"attempts": delivery.Attempts, "last_status_code": status, "last_error": attempt.Error, "next_attempt_at": time.Now().Add(cfg.Backoff(delivery.Attempts))}
+		if _, _, err := SupabaseClient.From("webhook_deliveries").Update(update, "minimal", "").Eq("id", delivery.ID).Execute(); err != nil {
+			return fmt.Errorf("error updating webhook delivery %s: %w", delivery.ID, err)
+		}
+	}
+	return sendErr
+}
+
+// deadLetterWebhookDelivery copies a delivery to webhook_dead_letters and marks it dead.
+func deadLetterWebhookDelivery(delivery WebhookDelivery, sub WebhookSubscription, lastError string) error {
+	deadLetter := WebhookDeadLetter{
+		DeliveryID: delivery.ID, SubscriptionID: delivery.SubscriptionID, EventID: delivery.EventID, URL: sub.URL,
+		Payload: delivery.Payload, Attempts: delivery.Attempts, LastError: lastError, DeadAt: time.Now(),
+	}
+	if _, _, err := SupabaseClient.From("webhook_dead_letters").Insert(deadLetter, false, "", "minimal", "").Execute(); err != nil {
+		return fmt.Errorf("error dead-lettering webhook delivery %s: %w", delivery.ID, err)
+	}
+	update := map[string]interface{}{"status": WebhookDead, "attempts": delivery.Attempts, "last_status_code": delivery.LastStatusCode, "last_error": lastError, "next_attempt_at": nil}
+	if _, _, err := SupabaseClient.From("webhook_deliveries").Update(update, "minimal", "").Eq("id", delivery.ID).Execute(); err != nil {
+		return fmt.Errorf("error updating webhook delivery %s: %w", delivery.ID, err)
+	}
+	log.Printf("Webhook delivery %s (event %s) dead-lettered after %d attempts: %s", delivery.ID, delivery.EventID, delivery.Attempts, lastError)
+	return nil
+}
+
+// WebhookRetryReport summarizes a DeliverDueWebhooks run.
+type WebhookRetryReport struct {
+	Due       int `json:"due"`
+	Claimed   int `json:"claimed"` // due deliveries not taken by another worker first
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"` // still pending or dead-lettered
+}
+
+// DeliverDueWebhooks sends up to limit pending deliveries whose next attempt is
+// due: new deliveries queued by StoreFeedbackWithPredictions and retries after a backoff.
+// Each delivery is claimed before it is sent, so concurrent runs never send
+// the same attempt twice. Deliveries to deactivated subscriptions are
+// dead-lettered without another attempt.
+func DeliverDueWebhooks(now time.Time, limit int) (WebhookRetryReport, error) {
+	report := WebhookRetryReport{}
+	if SupabaseClient == nil {
+		return report, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	rawData, _, err := SupabaseClient.From("webhook_deliveries").Select("*", "", false).
+		Eq("status", WebhookPending).Lte("next_attempt_at", now.UTC().Format(time.RFC3339Nano)).
+		Order("next_attempt_at", &postgrest.OrderOpts{Ascending: true}).Limit(limit, "").Execute()
+	if err != nil {
+		return report, fmt.Errorf("error selecting due webhook deliveries: %w", err)
+	}
+	var deliveries []WebhookDelivery
+	if err := json.Unmarshal(rawData, &deliveries); err != nil {
+		return report, fmt.Errorf("error unmarshalling webhook deliveries: %w", err)
+	}
+	subs, err := FetchWebhookSubscriptions(false)
+	if err != nil {
+		return report, err
+	}
+	byID := map[string]WebhookSubscription{}
+	for _, sub := range subs {
+		byID[sub.ID] = sub
+	}
+
+	report.Due = len(deliveries)
+	for _, delivery := range deliveries {
+		claimed, err := claimWebhookDelivery(delivery, now, WebhookSettings)
+		if err != nil {
+			return report, err
+		}
+		if !claimed {
+			continue
+		}
+		report.Claimed++
+		sub, ok := byID[delivery.SubscriptionID]
+		if !ok || !sub.Active {
+			report.Failed++
+			if err := deadLetterWebhookDelivery(delivery, sub, "subscription deactivated"); err != nil {
+				return report, err
+			}
+			continue
+		}
+		if err := attemptWebhookDelivery(delivery, sub, WebhookSettings); err != nil {
+			report.Failed++
+			continue
+		}
+		report.Delivered++
+	}
+	return report, nil
+}
+
+// RunWebhookDeliveries sends due deliveries every PollInterval until stop is
+// closed. A full batch is followed immediately by the next one.
+func (c WebhookConfig) RunWebhookDeliveries(stop <-chan struct{}) {
+	log.Println("Webhook delivery loop started.")
+	for {
+		report, err := DeliverDueWebhooks(time.Now(), c.BatchSize)
+		if err != nil {
+			log.Printf("Webhook deliveries: %v", err)
+		}
+		wait := c.PollInterval
+		if err == nil && report.Due == c.BatchSize {
+			wait = 0
+		}
+		select {
+		case <-stop:
+			log.Println("Webhook delivery loop stopped.")
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ConfigureWebhooks sets WebhookSettings from WEBHOOK_TIMEOUT, WEBHOOK_MAX_ATTEMPTS,
+// WEBHOOK_RETRY_BACKOFF and WEBHOOK_POLL_INTERVAL.
+func ConfigureWebhooks() error {
+	cfg := DefaultWebhookSettings()
+	for _, setting := range []struct {
+		name string
+		dest *time.Duration
+	}{{"WEBHOOK_TIMEOUT", &cfg.Timeout}, {"WEBHOOK_RETRY_BACKOFF", &cfg.BaseBackoff}, {"WEBHOOK_POLL_INTERVAL", &cfg.PollInterval}} {
+		if raw := strings.TrimSpace(os.Getenv(setting.name)); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("%s must be a positive Go duration, got %q", setting.name, raw)
+			}
+			*setting.dest = d
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be a positive integer, got %q", raw)
+		}
+		cfg.MaxAttempts = n
+	}
+	WebhookSettings = cfg
+	return nil
+}
+
+// FetchWebhookDeliveries returns the most recent deliveries, optionally only
+// those of one subscription or in one status.
+func FetchWebhookDeliveries(subscriptionID, status string, limit int) ([]WebhookDelivery, error) {
+	if SupabaseClient == nil {
+		return nil, fmt.Errorf("SupabaseClient not initialized in appcore")
+	}
+	query := SupabaseClient.From("webhook_deliveries").Select("*", "", false)
+	if subscriptionID != "" {
+		query = query.Eq("subscription_id", subscriptionID)
+	}
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+	rawData, _, err := query.Order("created_at", nil).Limit(limit, "").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error selecting webhook deliveries: %w", err)
+	}
+	var deliveries []WebhookDelivery
+	if err := json.Unmarshal(rawData, &deliveries); err != nil {
+		return nil, fmt.Errorf("error unmarshalling webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
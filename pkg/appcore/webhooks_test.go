@@ -0,0 +1,97 @@
+package appcore
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWebhookSubscription_Match tests the probability threshold and sentiment/topic rules.
+func TestWebhookSubscription_Match(t *testing.T) {
+	threshold := 0.7
+	sub := WebhookSubscription{
+		MinChurnProbability: &threshold,
+		Rules:               []WebhookRule{{Sentiment: "NEGATIVE", Topics: []string{"pricing"}}},
+	}
+	cases := []struct {
+		event    PredictionEventData
+		expected int
+	}{
+		{PredictionEventData{ChurnProbability: 0.8}, 1},
+		{PredictionEventData{ChurnProbability: 0.4, CommentSentiment: "NEGATIVE", CommentTopics: []string{"speed", "pricing"}}, 1},
+		{PredictionEventData{ChurnProbability: 0.9, CommentSentiment: "NEGATIVE", CommentTopics: []string{"pricing"}}, 2},
+		{PredictionEventData{ChurnProbability: 0.4, CommentSentiment: "POSITIVE", CommentTopics: []string{"pricing"}}, 0},
+		{PredictionEventData{ChurnProbability: 0.4, CommentSentiment: "NEGATIVE"}, 0},
+	}
+	for _, tc := range cases {
+		if got := sub.Match(tc.event); len(got) != tc.expected {
+			t.Errorf("%+v: expected %d matches, got %v", tc.event, tc.expected, got)
+		}
+	}
+}
+
+// TestPostWebhook_Signed tests that deliveries carry a signature the receiver can verify and that non-2xx is an error.
+func TestPostWebhook_Signed(t *testing.T) {
+	secret := "whsec_test"
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifyWebhookSignature(secret, r.Header.Get(WebhookSignatureHeader), body, 5*time.Minute, time.Now()); err != nil {
+			t.Errorf("Expected a valid signature, got %v", err)
+		}
+		if r.Header.Get(WebhookEventIDHeader) != "evt_1" {
+			t.Errorf("Expected the event ID header, got %q", r.Header.Get(WebhookEventIDHeader))
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	body := []byte(`{"id":"evt_1","type":"prediction.high_risk"}`)
+	if code, err := postWebhook(server.Client(), server.URL, secret, "evt_1", body); err != nil || code != http.StatusOK {
+		t.Errorf("Expected delivery to succeed, got %d, %v", code, err)
+	}
+	status = http.StatusBadGateway
+	if code, err := postWebhook(server.Client(), server.URL, secret, "evt_1", body); err == nil || code != http.StatusBadGateway {
+		t.Errorf("Expected a 502 to fail the delivery, got %d, %v", code, err)
+	}
+	// Subscription URLs get the callback client's address guard.
+	if _, err := PostWebhook(server.URL, secret, "evt_1", body, time.Second); err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("Expected a delivery to a loopback address to be refused, got %v", err)
+	}
+
+	header := SignWebhookPayload(secret, time.Now(), body)
+	if err := VerifyWebhookSignature("other", header, body, time.Minute, time.Now()); err == nil {
+		t.Errorf("Expected a wrong secret to fail verification")
+	}
+	if err := VerifyWebhookSignature(secret, header, body, time.Minute, time.Now().Add(time.Hour)); err == nil {
+		t.Errorf("Expected an old signature to fail verification")
+	}
+	if d := DefaultWebhookSettings().Backoff(3); d != 8*time.Minute {
+		t.Errorf("Expected the third retry after 8m, got %v", d)
+	}
+}
+
+// TestConfigureWebhooks_PollInterval tests the delivery loop interval and that
+// a claim outlasts one attempt.
+func TestConfigureWebhooks_PollInterval(t *testing.T) {
+	original := WebhookSettings
+	defer func() { WebhookSettings = original }()
+
+	t.Setenv("WEBHOOK_POLL_INTERVAL", "10s")
+	if err := ConfigureWebhooks(); err != nil {
+		t.Fatalf("ConfigureWebhooks failed: %v", err)
+	}
+	if WebhookSettings.PollInterval != 10*time.Second {
+		t.Errorf("Expected a 10s poll interval, got %v", WebhookSettings.PollInterval)
+	}
+	if WebhookSettings.ClaimLease() <= WebhookSettings.Timeout {
+		t.Errorf("Expected the claim lease to outlast the %v attempt timeout, got %v", WebhookSettings.Timeout, WebhookSettings.ClaimLease())
+	}
+	t.Setenv("WEBHOOK_POLL_INTERVAL", "soon")
+	if err := ConfigureWebhooks(); err == nil {
+		t.Errorf("Expected an invalid poll interval to be rejected")
+	}
+}